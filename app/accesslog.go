@@ -0,0 +1,59 @@
+package app
+
+import (
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+
+	"GoCastify/server"
+)
+
+// ShowAccessLog 显示媒体服务器最近收到的请求，用于排查设备的探测和范围请求
+func (app *App) ShowAccessLog() {
+	logger, ok := app.MediaServer.(configurableServer)
+	if !ok {
+		dialog.ShowInformation("请求日志不可用", "媒体服务器不支持记录请求。", app.Window)
+		return
+	}
+
+	text := widget.NewMultiLineEntry()
+	text.TextStyle = fyne.TextStyle{Monospace: true}
+	text.Wrapping = fyne.TextWrapOff
+	refresh := func() {
+		text.SetText(formatAccessLog(logger.AccessLog()))
+	}
+	refresh()
+
+	var logDialog dialog.Dialog
+	refreshButton := widget.NewButton("刷新", refresh)
+	clearButton := widget.NewButton("清空", func() {
+		logger.ClearAccessLog()
+		refresh()
+	})
+	copyButton := widget.NewButton("复制到剪贴板", func() {
+		app.Window.Clipboard().SetContent(text.Text)
+	})
+	closeButton := &widget.Button{Text: "关闭", Importance: widget.HighImportance, OnTapped: func() {
+		logDialog.Hide()
+	}}
+
+	logDialog = dialog.NewCustomWithoutButtons("媒体服务器请求日志", container.NewStack(text), app.Window)
+	logDialog.(*dialog.CustomDialog).SetButtons([]fyne.CanvasObject{refreshButton, clearButton, copyButton, closeButton})
+	logDialog.Resize(trackDialogSize(app.Window))
+	logDialog.Show()
+}
+
+// formatAccessLog 每行显示一个请求，没有请求时显示提示
+func formatAccessLog(entries []server.AccessLogEntry) string {
+	if len(entries) == 0 {
+		return "暂无请求"
+	}
+	lines := make([]string, len(entries))
+	for i, entry := range entries {
+		lines[i] = entry.String()
+	}
+	return strings.Join(lines, "\n")
+}
@@ -2,11 +2,15 @@ package app
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"net/url"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"fyne.io/fyne/v2"
@@ -16,6 +20,8 @@ import (
 	"fyne.io/fyne/v2/widget"
 
 	"GoCastify/dlna"
+	"GoCastify/interfaces"
+	"GoCastify/power"
 	"GoCastify/server"
 	"GoCastify/transcoder"
 	"GoCastify/types"
@@ -28,10 +34,23 @@ const (
 	dialogHeight             = 450
 	progressDialogWidth      = 400
 	progressDialogHeight     = 200
+	// castHandshakeTimeout 连接设备和SOAP握手（SetAVTransportURI/Play）各阶段的超时时间
+	// 只限制与设备的控制交互，不限制转码：需要转码的文件由媒体服务器在设备请求时按需转码，
+	// 转码耗时不受投屏超时影响
+	castHandshakeTimeout = 15 * time.Second
 )
 
+// ProgressDialog 自定义进度对话框
+// 默认显示无限加载动画，有确定进度时切换为进度条并显示详情
+type ProgressDialog struct {
+	dialog.Dialog
+	progressBar *widget.ProgressBar
+	infiniteBar *widget.ProgressBarInfinite
+	detailLabel *widget.Label
+}
+
 // createCustomProgressDialog 创建自定义进度对话框
-func createCustomProgressDialog(title, message string, parent fyne.Window) dialog.Dialog {
+func createCustomProgressDialog(title, message string, parent fyne.Window) *ProgressDialog {
 	// 创建标题和消息标签
 	titleLabel := widget.NewLabel(title)
 	messageLabel := widget.NewLabel(message)
@@ -44,6 +63,11 @@ func createCustomProgressDialog(title, message string, parent fyne.Window) dialo
 	// 创建无限加载动画
 	infiniteBar := widget.NewProgressBarInfinite()
 
+	// 创建进度详情标签（剩余时间、转码速度等，默认隐藏）
+	detailLabel := widget.NewLabel("")
+	detailLabel.Alignment = fyne.TextAlignCenter
+	detailLabel.Hide()
+
 	// 组织内容
 	content := container.NewVBox(
 		layout.NewSpacer(),
@@ -51,7 +75,8 @@ func createCustomProgressDialog(title, message string, parent fyne.Window) dialo
 		container.NewHBox(layout.NewSpacer(), messageLabel, layout.NewSpacer()),
 		layout.NewSpacer(),
 		container.NewHBox(layout.NewSpacer(), infiniteBar, layout.NewSpacer()),
-		container.NewHBox(layout.NewSpacer(), progressBar, layout.NewSpacer()),
+		progressBar,
+		container.NewHBox(layout.NewSpacer(), detailLabel, layout.NewSpacer()),
 		layout.NewSpacer(),
 	)
 
@@ -60,51 +85,120 @@ func createCustomProgressDialog(title, message string, parent fyne.Window) dialo
 	dlg.Resize(fyne.NewSize(progressDialogWidth, progressDialogHeight))
 
 	// 返回对话框
-	return dlg
+	return &ProgressDialog{
+		Dialog:      dlg,
+		progressBar: progressBar,
+		infiniteBar: infiniteBar,
+		detailLabel: detailLabel,
+	}
+}
+
+// NewProgressDialog 在应用主窗口上创建自定义进度对话框
+func (app *App) NewProgressDialog(title, message string) *ProgressDialog {
+	return createCustomProgressDialog(title, message, app.Window)
+}
+
+// SetProgress 切换为确定进度显示，fraction取值0-1
+func (pd *ProgressDialog) SetProgress(fraction float64, detail string) {
+	pd.infiniteBar.Hide()
+	pd.progressBar.Show()
+	pd.progressBar.SetValue(fraction)
+	pd.setDetail(detail)
+}
+
+// SetIndeterminate 切换回无限加载动画显示
+func (pd *ProgressDialog) SetIndeterminate(detail string) {
+	pd.progressBar.Hide()
+	pd.infiniteBar.Show()
+	pd.setDetail(detail)
+}
+
+// setDetail 更新进度详情标签，内容为空时隐藏
+func (pd *ProgressDialog) setDetail(detail string) {
+	pd.detailLabel.SetText(detail)
+	if detail == "" {
+		pd.detailLabel.Hide()
+	} else {
+		pd.detailLabel.Show()
+	}
 }
 
 // App 表示整个应用程序的状态和功能
 type App struct {
 	Window                fyne.Window
 	FyneApp               fyne.App
-	Devices               []types.DeviceInfo
-	SelectedDeviceIndex   int
-	MediaFile             string
-	MediaServer           *server.MediaServer
+	MediaServer           interfaces.MediaServer
+	Transcoder            *transcoder.Transcoder
+	PreTranscode          bool // 投屏前预先完成转码，而不是在设备请求时转码
+	AudioOnly             bool // 仅投屏音频：只提取并发送选择的音轨
+	Settings              Settings
+	nowPlaying            nowPlayingState
+	library               libraryState
+	queue                 queueState
+	transports            transportChoices      // 按设备记住的AVTransport服务选择
+	capabilities          *dlna.CapabilityCache // 按设备缓存的渲染器支持格式
+	sleepInhibitor        power.Inhibitor       // 投屏期间阻止系统休眠
+	deviceIcons           deviceIconCache       // 设备列表中显示的设备图标
+	deviceMonitor         deviceMonitorState    // 后台持续发现设备
 	FFmpegAvailable       bool
-	SubtitleTracks        []types.SubtitleTrack
-	SelectedSubtitleIndex int
-	AudioTracks           []types.AudioTrack
-	SelectedAudioIndex    int
 	SearchCancel          context.CancelFunc
 	DeviceList            *widget.List
 	RecentPath            string // 最近访问的文件路径
+
+	// 以下状态会被后台goroutine修改，通过state.go中的方法访问
+	stateMu               sync.RWMutex
+	devices               []types.DeviceInfo
+	selectedDeviceIndex   int
+	mediaFile             string
+	castAsIs              bool
+	startOffset           time.Duration
+	subtitleTracks        []types.SubtitleTrack
+	selectedSubtitleIndex int
+	audioTracks           []types.AudioTrack
+	selectedAudioIndex    int
 }
 
 // NewApp 创建一个新的应用程序实例
-func NewApp(fyneApp fyne.App, window fyne.Window) (*App, error) {
+// 使用依赖注入模式，接受一个媒体服务器参数，为nil时使用默认的HTTP媒体服务器
+func NewApp(fyneApp fyne.App, window fyne.Window, mediaServer interfaces.MediaServer) (*App, error) {
+	// 读取保存的设置，转码器按设置的画质创建
+	var prefs fyne.Preferences
+	if fyneApp != nil {
+		prefs = fyneApp.Preferences()
+	}
+	settings := LoadSettings(prefs)
+
 	// 创建转码器
-	transcoderInstance, _ := transcoder.NewTranscoder()
+	transcoderInstance, _ := transcoder.NewTranscoder(transcoder.QualitySettings(settings.TranscodeQuality))
 
-	// 创建媒体服务器
-	mediaServer := server.NewMediaServer(defaultMediaServerPort, transcoderInstance)
+	// 如果没有提供媒体服务器，创建默认的媒体服务器
+	if mediaServer == nil {
+		mediaServer = server.NewMediaServer(defaultMediaServerPort, transcoderInstance)
+	}
 
 	// 检查FFmpeg是否可用
 	ffmpegAvailable := transcoder.CheckFFmpeg()
 
-	return &App{
+	app := &App{
 		Window:                window,
 		FyneApp:               fyneApp,
-		Devices:               []types.DeviceInfo{},
-		SelectedDeviceIndex:   -1,
-		MediaFile:             "",
+		devices:               []types.DeviceInfo{},
+		selectedDeviceIndex:   -1,
 		MediaServer:           mediaServer,
+		Transcoder:            transcoderInstance,
+		capabilities:          dlna.NewCapabilityCache(),
+		sleepInhibitor:        power.NewInhibitor(),
 		FFmpegAvailable:       ffmpegAvailable,
-		SubtitleTracks:        []types.SubtitleTrack{},
-		SelectedSubtitleIndex: -1,
-		AudioTracks:           []types.AudioTrack{},
-		SelectedAudioIndex:    -1,
-	}, nil
+		subtitleTracks:        []types.SubtitleTrack{},
+		selectedSubtitleIndex: -1,
+		audioTracks:           []types.AudioTrack{},
+		selectedAudioIndex:    -1,
+	}
+
+	// 应用设置到转码器和媒体服务器
+	app.ApplySettings(settings)
+
+	return app, nil
 }
 
 // CreateSearchContext 创建一个用于设备搜索的上下文
@@ -112,44 +206,144 @@ func (app *App) CreateSearchContext() (context.Context, context.CancelFunc) {
 	return context.WithCancel(context.Background())
 }
 
+// StopSearch 立即停止正在进行的设备搜索
+func (app *App) StopSearch() {
+	if app.SearchCancel != nil {
+		app.SearchCancel()
+		app.SearchCancel = nil
+	}
+}
+
 // StartCastingWithContext 开始投屏操作（带上下文支持）
+// ctx用于取消整个投屏操作，不应设置覆盖转码时间的总超时；
+// 连接设备和SOAP握手各自使用castHandshakeTimeout限制，设置了较长的设备超时时相应延长
 func (app *App) StartCastingWithContext(ctx context.Context, progress dialog.Dialog) error {
-	selectedDevice := app.Devices[app.SelectedDeviceIndex]
+	selectedDevice, ok := app.SelectedDevice()
+	if !ok {
+		return fmt.Errorf("请先选择要投屏的设备")
+	}
+	mediaFile := app.MediaFile()
 	log.Printf("连接设备: %s, 地址: %s\n", selectedDevice.FriendlyName, selectedDevice.Location)
 
 	// 创建设备控制器
-	controller, err := dlna.NewDeviceControllerWithContext(ctx, selectedDevice.Location)
+	connectCtx, cancelConnect := context.WithTimeout(ctx, app.Settings.handshakeTimeout())
+	controller, err := dlna.NewDeviceControllerWithContext(connectCtx, selectedDevice.Location, app.Settings.ControllerOptions()...)
+	cancelConnect()
+	if errors.Is(err, dlna.ErrNotRenderer) || errors.Is(err, dlna.ErrNoAVTransport) {
+		// 设备本身无法投屏，直接显示说明和替代方案
+		return err
+	}
 	if err != nil {
 		return fmt.Errorf("创建设备控制器失败: %w", err)
 	}
+	// 多区域设备需要选择投屏的区域
+	app.selectTransport(ctx, controller, selectedDevice)
 
 	// 获取文件所在目录
-	mediaDir := filepath.Dir(app.MediaFile)
-	fileName := filepath.Base(app.MediaFile)
+	mediaDir := filepath.Dir(mediaFile)
+	fileName := filepath.Base(mediaFile)
 
-	// 启动媒体服务器并获取媒体文件的HTTP URL
-	var serverURL string
-	if app.MediaServer != nil {
-		serverURL, err = app.MediaServer.Start(mediaDir)
+	// 构建媒体文件的完整URL
+	var mediaURL string
+	// 经过媒体服务器投屏时，设备第一次请求文件时关闭
+	var firstRequest <-chan struct{}
+	var metadata *dlna.MediaMetadata
+	// 先尝试原文件时，设备播放失败后改用的转码地址
+	var transcodeURL string
+	// 源视频超过设备支持的分辨率时需要转码缩小，此时不提供原文件
+	maxHeight := 0
+	if !app.AudioOnly && !app.CastAsIs() {
+		maxHeight = app.castMaxHeight(ctx, controller, selectedDevice, mediaFile)
+	}
+	if directURL, ok := app.directMediaURL(selectedDevice); ok && !app.AudioOnly && app.StartOffset() == 0 && maxHeight == 0 {
+		// 同一主机或可访问网络共享的渲染器，直接发送文件地址，跳过媒体服务器
+		log.Printf("使用直接地址投屏，不经过媒体服务器\n")
+		mediaURL = directURL
+		metadata = &dlna.MediaMetadata{
+			Title:    fileName,
+			MimeType: server.MIMEType(mediaFile),
+		}
+	} else if app.MediaServer != nil {
+		// 启动媒体服务器并获取媒体文件的HTTP URL
+		serverURL, err := app.MediaServer.Start(mediaDir)
 		if err != nil {
 			return fmt.Errorf("启动媒体服务器失败: %w", err)
 		}
+		// 仅对外提供当前选择的文件
+		app.MediaServer.ClearAllowedFiles()
+		urlName := fileName
+		if app.Settings.ShortMediaURLs {
+			// 设备看到的是不含原文件名的短地址，原文件名通过元数据中的标题显示
+			urlName = app.MediaServer.AliasFile(mediaFile)
+		} else {
+			app.MediaServer.AllowFile(mediaFile)
+		}
+		// 在发送地址之前开始等待设备的第一次请求，预检使用的HEAD请求不算在内
+		firstRequest = app.watchFirstRequest(mediaFile)
+		if app.AudioOnly {
+			// 仅投屏音频时只提供提取的音轨，字幕和原文件选项都不适用
+			mediaURL = app.buildMediaURL(serverURL, urlName, false, false, 0)
+			metadata = audioOnlyMetadata(fileName)
+		} else {
+			// 支持外挂字幕的设备单独加载字幕，字幕不转码进视频
+			sidecar := app.useSidecarCaptions(controller)
+			// 设备原生支持或用户选择强制投屏时直接提供原文件，跳过转码
+			serveOriginal := app.CastAsIs() || (maxHeight == 0 && app.canServeOriginal(ctx, controller, selectedDevice, mediaFile, sidecar))
+			mediaURL = app.buildMediaURL(serverURL, urlName, serveOriginal, sidecar, maxHeight)
+			// 需要转码的文件先让设备尝试原文件，失败后再改用转码地址
+			originalFirst := maxHeight == 0 && app.triesOriginalFirst(mediaFile, serveOriginal, sidecar)
+			if originalFirst {
+				transcodeURL = withTranscodeHint(mediaURL)
+			}
+			// 元数据中的标题为原文件名，类型与实际提供的文件一致
+			mimeType := "video/mp4"
+			if _, needTranscode := transcoder.IsSupportedFormat(mediaFile); serveOriginal || originalFirst || (!needTranscode && maxHeight == 0) {
+				mimeType = server.MIMEType(mediaFile)
+			}
+			metadata = &dlna.MediaMetadata{
+				Title:    fileName,
+				MimeType: mimeType,
+			}
+			if sidecar {
+				metadata.SubtitleURL = app.buildSubtitleURL(serverURL, urlName)
+			}
+		}
 	} else {
-		// 如果没有媒体服务器，使用本地文件路径（这可能只在某些设备上工作）
-		serverURL = "file://" + mediaDir
+		return fmt.Errorf("媒体服务器不可用，且当前文件或设备不满足直接地址投屏的条件")
 	}
-
-	// 构建媒体文件的完整URL
-	mediaURL := app.buildMediaURL(serverURL, fileName)
 	log.Printf("媒体文件URL: %s\n", mediaURL)
 
-	// 播放媒体
-	err = controller.PlayMediaWithContext(ctx, mediaURL)
+	// 发送给设备前，先确认媒体服务器已经在监听并确实能提供该文件
+	// 预检在连接失败时会短暂重试，等待时间受ctx限制
+	if err := verifyMediaURL(ctx, mediaURL); err != nil {
+		return fmt.Errorf("媒体服务器无法提供该文件: %w", err)
+	}
+
+	// 播放媒体，SOAP握手使用单独的超时
+	playCtx, cancelPlay := context.WithTimeout(ctx, app.Settings.handshakeTimeout())
+	if player, ok := controller.(metadataPlayer); ok && metadata != nil {
+		// 通过DIDL元数据告知设备标题、媒体类型和外挂字幕地址
+		err = player.PlayMediaWithMetadataContext(playCtx, mediaURL, metadata)
+	} else {
+		err = controller.PlayMediaWithContext(playCtx, mediaURL)
+	}
+	cancelPlay()
 	if err != nil {
 		return fmt.Errorf("投屏失败: %w", err)
 	}
 
-	log.Printf("投屏成功: %s\n", filepath.Base(app.MediaFile))
+	log.Printf("投屏成功: %s\n", filepath.Base(mediaFile))
+	app.startNowPlaying(controller, selectedDevice, fileName, firstRequest)
+	app.saveLastCast(selectedDevice, mediaFile)
+	if transcodeURL != "" {
+		var transcodeMetadata *dlna.MediaMetadata
+		if metadata != nil {
+			copied := *metadata
+			copied.MimeType = "video/mp4"
+			transcodeMetadata = &copied
+		}
+		go app.fallBackToTranscode(controller, transcodeURL, transcodeMetadata)
+	}
 	return nil
 }
 
@@ -158,12 +352,8 @@ func (app *App) StartCastingWithContext(ctx context.Context, progress dialog.Dia
 //
 // Deprecated: Use StartCastingWithContext instead for better control and cancellation
 func (app *App) StartCasting(progress dialog.Dialog) {
-	// 创建一个带有超时的上下文
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
-
-	// 执行带上下文的投屏操作
-	err := app.StartCastingWithContext(ctx, progress)
+	// 执行带上下文的投屏操作，与设备交互的超时由StartCastingWithContext控制
+	err := app.StartCastingWithContext(context.Background(), progress)
 	if err != nil {
 		log.Printf("投屏操作失败: %v\n", err)
 		dialog.ShowError(err, app.Window)
@@ -177,7 +367,8 @@ func (app *App) StartCasting(progress dialog.Dialog) {
 
 // SelectAudio 打开音频选择对话框
 func (app *App) SelectAudio(audioLabel *widget.Label) {
-	if app.MediaFile == "" {
+	mediaFile := app.MediaFile()
+	if mediaFile == "" {
 		dialog.ShowInformation("提示", "请先选择一个媒体文件", app.Window)
 		return
 	}
@@ -190,11 +381,14 @@ func (app *App) SelectAudio(audioLabel *widget.Label) {
 
 	// 显示加载对话框
 	progress := createCustomProgressDialog("正在获取音频信息", "请稍候...", app.Window)
+	// 关闭进度对话框（包括点击取消）时终止探测
+	ctx, cancel := context.WithCancel(context.Background())
+	progress.SetOnClosed(cancel)
 	progress.Show()
 
 	// 在后台获取音频轨道信息
 	go func() {
-		transcoderInstance, err := transcoder.NewTranscoder()
+		transcoderInstance, err := app.trackProber()
 		if err != nil {
 			log.Printf("创建转码器失败: %v\n", err)
 			dialog.ShowError(err, app.Window)
@@ -203,7 +397,11 @@ func (app *App) SelectAudio(audioLabel *widget.Label) {
 		}
 
 		// 获取音频轨道信息
-		audioTracks, err := transcoderInstance.GetAudioTracks(app.MediaFile)
+		audioTracks, err := transcoderInstance.GetAudioTracksWithContext(ctx, mediaFile)
+		if errors.Is(err, context.Canceled) {
+			log.Printf("已取消获取音频信息\n")
+			return
+		}
 		if err != nil {
 			log.Printf("获取音频信息失败: %v\n", err)
 			dialog.ShowError(err, app.Window)
@@ -212,16 +410,18 @@ func (app *App) SelectAudio(audioLabel *widget.Label) {
 		}
 
 		// 保存音频轨道信息
-		app.AudioTracks = []types.AudioTrack{}
+		savedTracks := []types.AudioTrack{}
 		for _, track := range audioTracks {
-			app.AudioTracks = append(app.AudioTracks, types.AudioTrack{
+			savedTracks = append(savedTracks, types.AudioTrack{
 				Index:     track.Index,
 				Language:  track.Language,
 				Title:     track.Title,
 				CodecName: track.CodecName,
+				Channels:  track.Channels,
 				IsDefault: track.IsDefault,
 			})
 		}
+		app.setAudioTracks(savedTracks)
 
 		// 更新UI
 		app.Window.Content().Refresh()
@@ -232,116 +432,58 @@ func (app *App) SelectAudio(audioLabel *widget.Label) {
 		// 如果没有音频轨道
 		if len(audioTracks) == 0 {
 			dialog.ShowInformation("音频信息", "当前视频文件中未找到音频轨道", app.Window)
-			app.SelectedAudioIndex = -1
+			app.SetSelectedAudioIndex(-1)
 			audioLabel.SetText("音轨: 无")
 			audioLabel.Refresh()
 			return
 		}
 
-		// 创建音频轨道选择列表
-		audioList := widget.NewList(
-			func() int {
-				return len(audioTracks) + 1 // +1 表示"默认音轨"选项
-			},
-			func() fyne.CanvasObject {
-				// 创建更美观的列表项，符合苹果UI设计风格
-				item := widget.NewLabel("音频选项")
-				item.TextStyle = fyne.TextStyle{}
-				item.Wrapping = fyne.TextTruncate
-				// 使用容器来设置最小尺寸
-				return container.NewMax(item)
-			},
-			func(id widget.ListItemID, obj fyne.CanvasObject) {
-				container := obj.(*fyne.Container)
-				label := container.Objects[0].(*widget.Label)
-				label.TextStyle = fyne.TextStyle{}
-				label.Wrapping = fyne.TextTruncate
-				if id == 0 {
-					label.SetText("默认音轨")
-				} else {
-					track := audioTracks[id-1]
-					title := track.Title
-					if title == "" {
-						title = "未命名音频"
-					}
-					if track.Language != "" {
-						title += " (" + track.Language + ")"
-					}
-					if track.CodecName != "" {
-						title += " [" + track.CodecName + "]"
-					}
-					if track.IsDefault {
-						title += " [默认]"
-						label.TextStyle = fyne.TextStyle{Bold: true} // 默认轨道使用粗体，符合苹果突出显示的风格
-					}
-					label.SetText(fmt.Sprintf("%d: %s", id-1, title))
-				}
-			},
-		)
-
-		// 创建带有内边距的容器来包裹列表，确保内容有足够的空间
-		paddedList := container.NewPadded(
-			container.NewMax(
-				audioList,
-				// 添加占位元素来确保对话框有足够的大小
-				fyne.NewContainerWithLayout(
-					layout.NewGridLayout(1),
-					widget.NewLabelWithStyle("", fyne.TextAlignCenter, fyne.TextStyle{}), // 占位元素
-					widget.NewLabelWithStyle("", fyne.TextAlignCenter, fyne.TextStyle{}),
-					widget.NewLabelWithStyle("", fyne.TextAlignCenter, fyne.TextStyle{}),
-					widget.NewLabelWithStyle("", fyne.TextAlignCenter, fyne.TextStyle{}),
-					widget.NewLabelWithStyle("", fyne.TextAlignCenter, fyne.TextStyle{}),
-				),
-			),
-		)
-
-		// 创建说明标签，符合苹果UI的清晰性原则
-		descriptionLabel := widget.NewLabel("请选择您想要使用的音频轨道：")
-		descriptionLabel.TextStyle = fyne.TextStyle{Bold: true} // 标题使用粗体
-
-		// 创建符合macOS设计规范的对话框布局
-		dialogContent := container.NewVBox(
-			container.NewPadded(descriptionLabel),
-			widget.NewSeparator(), // 分隔线增强视觉层次
-			container.NewPadded(paddedList),
-		)
-
-		// 创建带有取消按钮的自定义对话框，符合macOS UI设计标准
-		audioDialog := dialog.NewCustomConfirm("选择音频轨道", "确定", "取消", dialogContent, func(confirmed bool) {}, app.Window)
-		// 调整对话框大小以符合macOS设计风格
-		audioDialog.Resize(fyne.NewSize(dialogWidth, dialogHeight))
-
-		// 修复重复显示的问题
-		// audioDialog.Show() 会在后面的OnSelected设置完成后调用
-
-		// 设置列表选择事件
-		audioList.OnSelected = func(id widget.ListItemID) {
-			if id == 0 {
-				app.SelectedAudioIndex = -1
+		// 创建音频轨道选项，第一项为"默认音轨"
+		options := []trackOption{{Label: "默认音轨"}}
+		for i, track := range audioTracks {
+			title := track.Title
+			if title == "" {
+				title = "未命名音频"
+			}
+			if track.Language != "" {
+				title += " (" + track.Language + ")"
+			}
+			if track.CodecName != "" {
+				title += " [" + track.CodecName + "]"
+			}
+			if track.IsDefault {
+				title += " [默认]"
+			}
+			// 默认轨道使用粗体，符合苹果突出显示的风格
+			options = append(options, trackOption{Label: fmt.Sprintf("%d: %s", i, title), Bold: track.IsDefault})
+		}
+
+		// 显示音频选择对话框
+		app.showTrackDialog("选择音频轨道", "请选择您想要使用的音频轨道：", options, func(index int) {
+			if index == 0 {
+				app.SetSelectedAudioIndex(-1)
 				audioLabel.SetText("音轨: 默认")
 			} else {
-				app.SelectedAudioIndex = audioTracks[id-1].Index
-				title := audioTracks[id-1].Title
+				track := audioTracks[index-1]
+				app.SetSelectedAudioIndex(track.Index)
+				title := track.Title
 				if title == "" {
 					title = "未命名音频"
 				}
-				if audioTracks[id-1].Language != "" {
-					title += " (" + audioTracks[id-1].Language + ")"
+				if track.Language != "" {
+					title += " (" + track.Language + ")"
 				}
 				audioLabel.SetText(fmt.Sprintf("音轨: %s", title))
 			}
 			audioLabel.Refresh()
-			audioDialog.Hide()
-		}
-
-		// 显示音频选择对话框
-		audioDialog.Show()
+		})
 	}()
 }
 
 // SelectSubtitle 打开字幕选择对话框
 func (app *App) SelectSubtitle(subtitleLabel *widget.Label) {
-	if app.MediaFile == "" {
+	mediaFile := app.MediaFile()
+	if mediaFile == "" {
 		dialog.ShowInformation("提示", "请先选择一个媒体文件", app.Window)
 		return
 	}
@@ -354,12 +496,15 @@ func (app *App) SelectSubtitle(subtitleLabel *widget.Label) {
 
 	// 显示加载对话框
 	progress := createCustomProgressDialog("处理中...", "正在提取视频中的字幕信息", app.Window)
+	// 关闭进度对话框（包括点击取消）时终止探测
+	ctx, cancel := context.WithCancel(context.Background())
+	progress.SetOnClosed(cancel)
 	progress.Show()
 
 	// 在后台提取字幕信息
 	go func() {
-		// 创建转码器实例
-		transcoderInstance, err := transcoder.NewTranscoder()
+		// 使用应用的转码器，复用已缓存的轨道信息
+		transcoderInstance, err := app.trackProber()
 		if err != nil {
 			log.Printf("创建转码器失败: %v\n", err)
 			dialog.ShowError(err, app.Window)
@@ -368,24 +513,33 @@ func (app *App) SelectSubtitle(subtitleLabel *widget.Label) {
 		}
 
 		// 获取字幕轨道信息
-		subtitleTracks, err := transcoderInstance.GetSubtitleTracks(app.MediaFile)
+		subtitleTracks, err := transcoderInstance.GetSubtitleTracksWithContext(ctx, mediaFile)
+		if errors.Is(err, context.Canceled) {
+			log.Printf("已取消获取字幕信息\n")
+			return
+		}
 		if err != nil {
 			log.Printf("获取字幕信息失败: %v\n", err)
 			dialog.ShowError(err, app.Window)
 			progress.Hide()
 			return
 		}
+		// 媒体文件旁边的外部字幕文件排在内嵌字幕之后，复制一份避免修改转码器缓存中的轨道列表
+		subtitleTracks = append(slices.Clone(subtitleTracks), transcoder.ExternalSubtitleTracks(mediaFile)...)
 
 		// 保存字幕轨道信息
-		app.SubtitleTracks = []types.SubtitleTrack{}
+		savedTracks := []types.SubtitleTrack{}
 		for _, track := range subtitleTracks {
-			app.SubtitleTracks = append(app.SubtitleTracks, types.SubtitleTrack{
-				Index:     track.Index,
-				Language:  track.Language,
-				Title:     track.Title,
-				IsDefault: track.IsDefault,
+			savedTracks = append(savedTracks, types.SubtitleTrack{
+				Index:        track.Index,
+				Language:     track.Language,
+				Title:        track.Title,
+				IsDefault:    track.IsDefault,
+				IsForced:     track.IsForced,
+				ExternalPath: track.ExternalPath,
 			})
 		}
+		app.setSubtitleTracks(savedTracks)
 
 		// 更新UI
 		app.Window.Content().Refresh()
@@ -395,105 +549,112 @@ func (app *App) SelectSubtitle(subtitleLabel *widget.Label) {
 
 		// 如果没有字幕轨道
 		if len(subtitleTracks) == 0 {
-			dialog.ShowInformation("字幕信息", "当前视频文件中未找到字幕轨道", app.Window)
-			app.SelectedSubtitleIndex = -1
+			dialog.ShowInformation("字幕信息", "当前视频文件中未找到字幕轨道，也没有找到对应的外部字幕文件", app.Window)
+			app.SetSelectedSubtitleIndex(-1)
 			subtitleLabel.SetText("字幕: 无")
 			subtitleLabel.Refresh()
 			return
 		}
 
-		// 创建字幕选择列表，优化UI以符合苹果设计标准
-		subtitleList := widget.NewList(
-			func() int {
-				return len(subtitleTracks) + 1 // +1 表示"无字幕"选项
-			},
-			func() fyne.CanvasObject {
-				// 创建更美观的列表项，符合苹果UI设计风格
-				item := widget.NewLabel("字幕选项")
-				item.TextStyle = fyne.TextStyle{}
-				item.Wrapping = fyne.TextTruncate
-				// 使用容器来设置最小尺寸
-				return container.NewMax(item)
-			},
-			func(id widget.ListItemID, obj fyne.CanvasObject) {
-				container := obj.(*fyne.Container)
-				label := container.Objects[0].(*widget.Label)
-				label.TextStyle = fyne.TextStyle{}
-				label.Wrapping = fyne.TextTruncate
-				if id == 0 {
-					label.SetText("无字幕")
-				} else {
-					track := subtitleTracks[id-1]
-					title := track.Title
-					if title == "" {
-						title = "未命名字幕"
-					}
-					if track.Language != "" {
-						title += " (" + track.Language + ")"
-					}
-					if track.IsDefault {
-						title += " [默认]"
-						label.TextStyle = fyne.TextStyle{Bold: true} // 默认轨道使用粗体，符合苹果突出显示的风格
-					}
-					label.SetText(fmt.Sprintf("%d: %s", id-1, title))
-				}
-			},
-		)
-
-		// 创建自定义列表的容器，增加内边距
-		paddedList := container.NewPadded(subtitleList)
-
-		// 创建符合macOS设计规范的对话框布局
-		label := widget.NewLabel("请选择您想要使用的字幕轨道")
-		label.Alignment = fyne.TextAlignCenter
-		label.TextStyle = fyne.TextStyle{Bold: true}
-		dialogContent := container.NewVBox(
-			container.NewPadded(label),
-			widget.NewSeparator(), // 分隔线增强视觉层次
-			container.NewPadded(paddedList),
-		)
-
-		// 创建带有取消按钮的自定义对话框，符合macOS UI设计标准
-		subtitleDialog := dialog.NewCustomConfirm("选择字幕轨道", "确定", "取消", dialogContent, func(confirmed bool) {}, app.Window)
-		// 调整对话框大小以符合macOS设计风格
-		subtitleDialog.Resize(fyne.NewSize(dialogWidth, dialogHeight))
-
-		// 设置列表选择事件
-		subtitleList.OnSelected = func(id widget.ListItemID) {
-			if id == 0 {
-				app.SelectedSubtitleIndex = -1
+		// 创建字幕轨道选项，第一项为"无字幕"
+		// optionTracks记录每个选项对应的字幕轨道在subtitleTracks中的位置，-1表示无字幕
+		options := []trackOption{{Label: "无字幕"}}
+		optionTracks := []int{-1}
+		forcedOnly := -1
+
+		// 有强制字幕时提供"仅强制字幕"选项，只显示外语对白部分的字幕
+		if forced, ok := transcoder.ForcedSubtitleTrack(subtitleTracks); ok {
+			label := "仅强制字幕"
+			if subtitleTracks[forced].Language != "" {
+				label += " (" + subtitleTracks[forced].Language + ")"
+			}
+			forcedOnly = len(options)
+			options = append(options, trackOption{Label: label})
+			optionTracks = append(optionTracks, forced)
+		}
+
+		for i, track := range subtitleTracks {
+			title := track.Title
+			if title == "" {
+				title = "未命名字幕"
+			}
+			if track.Language != "" {
+				title += " (" + track.Language + ")"
+			}
+			if track.IsForced {
+				title += " [强制]"
+			}
+			if track.IsDefault {
+				title += " [默认]"
+			}
+			// 外部字幕文件标注为外挂，不显示内嵌字幕的序号
+			label := fmt.Sprintf("%d: %s", i, title)
+			if track.ExternalPath != "" {
+				label = "外挂: " + title
+			}
+			// 默认轨道使用粗体，符合苹果突出显示的风格
+			options = append(options, trackOption{Label: label, Bold: track.IsDefault})
+			optionTracks = append(optionTracks, i)
+		}
+
+		// 显示字幕选择对话框
+		app.showTrackDialog("选择字幕轨道", "请选择您想要使用的字幕轨道", options, func(index int) {
+			if optionTracks[index] < 0 {
+				app.SetSelectedSubtitleIndex(-1)
 				subtitleLabel.SetText("字幕: 无")
+			} else if index == forcedOnly {
+				app.SetSelectedSubtitleIndex(subtitleTracks[optionTracks[index]].Index)
+				subtitleLabel.SetText("字幕: " + options[index].Label)
 			} else {
-				app.SelectedSubtitleIndex = subtitleTracks[id-1].Index
-				title := subtitleTracks[id-1].Title
+				track := subtitleTracks[optionTracks[index]]
+				app.SetSelectedSubtitleIndex(track.Index)
+				title := track.Title
 				if title == "" {
 					title = "未命名字幕"
 				}
-				if subtitleTracks[id-1].Language != "" {
-					title += " (" + subtitleTracks[id-1].Language + ")"
+				if track.Language != "" {
+					title += " (" + track.Language + ")"
 				}
 				subtitleLabel.SetText(fmt.Sprintf("字幕: %s", title))
 			}
 			subtitleLabel.Refresh()
-			subtitleDialog.Hide()
-		}
-
-		// 显示字幕选择对话框
-		subtitleDialog.Show()
+		})
 	}()
 }
 
 // buildMediaURL 构建媒体文件的完整URL，包括可选的字幕和音频参数
-func (app *App) buildMediaURL(serverURL, fileName string) string {
-	mediaURL := serverURL + "/" + fileName
+// serverURL应为本次投屏时MediaServer.Start返回的地址，媒体服务器重新启动后端口可能变化，
+// 因此每次投屏都重新构建，不缓存之前的地址；
+// serveOriginal为true时要求媒体服务器不转码，直接提供原文件；
+// sidecar为true时字幕以外挂字幕提供，媒体服务器在响应头中返回字幕地址；
+// 指定了起始位置时媒体服务器从该位置开始转码；maxHeight大于0时转码输出的视频高度不超过该值；
+// 仅投屏音频时只带音轨参数
+func (app *App) buildMediaURL(serverURL, fileName string, serveOriginal, sidecar bool, maxHeight int) string {
+	mediaURL := serverURL + server.MediaPathPrefix + url.PathEscape(fileName)
 
 	// 添加查询参数
 	params := []string{}
-	if app.SelectedSubtitleIndex >= 0 {
-		params = append(params, "subtitle="+strconv.Itoa(app.SelectedSubtitleIndex))
+	subtitleIndex, audioIndex := app.trackSelection()
+	if subtitleIndex >= 0 && !app.AudioOnly {
+		params = append(params, "subtitle="+strconv.Itoa(subtitleIndex))
+	}
+	if audioIndex >= 0 {
+		params = append(params, "audio="+strconv.Itoa(audioIndex))
+	}
+	if offset := app.StartOffset(); offset > 0 && !serveOriginal && !app.AudioOnly {
+		params = append(params, "start="+strconv.FormatFloat(offset.Seconds(), 'f', 3, 64))
+	}
+	if maxHeight > 0 && !serveOriginal && !app.AudioOnly {
+		params = append(params, "maxheight="+strconv.Itoa(maxHeight))
 	}
-	if app.SelectedAudioIndex >= 0 {
-		params = append(params, "audio="+strconv.Itoa(app.SelectedAudioIndex))
+	if app.AudioOnly {
+		params = append(params, "audioonly=1")
+	}
+	if subtitleIndex >= 0 && sidecar {
+		params = append(params, "sidecar=1")
+	}
+	if serveOriginal {
+		params = append(params, "original=1")
 	}
 
 	// 拼接查询参数
@@ -506,11 +667,9 @@ func (app *App) buildMediaURL(serverURL, fileName string) string {
 
 // Cleanup 清理应用资源
 func (app *App) Cleanup() {
-	// 停止设备搜索
-	if app.SearchCancel != nil {
-		app.SearchCancel()
-		app.SearchCancel = nil
-	}
+	// 停止设备搜索和后台设备发现
+	app.StopSearch()
+	app.StopDeviceMonitor()
 
 	// 停止媒体服务器
 	if app.MediaServer != nil {
@@ -520,7 +679,47 @@ func (app *App) Cleanup() {
 		app.MediaServer = nil
 	}
 
+	// 停止监视媒体库目录
+	app.CloseLibrary()
+
+	// 清除投屏状态
+	app.clearNowPlaying()
+
 	// 清空设备列表
-	app.Devices = nil
-	app.SelectedDeviceIndex = -1
+	app.ClearDevices()
+}
+
+// configurableServer 可以通过设置调整行为、提供网页遥控器和请求日志的媒体服务器
+// server.MediaServer实现了该接口；其他实现（例如测试中的假服务器）可以不实现，此时这些功能不可用
+type configurableServer interface {
+	SetStreamingTranscode(enabled bool)
+	SetTryOriginalFirst(enabled bool)
+	SetRateLimit(bytesPerSecond int64, perConnection bool)
+	SetIdleTimeout(timeout time.Duration)
+	SetPreferredInterface(nameOrIP string)
+	SetRemote(controller server.RemoteController, token string)
+	RemoteURL() string
+	AccessLog() []server.AccessLogEntry
+	ClearAccessLog()
+}
+
+// 确保server.MediaServer实现了configurableServer接口
+var _ configurableServer = (*server.MediaServer)(nil)
+
+// StopMediaServer 停止媒体服务器并释放端口，同时清除当前的投屏状态
+// 与Cleanup不同，应用继续运行，设备列表和转码缓存保留；再次投屏时媒体服务器自动重新启动
+func (app *App) StopMediaServer() error {
+	if app.MediaServer == nil {
+		return nil
+	}
+
+	if err := app.MediaServer.StopServing(); err != nil {
+		return fmt.Errorf("停止媒体服务器失败: %w", err)
+	}
+
+	// 不再对外提供任何文件，重新启动后由下一次投屏重新允许
+	app.MediaServer.ClearAllowedFiles()
+	app.clearNowPlaying()
+	log.Printf("媒体服务器已停止，应用继续运行\n")
+	return nil
 }
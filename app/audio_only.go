@@ -0,0 +1,16 @@
+package app
+
+import (
+	"GoCastify/dlna"
+	"GoCastify/transcoder"
+)
+
+// audioOnlyMetadata 仅投屏音频时发送给设备的元数据
+// 以音乐曲目类别声明MP3音频，部分设备据此切换到音乐播放界面
+func audioOnlyMetadata(fileName string) *dlna.MediaMetadata {
+	return &dlna.MediaMetadata{
+		Title:    fileName,
+		MimeType: transcoder.AudioOnlyMIMEType,
+		Class:    dlna.AudioItemClass,
+	}
+}
@@ -0,0 +1,84 @@
+package app
+
+import (
+	"context"
+	"log"
+
+	"GoCastify/dlna"
+	"GoCastify/interfaces"
+	"GoCastify/server"
+	"GoCastify/transcoder"
+	"GoCastify/types"
+)
+
+// protocolInfoProvider 能够查询渲染器支持的媒体格式的设备控制器
+type protocolInfoProvider interface {
+	GetProtocolInfoWithContext(ctx context.Context) ([]dlna.ProtocolInfo, error)
+}
+
+// deviceKey 返回按设备保存的状态（能力缓存、AVTransport服务选择等）使用的键
+// 优先使用不随地址变化的UDN，设备没有UDN时使用描述地址
+func deviceKey(device types.DeviceInfo) string {
+	if device.UDN != "" {
+		return device.UDN
+	}
+	return device.Location
+}
+
+// deviceSinkProtocols 获取设备支持的媒体格式，优先使用缓存
+// 设备不支持查询或查询失败时第二个返回值为false
+func (app *App) deviceSinkProtocols(ctx context.Context, controller interfaces.DLNAController, device types.DeviceInfo) ([]dlna.ProtocolInfo, bool) {
+	key := deviceKey(device)
+	if sink, ok := app.capabilities.Get(key); ok {
+		return sink, true
+	}
+
+	provider, ok := controller.(protocolInfoProvider)
+	if !ok {
+		return nil, false
+	}
+	queryCtx, cancel := context.WithTimeout(ctx, castHandshakeTimeout)
+	defer cancel()
+	sink, err := provider.GetProtocolInfoWithContext(queryCtx)
+	if err != nil {
+		log.Printf("获取设备支持的格式失败，按扩展名判断是否转码: %v\n", err)
+		return nil, false
+	}
+	app.capabilities.Set(key, sink)
+	return sink, true
+}
+
+// canServeOriginal 判断需要转码的文件能否直接提供给设备
+// 设备声明原生支持文件的容器格式和视频编码时跳过转码；
+// 选择了音轨或需要转码进视频的字幕、指定了起始位置、无法获取设备能力或媒体信息时返回false，按扩展名决定是否转码；
+// sidecarSubtitle为true时字幕由设备单独加载，不影响判断
+func (app *App) canServeOriginal(ctx context.Context, controller interfaces.DLNAController, device types.DeviceInfo, mediaFile string, sidecarSubtitle bool) bool {
+	if _, needTranscode := transcoder.IsSupportedFormat(mediaFile); !needTranscode {
+		return false
+	}
+	if subtitleIndex, audioIndex := app.trackSelection(); (subtitleIndex >= 0 && !sidecarSubtitle) || audioIndex >= 0 {
+		return false
+	}
+	// 从指定位置开始播放需要转码
+	if app.StartOffset() > 0 {
+		return false
+	}
+	if app.Transcoder == nil {
+		return false
+	}
+
+	sink, ok := app.deviceSinkProtocols(ctx, controller, device)
+	if !ok {
+		return false
+	}
+	info, err := app.Transcoder.GetMediaInfo(mediaFile)
+	if err != nil || info["video_codec"] == "" {
+		return false
+	}
+
+	if !dlna.SupportsMedia(sink, server.MIMEType(mediaFile), info["video_codec"]) {
+		return false
+	}
+	log.Printf("设备原生支持 %s (%s)，跳过转码\n", server.MIMEType(mediaFile), info["video_codec"])
+	return true
+}
@@ -0,0 +1,29 @@
+package app
+
+import (
+	"testing"
+
+	"GoCastify/types"
+)
+
+func TestDeviceKey(t *testing.T) {
+	tests := []struct {
+		name   string
+		device types.DeviceInfo
+		want   string
+	}{
+		{name: "UDN", device: types.DeviceInfo{UDN: "uuid:tv", Location: "http://10.0.0.2/desc.xml"}, want: "uuid:tv"},
+		{name: "location without UDN", device: types.DeviceInfo{Location: "http://10.0.0.2/desc.xml"}, want: "http://10.0.0.2/desc.xml"},
+	}
+	for _, tt := range tests {
+		if got := deviceKey(tt.device); got != tt.want {
+			t.Errorf("%s: deviceKey() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	// 设备地址变化后仍然使用同一个键
+	moved := types.DeviceInfo{UDN: "uuid:tv", Location: "http://10.0.0.9/desc.xml"}
+	if deviceKey(moved) != deviceKey(tests[0].device) {
+		t.Error("device key changed with the device address")
+	}
+}
@@ -0,0 +1,42 @@
+package app
+
+import (
+	"context"
+	"log"
+	"net/url"
+	"strconv"
+
+	"GoCastify/dlna"
+	"GoCastify/interfaces"
+	"GoCastify/server"
+)
+
+// metadataPlayer 能够随媒体地址发送DIDL元数据的设备控制器
+type metadataPlayer interface {
+	PlayMediaWithMetadataContext(ctx context.Context, mediaURL string, metadata *dlna.MediaMetadata) error
+}
+
+// sidecarCaptionPlayer 能够通过外挂字幕地址加载字幕的设备控制器（例如三星电视）
+type sidecarCaptionPlayer interface {
+	metadataPlayer
+	SupportsSidecarCaptions() bool
+}
+
+// useSidecarCaptions 判断选择的字幕是否以外挂字幕提供，而不是转码进视频
+// 只有设备支持外挂字幕、选择了字幕且未开启字幕烧录时使用
+func (app *App) useSidecarCaptions(controller interfaces.DLNAController) bool {
+	player, ok := controller.(sidecarCaptionPlayer)
+	if !ok || !player.SupportsSidecarCaptions() {
+		return false
+	}
+	if app.Settings.BurnSubtitles || app.SelectedSubtitleIndex() < 0 {
+		return false
+	}
+	log.Printf("设备支持外挂字幕，字幕将单独提供，不转码进视频\n")
+	return true
+}
+
+// buildSubtitleURL 构建选择的字幕轨道的外挂字幕地址
+func (app *App) buildSubtitleURL(serverURL, fileName string) string {
+	return serverURL + server.SubtitlePathPrefix + url.PathEscape(fileName) + ".srt?track=" + strconv.Itoa(app.SelectedSubtitleIndex())
+}
@@ -0,0 +1,366 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"GoCastify/dlna"
+	"GoCastify/interfaces"
+	"GoCastify/types"
+)
+
+// fakeMediaServer 记录调用的假媒体服务器，Start返回的地址对任何文件都响应200
+type fakeMediaServer struct {
+	mu      sync.Mutex
+	calls   []string
+	allowed []string
+	files   *httptest.Server
+}
+
+// 确保fakeMediaServer实现了interfaces.MediaServer接口
+var _ interfaces.MediaServer = (*fakeMediaServer)(nil)
+
+func newFakeMediaServer(t *testing.T) *fakeMediaServer {
+	fake := &fakeMediaServer{}
+	fake.files = httptest.NewServer(fake)
+	t.Cleanup(fake.files.Close)
+	return fake
+}
+
+func (f *fakeMediaServer) record(call string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, call)
+}
+
+// Calls 返回按顺序记录的方法调用
+func (f *fakeMediaServer) Calls() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.calls...)
+}
+
+func (f *fakeMediaServer) Start(mediaDir string) (string, error) {
+	f.record("Start")
+	return f.files.URL, nil
+}
+
+func (f *fakeMediaServer) Stop() error {
+	f.record("Stop")
+	return nil
+}
+
+func (f *fakeMediaServer) StopServing() error {
+	f.record("StopServing")
+	return nil
+}
+
+func (f *fakeMediaServer) AllowFile(filePath string) {
+	f.record("AllowFile")
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.allowed = append(f.allowed, filePath)
+}
+
+func (f *fakeMediaServer) AliasFile(filePath string) string {
+	f.record("AliasFile")
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.allowed = append(f.allowed, filePath)
+	return "v1.mp4"
+}
+
+func (f *fakeMediaServer) ClearAllowedFiles() {
+	f.record("ClearAllowedFiles")
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.allowed = nil
+}
+
+func (f *fakeMediaServer) NotifyFirstRequest(filePath string, callback func()) {
+	f.record("NotifyFirstRequest")
+}
+
+func (f *fakeMediaServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "video/mp4")
+	w.WriteHeader(http.StatusOK)
+}
+
+const fakeRendererDescription = `<?xml version="1.0"?>
+<root xmlns="urn:schemas-upnp-org:device-1-0">
+  <device>
+    <deviceType>urn:schemas-upnp-org:device:MediaRenderer:1</deviceType>
+    <friendlyName>Fake TV</friendlyName>
+    <UDN>uuid:fake-tv</UDN>
+    <serviceList>
+      <service>
+        <serviceType>urn:schemas-upnp-org:service:AVTransport:1</serviceType>
+        <serviceId>urn:upnp-org:serviceId:AVTransport</serviceId>
+        <controlURL>/avt/control</controlURL>
+        <eventSubURL>/avt/event</eventSubURL>
+      </service>
+    </serviceList>
+  </device>
+</root>`
+
+var soapActionPattern = regexp.MustCompile(`#(\w+)"?$`)
+
+// fakeRenderer 假DLNA渲染器，接受所有AVTransport操作并记录操作名称和请求内容
+type fakeRenderer struct {
+	mu      sync.Mutex
+	actions []string
+	bodies  map[string]string
+	server  *httptest.Server
+}
+
+func newFakeRenderer(t *testing.T) *fakeRenderer {
+	renderer := &fakeRenderer{bodies: make(map[string]string)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/desc.xml", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/xml")
+		io.WriteString(w, fakeRendererDescription)
+	})
+	mux.HandleFunc("/avt/control", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		match := soapActionPattern.FindStringSubmatch(r.Header.Get("SOAPAction"))
+		if match == nil {
+			http.Error(w, "missing SOAPAction", http.StatusBadRequest)
+			return
+		}
+		action := match[1]
+		renderer.mu.Lock()
+		renderer.actions = append(renderer.actions, action)
+		renderer.bodies[action] = string(body)
+		renderer.mu.Unlock()
+
+		w.Header().Set("Content-Type", `text/xml; charset="utf-8"`)
+		fmt.Fprintf(w, `<?xml version="1.0"?>
+<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/">
+  <s:Body><u:%sResponse xmlns:u="urn:schemas-upnp-org:service:AVTransport:1"/></s:Body>
+</s:Envelope>`, action)
+	})
+	renderer.server = httptest.NewServer(mux)
+	t.Cleanup(renderer.server.Close)
+	return renderer
+}
+
+// Actions 返回按顺序收到的SOAP操作
+func (r *fakeRenderer) Actions() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.actions...)
+}
+
+// Body 返回最近一次收到的指定操作的请求内容
+func (r *fakeRenderer) Body(action string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.bodies[action]
+}
+
+func (r *fakeRenderer) Device() types.DeviceInfo {
+	return types.DeviceInfo{FriendlyName: "Fake TV", UDN: "uuid:fake-tv", Location: r.server.URL + "/desc.xml"}
+}
+
+// newCastTestApp 创建使用假媒体服务器的App，关闭后台发现和休眠阻止
+// 假渲染器不需要准备时间，Play前不等待
+func newCastTestApp(t *testing.T, mediaServer interfaces.MediaServer, settings Settings) *App {
+	t.Helper()
+	app := &App{
+		devices:               []types.DeviceInfo{},
+		selectedDeviceIndex:   -1,
+		MediaServer:           mediaServer,
+		capabilities:          dlna.NewCapabilityCache(),
+		subtitleTracks:        []types.SubtitleTrack{},
+		selectedSubtitleIndex: -1,
+		audioTracks:           []types.AudioTrack{},
+		selectedAudioIndex:    -1,
+	}
+	settings.BackgroundDiscovery = false
+	settings.PreventSleep = false
+	settings.DeviceReadyDelay = 0
+	app.ApplySettings(settings)
+	t.Cleanup(app.clearNowPlaying)
+	return app
+}
+
+// writeMediaFile 在临时目录中创建一个不需要转码的媒体文件
+func writeMediaFile(t *testing.T, name string) string {
+	t.Helper()
+	mediaFile := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(mediaFile, []byte("not really a video"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return mediaFile
+}
+
+func TestStartCastingUsesMediaServer(t *testing.T) {
+	tests := []struct {
+		name          string
+		shortURLs     bool
+		wantCalls     []string
+		wantURLSuffix string
+	}{
+		{
+			name:          "allow file",
+			wantCalls:     []string{"Start", "ClearAllowedFiles", "AllowFile", "NotifyFirstRequest"},
+			wantURLSuffix: "/media/movie.mp4",
+		},
+		{
+			name:          "short media URL",
+			shortURLs:     true,
+			wantCalls:     []string{"Start", "ClearAllowedFiles", "AliasFile", "NotifyFirstRequest"},
+			wantURLSuffix: "/media/v1.mp4",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mediaServer := newFakeMediaServer(t)
+			renderer := newFakeRenderer(t)
+			settings := DefaultSettings()
+			settings.ShortMediaURLs = tt.shortURLs
+			settings.TryOriginalFirst = false
+			app := newCastTestApp(t, mediaServer, settings)
+
+			mediaFile := writeMediaFile(t, "movie.mp4")
+			app.SetMediaFile(mediaFile)
+			app.AddDevice(renderer.Device())
+			app.SelectDevice(0)
+
+			if err := app.StartCastingWithContext(context.Background(), nil); err != nil {
+				t.Fatalf("StartCastingWithContext() error = %v", err)
+			}
+
+			calls := mediaServer.Calls()
+			if strings.Join(calls, ",") != strings.Join(tt.wantCalls, ",") {
+				t.Errorf("media server calls = %v, want %v", calls, tt.wantCalls)
+			}
+			if len(mediaServer.allowed) != 1 || mediaServer.allowed[0] != mediaFile {
+				t.Errorf("allowed files = %v, want only %s", mediaServer.allowed, mediaFile)
+			}
+
+			actions := strings.Join(renderer.Actions(), ",")
+			if !strings.Contains(actions, "SetAVTransportURI") || !strings.HasSuffix(actions, "Play") {
+				t.Errorf("renderer actions = %s, want SetAVTransportURI followed by Play", actions)
+			}
+			wantURL := mediaServer.files.URL + tt.wantURLSuffix
+			if body := renderer.Body("SetAVTransportURI"); !strings.Contains(body, wantURL) {
+				t.Errorf("SetAVTransportURI did not send %s:\n%s", wantURL, body)
+			}
+			if info, playing := app.NowPlaying(); !playing || info.FileName != "movie.mp4" {
+				t.Errorf("NowPlaying() = %+v, %v, want movie.mp4 playing", info, playing)
+			}
+		})
+	}
+}
+
+func TestStopMediaServerStopsServing(t *testing.T) {
+	mediaServer := newFakeMediaServer(t)
+	app := newCastTestApp(t, mediaServer, DefaultSettings())
+
+	if err := app.StopMediaServer(); err != nil {
+		t.Fatalf("StopMediaServer() error = %v", err)
+	}
+	calls := strings.Join(mediaServer.Calls(), ",")
+	if calls != "StopServing,ClearAllowedFiles" {
+		t.Errorf("media server calls = %s, want StopServing,ClearAllowedFiles", calls)
+	}
+}
+
+// restartingMediaServer 每次Start都在新的端口上提供文件，模拟媒体服务器重启后端口变化
+type restartingMediaServer struct {
+	*fakeMediaServer
+	t    *testing.T
+	urls []string
+}
+
+func (r *restartingMediaServer) Start(mediaDir string) (string, error) {
+	r.record("Start")
+	files := httptest.NewServer(r.fakeMediaServer)
+	r.t.Cleanup(files.Close)
+	r.urls = append(r.urls, files.URL)
+	return files.URL, nil
+}
+
+func TestCastUsesServerURLFromEachStart(t *testing.T) {
+	mediaServer := &restartingMediaServer{fakeMediaServer: newFakeMediaServer(t), t: t}
+	renderer := newFakeRenderer(t)
+	settings := DefaultSettings()
+	settings.TryOriginalFirst = false
+	app := newCastTestApp(t, mediaServer, settings)
+
+	app.SetMediaFile(writeMediaFile(t, "movie.mp4"))
+	app.AddDevice(renderer.Device())
+	app.SelectDevice(0)
+
+	for i := 0; i < 2; i++ {
+		if err := app.StartCastingWithContext(context.Background(), nil); err != nil {
+			t.Fatalf("cast %d: %v", i+1, err)
+		}
+		wantURL := mediaServer.urls[i] + "/media/movie.mp4"
+		if body := renderer.Body("SetAVTransportURI"); !strings.Contains(body, wantURL) {
+			t.Errorf("cast %d did not send %s:\n%s", i+1, wantURL, body)
+		}
+		if err := app.StopMediaServer(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if mediaServer.urls[0] == mediaServer.urls[1] {
+		t.Fatalf("both starts returned %s", mediaServer.urls[0])
+	}
+}
+
+func TestCastUsesDeviceReadyDelay(t *testing.T) {
+	for _, delay := range []time.Duration{300 * time.Millisecond, 0} {
+		t.Run(delay.String(), func(t *testing.T) {
+			renderer := newFakeRenderer(t)
+			settings := DefaultSettings()
+			settings.TryOriginalFirst = false
+			app := newCastTestApp(t, newFakeMediaServer(t), settings)
+			app.Settings.DeviceReadyDelay = int(delay / time.Millisecond)
+
+			app.SetMediaFile(writeMediaFile(t, "movie.mp4"))
+			app.AddDevice(renderer.Device())
+			app.SelectDevice(0)
+
+			start := time.Now()
+			if err := app.StartCastingWithContext(context.Background(), nil); err != nil {
+				t.Fatalf("StartCastingWithContext() error = %v", err)
+			}
+			elapsed := time.Since(start)
+			if elapsed < delay || elapsed >= delay+dlna.DefaultReadyDelay {
+				t.Errorf("cast took %v, want the configured ready delay %v instead of the default %v", elapsed, delay, dlna.DefaultReadyDelay)
+			}
+		})
+	}
+}
+
+func TestSettingsHandshakeTimeout(t *testing.T) {
+	tests := []struct {
+		name       string
+		timeout    int
+		readyDelay int
+		want       time.Duration
+	}{
+		{name: "defaults", timeout: 5, readyDelay: 2000, want: castHandshakeTimeout},
+		{name: "slow device", timeout: 20, readyDelay: 5000, want: 45 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			settings := Settings{DeviceTimeout: tt.timeout, DeviceReadyDelay: tt.readyDelay}
+			if got := settings.handshakeTimeout(); got != tt.want {
+				t.Errorf("handshakeTimeout() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
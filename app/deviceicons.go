@@ -0,0 +1,95 @@
+package app
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"fyne.io/fyne/v2"
+
+	"GoCastify/types"
+)
+
+// 设备图标的下载限制
+const (
+	// deviceIconTimeout 下载一个设备图标的超时
+	deviceIconTimeout = 3 * time.Second
+	// deviceIconMaxSize 设备图标的最大字节数，设备图标通常只有几KB
+	deviceIconMaxSize = 256 * 1024
+)
+
+// deviceIconCache 按地址缓存下载的设备图标，下载失败的地址也会记录，避免反复请求
+type deviceIconCache struct {
+	mu      sync.Mutex
+	icons   map[string]fyne.Resource
+	loading map[string]bool
+}
+
+// DeviceIcon 返回设备的图标，没有图标或尚未下载完成时返回nil
+// 第一次请求某个图标时在后台下载，下载成功后调用onLoaded，由UI刷新设备列表
+func (app *App) DeviceIcon(device types.DeviceInfo, onLoaded func()) fyne.Resource {
+	iconURL := device.IconURL
+	if iconURL == "" {
+		return nil
+	}
+
+	cache := &app.deviceIcons
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	if icon, ok := cache.icons[iconURL]; ok {
+		return icon
+	}
+	if cache.loading[iconURL] {
+		return nil
+	}
+	if cache.loading == nil {
+		cache.loading = make(map[string]bool)
+		cache.icons = make(map[string]fyne.Resource)
+	}
+	cache.loading[iconURL] = true
+
+	go func() {
+		icon, err := fetchDeviceIcon(iconURL)
+		if err != nil {
+			log.Printf("下载设备图标失败: %v\n", err)
+		}
+		cache.mu.Lock()
+		cache.icons[iconURL] = icon
+		delete(cache.loading, iconURL)
+		cache.mu.Unlock()
+		if icon != nil && onLoaded != nil {
+			onLoaded()
+		}
+	}()
+	return nil
+}
+
+// fetchDeviceIcon 下载设备图标，响应不是图片或超过大小限制时返回错误
+func fetchDeviceIcon(iconURL string) (fyne.Resource, error) {
+	client := http.Client{Timeout: deviceIconTimeout}
+	resp, err := client.Get(iconURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s 状态码: %d", iconURL, resp.StatusCode)
+	}
+	if contentType := resp.Header.Get("Content-Type"); contentType != "" && !strings.HasPrefix(contentType, "image/") {
+		return nil, fmt.Errorf("%s 不是图片: %s", iconURL, contentType)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, deviceIconMaxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("读取 %s 失败: %w", iconURL, err)
+	}
+	if len(data) > deviceIconMaxSize {
+		return nil, fmt.Errorf("%s 超过%dKB", iconURL, deviceIconMaxSize/1024)
+	}
+	return fyne.NewStaticResource(path.Base(iconURL), data), nil
+}
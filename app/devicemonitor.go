@@ -0,0 +1,127 @@
+package app
+
+import (
+	"context"
+	"log"
+	"sync"
+
+	"GoCastify/discovery"
+	"GoCastify/types"
+)
+
+// deviceMonitorState 后台设备发现的运行状态
+type deviceMonitorState struct {
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	// key 正在运行的后台发现使用的设置，变化时重新启动
+	key deviceMonitorKey
+	// onChanged 后台发现更新设备列表后的回调
+	onChanged func()
+}
+
+// deviceMonitorKey 影响后台发现的设置
+type deviceMonitorKey struct {
+	renderersOnly    bool
+	networkInterface string
+}
+
+// SetOnDevicesChanged 设置后台发现更新设备列表后的回调，回调在后台goroutine中执行
+func (app *App) SetOnDevicesChanged(callback func()) {
+	app.deviceMonitor.mu.Lock()
+	app.deviceMonitor.onChanged = callback
+	app.deviceMonitor.mu.Unlock()
+}
+
+// configureDeviceMonitor 根据设置启动或停止后台设备发现
+// 已在运行且相关设置没有变化时不重复启动，只报告媒体渲染器或网络接口的设置变化时重新启动
+func (app *App) configureDeviceMonitor(settings Settings) {
+	if !settings.BackgroundDiscovery {
+		app.StopDeviceMonitor()
+		return
+	}
+
+	key := deviceMonitorKey{
+		renderersOnly:    settings.RenderersOnly,
+		networkInterface: settings.NetworkInterface,
+	}
+	app.deviceMonitor.mu.Lock()
+	defer app.deviceMonitor.mu.Unlock()
+	if app.deviceMonitor.cancel != nil {
+		if app.deviceMonitor.key == key {
+			return
+		}
+		app.deviceMonitor.cancel()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	app.deviceMonitor.cancel = cancel
+	app.deviceMonitor.key = key
+
+	discoverer := discovery.NewCompositeDiscoverer(
+		discovery.NewSSDPDiscoverer(settings.DiscoveryOptions()...),
+	)
+	go func() {
+		err := discoverer.StartMonitor(ctx, func(device types.DeviceInfo) {
+			app.upsertDevice(device)
+			app.notifyDevicesChanged()
+		}, func(udn string) {
+			if app.markDeviceLost(udn) {
+				app.notifyDevicesChanged()
+			}
+		})
+		if err != nil && ctx.Err() == nil {
+			log.Printf("后台发现设备失败: %v\n", err)
+		}
+	}()
+}
+
+// StopDeviceMonitor 停止后台设备发现，没有运行时不做任何事
+func (app *App) StopDeviceMonitor() {
+	app.deviceMonitor.mu.Lock()
+	defer app.deviceMonitor.mu.Unlock()
+	if app.deviceMonitor.cancel != nil {
+		app.deviceMonitor.cancel()
+		app.deviceMonitor.cancel = nil
+	}
+}
+
+// notifyDevicesChanged 通知界面设备列表已被后台发现更新
+func (app *App) notifyDevicesChanged() {
+	app.deviceMonitor.mu.Lock()
+	callback := app.deviceMonitor.onChanged
+	app.deviceMonitor.mu.Unlock()
+	if callback != nil {
+		callback()
+	}
+}
+
+// markDeviceLost 处理已离开网络的设备：设置了保留未响应的设备时标记为离线，否则从列表中移除
+// 并按设备重新确定选择的序号；列表有变化时返回true
+func (app *App) markDeviceLost(udn string) bool {
+	keepMissing := app.Settings.KeepMissingDevices
+	app.stateMu.Lock()
+	defer app.stateMu.Unlock()
+
+	for i, device := range app.devices {
+		if device.UDN != udn {
+			continue
+		}
+		if keepMissing {
+			if device.Offline {
+				return false
+			}
+			app.devices[i].Offline = true
+			return true
+		}
+
+		app.devices = append(app.devices[:i], app.devices[i+1:]...)
+		switch {
+		case app.selectedDeviceIndex == i:
+			// 选择的设备被移除时取消选择
+			app.selectedDeviceIndex = -1
+		case app.selectedDeviceIndex > i:
+			app.selectedDeviceIndex--
+		}
+		return true
+	}
+	return false
+}
@@ -0,0 +1,83 @@
+package app
+
+import "GoCastify/types"
+
+// DeviceRefresh 一次设备搜索，将搜索结果按UDN合并到现有的设备列表，而不是清空后重建
+// 已有的设备原位更新，设备序号和选择保持不变
+type DeviceRefresh struct {
+	app *App
+	// seen 本次搜索中响应的设备，键为deviceKey
+	seen map[string]bool
+}
+
+// BeginDeviceRefresh 开始一次设备搜索
+// 每次搜索使用单独的DeviceRefresh，被新搜索取消的旧搜索不会影响新搜索的结果
+func (app *App) BeginDeviceRefresh() *DeviceRefresh {
+	return &DeviceRefresh{app: app, seen: make(map[string]bool)}
+}
+
+// Add 合并一个搜索到的设备：已在列表中（UDN相同，没有UDN时地址相同）的设备更新信息并恢复在线，
+// 其他设备追加到列表末尾；返回合并后的设备数量
+func (r *DeviceRefresh) Add(device types.DeviceInfo) int {
+	r.seen[deviceKey(device)] = true
+	return r.app.upsertDevice(device)
+}
+
+// upsertDevice 将设备合并到设备列表：已在列表中的设备原位更新并恢复在线，其他设备追加到末尾
+// 返回合并后的设备数量
+func (app *App) upsertDevice(device types.DeviceInfo) int {
+	app.stateMu.Lock()
+	defer app.stateMu.Unlock()
+
+	key := deviceKey(device)
+	device.Offline = false
+	for i, existing := range app.devices {
+		if deviceKey(existing) == key {
+			app.devices[i] = device
+			return len(app.devices)
+		}
+	}
+	app.devices = append(app.devices, device)
+	return len(app.devices)
+}
+
+// Finish 结束搜索，返回本次搜索中响应的设备数量
+// completed为true（搜索正常结束）时处理没有响应的设备：设置了保留未响应的设备时标记为离线，
+// 否则从列表中移除，并按设备重新确定选择的序号；搜索被取消时列表保持不变
+func (r *DeviceRefresh) Finish(completed bool) int {
+	if !completed {
+		return len(r.seen)
+	}
+
+	app := r.app
+	keepMissing := app.Settings.KeepMissingDevices
+	app.stateMu.Lock()
+	defer app.stateMu.Unlock()
+
+	selectedKey := ""
+	if app.selectedDeviceIndex >= 0 && app.selectedDeviceIndex < len(app.devices) {
+		selectedKey = deviceKey(app.devices[app.selectedDeviceIndex])
+	}
+
+	devices := app.devices[:0]
+	for _, device := range app.devices {
+		if !r.seen[deviceKey(device)] {
+			if !keepMissing {
+				continue
+			}
+			device.Offline = true
+		}
+		devices = append(devices, device)
+	}
+	app.devices = devices
+
+	// 选择的设备被移除时取消选择
+	app.selectedDeviceIndex = -1
+	for i, device := range app.devices {
+		if selectedKey != "" && deviceKey(device) == selectedKey {
+			app.selectedDeviceIndex = i
+			break
+		}
+	}
+	return len(r.seen)
+}
@@ -0,0 +1,123 @@
+package app
+
+import (
+	"reflect"
+	"testing"
+
+	"GoCastify/types"
+)
+
+func TestDeviceRefreshMerge(t *testing.T) {
+	renamed := testDevice(2)
+	renamed.FriendlyName = "Living Room"
+	noUDN := types.DeviceInfo{FriendlyName: "Old TV", Location: "http://192.168.1.9:8080/desc.xml"}
+
+	tests := []struct {
+		name         string
+		keepMissing  bool
+		completed    bool
+		found        []types.DeviceInfo
+		wantNames    []string
+		wantOffline  []bool
+		wantSelected int
+	}{
+		{
+			name:         "update in place and append new",
+			keepMissing:  true,
+			completed:    true,
+			found:        []types.DeviceInfo{testDevice(4), renamed, testDevice(1), testDevice(3), noUDN},
+			wantNames:    []string{"TV 1", "Living Room", "TV 3", "Old TV", "TV 4"},
+			wantOffline:  []bool{false, false, false, false, false},
+			wantSelected: 1,
+		},
+		{
+			name:         "missing devices marked offline",
+			keepMissing:  true,
+			completed:    true,
+			found:        []types.DeviceInfo{testDevice(3)},
+			wantNames:    []string{"TV 1", "TV 2", "TV 3", "Old TV"},
+			wantOffline:  []bool{true, true, false, true},
+			wantSelected: 1,
+		},
+		{
+			name:         "missing devices removed",
+			completed:    true,
+			found:        []types.DeviceInfo{testDevice(3), testDevice(2)},
+			wantNames:    []string{"TV 2", "TV 3"},
+			wantOffline:  []bool{false, false},
+			wantSelected: 0,
+		},
+		{
+			name:         "selected device removed",
+			completed:    true,
+			found:        []types.DeviceInfo{testDevice(1), noUDN},
+			wantNames:    []string{"TV 1", "Old TV"},
+			wantOffline:  []bool{false, false},
+			wantSelected: -1,
+		},
+		{
+			name:         "canceled search keeps missing devices",
+			found:        []types.DeviceInfo{testDevice(3)},
+			wantNames:    []string{"TV 1", "TV 2", "TV 3", "Old TV"},
+			wantOffline:  []bool{false, false, false, false},
+			wantSelected: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newStateTestApp()
+			app.Settings.KeepMissingDevices = tt.keepMissing
+			for _, device := range []types.DeviceInfo{testDevice(1), testDevice(2), testDevice(3), noUDN} {
+				app.AddDevice(device)
+			}
+			app.SelectDevice(1)
+
+			refresh := app.BeginDeviceRefresh()
+			for _, device := range tt.found {
+				refresh.Add(device)
+			}
+			if got := refresh.Finish(tt.completed); got != len(tt.found) {
+				t.Errorf("Finish() = %d, want %d responding devices", got, len(tt.found))
+			}
+
+			var names []string
+			var offline []bool
+			for _, device := range app.Devices() {
+				names = append(names, device.FriendlyName)
+				offline = append(offline, device.Offline)
+			}
+			if !reflect.DeepEqual(names, tt.wantNames) || !reflect.DeepEqual(offline, tt.wantOffline) {
+				t.Errorf("devices = %v offline %v, want %v offline %v", names, offline, tt.wantNames, tt.wantOffline)
+			}
+			if got := app.SelectedDeviceIndex(); got != tt.wantSelected {
+				t.Errorf("SelectedDeviceIndex() = %d, want %d", got, tt.wantSelected)
+			}
+		})
+	}
+}
+
+func TestDeviceRefreshBringsOfflineDeviceBack(t *testing.T) {
+	app := newStateTestApp()
+	app.Settings.KeepMissingDevices = true
+	app.AddDevice(testDevice(1))
+	app.AddDevice(testDevice(2))
+	app.SelectDevice(0)
+
+	first := app.BeginDeviceRefresh()
+	first.Add(testDevice(2))
+	first.Finish(true)
+	if devices := app.Devices(); !devices[0].Offline {
+		t.Fatalf("TV 1 should be offline after a search without it: %+v", devices)
+	}
+
+	second := app.BeginDeviceRefresh()
+	second.Add(testDevice(1))
+	second.Finish(true)
+	devices := app.Devices()
+	if devices[0].Offline || !devices[1].Offline {
+		t.Errorf("devices = %+v, want TV 1 online and TV 2 offline", devices)
+	}
+	if selected, ok := app.SelectedDevice(); !ok || selected.UDN != testDevice(1).UDN {
+		t.Errorf("SelectedDevice() = %+v, %v, want TV 1", selected, ok)
+	}
+}
@@ -0,0 +1,29 @@
+package app
+
+import (
+	"fmt"
+	"net/url"
+
+	"fyne.io/fyne/v2/dialog"
+)
+
+// OpenDeviceWebUI 在浏览器中打开选择的设备的网页管理界面（设备描述中的presentationURL）
+func (app *App) OpenDeviceWebUI() {
+	device, ok := app.SelectedDevice()
+	if !ok {
+		dialog.ShowInformation("提示", "请先选择设备", app.Window)
+		return
+	}
+	if device.PresentationURL == "" {
+		dialog.ShowInformation("设备网页", "该设备没有提供网页管理界面", app.Window)
+		return
+	}
+
+	webURL, err := url.Parse(device.PresentationURL)
+	if err == nil {
+		err = app.FyneApp.OpenURL(webURL)
+	}
+	if err != nil {
+		dialog.ShowError(fmt.Errorf("打开设备网页失败: %w", err), app.Window)
+	}
+}
@@ -0,0 +1,112 @@
+package app
+
+import (
+	"net"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"GoCastify/transcoder"
+	"GoCastify/types"
+)
+
+// DirectURLMode 绕过媒体服务器直接发送媒体地址的模式
+type DirectURLMode string
+
+const (
+	// DirectURLOff 始终通过HTTP媒体服务器提供文件（默认）
+	DirectURLOff DirectURLMode = ""
+	// DirectURLFile 对与本机运行在同一主机上的渲染器发送file://地址
+	DirectURLFile DirectURLMode = "file"
+	// DirectURLSMB 对位于配置的共享目录下的文件发送SMB地址
+	DirectURLSMB DirectURLMode = "smb"
+)
+
+// DirectURLWarning 启用直接地址模式时向用户显示的提示
+const DirectURLWarning = "直接地址模式只适用于与本机运行在同一主机上的渲染器（file://）或能访问网络共享的渲染器（SMB）。\n" +
+	"该模式下不会进行转码，也不支持选择音轨和字幕，其他设备请保持关闭。"
+
+// directMediaURL 根据设置为当前媒体文件生成可直接发送给设备的地址
+// 不满足条件（未启用、需要转码、选择了轨道、设备不在本机等）时返回false，此时应使用媒体服务器
+func (app *App) directMediaURL(device types.DeviceInfo) (string, bool) {
+	mode := app.Settings.DirectURLMode
+	if mode == DirectURLOff {
+		return "", false
+	}
+	mediaFile := app.MediaFile()
+
+	// 直接地址无法转码，也无法选择轨道
+	if _, needTranscode := transcoder.IsSupportedFormat(mediaFile); needTranscode {
+		return "", false
+	}
+	if app.SelectedAudioIndex() >= 0 || app.SelectedSubtitleIndex() >= 0 {
+		return "", false
+	}
+
+	switch mode {
+	case DirectURLFile:
+		if !isLocalHostDevice(device) {
+			return "", false
+		}
+		absPath, err := filepath.Abs(mediaFile)
+		if err != nil {
+			return "", false
+		}
+		fileURL := url.URL{Scheme: "file", Path: filepath.ToSlash(absPath)}
+		return fileURL.String(), true
+	case DirectURLSMB:
+		return smbURLForFile(mediaFile, app.Settings.SMBLocalRoot, app.Settings.SMBShareURL)
+	}
+	return "", false
+}
+
+// smbURLForFile 将本地共享目录下的文件映射为SMB地址
+// 例如 localRoot为"/srv/media"、shareURL为"smb://nas/media"时，
+// "/srv/media/movies/a.mp4" 映射为 "smb://nas/media/movies/a.mp4"
+func smbURLForFile(filePath, localRoot, shareURL string) (string, bool) {
+	if localRoot == "" || shareURL == "" {
+		return "", false
+	}
+	rel, err := filepath.Rel(localRoot, filePath)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return "", false
+	}
+
+	base, err := url.Parse(shareURL)
+	if err != nil || base.Scheme != "smb" {
+		return "", false
+	}
+	base.Path = path.Join(base.Path, filepath.ToSlash(rel))
+	return base.String(), true
+}
+
+// isLocalHostDevice 判断设备是否与本机运行在同一主机上
+func isLocalHostDevice(device types.DeviceInfo) bool {
+	u, err := url.Parse(device.Location)
+	if err != nil {
+		return false
+	}
+	host := u.Hostname()
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	if ip.IsLoopback() {
+		return true
+	}
+
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return false
+	}
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,160 @@
+package app
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+
+	"GoCastify/transcoder"
+)
+
+// ListFolderMedia 列出目录中受支持的媒体文件，按文件名自然排序（ep2排在ep10之前）
+// recursive为true时包含子目录中的文件，按相对路径排序；隐藏文件和目录被忽略；
+// 第二个返回值为跳过的不受支持的文件数量
+func ListFolderMedia(dir string, recursive bool) ([]string, int, error) {
+	files := []string{}
+	skipped := 0
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			// 无法读取的子目录不影响其他文件，根目录无法读取时返回错误
+			if path == dir {
+				return err
+			}
+			return fs.SkipDir
+		}
+		if path == dir {
+			return nil
+		}
+		if strings.HasPrefix(entry.Name(), ".") {
+			if entry.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if entry.IsDir() {
+			if !recursive {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if supported, _ := transcoder.IsSupportedFormat(path); supported {
+			files = append(files, path)
+		} else {
+			skipped++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("读取文件夹失败: %w", err)
+	}
+
+	sort.SliceStable(files, func(i, j int) bool {
+		return naturalLess(relativeTo(dir, files[i]), relativeTo(dir, files[j]))
+	})
+	return files, skipped, nil
+}
+
+// relativeTo 返回path相对于dir的路径，无法计算时返回path本身
+func relativeTo(dir, path string) string {
+	if rel, err := filepath.Rel(dir, path); err == nil {
+		return rel
+	}
+	return path
+}
+
+// naturalLess 按自然顺序比较两个文件名：不区分大小写，连续的数字按数值比较
+// 例如 "ep2.mkv" 排在 "ep10.mkv" 之前；数值相同时前导零较少的排在前面
+func naturalLess(a, b string) bool {
+	a, b = strings.ToLower(a), strings.ToLower(b)
+	for a != "" && b != "" {
+		if isDigit(a[0]) && isDigit(b[0]) {
+			numberA, restA := cutDigits(a)
+			numberB, restB := cutDigits(b)
+			// 去掉前导零后，位数少的数值较小，位数相同时按字典序比较
+			trimmedA, trimmedB := strings.TrimLeft(numberA, "0"), strings.TrimLeft(numberB, "0")
+			if len(trimmedA) != len(trimmedB) {
+				return len(trimmedA) < len(trimmedB)
+			}
+			if trimmedA != trimmedB {
+				return trimmedA < trimmedB
+			}
+			if len(numberA) != len(numberB) {
+				return len(numberA) < len(numberB)
+			}
+			a, b = restA, restB
+			continue
+		}
+		if a[0] != b[0] {
+			return a[0] < b[0]
+		}
+		a, b = a[1:], b[1:]
+	}
+	return len(a) < len(b)
+}
+
+// isDigit 判断字节是否为ASCII数字
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// cutDigits 将s开头的连续数字与其余部分分开
+func cutDigits(s string) (digits, rest string) {
+	end := 0
+	for end < len(s) && isDigit(s[end]) {
+		end++
+	}
+	return s[:end], s[end:]
+}
+
+// ShowQueueFolder 选择一个文件夹，将其中受支持的媒体文件按文件名顺序加入投屏队列
+func (app *App) ShowQueueFolder() {
+	folderDialog := dialog.NewFolderOpen(func(dir fyne.ListableURI, err error) {
+		if err != nil {
+			dialog.ShowError(err, app.Window)
+			return
+		}
+		if dir == nil {
+			return
+		}
+
+		recursiveCheck := widget.NewCheck("包含子文件夹", nil)
+		content := container.NewVBox(
+			widget.NewLabel(fmt.Sprintf("将 %s 中的媒体文件按文件名顺序加入投屏队列", filepath.Base(dir.Path()))),
+			recursiveCheck,
+		)
+		dialog.ShowCustomConfirm("选择文件夹", "加入队列", "取消", content, func(confirmed bool) {
+			if confirmed {
+				app.queueFolder(dir.Path(), recursiveCheck.Checked)
+			}
+		}, app.Window)
+	}, app.Window)
+	folderDialog.Resize(fyne.NewSize(800, 600))
+	folderDialog.Show()
+}
+
+// queueFolder 将目录中的媒体文件加入投屏队列并显示结果
+func (app *App) queueFolder(dir string, recursive bool) {
+	files, skipped, err := ListFolderMedia(dir, recursive)
+	if err != nil {
+		dialog.ShowError(err, app.Window)
+		return
+	}
+	if len(files) == 0 {
+		dialog.ShowInformation("选择文件夹", fmt.Sprintf("%s 中没有受支持的媒体文件", filepath.Base(dir)), app.Window)
+		return
+	}
+
+	app.AppendToQueue(files...)
+	message := fmt.Sprintf("已从 %s 加入 %d 个文件", filepath.Base(dir), len(files))
+	if skipped > 0 {
+		message += fmt.Sprintf("，跳过 %d 个不支持的文件", skipped)
+	}
+	dialog.ShowInformation("选择文件夹", message, app.Window)
+}
@@ -0,0 +1,97 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestNaturalLessEpisodeOrder(t *testing.T) {
+	tests := []struct {
+		name  string
+		files []string
+		want  []string
+	}{
+		{
+			name:  "episode numbers",
+			files: []string{"Show ep10.mkv", "Show ep2.mkv", "Show ep1.mkv", "Show ep11.mkv"},
+			want:  []string{"Show ep1.mkv", "Show ep2.mkv", "Show ep10.mkv", "Show ep11.mkv"},
+		},
+		{
+			name:  "season and episode",
+			files: []string{"S02E01.mp4", "S01E10.mp4", "S01E02.mp4"},
+			want:  []string{"S01E02.mp4", "S01E10.mp4", "S02E01.mp4"},
+		},
+		{
+			name:  "leading zeros",
+			files: []string{"ep010.mkv", "ep9.mkv", "ep09.mkv"},
+			want:  []string{"ep9.mkv", "ep09.mkv", "ep010.mkv"},
+		},
+		{
+			name:  "case insensitive",
+			files: []string{"b.mkv", "A.mkv", "c.mkv"},
+			want:  []string{"A.mkv", "b.mkv", "c.mkv"},
+		},
+		{
+			name:  "prefix first",
+			files: []string{"movie part.mkv", "movie.mkv", "movie"},
+			want:  []string{"movie", "movie part.mkv", "movie.mkv"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := append([]string(nil), tt.files...)
+			sort.Slice(got, func(i, j int) bool { return naturalLess(got[i], got[j]) })
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("sorted = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestListFolderMedia(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"ep10.mkv", "ep2.mp4", "cover.jpg", "notes.txt", ".hidden.mkv", "extras/ep1.mkv", ".cache/ep3.mkv"} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(name), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	empty := t.TempDir()
+
+	tests := []struct {
+		name        string
+		dir         string
+		recursive   bool
+		want        []string
+		wantSkipped int
+	}{
+		{name: "top level", dir: dir, want: []string{"ep2.mp4", "ep10.mkv"}, wantSkipped: 2},
+		{name: "recursive", dir: dir, recursive: true, want: []string{"ep2.mp4", "ep10.mkv", "extras/ep1.mkv"}, wantSkipped: 2},
+		{name: "empty folder", dir: empty, want: []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files, skipped, err := ListFolderMedia(tt.dir, tt.recursive)
+			if err != nil {
+				t.Fatalf("ListFolderMedia() error = %v", err)
+			}
+			want := make([]string, len(tt.want))
+			for i, name := range tt.want {
+				want[i] = filepath.Join(tt.dir, filepath.FromSlash(name))
+			}
+			if !reflect.DeepEqual(files, want) || skipped != tt.wantSkipped {
+				t.Errorf("ListFolderMedia() = %q, %d, want %q, %d", files, skipped, want, tt.wantSkipped)
+			}
+		})
+	}
+
+	if _, _, err := ListFolderMedia(filepath.Join(dir, "missing"), false); err == nil {
+		t.Error("ListFolderMedia() of a missing folder returned no error")
+	}
+}
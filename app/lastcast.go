@@ -0,0 +1,135 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+
+	"GoCastify/dlna"
+	"GoCastify/types"
+)
+
+// prefLastCast 上次成功投屏的内容在Preferences中的键，值为LastCast的JSON
+const prefLastCast = "cast.last"
+
+// LastCast 上次成功投屏的设备、文件和轨道选择，用于一键重新投屏
+type LastCast struct {
+	// DeviceUDN 设备的唯一设备名称，设备地址变化后仍可识别
+	DeviceUDN string `json:"deviceUDN"`
+	// DeviceName 设备名称，用于提示
+	DeviceName string `json:"deviceName"`
+	// DeviceLocation 设备描述地址，设备不在列表中时用于确认设备是否在线
+	DeviceLocation string `json:"deviceLocation"`
+	// MediaFile 媒体文件路径
+	MediaFile string `json:"mediaFile"`
+	// AudioIndex 选择的音频流索引，-1表示默认音轨
+	AudioIndex int `json:"audioIndex"`
+	// SubtitleIndex 选择的字幕流索引，-1表示无字幕
+	SubtitleIndex int `json:"subtitleIndex"`
+}
+
+// saveLastCast 保存本次成功投屏的内容
+func (app *App) saveLastCast(device types.DeviceInfo, mediaFile string) {
+	if app.FyneApp == nil {
+		return
+	}
+	subtitleIndex, audioIndex := app.trackSelection()
+	data, err := json.Marshal(LastCast{
+		DeviceUDN:      device.UDN,
+		DeviceName:     device.FriendlyName,
+		DeviceLocation: device.Location,
+		MediaFile:      mediaFile,
+		AudioIndex:     audioIndex,
+		SubtitleIndex:  subtitleIndex,
+	})
+	if err != nil {
+		log.Printf("保存上次投屏内容失败: %v\n", err)
+		return
+	}
+	app.FyneApp.Preferences().SetString(prefLastCast, string(data))
+}
+
+// LastCast 获取上次成功投屏的内容，没有记录时第二个返回值为false
+func (app *App) LastCast() (LastCast, bool) {
+	if app.FyneApp == nil {
+		return LastCast{}, false
+	}
+	data := app.FyneApp.Preferences().String(prefLastCast)
+	if data == "" {
+		return LastCast{}, false
+	}
+	var last LastCast
+	if err := json.Unmarshal([]byte(data), &last); err != nil || last.MediaFile == "" || last.DeviceLocation == "" {
+		log.Printf("忽略无效的上次投屏记录: %s\n", data)
+		return LastCast{}, false
+	}
+	return last, true
+}
+
+// RestoreLastCast 恢复上次投屏的设备和文件选择，之后即可直接投屏
+// 设备不在当前列表中时访问其描述地址确认是否在线，在线则加入列表；
+// 文件已被移动或删除、设备不在线时返回说明原因的错误，不修改当前选择
+func (app *App) RestoreLastCast(ctx context.Context, last LastCast) error {
+	if _, err := os.Stat(last.MediaFile); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("上次投屏的文件已不存在，可能已被移动或删除：\n%s", last.MediaFile)
+		}
+		return fmt.Errorf("无法访问上次投屏的文件: %w", err)
+	}
+
+	index, ok := app.findLastCastDevice(last)
+	if !ok {
+		device, err := probeLastCastDevice(ctx, last)
+		if err != nil {
+			return err
+		}
+		index = app.AddDevice(device) - 1
+	}
+
+	app.SelectDevice(index)
+	app.SetMediaFile(last.MediaFile)
+	app.SetSelectedAudioIndex(last.AudioIndex)
+	app.SetSelectedSubtitleIndex(last.SubtitleIndex)
+	return nil
+}
+
+// findLastCastDevice 在已发现的设备中查找上次投屏的设备，优先按UDN匹配
+func (app *App) findLastCastDevice(last LastCast) (int, bool) {
+	devices := app.Devices()
+	if last.DeviceUDN != "" {
+		for i, device := range devices {
+			if device.UDN == last.DeviceUDN {
+				return i, true
+			}
+		}
+	}
+	for i, device := range devices {
+		if device.Location == last.DeviceLocation {
+			return i, true
+		}
+	}
+	return -1, false
+}
+
+// probeLastCastDevice 访问上次投屏设备的描述地址，确认设备在线且仍是同一台设备
+func probeLastCastDevice(ctx context.Context, last LastCast) (types.DeviceInfo, error) {
+	offline := fmt.Errorf("设备 %s 当前不在线。\n请确认设备已开机并与电脑连接到同一网络，然后搜索设备后重试。", last.DeviceName)
+
+	probeCtx, cancel := context.WithTimeout(ctx, castHandshakeTimeout)
+	defer cancel()
+	controller, err := dlna.NewDeviceControllerWithContext(probeCtx, last.DeviceLocation)
+	if err != nil {
+		log.Printf("上次投屏的设备无法访问(%s): %v\n", last.DeviceLocation, err)
+		return types.DeviceInfo{}, offline
+	}
+	device := controller.GetDeviceInfo()
+	// 地址被分配给了其他设备
+	if last.DeviceUDN != "" && device.UDN != "" && device.UDN != last.DeviceUDN {
+		log.Printf("设备地址 %s 现在属于 %s (%s)\n", last.DeviceLocation, device.FriendlyName, device.UDN)
+		return types.DeviceInfo{}, offline
+	}
+	return device, nil
+}
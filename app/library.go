@@ -0,0 +1,91 @@
+package app
+
+import (
+	"log"
+	"sync"
+
+	"GoCastify/server"
+)
+
+// libraryState 保存当前打开的媒体库目录
+type libraryState struct {
+	mu      sync.RWMutex
+	library *server.Library
+	// onChanged 媒体库文件列表变化时的回调，由UI设置
+	onChanged func()
+}
+
+// OpenLibrary 打开媒体库目录：扫描一次其中的媒体文件，之后监视目录变化
+// 会关闭之前打开的媒体库
+func (app *App) OpenLibrary(dir string) error {
+	library, err := server.OpenLibrary(dir, app.onLibraryFilesChanged)
+	if err != nil {
+		return err
+	}
+
+	app.library.mu.Lock()
+	previous := app.library.library
+	app.library.library = library
+	app.library.mu.Unlock()
+
+	if previous != nil {
+		previous.Close()
+	}
+	if !library.Watching() {
+		log.Printf("媒体库目录 %s 不会自动更新\n", dir)
+	}
+
+	app.notifyLibraryChanged()
+	return nil
+}
+
+// CloseLibrary 关闭当前打开的媒体库
+func (app *App) CloseLibrary() {
+	app.library.mu.Lock()
+	library := app.library.library
+	app.library.library = nil
+	app.library.mu.Unlock()
+
+	if library != nil {
+		if err := library.Close(); err != nil {
+			log.Printf("关闭媒体库时出错: %v\n", err)
+		}
+	}
+}
+
+// LibraryFiles 获取当前媒体库中的媒体文件，没有打开媒体库时返回nil
+func (app *App) LibraryFiles() []string {
+	app.library.mu.RLock()
+	defer app.library.mu.RUnlock()
+	if app.library.library == nil {
+		return nil
+	}
+	return app.library.library.Files()
+}
+
+// SetOnLibraryChanged 设置媒体库文件列表变化时的回调
+func (app *App) SetOnLibraryChanged(callback func()) {
+	app.library.mu.Lock()
+	app.library.onChanged = callback
+	app.library.mu.Unlock()
+}
+
+// onLibraryFilesChanged 媒体库目录中的文件变化后，清除被删除文件的转码缓存并通知UI
+func (app *App) onLibraryFilesChanged(added, removed []string) {
+	if app.Transcoder != nil {
+		for _, file := range removed {
+			app.Transcoder.InvalidateSource(file)
+		}
+	}
+	app.notifyLibraryChanged()
+}
+
+// notifyLibraryChanged 通知UI媒体库文件列表已变化
+func (app *App) notifyLibraryChanged() {
+	app.library.mu.RLock()
+	callback := app.library.onChanged
+	app.library.mu.RUnlock()
+	if callback != nil {
+		callback()
+	}
+}
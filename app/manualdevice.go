@@ -0,0 +1,34 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"GoCastify/discovery"
+	"GoCastify/types"
+)
+
+// manualDeviceTimeout 手动添加设备时获取设备描述的超时时间
+const manualDeviceTimeout = 10 * time.Second
+
+// AddDeviceManually 根据用户输入的设备描述地址或IP添加设备，用于SSDP搜索无法发现设备的网络
+// 只输入IP时在常见端口上查找设备描述；设备必须提供AVTransport服务。
+// 设备已在列表中时更新设备信息并恢复在线，不重复添加；手动添加的设备不响应SSDP时，
+// 下次搜索后会被标记为离线或移除
+func (app *App) AddDeviceManually(ctx context.Context, location string) (types.DeviceInfo, error) {
+	locations, err := discovery.ManualDeviceLocations(location)
+	if err != nil {
+		return types.DeviceInfo{}, err
+	}
+
+	describeCtx, cancel := context.WithTimeout(ctx, manualDeviceTimeout)
+	defer cancel()
+	device, err := discovery.DescribeDevice(describeCtx, locations...)
+	if err != nil {
+		return types.DeviceInfo{}, fmt.Errorf("添加设备失败: %w", err)
+	}
+
+	app.upsertDevice(device)
+	return device, nil
+}
@@ -0,0 +1,80 @@
+package app
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// ShowMediaProbe 获取当前文件完整的ffprobe输出，在对话框中显示，并可以复制到剪贴板或保存到文件
+func (app *App) ShowMediaProbe() {
+	mediaFile := app.MediaFile()
+	if mediaFile == "" {
+		dialog.ShowInformation("提示", "请先选择要投屏的文件", app.Window)
+		return
+	}
+	if app.Transcoder == nil {
+		dialog.ShowInformation("媒体信息不可用", "转码器未初始化，无法获取媒体信息。", app.Window)
+		return
+	}
+
+	go func() {
+		output, err := app.Transcoder.FullProbe(mediaFile)
+		if err != nil {
+			dialog.ShowError(fmt.Errorf("获取媒体信息失败: %w", err), app.Window)
+			return
+		}
+		app.showMediaProbeDialog(mediaFile, output)
+	}()
+}
+
+// showMediaProbeDialog 显示完整的媒体信息
+func (app *App) showMediaProbeDialog(mediaFile, output string) {
+	text := widget.NewMultiLineEntry()
+	text.SetText(output)
+	text.TextStyle = fyne.TextStyle{Monospace: true}
+	text.Wrapping = fyne.TextWrapOff
+
+	var probeDialog dialog.Dialog
+	copyButton := widget.NewButton("复制到剪贴板", func() {
+		app.Window.Clipboard().SetContent(output)
+		dialog.ShowInformation("已复制", "完整的媒体信息已复制到剪贴板", app.Window)
+	})
+	saveButton := widget.NewButton("保存到文件", func() {
+		app.saveMediaProbe(mediaFile, output)
+	})
+	closeButton := &widget.Button{Text: "关闭", Importance: widget.HighImportance, OnTapped: func() {
+		probeDialog.Hide()
+	}}
+
+	probeDialog = dialog.NewCustomWithoutButtons("媒体信息 - "+filepath.Base(mediaFile), container.NewStack(text), app.Window)
+	probeDialog.(*dialog.CustomDialog).SetButtons([]fyne.CanvasObject{copyButton, saveButton, closeButton})
+	probeDialog.Resize(trackDialogSize(app.Window))
+	probeDialog.Show()
+}
+
+// saveMediaProbe 将媒体信息保存为JSON文件，默认文件名为媒体文件名加 .ffprobe.json
+func (app *App) saveMediaProbe(mediaFile, output string) {
+	saveDialog := dialog.NewFileSave(func(writer fyne.URIWriteCloser, err error) {
+		if err != nil {
+			dialog.ShowError(err, app.Window)
+			return
+		}
+		if writer == nil {
+			return
+		}
+		defer writer.Close()
+		if _, err := writer.Write([]byte(output)); err != nil {
+			dialog.ShowError(fmt.Errorf("保存媒体信息失败: %w", err), app.Window)
+		}
+	}, app.Window)
+	name := strings.TrimSuffix(filepath.Base(mediaFile), filepath.Ext(mediaFile)) + ".ffprobe.json"
+	saveDialog.SetFileName(name)
+	saveDialog.Resize(fyne.NewSize(800, 600))
+	saveDialog.Show()
+}
@@ -0,0 +1,136 @@
+package app
+
+import (
+	"sync"
+	"time"
+
+	"GoCastify/interfaces"
+	"GoCastify/types"
+)
+
+// 投屏经过媒体服务器时，设备第一次请求媒体文件前后显示的状态
+const (
+	nowPlayingAwaitingState     = "等待设备请求"
+	nowPlayingTransferringState = "正在传输"
+)
+
+// NowPlaying 当前投屏的播放状态
+type NowPlaying struct {
+	Device   types.DeviceInfo
+	FileName string
+	State    string
+	Position time.Duration
+	Duration time.Duration
+	// AwaitingRequest 设备已接受投屏地址，但还没有向媒体服务器请求文件
+	AwaitingRequest bool
+}
+
+// nowPlayingState 保存当前投屏的控制器和播放状态
+type nowPlayingState struct {
+	mu         sync.RWMutex
+	controller interfaces.DLNAController
+	info       *NowPlaying
+	// firstRequest 设备第一次请求媒体文件时关闭，直接地址投屏时为nil
+	firstRequest <-chan struct{}
+	// statePolled 已经从设备查询到传输状态，此后显示设备报告的状态
+	statePolled bool
+	// onChanged 播放状态变化时的回调，由UI设置
+	onChanged func()
+}
+
+// NowPlaying 获取当前的播放状态，没有正在进行的投屏时第二个返回值为false
+func (app *App) NowPlaying() (NowPlaying, bool) {
+	app.nowPlaying.mu.RLock()
+	defer app.nowPlaying.mu.RUnlock()
+	if app.nowPlaying.info == nil {
+		return NowPlaying{}, false
+	}
+	info := *app.nowPlaying.info
+	if app.nowPlaying.firstRequest != nil {
+		select {
+		case <-app.nowPlaying.firstRequest:
+			if !app.nowPlaying.statePolled {
+				info.State = nowPlayingTransferringState
+			}
+		default:
+			info.State = nowPlayingAwaitingState
+			info.AwaitingRequest = true
+		}
+	}
+	return info, true
+}
+
+// ActiveController 获取当前投屏使用的设备控制器，没有投屏时返回nil
+func (app *App) ActiveController() interfaces.DLNAController {
+	app.nowPlaying.mu.RLock()
+	defer app.nowPlaying.mu.RUnlock()
+	return app.nowPlaying.controller
+}
+
+// SetOnNowPlayingChanged 设置播放状态变化时的回调
+func (app *App) SetOnNowPlayingChanged(callback func()) {
+	app.nowPlaying.mu.Lock()
+	app.nowPlaying.onChanged = callback
+	app.nowPlaying.mu.Unlock()
+}
+
+// startNowPlaying 记录一次成功的投屏
+// firstRequest在设备第一次请求媒体文件时关闭，为nil时无法得知设备是否开始读取
+func (app *App) startNowPlaying(controller interfaces.DLNAController, device types.DeviceInfo, fileName string, firstRequest <-chan struct{}) {
+	app.nowPlaying.mu.Lock()
+	previous := app.nowPlaying.controller
+	app.nowPlaying.controller = controller
+	app.nowPlaying.firstRequest = firstRequest
+	app.nowPlaying.statePolled = false
+	app.nowPlaying.info = &NowPlaying{
+		Device:   device,
+		FileName: fileName,
+		State:    "正在播放",
+	}
+	app.nowPlaying.mu.Unlock()
+	// 上一次投屏的设备不再需要推送状态
+	if previous != nil && previous != controller {
+		releaseTransportEvents(previous)
+	}
+	app.watchTransportEvents(controller)
+	app.preventSleep()
+	app.notifyNowPlayingChanged()
+	go app.pollPlaybackState(controller)
+}
+
+// clearNowPlaying 清除当前的投屏状态
+func (app *App) clearNowPlaying() {
+	app.nowPlaying.mu.Lock()
+	previous := app.nowPlaying.controller
+	app.nowPlaying.controller = nil
+	app.nowPlaying.info = nil
+	app.nowPlaying.firstRequest = nil
+	app.nowPlaying.statePolled = false
+	app.nowPlaying.mu.Unlock()
+	if previous != nil {
+		releaseTransportEvents(previous)
+	}
+	app.allowSleep()
+	app.notifyNowPlayingChanged()
+}
+
+// watchFirstRequest 等待设备第一次向媒体服务器请求mediaFile，返回届时关闭的通道
+// 需要在发送投屏地址之前调用，避免错过设备立即发出的请求
+func (app *App) watchFirstRequest(mediaFile string) <-chan struct{} {
+	firstRequest := make(chan struct{})
+	app.MediaServer.NotifyFirstRequest(mediaFile, func() {
+		close(firstRequest)
+		app.notifyNowPlayingChanged()
+	})
+	return firstRequest
+}
+
+// notifyNowPlayingChanged 通知UI播放状态已变化
+func (app *App) notifyNowPlayingChanged() {
+	app.nowPlaying.mu.RLock()
+	callback := app.nowPlaying.onChanged
+	app.nowPlaying.mu.RUnlock()
+	if callback != nil {
+		callback()
+	}
+}
@@ -0,0 +1,90 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// PausePlayback 暂停当前投屏的播放
+func (app *App) PausePlayback(ctx context.Context) error {
+	controller := app.ActiveController()
+	if controller == nil {
+		return fmt.Errorf("当前没有正在进行的投屏")
+	}
+	ctx, cancel := context.WithTimeout(ctx, castHandshakeTimeout)
+	defer cancel()
+	return controller.PauseWithContext(ctx)
+}
+
+// SeekPlayback 将当前投屏跳转到指定位置
+func (app *App) SeekPlayback(ctx context.Context, position time.Duration) error {
+	controller := app.ActiveController()
+	if controller == nil {
+		return fmt.Errorf("当前没有正在进行的投屏")
+	}
+	ctx, cancel := context.WithTimeout(ctx, castHandshakeTimeout)
+	defer cancel()
+	return controller.SeekWithContext(ctx, position)
+}
+
+// PlaybackVolume 获取当前投屏设备的音量（0-100）
+func (app *App) PlaybackVolume(ctx context.Context) (int, error) {
+	controller := app.ActiveController()
+	if controller == nil {
+		return 0, fmt.Errorf("当前没有正在进行的投屏")
+	}
+	ctx, cancel := context.WithTimeout(ctx, castHandshakeTimeout)
+	defer cancel()
+	return controller.GetVolume(ctx)
+}
+
+// SetPlaybackVolume 设置当前投屏设备的音量（0-100）
+func (app *App) SetPlaybackVolume(ctx context.Context, level int) error {
+	controller := app.ActiveController()
+	if controller == nil {
+		return fmt.Errorf("当前没有正在进行的投屏")
+	}
+	ctx, cancel := context.WithTimeout(ctx, castHandshakeTimeout)
+	defer cancel()
+	return controller.SetVolume(ctx, level)
+}
+
+// PlaybackMuted 获取当前投屏设备是否静音
+func (app *App) PlaybackMuted(ctx context.Context) (bool, error) {
+	controller := app.ActiveController()
+	if controller == nil {
+		return false, fmt.Errorf("当前没有正在进行的投屏")
+	}
+	ctx, cancel := context.WithTimeout(ctx, castHandshakeTimeout)
+	defer cancel()
+	return controller.GetMute(ctx)
+}
+
+// SetPlaybackMuted 设置当前投屏设备静音或取消静音
+func (app *App) SetPlaybackMuted(ctx context.Context, mute bool) error {
+	controller := app.ActiveController()
+	if controller == nil {
+		return fmt.Errorf("当前没有正在进行的投屏")
+	}
+	ctx, cancel := context.WithTimeout(ctx, castHandshakeTimeout)
+	defer cancel()
+	return controller.SetMute(ctx, mute)
+}
+
+// StopCasting 停止当前投屏的播放并清除投屏状态
+// 设备无法停止（例如已经离线）时同样清除投屏状态，并返回错误供界面显示
+func (app *App) StopCasting(ctx context.Context) error {
+	controller := app.ActiveController()
+	if controller == nil {
+		return fmt.Errorf("当前没有正在进行的投屏")
+	}
+	ctx, cancel := context.WithTimeout(ctx, castHandshakeTimeout)
+	defer cancel()
+	err := controller.StopWithContext(ctx)
+	app.clearNowPlaying()
+	if err != nil {
+		return fmt.Errorf("停止投屏失败: %w", err)
+	}
+	return nil
+}
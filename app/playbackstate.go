@@ -0,0 +1,136 @@
+package app
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"GoCastify/dlna"
+	"GoCastify/interfaces"
+)
+
+// playbackPollInterval 查询设备播放状态的间隔
+const playbackPollInterval = 2 * time.Second
+
+// transportInfoReader 能够查询传输状态的设备控制器
+type transportInfoReader interface {
+	GetTransportInfo(ctx context.Context) (dlna.TransportState, error)
+}
+
+// transportEventSource 能够推送设备传输状态变化的设备控制器
+type transportEventSource interface {
+	SetOnTransportStateChanged(callback func(dlna.TransportState))
+	UnsubscribeEvents()
+}
+
+// transportStateNames 传输状态在界面上显示的名称
+var transportStateNames = map[dlna.TransportState]string{
+	dlna.TransportStatePlaying:        "正在播放",
+	dlna.TransportStatePaused:         "已暂停",
+	dlna.TransportStateStopped:        "已停止",
+	dlna.TransportStateTransitioning:  "正在缓冲",
+	dlna.TransportStateNoMediaPresent: "没有媒体",
+}
+
+// watchTransportEvents 将设备推送的传输状态记录为播放状态，比定期查询更及时
+func (app *App) watchTransportEvents(controller interfaces.DLNAController) {
+	if source, ok := controller.(transportEventSource); ok {
+		source.SetOnTransportStateChanged(func(state dlna.TransportState) {
+			app.updateTransportState(controller, state)
+		})
+	}
+}
+
+// releaseTransportEvents 取消不再使用的控制器的事件订阅
+func releaseTransportEvents(controller interfaces.DLNAController) {
+	if source, ok := controller.(transportEventSource); ok {
+		source.SetOnTransportStateChanged(nil)
+		source.UnsubscribeEvents()
+	}
+}
+
+// pollPlaybackState 定期查询设备的传输状态和播放位置并更新播放状态，直到开始了其他投屏或投屏被清除
+// 用户在电视上停止播放或视频播放结束时，状态会变为"已停止"；连续查询失败时只记录第一次
+func (app *App) pollPlaybackState(controller interfaces.DLNAController) {
+	reader, _ := controller.(transportInfoReader)
+
+	ticker := time.NewTicker(playbackPollInterval)
+	defer ticker.Stop()
+	failing := false
+	for range ticker.C {
+		if app.ActiveController() != controller {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), playbackPollInterval)
+		err := app.pollPlaybackOnce(ctx, controller, reader)
+		cancel()
+		if err != nil {
+			if !failing {
+				log.Printf("查询播放状态失败: %v\n", err)
+			}
+			failing = true
+			continue
+		}
+		failing = false
+	}
+}
+
+// pollPlaybackOnce 查询一次设备的传输状态（控制器支持时）和播放位置并记录
+func (app *App) pollPlaybackOnce(ctx context.Context, controller interfaces.DLNAController, reader transportInfoReader) error {
+	if reader != nil {
+		state, err := reader.GetTransportInfo(ctx)
+		if err != nil {
+			return err
+		}
+		app.updateTransportState(controller, state)
+	}
+
+	position, duration, err := controller.GetPositionInfo(ctx)
+	if err != nil {
+		return err
+	}
+	app.updatePosition(controller, position, duration)
+	return nil
+}
+
+// updateTransportState 记录设备报告的传输状态，状态变化时通知UI
+// 期间开始了其他投屏时不做处理
+func (app *App) updateTransportState(controller interfaces.DLNAController, state dlna.TransportState) {
+	name, ok := transportStateNames[state]
+	if !ok {
+		name = string(state)
+	}
+
+	app.nowPlaying.mu.Lock()
+	if app.nowPlaying.controller != controller || app.nowPlaying.info == nil {
+		app.nowPlaying.mu.Unlock()
+		return
+	}
+	changed := !app.nowPlaying.statePolled || app.nowPlaying.info.State != name
+	app.nowPlaying.info.State = name
+	app.nowPlaying.statePolled = true
+	app.nowPlaying.mu.Unlock()
+
+	if changed {
+		app.notifyNowPlayingChanged()
+	}
+}
+
+// updatePosition 记录设备报告的播放位置和时长，变化时通知UI
+// 期间开始了其他投屏时不做处理
+func (app *App) updatePosition(controller interfaces.DLNAController, position, duration time.Duration) {
+	app.nowPlaying.mu.Lock()
+	if app.nowPlaying.controller != controller || app.nowPlaying.info == nil {
+		app.nowPlaying.mu.Unlock()
+		return
+	}
+	changed := app.nowPlaying.info.Position != position || app.nowPlaying.info.Duration != duration
+	app.nowPlaying.info.Position = position
+	app.nowPlaying.info.Duration = duration
+	app.nowPlaying.mu.Unlock()
+
+	if changed {
+		app.notifyNowPlayingChanged()
+	}
+}
@@ -0,0 +1,159 @@
+package app
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/storage"
+
+	"GoCastify/transcoder"
+)
+
+// playlistExtensions 支持导入的播放列表扩展名
+var playlistExtensions = []string{".m3u", ".m3u8"}
+
+// ParsePlaylist 解析m3u/m3u8播放列表，返回其中的文件路径
+// 忽略空行和以#开头的注释（包括#EXTM3U、#EXTINF）；相对路径相对于baseDir解析，
+// file://地址转换为本地路径，其他网络地址原样返回
+func ParsePlaylist(r io.Reader, baseDir string) ([]string, error) {
+	entries := []string{}
+	scanner := bufio.NewScanner(r)
+	for first := true; scanner.Scan(); first = false {
+		line := strings.TrimSpace(scanner.Text())
+		if first {
+			line = strings.TrimPrefix(line, "\ufeff")
+		}
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		entries = append(entries, resolvePlaylistEntry(line, baseDir))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取播放列表失败: %w", err)
+	}
+	return entries, nil
+}
+
+// resolvePlaylistEntry 将播放列表中的一项解析为文件路径或网络地址
+func resolvePlaylistEntry(entry, baseDir string) string {
+	if u, err := url.Parse(entry); err == nil && len(u.Scheme) > 1 {
+		if u.Scheme == "file" {
+			return filepath.FromSlash(u.Path)
+		}
+		return entry
+	}
+	// Windows下生成的播放列表使用反斜杠分隔路径
+	if filepath.Separator == '/' {
+		entry = strings.ReplaceAll(entry, `\`, "/")
+	}
+	entry = filepath.FromSlash(entry)
+	if !filepath.IsAbs(entry) {
+		entry = filepath.Join(baseDir, entry)
+	}
+	return filepath.Clean(entry)
+}
+
+// playableEntries 从播放列表项中选出存在且格式受支持的本地文件
+// 返回可以投屏的文件和跳过的项及原因
+func playableEntries(entries []string) (files, skipped []string) {
+	for _, entry := range entries {
+		if strings.Contains(entry, "://") {
+			skipped = append(skipped, entry+"（不是本地文件）")
+			continue
+		}
+		info, err := os.Stat(entry)
+		switch {
+		case err != nil:
+			skipped = append(skipped, entry+"（文件不存在）")
+		case info.IsDir():
+			skipped = append(skipped, entry+"（是文件夹）")
+		default:
+			if supported, _ := transcoder.IsSupportedFormat(entry); !supported {
+				skipped = append(skipped, entry+"（格式不受支持）")
+				continue
+			}
+			files = append(files, entry)
+		}
+	}
+	return files, skipped
+}
+
+// WritePlaylist 将文件列表写为扩展m3u格式的播放列表，使用绝对路径
+func WritePlaylist(w io.Writer, files []string) error {
+	var b strings.Builder
+	b.WriteString("#EXTM3U\n")
+	for _, file := range files {
+		if abs, err := filepath.Abs(file); err == nil {
+			file = abs
+		}
+		fmt.Fprintf(&b, "#EXTINF:-1,%s\n%s\n", filepath.Base(file), file)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// ShowImportPlaylist 选择m3u/m3u8播放列表并将其中的文件加入投屏队列
+// 不存在或格式不受支持的项会被跳过并提示
+func (app *App) ShowImportPlaylist() {
+	openDialog := dialog.NewFileOpen(func(reader fyne.URIReadCloser, err error) {
+		if err != nil {
+			dialog.ShowError(err, app.Window)
+			return
+		}
+		if reader == nil {
+			return
+		}
+		defer reader.Close()
+
+		playlistPath := reader.URI().Path()
+		entries, err := ParsePlaylist(reader, filepath.Dir(playlistPath))
+		if err != nil {
+			dialog.ShowError(err, app.Window)
+			return
+		}
+		files, skipped := playableEntries(entries)
+		app.AppendToQueue(files...)
+
+		message := fmt.Sprintf("已从 %s 加入 %d 个文件", filepath.Base(playlistPath), len(files))
+		if len(skipped) > 0 {
+			message += fmt.Sprintf("，跳过 %d 项：\n%s", len(skipped), strings.Join(skipped, "\n"))
+		}
+		dialog.ShowInformation("导入播放列表", message, app.Window)
+	}, app.Window)
+	openDialog.SetFilter(storage.NewExtensionFileFilter(playlistExtensions))
+	openDialog.Resize(fyne.NewSize(800, 600))
+	openDialog.Show()
+}
+
+// ShowExportPlaylist 将投屏队列导出为m3u播放列表
+func (app *App) ShowExportPlaylist() {
+	files := app.Queue()
+	if len(files) == 0 {
+		dialog.ShowInformation("导出播放列表", "投屏队列为空", app.Window)
+		return
+	}
+
+	saveDialog := dialog.NewFileSave(func(writer fyne.URIWriteCloser, err error) {
+		if err != nil {
+			dialog.ShowError(err, app.Window)
+			return
+		}
+		if writer == nil {
+			return
+		}
+		defer writer.Close()
+		if err := WritePlaylist(writer, files); err != nil {
+			dialog.ShowError(fmt.Errorf("导出播放列表失败: %w", err), app.Window)
+		}
+	}, app.Window)
+	saveDialog.SetFileName("playlist.m3u")
+	saveDialog.Resize(fyne.NewSize(800, 600))
+	saveDialog.Show()
+}
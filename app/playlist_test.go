@@ -0,0 +1,113 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParsePlaylist(t *testing.T) {
+	baseDir := filepath.Join(string(filepath.Separator), "music", "lists")
+	tests := []struct {
+		name     string
+		playlist string
+		want     []string
+	}{
+		{
+			name:     "comments and blank lines",
+			playlist: "#EXTM3U\n\n#EXTINF:120,Song\nsong.mp3\n  # indented comment\n",
+			want:     []string{filepath.Join(baseDir, "song.mp3")},
+		},
+		{
+			name:     "relative paths",
+			playlist: "../albums/a.flac\nsub/b.mp3\n./c.mp4\n",
+			want: []string{
+				filepath.Join(string(filepath.Separator), "music", "albums", "a.flac"),
+				filepath.Join(baseDir, "sub", "b.mp3"),
+				filepath.Join(baseDir, "c.mp4"),
+			},
+		},
+		{
+			name:     "absolute path and file URL",
+			playlist: "/videos/movie.mkv\nfile:///videos/clip.mp4\n",
+			want:     []string{filepath.FromSlash("/videos/movie.mkv"), filepath.FromSlash("/videos/clip.mp4")},
+		},
+		{
+			name:     "network address kept",
+			playlist: "http://example.com/stream.mp3\n",
+			want:     []string{"http://example.com/stream.mp3"},
+		},
+		{
+			name:     "BOM and CRLF",
+			playlist: "\ufeff#EXTM3U\r\nsong.mp3\r\n",
+			want:     []string{filepath.Join(baseDir, "song.mp3")},
+		},
+		{name: "empty", playlist: "#EXTM3U\n", want: []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParsePlaylist(strings.NewReader(tt.playlist), baseDir)
+			if err != nil {
+				t.Fatalf("ParsePlaylist() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParsePlaylist() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlayableEntriesSkipsMissingAndUnsupported(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"movie.mkv", "clip.mp4", "notes.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(name), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "folder.mp4"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	playlist := "#EXTM3U\nmovie.mkv\nmissing.mp4\nnotes.txt\nfolder.mp4\nhttp://example.com/a.mp4\nclip.mp4\n"
+	entries, err := ParsePlaylist(strings.NewReader(playlist), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, skipped := playableEntries(entries)
+
+	wantFiles := []string{filepath.Join(dir, "movie.mkv"), filepath.Join(dir, "clip.mp4")}
+	if !reflect.DeepEqual(files, wantFiles) {
+		t.Errorf("files = %q, want %q", files, wantFiles)
+	}
+	wantSkipped := []string{
+		filepath.Join(dir, "missing.mp4") + "（文件不存在）",
+		filepath.Join(dir, "notes.txt") + "（格式不受支持）",
+		filepath.Join(dir, "folder.mp4") + "（是文件夹）",
+		"http://example.com/a.mp4（不是本地文件）",
+	}
+	if !reflect.DeepEqual(skipped, wantSkipped) {
+		t.Errorf("skipped = %q, want %q", skipped, wantSkipped)
+	}
+}
+
+func TestWritePlaylistRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	files := []string{filepath.Join(dir, "a.mp4"), filepath.Join(dir, "b c.mkv")}
+
+	var b strings.Builder
+	if err := WritePlaylist(&b, files); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(b.String(), "#EXTM3U\n#EXTINF:-1,a.mp4\n") {
+		t.Errorf("playlist = %q, want an extended m3u header", b.String())
+	}
+	got, err := ParsePlaylist(strings.NewReader(b.String()), t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, files) {
+		t.Errorf("round trip = %q, want %q", got, files)
+	}
+}
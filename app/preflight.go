@@ -0,0 +1,70 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// 媒体地址预检的超时时间和连接失败时的重试间隔
+const (
+	mediaPreflightTimeout       = 5 * time.Second
+	mediaPreflightRetryInterval = 200 * time.Millisecond
+)
+
+// verifyMediaURL 在发送给设备之前，从本机请求一次媒体地址，确认媒体服务器能够提供该文件
+// 先发送HEAD请求，服务器不支持HEAD时退回只请求第一个字节的GET请求
+// 非HTTP地址（file://、smb://）无法从本机验证，直接跳过
+func verifyMediaURL(ctx context.Context, mediaURL string) error {
+	if !strings.HasPrefix(mediaURL, "http://") && !strings.HasPrefix(mediaURL, "https://") {
+		return nil
+	}
+
+	checkCtx, cancel := context.WithTimeout(ctx, mediaPreflightTimeout)
+	defer cancel()
+
+	client := http.Client{}
+
+	// 媒体服务器刚启动时可能尚未开始监听，连接失败时短暂重试
+	status, err := preflightRequest(checkCtx, &client, http.MethodHead, mediaURL)
+	for err != nil && checkCtx.Err() == nil {
+		select {
+		case <-checkCtx.Done():
+		case <-time.After(mediaPreflightRetryInterval):
+			status, err = preflightRequest(checkCtx, &client, http.MethodHead, mediaURL)
+		}
+	}
+	if err == nil && (status == http.StatusMethodNotAllowed || status == http.StatusNotImplemented) {
+		status, err = preflightRequest(checkCtx, &client, http.MethodGet, mediaURL)
+	}
+	if err != nil {
+		return fmt.Errorf("请求媒体地址失败: %w", err)
+	}
+	if status != http.StatusOK && status != http.StatusPartialContent {
+		return fmt.Errorf("媒体地址返回状态码: %d", status)
+	}
+
+	log.Printf("媒体地址预检通过: %s\n", mediaURL)
+	return nil
+}
+
+// preflightRequest 发送预检请求并返回状态码，GET请求只读取第一个字节
+func preflightRequest(ctx context.Context, client *http.Client, method, mediaURL string) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, method, mediaURL, nil)
+	if err != nil {
+		return 0, err
+	}
+	if method == http.MethodGet {
+		req.Header.Set("Range", "bytes=0-0")
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,110 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"GoCastify/transcoder"
+)
+
+// 转码进度估算的稳定阈值
+// 在转码初期进度数据太少，估算的剩余时间波动很大，此时继续显示无限加载动画
+const (
+	minEstimateElapsed = 3 * time.Second
+	minEstimatePercent = 1.0
+)
+
+// transcodeEstimator 根据转码进度和已用时间估算剩余时间和转码速度
+type transcodeEstimator struct {
+	startTime     time.Time
+	mediaDuration time.Duration
+}
+
+// newTranscodeEstimator 创建一个转码进度估算器，mediaDuration未知时传0
+func newTranscodeEstimator(mediaDuration time.Duration) *transcodeEstimator {
+	return &transcodeEstimator{
+		startTime:     time.Now(),
+		mediaDuration: mediaDuration,
+	}
+}
+
+// estimate 根据当前进度百分比估算剩余时间和转码速度(相对于实时播放的倍数)
+// 数据不足以给出稳定估算时ok为false；媒体时长未知时speed为0
+func (e *transcodeEstimator) estimate(percent float64) (eta time.Duration, speed float64, ok bool) {
+	elapsed := time.Since(e.startTime)
+	if percent < minEstimatePercent || elapsed < minEstimateElapsed {
+		return 0, 0, false
+	}
+
+	fraction := percent / 100
+	eta = time.Duration(float64(elapsed) * (1 - fraction) / fraction)
+
+	if e.mediaDuration > 0 {
+		processed := time.Duration(float64(e.mediaDuration) * fraction)
+		speed = processed.Seconds() / elapsed.Seconds()
+	}
+	return eta.Round(time.Second), speed, true
+}
+
+// formatEstimate 格式化剩余时间和转码速度，例如 "剩余约 1m23s · 1.8x"
+func formatEstimate(eta time.Duration, speed float64) string {
+	text := fmt.Sprintf("剩余约 %v", eta)
+	if speed > 0 {
+		text += fmt.Sprintf(" · %.1fx", speed)
+	}
+	return text
+}
+
+// PreTranscodeWithProgress 在投屏前预先完成转码，并在进度对话框中显示进度、剩余时间和转码速度
+// 转码结果写入转码器缓存，之后设备请求时媒体服务器直接提供缓存文件
+// 文件不需要转码或未启用预转码时直接返回；ctx被取消时终止转码
+func (app *App) PreTranscodeWithProgress(ctx context.Context, progress *ProgressDialog) error {
+	// 仅投屏音频时在设备请求时提取音轨，不预先转码视频
+	if !app.PreTranscode || app.AudioOnly || app.Transcoder == nil {
+		return nil
+	}
+	mediaFile := app.MediaFile()
+	// 分辨率上限与投屏时一致，使设备请求时能够命中缓存；此时还没有连接设备，只使用已缓存的设备能力
+	cast := transcoder.CastOptions{StartOffset: app.StartOffset()}
+	if device, ok := app.SelectedDevice(); ok && !app.CastAsIs() {
+		cast.MaxHeight = app.castMaxHeight(ctx, nil, device, mediaFile)
+	}
+	if _, needTranscode := transcoder.IsSupportedFormat(mediaFile); !needTranscode && cast.IsZero() {
+		return nil
+	}
+
+	// 获取媒体时长用于计算转码速度，失败时只显示剩余时间
+	var mediaDuration time.Duration
+	if info, err := app.Transcoder.GetMediaInfoWithContext(ctx, mediaFile); err == nil {
+		if seconds, err := time.ParseDuration(info["duration"] + "s"); err == nil {
+			mediaDuration = seconds - cast.StartOffset
+		}
+	}
+
+	estimator := newTranscodeEstimator(mediaDuration)
+	onProgress := func(percent float64) {
+		if progress == nil {
+			return
+		}
+		eta, speed, ok := estimator.estimate(percent)
+		if !ok {
+			progress.SetIndeterminate("正在转码...")
+			return
+		}
+		progress.SetProgress(percent/100, formatEstimate(eta, speed))
+	}
+
+	log.Printf("开始预转码: %s\n", mediaFile)
+	subtitleIndex, audioIndex := app.trackSelection()
+	_, err := app.Transcoder.TranscodeToMp4ForCastWithContext(ctx, mediaFile, subtitleIndex, audioIndex, cast, onProgress)
+	if err != nil {
+		return fmt.Errorf("预转码失败: %w", err)
+	}
+
+	if progress != nil {
+		progress.SetIndeterminate("正在连接设备...")
+	}
+	return nil
+}
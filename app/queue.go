@@ -0,0 +1,53 @@
+package app
+
+import "sync"
+
+// queueState 保存投屏队列中的媒体文件
+type queueState struct {
+	mu    sync.RWMutex
+	files []string
+	// onChanged 队列变化时的回调，由UI设置
+	onChanged func()
+}
+
+// Queue 获取投屏队列中文件的副本
+func (app *App) Queue() []string {
+	app.queue.mu.RLock()
+	defer app.queue.mu.RUnlock()
+	files := make([]string, len(app.queue.files))
+	copy(files, app.queue.files)
+	return files
+}
+
+// AppendToQueue 将文件依次加入投屏队列末尾
+func (app *App) AppendToQueue(files ...string) {
+	app.queue.mu.Lock()
+	app.queue.files = append(app.queue.files, files...)
+	app.queue.mu.Unlock()
+	app.notifyQueueChanged()
+}
+
+// ClearQueue 清空投屏队列
+func (app *App) ClearQueue() {
+	app.queue.mu.Lock()
+	app.queue.files = nil
+	app.queue.mu.Unlock()
+	app.notifyQueueChanged()
+}
+
+// SetOnQueueChanged 设置投屏队列变化时的回调
+func (app *App) SetOnQueueChanged(callback func()) {
+	app.queue.mu.Lock()
+	app.queue.onChanged = callback
+	app.queue.mu.Unlock()
+}
+
+// notifyQueueChanged 通知UI投屏队列已变化
+func (app *App) notifyQueueChanged() {
+	app.queue.mu.RLock()
+	callback := app.queue.onChanged
+	app.queue.mu.RUnlock()
+	if callback != nil {
+		callback()
+	}
+}
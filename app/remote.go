@@ -0,0 +1,112 @@
+package app
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"time"
+
+	"fyne.io/fyne/v2"
+
+	"GoCastify/server"
+)
+
+// prefRemoteToken 网页遥控器访问令牌在Preferences中的键，令牌在首次启用时生成
+const prefRemoteToken = "remote.token"
+
+// 确保App实现了server.RemoteController接口
+var _ server.RemoteController = (*App)(nil)
+
+// configureRemote 根据设置启用或关闭媒体服务器上的网页遥控器
+func (app *App) configureRemote(enabled bool) {
+	host, ok := app.MediaServer.(configurableServer)
+	if !ok {
+		return
+	}
+	if !enabled {
+		host.SetRemote(nil, "")
+		return
+	}
+
+	var prefs fyne.Preferences
+	if app.FyneApp != nil {
+		prefs = app.FyneApp.Preferences()
+	}
+	token, err := remoteToken(prefs)
+	if err != nil {
+		log.Printf("生成网页遥控器令牌失败: %v\n", err)
+		host.SetRemote(nil, "")
+		return
+	}
+	host.SetRemote(app, token)
+}
+
+// remoteToken 读取保存的网页遥控器令牌，没有时生成一个新的随机令牌并保存
+func remoteToken(prefs fyne.Preferences) (string, error) {
+	if prefs != nil {
+		if token := prefs.String(prefRemoteToken); token != "" {
+			return token, nil
+		}
+	}
+
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("读取随机数失败: %w", err)
+	}
+	token := hex.EncodeToString(buf)
+	if prefs != nil {
+		prefs.SetString(prefRemoteToken, token)
+	}
+	return token, nil
+}
+
+// RemoteURL 返回网页遥控器的地址，未启用时返回空字符串
+// 网页遥控器由媒体服务器提供，只在媒体服务器运行（投屏）期间可以访问
+func (app *App) RemoteURL() string {
+	if host, ok := app.MediaServer.(configurableServer); ok {
+		return host.RemoteURL()
+	}
+	return ""
+}
+
+// RemoteStatus 实现server.RemoteController，返回当前的播放状态
+func (app *App) RemoteStatus() server.RemoteStatus {
+	info, playing := app.NowPlaying()
+	if !playing {
+		return server.RemoteStatus{}
+	}
+	return server.RemoteStatus{
+		Playing:  true,
+		Device:   info.Device.FriendlyName,
+		File:     info.FileName,
+		State:    info.State,
+		Position: info.Position.Seconds(),
+		Duration: info.Duration.Seconds(),
+	}
+}
+
+// RemoteCommand 实现server.RemoteController，执行网页遥控器发送的命令
+func (app *App) RemoteCommand(ctx context.Context, action string, value float64) error {
+	if app.ActiveController() == nil {
+		return fmt.Errorf("当前没有正在进行的投屏")
+	}
+	switch action {
+	case "pause":
+		return app.PausePlayback(ctx)
+	case "stop":
+		return app.StopCasting(ctx)
+	case "seek":
+		// value为目标位置的秒数
+		return app.SeekPlayback(ctx, time.Duration(value*float64(time.Second)))
+	case "volume":
+		// value为0-100的音量
+		return app.SetPlaybackVolume(ctx, int(value))
+	case "play":
+		// 设备控制器尚未提供这些播放控制操作
+		return fmt.Errorf("%w: %s", server.ErrRemoteCommandUnsupported, action)
+	default:
+		return fmt.Errorf("未知的遥控命令: %s", action)
+	}
+}
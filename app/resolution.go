@@ -0,0 +1,64 @@
+package app
+
+import (
+	"context"
+	"log"
+	"strconv"
+
+	"GoCastify/dlna"
+	"GoCastify/interfaces"
+	"GoCastify/types"
+)
+
+// 最大视频高度设置的特殊取值
+const (
+	// MaxVideoHeightAuto 按设备自动决定分辨率上限
+	MaxVideoHeightAuto = 0
+	// MaxVideoHeightNone 不限制分辨率
+	MaxVideoHeightNone = -1
+)
+
+// deviceMaxVideoHeight 推断设备能够播放的最大视频高度，无法确定时返回0
+// 已知型号的限制和设备声明的DLNA配置名称同时存在时取较小者；
+// controller为nil时只使用已缓存的设备能力，不查询设备
+func (app *App) deviceMaxVideoHeight(ctx context.Context, controller interfaces.DLNAController, device types.DeviceInfo) int {
+	limit := dlna.QuirksForDevice(device).MaxVideoHeight
+
+	var sink []dlna.ProtocolInfo
+	var ok bool
+	if controller != nil {
+		sink, ok = app.deviceSinkProtocols(ctx, controller, device)
+	} else {
+		sink, ok = app.capabilities.Get(deviceKey(device))
+	}
+	if ok {
+		if height := dlna.MaxVideoHeight(sink); height > 0 && (limit == 0 || height < limit) {
+			limit = height
+		}
+	}
+	return limit
+}
+
+// castMaxHeight 返回本次投屏转码时使用的最大视频高度，不需要缩小时返回0
+// 按设置使用固定的上限或按设备自动决定；只有源视频的高度确实超过上限时才缩小，
+// 无法获取源视频的分辨率时不缩小
+func (app *App) castMaxHeight(ctx context.Context, controller interfaces.DLNAController, device types.DeviceInfo, mediaFile string) int {
+	limit := app.Settings.MaxVideoHeight
+	if limit == MaxVideoHeightAuto {
+		limit = app.deviceMaxVideoHeight(ctx, controller, device)
+	}
+	if limit <= 0 || app.Transcoder == nil {
+		return 0
+	}
+
+	info, err := app.Transcoder.GetMediaInfo(mediaFile)
+	if err != nil {
+		return 0
+	}
+	height, err := strconv.Atoi(info["height"])
+	if err != nil || height <= limit {
+		return 0
+	}
+	log.Printf("视频高度 %d 超过设备支持的 %d，转码时缩小画面\n", height, limit)
+	return limit
+}
@@ -0,0 +1,93 @@
+package app
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"GoCastify/dlna"
+	"GoCastify/transcoder"
+	"GoCastify/types"
+)
+
+// useFakeProbe 将ffprobe替换为报告指定视频高度的脚本，ffmpeg替换为什么都不做的脚本
+func useFakeProbe(t *testing.T, height string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fake ffprobe script requires a POSIX shell")
+	}
+	dir := t.TempDir()
+	output := `{"streams": [{"index": 0, "codec_type": "video", "codec_name": "hevc", "width": 3840, "height": ` + height + `}], "format": {"duration": "60"}}`
+	scripts := map[string]string{
+		"ffprobe": "#!/bin/sh\ncat <<'EOF'\n" + output + "\nEOF\n",
+		"ffmpeg":  "#!/bin/sh\nexit 0\n",
+	}
+	for name, script := range scripts {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(script), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	transcoder.SetBinaryPaths(filepath.Join(dir, "ffmpeg"), filepath.Join(dir, "ffprobe"))
+	t.Cleanup(func() { transcoder.SetBinaryPaths("", "") })
+}
+
+func TestDeviceMaxVideoHeight(t *testing.T) {
+	xbox := types.DeviceInfo{UDN: "uuid:xbox", Manufacturer: "Microsoft Corporation", ModelName: "Xbox 360"}
+	tv := types.DeviceInfo{UDN: "uuid:tv", Manufacturer: "Example", ModelName: "TV"}
+	tests := []struct {
+		name   string
+		device types.DeviceInfo
+		sink   string
+		want   int
+	}{
+		{name: "known model", device: xbox, want: 1080},
+		{name: "protocolInfo below model limit", device: xbox, sink: "http-get:*:video/mpeg:DLNA.ORG_PN=MPEG_TS_SD_EU", want: 576},
+		{name: "protocolInfo above model limit", device: xbox, sink: "http-get:*:video/mpeg:DLNA.ORG_PN=MPEG_TS_SD_EU,http-get:*:video/mp4:DLNA.ORG_PN=AVC_MP4_MP_HD_AAC", want: 1080},
+		{name: "protocolInfo only", device: tv, sink: "http-get:*:video/mp4:DLNA.ORG_PN=AVC_MP4_MP_HD_AAC", want: 1080},
+		{name: "unknown device", device: tv, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := &App{capabilities: dlna.NewCapabilityCache()}
+			if tt.sink != "" {
+				app.capabilities.Set(deviceKey(tt.device), dlna.ParseProtocolInfoList(tt.sink))
+			}
+			if got := app.deviceMaxVideoHeight(context.Background(), nil, tt.device); got != tt.want {
+				t.Errorf("deviceMaxVideoHeight() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCastMaxHeight(t *testing.T) {
+	xbox := types.DeviceInfo{UDN: "uuid:xbox", Manufacturer: "Microsoft Corporation", ModelName: "Xbox 360"}
+	tests := []struct {
+		name         string
+		setting      int
+		sourceHeight string
+		want         int
+	}{
+		{name: "auto caps 4K on a 1080p device", setting: MaxVideoHeightAuto, sourceHeight: "2160", want: 1080},
+		{name: "auto keeps smaller source", setting: MaxVideoHeightAuto, sourceHeight: "720", want: 0},
+		{name: "manual override", setting: 720, sourceHeight: "1080", want: 720},
+		{name: "no limit", setting: MaxVideoHeightNone, sourceHeight: "2160", want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useFakeProbe(t, tt.sourceHeight)
+			mediaTranscoder, err := transcoder.NewTranscoder(transcoder.DefaultTranscodeSettings())
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { mediaTranscoder.Cleanup() })
+			app := &App{capabilities: dlna.NewCapabilityCache(), Transcoder: mediaTranscoder}
+			app.Settings.MaxVideoHeight = tt.setting
+
+			if got := app.castMaxHeight(context.Background(), nil, xbox, "movie.mkv"); got != tt.want {
+				t.Errorf("castMaxHeight() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"time"
+
+	"GoCastify/dlna"
+)
+
+// 投屏自动重试的默认间隔（秒）
+const defaultCastRetryDelay = 3
+
+// isRetryableCastError 判断投屏错误是否值得自动重试
+// 网络错误、握手超时、设备暂时忙或正在切换状态时重试；
+// 设备不支持投屏、格式不支持、缺少FFmpeg等错误重试也不会成功
+func isRetryableCastError(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	if errors.Is(err, dlna.ErrNotRenderer) || errors.Is(err, dlna.ErrNoAVTransport) {
+		return false
+	}
+
+	var soapErr *dlna.SOAPError
+	if errors.As(err, &soapErr) {
+		return soapErr.Temporary()
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// CastWithRetry 开始投屏，失败且错误可重试时按设置自动重试
+// 重试次数和间隔由Settings.CastRetryAttempts和CastRetryDelay决定，每次重试前在进度对话框中显示尝试次数
+func (app *App) CastWithRetry(ctx context.Context, progress *ProgressDialog) error {
+	retries := app.Settings.CastRetryAttempts
+	delay := time.Duration(app.Settings.CastRetryDelay) * time.Second
+
+	err := app.StartCastingWithContext(ctx, progress)
+	for attempt := 1; attempt <= retries && isRetryableCastError(err); attempt++ {
+		log.Printf("投屏失败，%v后进行第%d次重试: %v\n", delay, attempt, err)
+		if progress != nil {
+			progress.SetIndeterminate(fmt.Sprintf("投屏失败，正在重试（第%d/%d次）...", attempt, retries))
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		err = app.StartCastingWithContext(ctx, progress)
+	}
+	return err
+}
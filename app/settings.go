@@ -0,0 +1,346 @@
+package app
+
+import (
+	"log"
+	"time"
+
+	"fyne.io/fyne/v2"
+
+	"GoCastify/discovery"
+	"GoCastify/dlna"
+	"GoCastify/server"
+	"GoCastify/transcoder"
+)
+
+// 后台清理转码缓存的默认间隔（分钟）
+const defaultCacheCleanupInterval = 30
+
+// 转码缓存的默认大小上限（MB）
+const defaultMaxCacheSize = 10240
+
+// Preferences中保存设置使用的键
+const (
+	prefBurnSubtitles        = "transcode.burnSubtitles"
+	prefSubtitleFontName     = "subtitle.fontName"
+	prefSubtitleFontSize     = "subtitle.fontSize"
+	prefSubtitlePrimaryColor = "subtitle.primaryColor"
+	prefSubtitleOutlineWidth = "subtitle.outlineWidth"
+	prefSubtitleMarginV      = "subtitle.marginV"
+	prefPersistCache         = "transcode.persistCache"
+	prefDirectURLMode        = "cast.directURLMode"
+	prefSMBLocalRoot         = "cast.smbLocalRoot"
+	prefSMBShareURL          = "cast.smbShareURL"
+	prefMaxFrameRate         = "transcode.maxFrameRate"
+	prefExtraFFmpegArgs      = "transcode.extraArgs"
+	prefCastRetryAttempts    = "cast.retryAttempts"
+	prefCastRetryDelay       = "cast.retryDelay"
+	prefCacheCleanupInterval = "transcode.cacheCleanupInterval"
+	prefMaxCacheSize         = "transcode.maxCacheSize"
+	prefRemoteEnabled        = "remote.enabled"
+	prefStreamTranscode      = "transcode.stream"
+	prefPreferredLanguages   = "tracks.preferredLanguages"
+	prefShortMediaURLs       = "server.shortMediaURLs"
+	prefTryOriginalFirst     = "cast.tryOriginalFirst"
+	prefMaxVideoHeight       = "transcode.maxVideoHeight"
+	prefFFmpegPath           = "transcode.ffmpegPath"
+	prefFFprobePath          = "transcode.ffprobePath"
+	prefUploadRateLimit      = "server.uploadRateLimit"
+	prefRateLimitPerConn     = "server.rateLimitPerConnection"
+	prefPreventSleep         = "cast.preventSleep"
+	prefMuxAllSubtitles      = "transcode.muxAllSubtitles"
+	prefKeepMissingDevices   = "discovery.keepMissingDevices"
+	prefBackgroundDiscovery  = "discovery.background"
+	prefSearchTimeout        = "discovery.searchTimeout"
+	prefRenderersOnly        = "discovery.renderersOnly"
+	prefNetworkInterface     = "network.interface"
+	prefVideoEncoder         = "transcode.videoEncoder"
+	prefTranscodeQuality     = "transcode.quality"
+	prefIdleTimeout          = "server.idleTimeout"
+	prefDeviceTimeout        = "cast.deviceTimeout"
+	prefDeviceReadyDelay     = "cast.deviceReadyDelay"
+)
+
+// Settings 用户可配置的应用设置，持久化保存在Fyne Preferences中
+type Settings struct {
+	// BurnSubtitles 将选择的字幕烧录进视频画面
+	BurnSubtitles bool
+	// SubtitleStyle 烧录字幕时的渲染样式
+	SubtitleStyle transcoder.SubtitleStyle
+	// MuxAllSubtitles 不烧录字幕时封装所有文本字幕，选择的字幕为默认，设备可以自行切换
+	MuxAllSubtitles bool
+	// PersistTranscodeCache 跨会话保留转码缓存，源文件未变化时直接复用
+	// 关闭该选项在下次启动后生效
+	PersistTranscodeCache bool
+	// DirectURLMode 绕过媒体服务器直接发送file://或SMB地址
+	DirectURLMode DirectURLMode
+	// SMBLocalRoot 与SMBShareURL对应的本地共享目录
+	SMBLocalRoot string
+	// SMBShareURL 共享目录的SMB地址，例如 "smb://nas/media"
+	SMBShareURL string
+	// MaxFrameRate 转码输出的帧率上限，0表示不限制
+	MaxFrameRate int
+	// ExtraFFmpegArgs 追加到FFmpeg命令行的额外参数，以空白分隔
+	// 这些参数会原样传给FFmpeg，只应填写可信的内容
+	ExtraFFmpegArgs string
+	// CastRetryAttempts 投屏因暂时性错误失败时自动重试的次数，0表示不重试
+	CastRetryAttempts int
+	// CastRetryDelay 自动重试前等待的秒数
+	CastRetryDelay int
+	// DeviceTimeout 获取设备描述和每个SOAP请求的超时（秒），启动DMR应用较慢的电视需要更长的时间
+	DeviceTimeout int
+	// DeviceReadyDelay 设置媒体地址后、发送Play前等待设备准备的时间（毫秒），0表示不等待
+	DeviceReadyDelay int
+	// CacheCleanupInterval 后台清理过期转码缓存的间隔（分钟），0表示只在转码时清理
+	CacheCleanupInterval int
+	// MaxCacheSize 转码缓存的总大小上限（MB），超过时删除最久未使用的缓存，0表示不限制
+	MaxCacheSize int
+	// RemoteEnabled 在媒体服务器上提供网页遥控器，局域网内持有令牌的浏览器可以控制播放
+	RemoteEnabled bool
+	// StreamTranscode 需要转码的文件边转码边播放，不等待转码完成
+	// 流式输出的总大小未知，设备无法跳转
+	StreamTranscode bool
+	// PreferredLanguages 选择默认字幕和音轨时的首选语言，按优先级排列，例如 "zh-CN, zh, en"
+	// 为空时按文件的默认标记和系统语言选择
+	PreferredLanguages string
+	// ShortMediaURLs 使用由文件路径生成的短别名作为媒体地址，不在地址中包含文件名
+	// 用于文件名过长或包含特殊字符时设备拒绝播放的情况
+	ShortMediaURLs bool
+	// TryOriginalFirst 需要转码的文件先让设备尝试播放原文件，设备没有开始播放时再改为转码
+	TryOriginalFirst bool
+	// MaxVideoHeight 转码输出的最大视频高度，超过时按比例缩小
+	// 取值为MaxVideoHeightAuto时按设备声明的格式和已知型号自动决定，MaxVideoHeightNone表示不限制
+	MaxVideoHeight int
+	// FFmpegPath FFmpeg可执行文件的路径，为空时使用系统PATH中的ffmpeg
+	FFmpegPath string
+	// FFprobePath ffprobe可执行文件的路径，为空时使用系统PATH中的ffprobe
+	FFprobePath string
+	// UploadRateLimit 媒体服务器的上传限速（KB/s），0表示不限速
+	UploadRateLimit int
+	// PerConnectionLimit 每个连接单独限速，关闭时所有连接共享同一个总速率
+	PerConnectionLimit bool
+	// IdleTimeout 媒体服务器保持空闲连接的时间（分钟），设备暂停播放时连接可能长时间空闲
+	// 修改后在媒体服务器下次启动时生效
+	IdleTimeout int
+	// PreventSleep 投屏期间阻止系统休眠，避免休眠后媒体服务器停止导致播放中断
+	PreventSleep bool
+	// KeepMissingDevices 再次搜索时保留没有响应的设备并标记为离线，关闭时从列表中移除
+	KeepMissingDevices bool
+	// BackgroundDiscovery 在后台持续监听设备的上线和离开通告，自动更新设备列表
+	BackgroundDiscovery bool
+	// SearchTimeout 点击"搜索设备"后等待设备响应的秒数
+	SearchTimeout int
+	// RenderersOnly 搜索和后台发现只显示提供AVTransport服务的设备，关闭时显示所有UPnP设备
+	RenderersOnly bool
+	// NetworkInterface 设备发现和媒体地址使用的网络接口名称或IPv4地址，为空时自动选择
+	// 有多个网卡时组播可能从错误的接口发出，媒体地址也可能不在设备所在的网络中
+	NetworkInterface string
+	// VideoEncoder 转码使用的视频编码器，为空时优先使用检测到的硬件编码器
+	// 取值见transcoder.VideoEncoderAuto、transcoder.VideoEncoderSoftware和transcoder.HardwareEncoderNames
+	VideoEncoder string
+	// TranscodeQuality 转码画质预设：transcoder.QualityFast、QualityBalanced或QualityHigh
+	TranscodeQuality string
+}
+
+// DefaultSettings 返回默认设置
+func DefaultSettings() Settings {
+	return Settings{
+		SubtitleStyle:        transcoder.DefaultSubtitleStyle(),
+		CastRetryDelay:       defaultCastRetryDelay,
+		DeviceTimeout:        int(dlna.DefaultTimeout / time.Second),
+		DeviceReadyDelay:     int(dlna.DefaultReadyDelay / time.Millisecond),
+		CacheCleanupInterval: defaultCacheCleanupInterval,
+		MaxCacheSize:         defaultMaxCacheSize,
+		PreventSleep:         true,
+		KeepMissingDevices:   true,
+		BackgroundDiscovery:  true,
+		SearchTimeout:        int(discovery.DefaultSearchTimeout / time.Second),
+		RenderersOnly:        true,
+		IdleTimeout:          int(server.DefaultIdleTimeout / time.Minute),
+		TranscodeQuality:     transcoder.QualityFast,
+	}
+}
+
+// LoadSettings 从Preferences中读取设置，缺失的项使用默认值
+func LoadSettings(prefs fyne.Preferences) Settings {
+	defaults := DefaultSettings()
+	if prefs == nil {
+		return defaults
+	}
+
+	return Settings{
+		BurnSubtitles: prefs.BoolWithFallback(prefBurnSubtitles, defaults.BurnSubtitles),
+		SubtitleStyle: transcoder.SubtitleStyle{
+			FontName:     prefs.StringWithFallback(prefSubtitleFontName, defaults.SubtitleStyle.FontName),
+			FontSize:     prefs.IntWithFallback(prefSubtitleFontSize, defaults.SubtitleStyle.FontSize),
+			PrimaryColor: prefs.StringWithFallback(prefSubtitlePrimaryColor, defaults.SubtitleStyle.PrimaryColor),
+			OutlineWidth: prefs.IntWithFallback(prefSubtitleOutlineWidth, defaults.SubtitleStyle.OutlineWidth),
+			MarginV:      prefs.IntWithFallback(prefSubtitleMarginV, defaults.SubtitleStyle.MarginV),
+		},
+		PersistTranscodeCache: prefs.BoolWithFallback(prefPersistCache, defaults.PersistTranscodeCache),
+		DirectURLMode:         DirectURLMode(prefs.StringWithFallback(prefDirectURLMode, string(defaults.DirectURLMode))),
+		SMBLocalRoot:          prefs.StringWithFallback(prefSMBLocalRoot, defaults.SMBLocalRoot),
+		SMBShareURL:           prefs.StringWithFallback(prefSMBShareURL, defaults.SMBShareURL),
+		MaxFrameRate:          prefs.IntWithFallback(prefMaxFrameRate, defaults.MaxFrameRate),
+		ExtraFFmpegArgs:       prefs.StringWithFallback(prefExtraFFmpegArgs, defaults.ExtraFFmpegArgs),
+		CastRetryAttempts:     prefs.IntWithFallback(prefCastRetryAttempts, defaults.CastRetryAttempts),
+		CastRetryDelay:        prefs.IntWithFallback(prefCastRetryDelay, defaults.CastRetryDelay),
+		DeviceTimeout:         prefs.IntWithFallback(prefDeviceTimeout, defaults.DeviceTimeout),
+		DeviceReadyDelay:      prefs.IntWithFallback(prefDeviceReadyDelay, defaults.DeviceReadyDelay),
+		CacheCleanupInterval:  prefs.IntWithFallback(prefCacheCleanupInterval, defaults.CacheCleanupInterval),
+		MaxCacheSize:          prefs.IntWithFallback(prefMaxCacheSize, defaults.MaxCacheSize),
+		RemoteEnabled:         prefs.BoolWithFallback(prefRemoteEnabled, defaults.RemoteEnabled),
+		StreamTranscode:       prefs.BoolWithFallback(prefStreamTranscode, defaults.StreamTranscode),
+		PreferredLanguages:    prefs.StringWithFallback(prefPreferredLanguages, defaults.PreferredLanguages),
+		ShortMediaURLs:        prefs.BoolWithFallback(prefShortMediaURLs, defaults.ShortMediaURLs),
+		TryOriginalFirst:      prefs.BoolWithFallback(prefTryOriginalFirst, defaults.TryOriginalFirst),
+		MaxVideoHeight:        prefs.IntWithFallback(prefMaxVideoHeight, defaults.MaxVideoHeight),
+		FFmpegPath:            prefs.StringWithFallback(prefFFmpegPath, defaults.FFmpegPath),
+		FFprobePath:           prefs.StringWithFallback(prefFFprobePath, defaults.FFprobePath),
+		UploadRateLimit:       prefs.IntWithFallback(prefUploadRateLimit, defaults.UploadRateLimit),
+		PerConnectionLimit:    prefs.BoolWithFallback(prefRateLimitPerConn, defaults.PerConnectionLimit),
+		PreventSleep:          prefs.BoolWithFallback(prefPreventSleep, defaults.PreventSleep),
+		MuxAllSubtitles:       prefs.BoolWithFallback(prefMuxAllSubtitles, defaults.MuxAllSubtitles),
+		KeepMissingDevices:    prefs.BoolWithFallback(prefKeepMissingDevices, defaults.KeepMissingDevices),
+		IdleTimeout:           prefs.IntWithFallback(prefIdleTimeout, defaults.IdleTimeout),
+		BackgroundDiscovery:   prefs.BoolWithFallback(prefBackgroundDiscovery, defaults.BackgroundDiscovery),
+		SearchTimeout:         prefs.IntWithFallback(prefSearchTimeout, defaults.SearchTimeout),
+		RenderersOnly:         prefs.BoolWithFallback(prefRenderersOnly, defaults.RenderersOnly),
+		NetworkInterface:      prefs.StringWithFallback(prefNetworkInterface, defaults.NetworkInterface),
+		VideoEncoder:          prefs.StringWithFallback(prefVideoEncoder, defaults.VideoEncoder),
+		TranscodeQuality:      prefs.StringWithFallback(prefTranscodeQuality, defaults.TranscodeQuality),
+	}
+}
+
+// Save 将设置写入Preferences
+func (s Settings) Save(prefs fyne.Preferences) {
+	if prefs == nil {
+		return
+	}
+
+	prefs.SetBool(prefBurnSubtitles, s.BurnSubtitles)
+	prefs.SetString(prefSubtitleFontName, s.SubtitleStyle.FontName)
+	prefs.SetInt(prefSubtitleFontSize, s.SubtitleStyle.FontSize)
+	prefs.SetString(prefSubtitlePrimaryColor, s.SubtitleStyle.PrimaryColor)
+	prefs.SetInt(prefSubtitleOutlineWidth, s.SubtitleStyle.OutlineWidth)
+	prefs.SetInt(prefSubtitleMarginV, s.SubtitleStyle.MarginV)
+	prefs.SetBool(prefPersistCache, s.PersistTranscodeCache)
+	prefs.SetString(prefDirectURLMode, string(s.DirectURLMode))
+	prefs.SetString(prefSMBLocalRoot, s.SMBLocalRoot)
+	prefs.SetString(prefSMBShareURL, s.SMBShareURL)
+	prefs.SetInt(prefMaxFrameRate, s.MaxFrameRate)
+	prefs.SetString(prefExtraFFmpegArgs, s.ExtraFFmpegArgs)
+	prefs.SetInt(prefCastRetryAttempts, s.CastRetryAttempts)
+	prefs.SetInt(prefCastRetryDelay, s.CastRetryDelay)
+	prefs.SetInt(prefDeviceTimeout, s.DeviceTimeout)
+	prefs.SetInt(prefDeviceReadyDelay, s.DeviceReadyDelay)
+	prefs.SetInt(prefCacheCleanupInterval, s.CacheCleanupInterval)
+	prefs.SetInt(prefMaxCacheSize, s.MaxCacheSize)
+	prefs.SetBool(prefRemoteEnabled, s.RemoteEnabled)
+	prefs.SetBool(prefStreamTranscode, s.StreamTranscode)
+	prefs.SetString(prefPreferredLanguages, s.PreferredLanguages)
+	prefs.SetBool(prefShortMediaURLs, s.ShortMediaURLs)
+	prefs.SetBool(prefTryOriginalFirst, s.TryOriginalFirst)
+	prefs.SetInt(prefMaxVideoHeight, s.MaxVideoHeight)
+	prefs.SetString(prefFFmpegPath, s.FFmpegPath)
+	prefs.SetString(prefFFprobePath, s.FFprobePath)
+	prefs.SetInt(prefUploadRateLimit, s.UploadRateLimit)
+	prefs.SetBool(prefRateLimitPerConn, s.PerConnectionLimit)
+	prefs.SetBool(prefPreventSleep, s.PreventSleep)
+	prefs.SetBool(prefMuxAllSubtitles, s.MuxAllSubtitles)
+	prefs.SetBool(prefKeepMissingDevices, s.KeepMissingDevices)
+	prefs.SetInt(prefIdleTimeout, s.IdleTimeout)
+	prefs.SetBool(prefBackgroundDiscovery, s.BackgroundDiscovery)
+	prefs.SetInt(prefSearchTimeout, s.SearchTimeout)
+	prefs.SetBool(prefRenderersOnly, s.RenderersOnly)
+	prefs.SetString(prefNetworkInterface, s.NetworkInterface)
+	prefs.SetString(prefVideoEncoder, s.VideoEncoder)
+	prefs.SetString(prefTranscodeQuality, s.TranscodeQuality)
+}
+
+// transcodeOptions 根据设置生成转码选项
+func (s Settings) transcodeOptions() transcoder.TranscodeOptions {
+	options := transcoder.DefaultTranscodeOptions()
+	options.BurnSubtitles = s.BurnSubtitles
+	options.SubtitleStyle = s.SubtitleStyle
+	options.MuxAllSubtitles = s.MuxAllSubtitles
+	options.MaxFrameRate = s.MaxFrameRate
+	options.VideoEncoder = s.VideoEncoder
+	options.Quality = transcoder.QualitySettings(s.TranscodeQuality)
+	// 保存的额外参数无效时忽略，避免影响正常转码
+	extraArgs, err := transcoder.ParseExtraArgs(s.ExtraFFmpegArgs)
+	if err != nil {
+		log.Printf("忽略无效的额外FFmpeg参数: %v\n", err)
+	}
+	options.ExtraArgs = extraArgs
+	return options
+}
+
+// DiscoveryOptions 根据设置生成SSDP设备发现器的选项，搜索和后台发现共用
+func (s Settings) DiscoveryOptions() []discovery.SSDPOption {
+	return []discovery.SSDPOption{
+		discovery.WithSearchTimeout(time.Duration(s.SearchTimeout) * time.Second),
+		discovery.WithRenderersOnly(s.RenderersOnly),
+		discovery.WithInterface(s.NetworkInterface),
+	}
+}
+
+// ControllerOptions 根据设置生成投屏时创建设备控制器的选项
+func (s Settings) ControllerOptions() []dlna.ControllerOption {
+	return []dlna.ControllerOption{
+		dlna.WithTimeout(time.Duration(s.DeviceTimeout) * time.Second),
+		dlna.WithReadyDelay(time.Duration(s.DeviceReadyDelay) * time.Millisecond),
+	}
+}
+
+// handshakeTimeout 返回连接设备和SOAP握手各阶段的超时时间
+// 设备超时或准备时间设置得较长时相应延长，避免阶段超时先于单个请求的超时取消握手
+func (s Settings) handshakeTimeout() time.Duration {
+	timeout := 2*time.Duration(s.DeviceTimeout)*time.Second + time.Duration(s.DeviceReadyDelay)*time.Millisecond
+	return max(timeout, castHandshakeTimeout)
+}
+
+// ApplySettings 应用并保存设置，同时更新转码器选项
+func (app *App) ApplySettings(settings Settings) {
+	app.Settings = settings
+	if app.FyneApp != nil {
+		settings.Save(app.FyneApp.Preferences())
+	}
+	// 使用新的可执行文件路径重新检查FFmpeg是否可用
+	transcoder.SetBinaryPaths(settings.FFmpegPath, settings.FFprobePath)
+	app.FFmpegAvailable = transcoder.CheckFFmpeg()
+	if app.Transcoder != nil {
+		app.Transcoder.SetOptions(settings.transcodeOptions())
+		if settings.PersistTranscodeCache {
+			app.enablePersistentCache()
+		}
+		app.Transcoder.SetPreferredLanguages(transcoder.ParseLanguageList(settings.PreferredLanguages))
+		app.Transcoder.StartCacheJanitor(time.Duration(settings.CacheCleanupInterval) * time.Minute)
+		app.Transcoder.SetMaxCacheSize(int64(settings.MaxCacheSize) * 1024 * 1024)
+	}
+	if server, ok := app.MediaServer.(configurableServer); ok {
+		server.SetStreamingTranscode(settings.StreamTranscode)
+		server.SetTryOriginalFirst(settings.TryOriginalFirst)
+		server.SetRateLimit(int64(settings.UploadRateLimit)*1024, settings.PerConnectionLimit)
+		server.SetIdleTimeout(time.Duration(settings.IdleTimeout) * time.Minute)
+		server.SetPreferredInterface(settings.NetworkInterface)
+	}
+	app.configureRemote(settings.RemoteEnabled)
+	app.configureDeviceMonitor(settings)
+	// 投屏期间修改设置时立即生效
+	if _, playing := app.NowPlaying(); playing && settings.PreventSleep {
+		app.preventSleep()
+	} else {
+		app.allowSleep()
+	}
+}
+
+// enablePersistentCache 为转码器启用持久化缓存，失败时继续使用临时缓存
+func (app *App) enablePersistentCache() {
+	dir, err := transcoder.DefaultPersistentCacheDir()
+	if err == nil {
+		err = app.Transcoder.EnablePersistentCache(dir)
+	}
+	if err != nil {
+		log.Printf("启用持久化转码缓存失败: %v\n", err)
+	}
+}
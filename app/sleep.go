@@ -0,0 +1,24 @@
+package app
+
+import "log"
+
+// preventSleep 开始投屏时阻止系统休眠，设置中关闭了该选项时不做任何事
+// 失败时只记录日志，不影响投屏
+func (app *App) preventSleep() {
+	if app.sleepInhibitor == nil || !app.Settings.PreventSleep {
+		return
+	}
+	if err := app.sleepInhibitor.Inhibit("GoCastify正在投屏"); err != nil {
+		log.Printf("阻止系统休眠失败: %v\n", err)
+	}
+}
+
+// allowSleep 投屏结束或应用退出时解除对系统休眠的阻止
+func (app *App) allowSleep() {
+	if app.sleepInhibitor == nil {
+		return
+	}
+	if err := app.sleepInhibitor.Release(); err != nil {
+		log.Printf("解除休眠阻止失败: %v\n", err)
+	}
+}
@@ -0,0 +1,35 @@
+package app
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParseStartOffset 解析用户输入的起始位置，支持 "90"（秒）、"1:30"、"1:02:03" 和带小数的秒数
+// 空字符串表示从头播放，返回0
+func ParseStartOffset(text string) (time.Duration, error) {
+	text = strings.TrimSpace(strings.ReplaceAll(text, "：", ":"))
+	if text == "" {
+		return 0, nil
+	}
+
+	parts := strings.Split(text, ":")
+	if len(parts) > 3 {
+		return 0, fmt.Errorf("起始时间格式无效: %s", text)
+	}
+	var seconds float64
+	for i, part := range parts {
+		value, err := strconv.ParseFloat(part, 64)
+		// 只有最后一段（秒）可以带小数，时和分必须是整数
+		if err != nil || value < 0 || (i < len(parts)-1 && strings.Contains(part, ".")) {
+			return 0, fmt.Errorf("起始时间格式无效: %s", text)
+		}
+		if i > 0 && value >= 60 {
+			return 0, fmt.Errorf("起始时间格式无效: %s（分和秒应小于60）", text)
+		}
+		seconds = seconds*60 + value
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
@@ -0,0 +1,189 @@
+package app
+
+import (
+	"time"
+
+	"GoCastify/types"
+)
+
+// 设备列表、媒体文件和轨道选择会被搜索回调、轨道提取等后台goroutine修改，
+// 同时在UI线程中读取，所有访问都需要通过以下方法并持有stateMu
+
+// Devices 获取已发现设备列表的副本
+func (app *App) Devices() []types.DeviceInfo {
+	app.stateMu.RLock()
+	defer app.stateMu.RUnlock()
+	devices := make([]types.DeviceInfo, len(app.devices))
+	copy(devices, app.devices)
+	return devices
+}
+
+// DeviceCount 获取已发现设备的数量
+func (app *App) DeviceCount() int {
+	app.stateMu.RLock()
+	defer app.stateMu.RUnlock()
+	return len(app.devices)
+}
+
+// Device 获取指定序号的设备，序号无效时第二个返回值为false
+func (app *App) Device(index int) (types.DeviceInfo, bool) {
+	app.stateMu.RLock()
+	defer app.stateMu.RUnlock()
+	if index < 0 || index >= len(app.devices) {
+		return types.DeviceInfo{}, false
+	}
+	return app.devices[index], true
+}
+
+// AddDevice 添加一个发现的设备，返回添加后的设备数量
+func (app *App) AddDevice(device types.DeviceInfo) int {
+	app.stateMu.Lock()
+	defer app.stateMu.Unlock()
+	app.devices = append(app.devices, device)
+	return len(app.devices)
+}
+
+// ClearDevices 清空设备列表和设备选择
+func (app *App) ClearDevices() {
+	app.stateMu.Lock()
+	defer app.stateMu.Unlock()
+	app.devices = []types.DeviceInfo{}
+	app.selectedDeviceIndex = -1
+}
+
+// SelectDevice 选择要投屏的设备
+func (app *App) SelectDevice(index int) {
+	app.stateMu.Lock()
+	defer app.stateMu.Unlock()
+	app.selectedDeviceIndex = index
+}
+
+// SelectedDeviceIndex 获取选择的设备序号，未选择时为-1
+func (app *App) SelectedDeviceIndex() int {
+	app.stateMu.RLock()
+	defer app.stateMu.RUnlock()
+	return app.selectedDeviceIndex
+}
+
+// SelectedDevice 获取选择的设备，未选择或选择无效时第二个返回值为false
+func (app *App) SelectedDevice() (types.DeviceInfo, bool) {
+	app.stateMu.RLock()
+	defer app.stateMu.RUnlock()
+	if app.selectedDeviceIndex < 0 || app.selectedDeviceIndex >= len(app.devices) {
+		return types.DeviceInfo{}, false
+	}
+	return app.devices[app.selectedDeviceIndex], true
+}
+
+// MediaFile 获取要投屏的媒体文件路径
+func (app *App) MediaFile() string {
+	app.stateMu.RLock()
+	defer app.stateMu.RUnlock()
+	return app.mediaFile
+}
+
+// SetMediaFile 设置要投屏的媒体文件，同时清除之前的轨道选择、强制投屏选项和起始位置
+func (app *App) SetMediaFile(filePath string) {
+	app.stateMu.Lock()
+	defer app.stateMu.Unlock()
+	app.mediaFile = filePath
+	app.selectedAudioIndex = -1
+	app.selectedSubtitleIndex = -1
+	app.castAsIs = false
+	app.startOffset = 0
+}
+
+// StartOffset 获取投屏的起始位置，0表示从头播放
+func (app *App) StartOffset() time.Duration {
+	app.stateMu.RLock()
+	defer app.stateMu.RUnlock()
+	return app.startOffset
+}
+
+// SetStartOffset 设置投屏的起始位置，需要转码，媒体服务器提供的文件从该位置开始
+func (app *App) SetStartOffset(offset time.Duration) {
+	app.stateMu.Lock()
+	defer app.stateMu.Unlock()
+	app.startOffset = offset
+}
+
+// CastAsIs 是否对当前文件强制投屏：跳过格式检查和转码，直接提供原文件
+func (app *App) CastAsIs() bool {
+	app.stateMu.RLock()
+	defer app.stateMu.RUnlock()
+	return app.castAsIs
+}
+
+// SetCastAsIs 设置是否对当前文件强制投屏，更换文件后自动关闭
+func (app *App) SetCastAsIs(enabled bool) {
+	app.stateMu.Lock()
+	defer app.stateMu.Unlock()
+	app.castAsIs = enabled
+}
+
+// SelectedAudioIndex 获取选择的音频流索引，-1表示默认音轨
+func (app *App) SelectedAudioIndex() int {
+	app.stateMu.RLock()
+	defer app.stateMu.RUnlock()
+	return app.selectedAudioIndex
+}
+
+// SetSelectedAudioIndex 设置选择的音频流索引
+func (app *App) SetSelectedAudioIndex(index int) {
+	app.stateMu.Lock()
+	defer app.stateMu.Unlock()
+	app.selectedAudioIndex = index
+}
+
+// SelectedSubtitleIndex 获取选择的字幕流索引，-1表示无字幕
+func (app *App) SelectedSubtitleIndex() int {
+	app.stateMu.RLock()
+	defer app.stateMu.RUnlock()
+	return app.selectedSubtitleIndex
+}
+
+// SetSelectedSubtitleIndex 设置选择的字幕流索引
+func (app *App) SetSelectedSubtitleIndex(index int) {
+	app.stateMu.Lock()
+	defer app.stateMu.Unlock()
+	app.selectedSubtitleIndex = index
+}
+
+// trackSelection 同时获取选择的字幕和音频流索引
+func (app *App) trackSelection() (subtitleIndex, audioIndex int) {
+	app.stateMu.RLock()
+	defer app.stateMu.RUnlock()
+	return app.selectedSubtitleIndex, app.selectedAudioIndex
+}
+
+// AudioTracks 获取最近一次提取的音频轨道
+func (app *App) AudioTracks() []types.AudioTrack {
+	app.stateMu.RLock()
+	defer app.stateMu.RUnlock()
+	tracks := make([]types.AudioTrack, len(app.audioTracks))
+	copy(tracks, app.audioTracks)
+	return tracks
+}
+
+// setAudioTracks 保存提取的音频轨道
+func (app *App) setAudioTracks(tracks []types.AudioTrack) {
+	app.stateMu.Lock()
+	defer app.stateMu.Unlock()
+	app.audioTracks = tracks
+}
+
+// SubtitleTracks 获取最近一次提取的字幕轨道
+func (app *App) SubtitleTracks() []types.SubtitleTrack {
+	app.stateMu.RLock()
+	defer app.stateMu.RUnlock()
+	tracks := make([]types.SubtitleTrack, len(app.subtitleTracks))
+	copy(tracks, app.subtitleTracks)
+	return tracks
+}
+
+// setSubtitleTracks 保存提取的字幕轨道
+func (app *App) setSubtitleTracks(tracks []types.SubtitleTrack) {
+	app.stateMu.Lock()
+	defer app.stateMu.Unlock()
+	app.subtitleTracks = tracks
+}
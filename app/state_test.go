@@ -0,0 +1,101 @@
+package app
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"GoCastify/types"
+)
+
+// newStateTestApp 创建只用于测试设备列表和轨道选择的App
+func newStateTestApp() *App {
+	return &App{
+		devices:               []types.DeviceInfo{},
+		selectedDeviceIndex:   -1,
+		subtitleTracks:        []types.SubtitleTrack{},
+		selectedSubtitleIndex: -1,
+		audioTracks:           []types.AudioTrack{},
+		selectedAudioIndex:    -1,
+	}
+}
+
+func testDevice(i int) types.DeviceInfo {
+	return types.DeviceInfo{
+		FriendlyName: fmt.Sprintf("TV %d", i),
+		UDN:          fmt.Sprintf("uuid:tv-%d", i),
+		Location:     fmt.Sprintf("http://192.168.1.%d:8080/desc.xml", i),
+	}
+}
+
+// TestStateConcurrentAccess 模拟后台搜索和轨道提取的同时在UI线程读取状态，需要配合 go test -race 运行
+func TestStateConcurrentAccess(t *testing.T) {
+	app := newStateTestApp()
+	const rounds = 200
+	var wg sync.WaitGroup
+
+	// 设备搜索：合并搜索结果、结束搜索，以及设备离开通知
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for round := 0; round < rounds; round++ {
+			refresh := app.BeginDeviceRefresh()
+			for i := 0; i < 5; i++ {
+				refresh.Add(testDevice((round + i) % 8))
+			}
+			refresh.Finish(true)
+			app.markDeviceLost(testDevice(round % 8).UDN)
+		}
+	}()
+
+	// 轨道提取：切换文件并保存轨道和默认选择
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for round := 0; round < rounds; round++ {
+			app.SetMediaFile(fmt.Sprintf("/videos/movie-%d.mkv", round))
+			app.setAudioTracks([]types.AudioTrack{{Index: 1, Language: "eng"}, {Index: 2, Language: "chi"}})
+			app.setSubtitleTracks([]types.SubtitleTrack{{Index: 3, Language: "chi"}})
+			app.SetSelectedAudioIndex(2)
+			app.SetSelectedSubtitleIndex(3)
+			app.SetStartOffset(0)
+		}
+	}()
+
+	// UI线程：选择设备并读取状态
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for round := 0; round < rounds; round++ {
+			devices := app.Devices()
+			if len(devices) > 0 {
+				app.SelectDevice(round % len(devices))
+			}
+			if device, ok := app.SelectedDevice(); ok && device.UDN == "" {
+				t.Errorf("SelectedDevice() returned an empty device")
+			}
+			for i := 0; i < app.DeviceCount(); i++ {
+				app.Device(i)
+			}
+			app.MediaFile()
+			app.AudioTracks()
+			app.SubtitleTracks()
+			app.trackSelection()
+			app.CastAsIs()
+			app.StartOffset()
+		}
+	}()
+
+	wg.Wait()
+}
+
+func TestDevicesReturnsCopy(t *testing.T) {
+	app := newStateTestApp()
+	app.AddDevice(testDevice(1))
+
+	devices := app.Devices()
+	devices[0].FriendlyName = "changed"
+	if device, _ := app.Device(0); device.FriendlyName != "TV 1" {
+		t.Errorf("modifying the result of Devices() changed the device list: %q", device.FriendlyName)
+	}
+}
@@ -0,0 +1,34 @@
+package app
+
+import (
+	"fmt"
+
+	"GoCastify/transcoder"
+)
+
+// trackProber 获取用于读取轨道信息的转码器
+// 优先使用应用的转码器以复用其轨道信息缓存，未初始化时创建新的实例
+func (app *App) trackProber() (*transcoder.Transcoder, error) {
+	if app.Transcoder != nil {
+		return app.Transcoder, nil
+	}
+	return transcoder.NewTranscoder(transcoder.QualitySettings(app.Settings.TranscodeQuality))
+}
+
+// TrackCounts 获取媒体文件中音频轨道和字幕轨道的数量，字幕数量包括对应的外部字幕文件
+// 结果与选择轨道的对话框共用转码器缓存，之后打开对话框不需要再次探测
+func (app *App) TrackCounts(filePath string) (audioCount, subtitleCount int, err error) {
+	if app.Transcoder == nil || !transcoder.CheckFFmpeg() {
+		return 0, 0, fmt.Errorf("未找到FFmpeg，无法获取轨道信息")
+	}
+	audioTracks, err := app.Transcoder.GetAudioTracks(filePath)
+	if err != nil {
+		return 0, 0, err
+	}
+	subtitleTracks, err := app.Transcoder.GetSubtitleTracks(filePath)
+	if err != nil {
+		return 0, 0, err
+	}
+	// 外部字幕文件同样可以选择
+	return len(audioTracks), len(subtitleTracks) + len(transcoder.FindExternalSubtitles(filePath)), nil
+}
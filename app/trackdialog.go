@@ -0,0 +1,164 @@
+package app
+
+import (
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// 轨道选择对话框相对于主窗口的大小比例
+const trackDialogSizeRatio = 0.8
+
+// trackOption 轨道选择对话框中的一项
+type trackOption struct {
+	// Label 列表中显示的文本，同时用于筛选
+	Label string
+	// Bold 是否以粗体显示（用于默认轨道）
+	Bold bool
+}
+
+// trackFilterEntry 轨道筛选输入框，支持用上下方向键在列表中移动选择
+type trackFilterEntry struct {
+	widget.Entry
+	onUp   func()
+	onDown func()
+}
+
+// newTrackFilterEntry 创建轨道筛选输入框
+func newTrackFilterEntry() *trackFilterEntry {
+	entry := &trackFilterEntry{}
+	entry.ExtendBaseWidget(entry)
+	entry.SetPlaceHolder("按语言或标题筛选")
+	return entry
+}
+
+// TypedKey 处理上下方向键，其余按键交给输入框
+func (e *trackFilterEntry) TypedKey(key *fyne.KeyEvent) {
+	switch key.Name {
+	case fyne.KeyUp:
+		if e.onUp != nil {
+			e.onUp()
+		}
+	case fyne.KeyDown:
+		if e.onDown != nil {
+			e.onDown()
+		}
+	default:
+		e.Entry.TypedKey(key)
+	}
+}
+
+// showTrackDialog 显示可筛选的轨道选择对话框
+// options的第一项为"默认"/"无"选项，始终显示且不参与筛选；
+// 确认后以所选项在options中的序号调用onChosen；返回显示的对话框
+func (app *App) showTrackDialog(title, prompt string, options []trackOption, onChosen func(index int)) dialog.Dialog {
+	// visible 为筛选后显示的项在options中的序号
+	visible := make([]int, len(options))
+	for i := range options {
+		visible[i] = i
+	}
+	selected := -1
+
+	list := widget.NewList(
+		func() int {
+			return len(visible)
+		},
+		func() fyne.CanvasObject {
+			label := widget.NewLabel("")
+			label.Truncation = fyne.TextTruncateEllipsis
+			return label
+		},
+		func(id widget.ListItemID, obj fyne.CanvasObject) {
+			label := obj.(*widget.Label)
+			option := options[visible[id]]
+			label.TextStyle = fyne.TextStyle{Bold: option.Bold}
+			label.SetText(option.Label)
+		},
+	)
+	list.OnSelected = func(id widget.ListItemID) {
+		selected = id
+	}
+	list.OnUnselected = func(id widget.ListItemID) {
+		if selected == id {
+			selected = -1
+		}
+	}
+
+	filter := newTrackFilterEntry()
+	filter.OnChanged = func(text string) {
+		keyword := strings.ToLower(strings.TrimSpace(text))
+		visible = visible[:0]
+		for i, option := range options {
+			if i == 0 || keyword == "" || strings.Contains(strings.ToLower(option.Label), keyword) {
+				visible = append(visible, i)
+			}
+		}
+		list.UnselectAll()
+		list.Refresh()
+	}
+	filter.onUp = func() {
+		if selected > 0 {
+			list.Select(selected - 1)
+		}
+	}
+	filter.onDown = func() {
+		if selected < len(visible)-1 {
+			list.Select(selected + 1)
+		}
+	}
+
+	promptLabel := widget.NewLabel(prompt)
+	promptLabel.TextStyle = fyne.TextStyle{Bold: true}
+
+	// 列表占据对话框的剩余空间，随对话框大小伸缩
+	content := container.NewBorder(
+		container.NewVBox(promptLabel, filter, widget.NewSeparator()),
+		nil, nil, nil,
+		list,
+	)
+
+	var trackDialog dialog.Dialog
+	confirm := func() {
+		// 没有选择时，回车选择筛选结果中的第一个轨道
+		index := selected
+		if index < 0 && len(visible) > 1 && filter.Text != "" {
+			index = 1
+		}
+		if index < 0 {
+			return
+		}
+		onChosen(visible[index])
+		trackDialog.Hide()
+	}
+	filter.OnSubmitted = func(string) {
+		confirm()
+	}
+
+	trackDialog = dialog.NewCustomWithoutButtons(title, content, app.Window)
+	trackDialog.(*dialog.CustomDialog).SetButtons([]fyne.CanvasObject{
+		widget.NewButton("取消", func() {
+			trackDialog.Hide()
+		}),
+		&widget.Button{Text: "确定", Importance: widget.HighImportance, OnTapped: confirm},
+	})
+	trackDialog.Resize(trackDialogSize(app.Window))
+	trackDialog.Show()
+	app.Window.Canvas().Focus(filter)
+	return trackDialog
+}
+
+// trackDialogSize 根据主窗口大小计算轨道选择对话框的大小，不小于默认尺寸
+func trackDialogSize(window fyne.Window) fyne.Size {
+	size := fyne.NewSize(dialogWidth, dialogHeight)
+	canvasSize := window.Canvas().Size()
+	if width := canvasSize.Width * trackDialogSizeRatio; width > size.Width {
+		size.Width = width
+	}
+	if height := canvasSize.Height * trackDialogSizeRatio; height > size.Height {
+		size.Height = height
+	}
+	return size
+}
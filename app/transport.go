@@ -0,0 +1,88 @@
+package app
+
+import (
+	"context"
+	"log"
+	"sync"
+
+	"GoCastify/dlna"
+	"GoCastify/interfaces"
+	"GoCastify/types"
+)
+
+// transportChoices 记住用户为每个设备选择的AVTransport服务，避免每次投屏都询问
+type transportChoices struct {
+	mu      sync.Mutex
+	choices map[string]int
+}
+
+// get 获取设备已选择的服务序号
+func (c *transportChoices) get(key string) (int, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	index, ok := c.choices[key]
+	return index, ok
+}
+
+// set 记住设备选择的服务序号
+func (c *transportChoices) set(key string, index int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.choices == nil {
+		c.choices = make(map[string]int)
+	}
+	c.choices[key] = index
+}
+
+// selectTransport 设备有多个AVTransport服务（如多区域功放）时让用户选择投屏的区域
+// 用户的选择按设备记住；只有一个服务或用户取消选择时使用第一个服务
+func (app *App) selectTransport(ctx context.Context, controller interfaces.DLNAController, device types.DeviceInfo) {
+	dc, ok := controller.(*dlna.DeviceController)
+	if !ok || len(dc.Transports) < 2 {
+		return
+	}
+
+	key := deviceKey(device)
+	index, ok := app.transports.get(key)
+	if !ok {
+		index = app.askTransport(ctx, dc.Transports)
+		if index < 0 {
+			return
+		}
+		app.transports.set(key, index)
+	}
+
+	if err := dc.SelectTransport(index); err != nil {
+		log.Printf("选择AVTransport服务失败，使用第一个服务: %v\n", err)
+		return
+	}
+	log.Printf("使用AVTransport服务: %s\n", dc.Transports[index].Name())
+}
+
+// askTransport 显示AVTransport服务选择对话框并等待用户选择，取消时返回-1
+func (app *App) askTransport(ctx context.Context, transports []dlna.AVTransportService) int {
+	options := make([]trackOption, 0, len(transports))
+	for _, transport := range transports {
+		options = append(options, trackOption{Label: transport.Name()})
+	}
+
+	chosen := make(chan int, 1)
+	transportDialog := app.showTrackDialog("选择投屏区域", "该设备有多个播放区域，请选择要投屏的区域", options, func(index int) {
+		chosen <- index
+	})
+	transportDialog.SetOnClosed(func() {
+		// 确认时onChosen先于关闭回调执行，这里只在取消时生效
+		select {
+		case chosen <- -1:
+		default:
+		}
+	})
+
+	select {
+	case index := <-chosen:
+		return index
+	case <-ctx.Done():
+		transportDialog.Hide()
+		return -1
+	}
+}
@@ -0,0 +1,79 @@
+package app
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	"GoCastify/dlna"
+	"GoCastify/interfaces"
+	"GoCastify/transcoder"
+)
+
+// originalPlaybackCheckDelay 先尝试原文件时，投屏后等待多久检查设备是否在播放
+const originalPlaybackCheckDelay = 10 * time.Second
+
+// transportStateReader 能够查询传输状态的设备控制器
+type transportStateReader interface {
+	GetTransportStateWithContext(ctx context.Context) (string, error)
+}
+
+// triesOriginalFirst 判断本次投屏是否先让设备尝试播放原文件
+// 需要开启该设置，文件需要转码，且没有选择音轨、需要转码进视频的字幕和起始位置
+func (app *App) triesOriginalFirst(mediaFile string, serveOriginal, sidecar bool) bool {
+	if !app.Settings.TryOriginalFirst || serveOriginal || app.AudioOnly || app.StartOffset() > 0 {
+		return false
+	}
+	if _, ok := app.MediaServer.(configurableServer); !ok {
+		return false
+	}
+	subtitleIndex, audioIndex := app.trackSelection()
+	if audioIndex >= 0 || (subtitleIndex >= 0 && !sidecar) {
+		return false
+	}
+	supported, needTranscode := transcoder.IsSupportedFormat(mediaFile)
+	return supported && needTranscode
+}
+
+// withTranscodeHint 在媒体地址后追加transcode=1，要求媒体服务器提供转码后的文件
+func withTranscodeHint(mediaURL string) string {
+	if strings.Contains(mediaURL, "?") {
+		return mediaURL + "&transcode=1"
+	}
+	return mediaURL + "?transcode=1"
+}
+
+// fallBackToTranscode 等待设备开始播放原文件，设备已停止或没有媒体时改为投屏转码后的文件
+// 期间开始了其他投屏时不做处理
+func (app *App) fallBackToTranscode(controller interfaces.DLNAController, transcodeURL string, metadata *dlna.MediaMetadata) {
+	reader, ok := controller.(transportStateReader)
+	if !ok {
+		return
+	}
+	time.Sleep(originalPlaybackCheckDelay)
+	if app.ActiveController() != controller {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), castHandshakeTimeout)
+	defer cancel()
+	state, err := reader.GetTransportStateWithContext(ctx)
+	if err != nil {
+		log.Printf("检查原文件播放状态失败: %v\n", err)
+		return
+	}
+	if state != "STOPPED" && state != "NO_MEDIA_PRESENT" {
+		return
+	}
+
+	log.Printf("设备未能播放原文件（状态: %s），改为提供转码后的文件\n", state)
+	if player, ok := controller.(metadataPlayer); ok && metadata != nil {
+		err = player.PlayMediaWithMetadataContext(ctx, transcodeURL, metadata)
+	} else {
+		err = controller.PlayMediaWithContext(ctx, transcodeURL)
+	}
+	if err != nil {
+		log.Printf("回退到转码播放失败: %v\n", err)
+	}
+}
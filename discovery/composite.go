@@ -0,0 +1,209 @@
+package discovery
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/url"
+	"sync"
+
+	"GoCastify/interfaces"
+	"GoCastify/types"
+)
+
+// CompositeDiscoverer 组合多个设备发现后端
+// 并发执行所有后端的搜索，对结果统一去重后再回调
+// 实现了interfaces.DeviceDiscoverer接口
+type CompositeDiscoverer struct {
+	backends     []interfaces.DeviceDiscoverer
+	devices      []types.DeviceInfo
+	seen         map[string]bool
+	devicesMutex sync.RWMutex
+}
+
+// 确保CompositeDiscoverer实现了interfaces.DeviceDiscoverer接口
+var _ interfaces.DeviceDiscoverer = (*CompositeDiscoverer)(nil)
+
+// NewCompositeDiscoverer 创建一个组合设备发现器
+func NewCompositeDiscoverer(backends ...interfaces.DeviceDiscoverer) *CompositeDiscoverer {
+	return &CompositeDiscoverer{
+		backends: backends,
+		seen:     make(map[string]bool),
+	}
+}
+
+// AddBackend 添加一个设备发现后端
+func (cd *CompositeDiscoverer) AddBackend(backend interfaces.DeviceDiscoverer) {
+	cd.devicesMutex.Lock()
+	defer cd.devicesMutex.Unlock()
+	cd.backends = append(cd.backends, backend)
+}
+
+// StartSearchWithContext 并发启动所有后端的搜索，并合并去重结果
+// 各后端在不同的goroutine中报告设备，onDeviceFound按顺序逐个调用，不会并发执行；
+// 只要有一个后端成功或找到了设备就返回nil
+func (cd *CompositeDiscoverer) StartSearchWithContext(ctx context.Context, onDeviceFound func(types.DeviceInfo)) error {
+	cd.devicesMutex.Lock()
+	cd.devices = []types.DeviceInfo{}
+	cd.seen = make(map[string]bool)
+	backends := make([]interfaces.DeviceDiscoverer, len(cd.backends))
+	copy(backends, cd.backends)
+	cd.devicesMutex.Unlock()
+
+	if len(backends) == 0 {
+		return errors.New("未配置任何设备发现后端")
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(backends))
+	// 串行化回调，调用方不需要自己加锁
+	var callbackMutex sync.Mutex
+
+	for i, backend := range backends {
+		wg.Add(1)
+		go func(i int, backend interfaces.DeviceDiscoverer) {
+			defer wg.Done()
+			errs[i] = backend.StartSearchWithContext(ctx, func(device types.DeviceInfo) {
+				if cd.addDevice(device) && onDeviceFound != nil {
+					callbackMutex.Lock()
+					defer callbackMutex.Unlock()
+					onDeviceFound(device)
+				}
+			})
+			if errs[i] != nil {
+				log.Printf("设备发现后端 %T 搜索失败: %v\n", backend, errs[i])
+			}
+		}(i, backend)
+	}
+	wg.Wait()
+
+	// 将各后端最终的设备列表也合并进来，防止有后端未通过回调报告设备
+	for _, backend := range backends {
+		for _, device := range backend.GetDevices() {
+			cd.addDevice(device)
+		}
+	}
+
+	cd.devicesMutex.RLock()
+	found := len(cd.devices)
+	cd.devicesMutex.RUnlock()
+
+	for _, err := range errs {
+		if err == nil {
+			return nil
+		}
+	}
+	if found > 0 {
+		return nil
+	}
+	return errors.Join(errs...)
+}
+
+// StartMonitor 并发启动所有后端的后台监听，直到ctx取消
+// 同一设备被多个后端报告时只回调一次；设备离开后从合并列表中移除，再次上线时重新报告
+// 设备离开的通知总是转发给onLost，设备可能是在监听之前通过搜索发现的
+// onFound和onLost按顺序逐个调用，不会并发执行；所有后端都无法监听时返回它们的错误
+func (cd *CompositeDiscoverer) StartMonitor(ctx context.Context, onFound func(types.DeviceInfo), onLost func(udn string)) error {
+	cd.devicesMutex.RLock()
+	backends := make([]interfaces.DeviceDiscoverer, len(cd.backends))
+	copy(backends, cd.backends)
+	cd.devicesMutex.RUnlock()
+
+	if len(backends) == 0 {
+		return errors.New("未配置任何设备发现后端")
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(backends))
+	// 串行化回调，调用方不需要自己加锁
+	var callbackMutex sync.Mutex
+
+	for i, backend := range backends {
+		wg.Add(1)
+		go func(i int, backend interfaces.DeviceDiscoverer) {
+			defer wg.Done()
+			errs[i] = backend.StartMonitor(ctx, func(device types.DeviceInfo) {
+				if cd.addDevice(device) && onFound != nil {
+					callbackMutex.Lock()
+					defer callbackMutex.Unlock()
+					onFound(device)
+				}
+			}, func(udn string) {
+				cd.removeDevice(udn)
+				if onLost != nil {
+					callbackMutex.Lock()
+					defer callbackMutex.Unlock()
+					onLost(udn)
+				}
+			})
+			if errs[i] != nil {
+				log.Printf("设备发现后端 %T 后台监听失败: %v\n", backend, errs[i])
+			}
+		}(i, backend)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err == nil {
+			return nil
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// GetDevices 获取所有后端合并后的设备列表
+func (cd *CompositeDiscoverer) GetDevices() []types.DeviceInfo {
+	cd.devicesMutex.RLock()
+	defer cd.devicesMutex.RUnlock()
+
+	// 返回设备列表的副本
+	devicesCopy := make([]types.DeviceInfo, len(cd.devices))
+	copy(devicesCopy, cd.devices)
+	return devicesCopy
+}
+
+// addDevice 将设备加入合并列表，如果是新设备返回true
+func (cd *CompositeDiscoverer) addDevice(device types.DeviceInfo) bool {
+	key := deviceKey(device)
+
+	cd.devicesMutex.Lock()
+	defer cd.devicesMutex.Unlock()
+
+	if cd.seen[key] {
+		return false
+	}
+	cd.seen[key] = true
+	cd.devices = append(cd.devices, device)
+	return true
+}
+
+// removeDevice 从合并列表中移除UDN相同的设备，有设备被移除时返回true
+func (cd *CompositeDiscoverer) removeDevice(udn string) bool {
+	cd.devicesMutex.Lock()
+	defer cd.devicesMutex.Unlock()
+
+	removed := false
+	devices := cd.devices[:0]
+	for _, device := range cd.devices {
+		if device.UDN == udn {
+			delete(cd.seen, deviceKey(device))
+			removed = true
+			continue
+		}
+		devices = append(devices, device)
+	}
+	cd.devices = devices
+	return removed
+}
+
+// deviceKey 生成设备的去重键
+// 优先使用UDN，与removeDevice按UDN移除保持一致；没有UDN时使用描述地址中的host:port，无法解析时退回完整地址
+func deviceKey(device types.DeviceInfo) string {
+	if device.UDN != "" {
+		return device.UDN
+	}
+	if u, err := url.Parse(device.Location); err == nil && u.Host != "" {
+		return u.Host
+	}
+	return device.Location
+}
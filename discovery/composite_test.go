@@ -0,0 +1,180 @@
+package discovery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"GoCastify/types"
+)
+
+// fakeDiscoverer 返回固定设备列表的发现后端
+type fakeDiscoverer struct {
+	devices []types.DeviceInfo
+	err     error
+}
+
+func (f *fakeDiscoverer) StartSearchWithContext(ctx context.Context, onDeviceFound func(types.DeviceInfo)) error {
+	for _, device := range f.devices {
+		onDeviceFound(device)
+	}
+	return f.err
+}
+
+func (f *fakeDiscoverer) StartMonitor(ctx context.Context, onFound func(types.DeviceInfo), onLost func(udn string)) error {
+	return f.err
+}
+
+func (f *fakeDiscoverer) GetDevices() []types.DeviceInfo {
+	return f.devices
+}
+
+func TestDeviceKey(t *testing.T) {
+	tests := []struct {
+		name   string
+		device types.DeviceInfo
+		want   string
+	}{
+		{name: "udn", device: types.DeviceInfo{UDN: "uuid:tv", Location: "http://192.168.1.10:8080/desc.xml"}, want: "uuid:tv"},
+		{name: "host and port", device: types.DeviceInfo{Location: "http://192.168.1.10:8080/desc.xml"}, want: "192.168.1.10:8080"},
+		{name: "unparsable location", device: types.DeviceInfo{Location: "desc.xml"}, want: "desc.xml"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := deviceKey(tt.device); got != tt.want {
+				t.Errorf("deviceKey() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompositeDiscovererMergesBackends(t *testing.T) {
+	tv := types.DeviceInfo{FriendlyName: "TV", UDN: "uuid:tv", Location: "http://192.168.1.10:8080/desc.xml"}
+	// 同一台设备的描述地址随DHCP变化，UDN不变
+	tvMoved := types.DeviceInfo{FriendlyName: "TV", UDN: "uuid:tv", Location: "http://192.168.1.20:8080/desc.xml"}
+	// 同一主机上的两个设备共用host:port，UDN不同
+	speaker := types.DeviceInfo{FriendlyName: "Speaker", UDN: "uuid:speaker", Location: "http://192.168.1.30:49152/speaker.xml"}
+	renderer := types.DeviceInfo{FriendlyName: "Renderer", UDN: "uuid:renderer", Location: "http://192.168.1.30:49152/renderer.xml"}
+	manual := types.DeviceInfo{FriendlyName: "Manual", Location: "http://192.168.1.40:1400/desc.xml"}
+
+	tests := []struct {
+		name     string
+		backends []*fakeDiscoverer
+		want     []string
+		wantErr  bool
+	}{
+		{
+			name: "overlapping devices",
+			backends: []*fakeDiscoverer{
+				{devices: []types.DeviceInfo{tv, speaker}},
+				{devices: []types.DeviceInfo{tvMoved, speaker}},
+			},
+			want: []string{"Speaker", "TV"},
+		},
+		{
+			name: "distinct devices on one host",
+			backends: []*fakeDiscoverer{
+				{devices: []types.DeviceInfo{speaker}},
+				{devices: []types.DeviceInfo{renderer, manual}},
+			},
+			want: []string{"Manual", "Renderer", "Speaker"},
+		},
+		{
+			name: "one backend fails",
+			backends: []*fakeDiscoverer{
+				{err: errors.New("network unreachable")},
+				{devices: []types.DeviceInfo{tv}},
+			},
+			want: []string{"TV"},
+		},
+		{
+			name: "all backends fail",
+			backends: []*fakeDiscoverer{
+				{err: errors.New("network unreachable")},
+				{err: errors.New("timeout")},
+			},
+			want:    []string{},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			composite := NewCompositeDiscoverer()
+			for _, backend := range tt.backends {
+				composite.AddBackend(backend)
+			}
+			var reported atomic.Int32
+			err := composite.StartSearchWithContext(context.Background(), func(types.DeviceInfo) {
+				reported.Add(1)
+			})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("StartSearchWithContext() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			got := []string{}
+			for _, device := range composite.GetDevices() {
+				got = append(got, device.FriendlyName)
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetDevices() = %q, want %q", got, tt.want)
+			}
+			if got := int(reported.Load()); got != len(tt.want) {
+				t.Errorf("onDeviceFound called %d times, want %d", got, len(tt.want))
+			}
+		})
+	}
+}
+
+func TestCompositeDiscovererRemoveDevice(t *testing.T) {
+	composite := NewCompositeDiscoverer()
+	tv := types.DeviceInfo{FriendlyName: "TV", UDN: "uuid:tv", Location: "http://192.168.1.10:8080/desc.xml"}
+	if !composite.addDevice(tv) {
+		t.Fatal("addDevice() rejected a new device")
+	}
+	if !composite.removeDevice("uuid:tv") {
+		t.Fatal("removeDevice() did not remove the device")
+	}
+	// 设备离开后以新地址重新上线时应再次报告
+	tv.Location = "http://192.168.1.20:8080/desc.xml"
+	if !composite.addDevice(tv) {
+		t.Error("addDevice() rejected a device that came back after leaving")
+	}
+	if composite.addDevice(tv) {
+		t.Error("addDevice() accepted the same device twice")
+	}
+}
+
+func TestCompositeDiscovererSerializesCallbacks(t *testing.T) {
+	composite := NewCompositeDiscoverer()
+	for b := 0; b < 4; b++ {
+		backend := &fakeDiscoverer{}
+		for i := 0; i < 5; i++ {
+			backend.devices = append(backend.devices, types.DeviceInfo{UDN: fmt.Sprintf("uuid:tv-%d-%d", b, i)})
+		}
+		composite.AddBackend(backend)
+	}
+
+	// 回调中不加锁地修改状态，并检查是否有其他回调同时在执行
+	var active atomic.Int32
+	reported := 0
+	err := composite.StartSearchWithContext(context.Background(), func(types.DeviceInfo) {
+		if active.Add(1) != 1 {
+			t.Error("onDeviceFound called concurrently")
+		}
+		time.Sleep(time.Millisecond)
+		reported++
+		active.Add(-1)
+	})
+	if err != nil {
+		t.Fatalf("StartSearchWithContext() error = %v", err)
+	}
+	if reported != 20 {
+		t.Errorf("onDeviceFound called %d times, want 20", reported)
+	}
+}
@@ -0,0 +1,72 @@
+package discovery
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"syscall"
+)
+
+// ErrDiscoveryUnavailable 设备发现无法运行：没有可用的网络接口，或无法发送、接收SSDP组播
+// 与搜索正常完成但没有设备响应不同，调用方可以通过errors.Is区分
+var ErrDiscoveryUnavailable = errors.New("设备发现无法运行")
+
+// multicastInterfaceNames 返回已启用、支持组播且有IPv4地址的非回环网络接口名称
+// 与SSDP库选择接口的条件一致
+func multicastInterfaceNames() ([]string, error) {
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+	for _, iface := range interfaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagMulticast == 0 || iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.To4() != nil {
+				names = append(names, iface.Name)
+				break
+			}
+		}
+	}
+	return names, nil
+}
+
+// checkMulticastAvailable 搜索前检查是否有可用于SSDP组播的网络接口
+func checkMulticastAvailable() error {
+	names, err := multicastInterfaceNames()
+	if err != nil {
+		return fmt.Errorf("%w: 无法获取网络接口: %v", ErrDiscoveryUnavailable, err)
+	}
+	if len(names) == 0 {
+		return fmt.Errorf("%w: 没有已连接且支持组播的IPv4网络接口", ErrDiscoveryUnavailable)
+	}
+	return nil
+}
+
+// explainSearchError 将SSDP搜索失败的错误转换为说明可能原因的错误，结果包装ErrDiscoveryUnavailable
+func explainSearchError(err error) error {
+	message := strings.ToLower(err.Error())
+	var reason string
+	switch {
+	case errors.Is(err, os.ErrPermission) || strings.Contains(message, "access permissions"):
+		reason = "发送SSDP组播被拒绝，可能是防火墙阻止了UDP 1900端口"
+	case errors.Is(err, syscall.ENETUNREACH) || errors.Is(err, syscall.EHOSTUNREACH):
+		reason = "网络不可达，当前网络可能不允许组播"
+	case errors.Is(err, syscall.EADDRINUSE):
+		reason = "无法绑定SSDP使用的UDP端口"
+	case strings.Contains(message, "joined to group"):
+		reason = "没有网络接口能够加入SSDP组播组，当前网络可能不支持组播"
+	default:
+		reason = "SSDP搜索失败"
+	}
+	return fmt.Errorf("%w: %s (%v)", ErrDiscoveryUnavailable, reason, err)
+}
@@ -0,0 +1,127 @@
+package discovery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+
+	"GoCastify/dlna"
+	"GoCastify/types"
+)
+
+// commonDescriptionPaths 只输入IP时尝试的常见设备描述地址（端口和路径）
+// 依次为libupnp系渲染器的默认端口、三星电视、Sonos和常见的Linux渲染器
+var commonDescriptionPaths = []struct {
+	port string
+	path string
+}{
+	{"49152", "/description.xml"},
+	{"49153", "/description.xml"},
+	{"9197", "/dmr"},
+	{"1400", "/xml/device_description.xml"},
+	{"49494", "/description.xml"},
+	{"8080", "/description.xml"},
+}
+
+// NormalizeDeviceLocation 规范化用户输入的设备描述地址
+// 没有协议时补上http://，只接受带主机名的http和https地址，
+// 例如 "192.168.1.20:49152/description.xml" 返回 "http://192.168.1.20:49152/description.xml"
+func NormalizeDeviceLocation(input string) (string, error) {
+	location := strings.TrimSpace(input)
+	if location == "" {
+		return "", fmt.Errorf("请输入设备描述地址")
+	}
+	if !strings.Contains(location, "://") {
+		location = "http://" + location
+	}
+	u, err := url.Parse(location)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return "", fmt.Errorf("设备描述地址无效: %s", input)
+	}
+	return u.String(), nil
+}
+
+// ManualDeviceLocations 返回手动添加设备时尝试的描述地址
+// 输入带路径时只返回规范化后的地址；只输入IP或主机名时返回常见端口和路径的地址，
+// 带端口时只在该端口上尝试常见路径
+func ManualDeviceLocations(input string) ([]string, error) {
+	location, err := NormalizeDeviceLocation(input)
+	if err != nil {
+		return nil, err
+	}
+	u, _ := url.Parse(location)
+	if strings.Trim(u.Path, "/") != "" || u.RawQuery != "" {
+		return []string{location}, nil
+	}
+
+	locations := []string{}
+	for _, common := range commonDescriptionPaths {
+		port := common.port
+		if u.Port() != "" {
+			port = u.Port()
+		}
+		candidate := url.URL{Scheme: u.Scheme, Host: net.JoinHostPort(u.Hostname(), port), Path: common.path}
+		locations = appendUnique(locations, candidate.String())
+	}
+	return locations, nil
+}
+
+// DescribeDevice 从设备描述地址获取设备信息，用于手动添加无法通过SSDP发现的设备
+// 有多个地址时并发尝试，按顺序返回第一个提供AVTransport服务的设备；
+// 找到的设备都不能投屏时返回dlna.ErrNotRenderer或dlna.ErrNoAVTransport
+func DescribeDevice(ctx context.Context, locations ...string) (types.DeviceInfo, error) {
+	if len(locations) == 0 {
+		return types.DeviceInfo{}, fmt.Errorf("没有可用的设备描述地址")
+	}
+
+	details := make([]*deviceXML, len(locations))
+	errs := make([]error, len(locations))
+	var wg sync.WaitGroup
+	for i, location := range locations {
+		wg.Add(1)
+		go func(i int, location string) {
+			defer wg.Done()
+			details[i], errs[i] = getDeviceDetailsWithContext(ctx, location, DefaultDetailTimeout)
+		}(i, location)
+	}
+	wg.Wait()
+
+	var renderErr error
+	for i, detail := range details {
+		if detail == nil {
+			continue
+		}
+		if detail.Device.FriendlyName == "" && detail.Device.UDN == "" {
+			errs[i] = fmt.Errorf("%s 不是UPnP设备描述", locations[i])
+			continue
+		}
+		if !hasAVTransport(detail) {
+			if renderErr == nil {
+				renderErr = dlna.ErrNoAVTransport
+				if dlna.IsSourceOnly(types.DeviceInfo{DeviceType: detail.Device.DeviceType}) {
+					renderErr = dlna.ErrNotRenderer
+				}
+			}
+			continue
+		}
+		return newDeviceInfo(detail, locations[i], ""), nil
+	}
+
+	if renderErr != nil {
+		return types.DeviceInfo{}, renderErr
+	}
+	if len(locations) == 1 {
+		return types.DeviceInfo{}, fmt.Errorf("获取设备描述失败: %w", errs[0])
+	}
+	for i, err := range errs {
+		if err != nil {
+			log.Printf("尝试设备描述地址 %s 失败: %v\n", locations[i], err)
+		}
+	}
+	return types.DeviceInfo{}, errors.New("在常见端口上没有找到设备描述，请输入完整的设备描述地址")
+}
@@ -0,0 +1,193 @@
+package discovery
+
+import (
+	"context"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/koron/go-ssdp"
+
+	"GoCastify/types"
+)
+
+// 后台设备发现的参数
+const (
+	// monitorDefaultMaxAge 设备的通告没有max-age时假定的有效期
+	monitorDefaultMaxAge = 30 * time.Minute
+	// monitorSweepInterval 检查设备通告是否过期的间隔
+	monitorSweepInterval = 30 * time.Second
+	// monitorDetailTimeout 获取一个设备详情的超时
+	monitorDetailTimeout = 5 * time.Second
+)
+
+// monitoredDevice 后台发现中已知的设备
+type monitoredDevice struct {
+	location string
+	// expires 设备通告的有效期，期间没有新的alive通告时认为设备已离开
+	expires time.Time
+	// udn 设备描述中的UDN，获取到设备详情并报告给调用方后才有值
+	udn string
+}
+
+// StartMonitor 持续监听设备发出的SSDP NOTIFY通告，直到ctx取消
+// 媒体渲染器发出ssdp:alive时获取设备详情并调用onFound，同一设备的地址不变时只报告一次；
+// 设备发出ssdp:byebye或通告超过max-age没有更新时调用onLost，参数为设备的UDN；
+// 开始监听之前（例如通过搜索）发现的设备发出ssdp:byebye时也会调用onLost
+// 回调在监听的goroutine中执行；无法监听组播时返回说明原因的错误
+func (sd *SSDPDiscoverer) StartMonitor(ctx context.Context, onFound func(types.DeviceInfo), onLost func(udn string)) error {
+	if err := checkMulticastAvailable(); err != nil {
+		return err
+	}
+	if _, err := useInterface(sd.Interface); err != nil {
+		return err
+	}
+
+	var mu sync.Mutex
+	known := make(map[string]*monitoredDevice)
+
+	lost := func(id string) {
+		if onLost != nil {
+			onLost(id)
+		}
+	}
+
+	monitor := &ssdp.Monitor{
+		Alive: func(msg *ssdp.AliveMessage) {
+			if !isRendererAnnouncement(msg.Type) || msg.Location == "" {
+				return
+			}
+			id := deviceIDFromUSN(msg.USN, msg.Location)
+			maxAge := monitorDefaultMaxAge
+			if seconds := msg.MaxAge(); seconds > 0 {
+				maxAge = time.Duration(seconds) * time.Second
+			}
+
+			mu.Lock()
+			device, exists := known[id]
+			if exists && device.location == msg.Location {
+				// 已知设备的续期通告，只更新有效期
+				device.expires = time.Now().Add(maxAge)
+				mu.Unlock()
+				return
+			}
+			known[id] = &monitoredDevice{location: msg.Location, expires: time.Now().Add(maxAge)}
+			mu.Unlock()
+
+			go func() {
+				detailCtx, cancel := context.WithTimeout(ctx, monitorDetailTimeout)
+				defer cancel()
+				detail, location, err := fetchDeviceDetails(detailCtx, []string{msg.Location}, sd.detailTimeout())
+				if err != nil {
+					log.Printf("获取通告设备的详情失败(%s): %v\n", msg.Location, err)
+					// 下一次通告时重试
+					mu.Lock()
+					delete(known, id)
+					mu.Unlock()
+					return
+				}
+				if sd.RenderersOnly && !hasAVTransport(detail) {
+					// 保留在已知设备中，续期通告时不再获取详情
+					return
+				}
+				device := newDeviceInfo(detail, location, msg.Server)
+				sd.rememberDevice(device)
+
+				mu.Lock()
+				if current, ok := known[id]; ok {
+					current.udn = device.UDN
+				}
+				mu.Unlock()
+				if onFound != nil {
+					onFound(device)
+				}
+			}()
+		},
+		Bye: func(msg *ssdp.ByeMessage) {
+			// 设备为每个通告过的类型分别发出ssdp:byebye，只处理设备类型的一条，避免重复报告
+			if !strings.Contains(msg.Type, ":device:MediaRenderer:") {
+				return
+			}
+			id := deviceIDFromUSN(msg.USN, "")
+			udn := id
+			mu.Lock()
+			if device, exists := known[id]; exists && device.udn != "" {
+				udn = device.udn
+			}
+			delete(known, id)
+			mu.Unlock()
+			if udn == "" {
+				return
+			}
+			sd.RemoveDevice(udn)
+			log.Printf("设备已离开: %s\n", udn)
+			lost(udn)
+		},
+	}
+	if err := monitor.Start(); err != nil {
+		return explainSearchError(err)
+	}
+	defer monitor.Close()
+	log.Printf("开始后台监听设备通告\n")
+
+	ticker := time.NewTicker(monitorSweepInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			log.Printf("停止后台监听设备通告\n")
+			return nil
+		case <-ticker.C:
+			now := time.Now()
+			expired := []string{}
+			mu.Lock()
+			for id, device := range known {
+				if now.After(device.expires) {
+					delete(known, id)
+					if device.udn != "" {
+						expired = append(expired, device.udn)
+					}
+				}
+			}
+			mu.Unlock()
+			for _, udn := range expired {
+				log.Printf("设备通告已过期: %s\n", udn)
+				sd.RemoveDevice(udn)
+				lost(udn)
+			}
+		}
+	}
+}
+
+// isRendererAnnouncement 判断通告的类型（NT）是否属于媒体渲染器
+// 同一设备会为根设备、设备类型和每个服务分别通告，只处理渲染器类型和AVTransport服务的通告
+func isRendererAnnouncement(nt string) bool {
+	return strings.Contains(nt, ":device:MediaRenderer:") || strings.Contains(nt, ":service:AVTransport:")
+}
+
+// rememberDevice 将后台发现的设备记录到设备列表，UDN相同的设备更新信息
+func (sd *SSDPDiscoverer) rememberDevice(device types.DeviceInfo) {
+	sd.devicesMutex.Lock()
+	defer sd.devicesMutex.Unlock()
+	for i, existing := range sd.devices {
+		if existing.UDN == device.UDN {
+			sd.devices[i] = device
+			return
+		}
+	}
+	sd.devices = append(sd.devices, device)
+}
+
+// RemoveDevice 从设备列表中移除UDN相同的设备，有设备被移除时返回true
+func (sd *SSDPDiscoverer) RemoveDevice(udn string) bool {
+	sd.devicesMutex.Lock()
+	defer sd.devicesMutex.Unlock()
+	for i, device := range sd.devices {
+		if device.UDN == udn {
+			sd.devices = append(sd.devices[:i], sd.devices[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,71 @@
+package discovery
+
+import (
+	"fmt"
+	"net"
+	"sync"
+
+	"github.com/koron/go-ssdp"
+)
+
+// interfacesMu 保护go-ssdp的全局接口列表ssdp.Interfaces
+// 该列表对所有搜索和监听生效，同时运行的发现器应使用相同的网络接口
+var interfacesMu sync.Mutex
+
+// MulticastInterfaces 返回可用于SSDP组播的网络接口名称，用于让用户选择发现使用的接口
+func MulticastInterfaces() []string {
+	names, err := multicastInterfaceNames()
+	if err != nil {
+		return nil
+	}
+	return names
+}
+
+// ResolveInterface 根据网络接口名称或本机IPv4地址查找网络接口，返回接口和它的IPv4地址
+// 接口必须已启用、支持组播并有IPv4地址
+func ResolveInterface(nameOrIP string) (*net.Interface, net.IP, error) {
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		return nil, nil, fmt.Errorf("获取网络接口失败: %w", err)
+	}
+	wantIP := net.ParseIP(nameOrIP)
+
+	for i := range interfaces {
+		iface := &interfaces[i]
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagMulticast == 0 {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			ipNet, ok := addr.(*net.IPNet)
+			if !ok || ipNet.IP.To4() == nil {
+				continue
+			}
+			if iface.Name == nameOrIP || (wantIP != nil && ipNet.IP.Equal(wantIP)) {
+				return iface, ipNet.IP.To4(), nil
+			}
+		}
+	}
+	return nil, nil, fmt.Errorf("%w: 找不到已启用且支持组播的网络接口 %s", ErrDiscoveryUnavailable, nameOrIP)
+}
+
+// useInterface 让之后的SSDP搜索和监听只使用指定的网络接口，返回M-SEARCH绑定的本地地址
+// nameOrIP为空时使用所有可用的接口，本地地址为空
+func useInterface(nameOrIP string) (string, error) {
+	interfacesMu.Lock()
+	defer interfacesMu.Unlock()
+
+	if nameOrIP == "" {
+		ssdp.Interfaces = nil
+		return "", nil
+	}
+	iface, ip, err := ResolveInterface(nameOrIP)
+	if err != nil {
+		return "", err
+	}
+	ssdp.Interfaces = []net.Interface{*iface}
+	return net.JoinHostPort(ip.String(), "0"), nil
+}
@@ -0,0 +1,66 @@
+package discovery
+
+import "time"
+
+// SSDP搜索的默认超时
+const (
+	// DefaultSearchTimeout 一次设备搜索的总时长，每种设备类型的M-SEARCH等待其中的一半
+	DefaultSearchTimeout = 10 * time.Second
+	// DefaultDetailTimeout 获取一个设备描述的超时
+	DefaultDetailTimeout = 3 * time.Second
+)
+
+// SSDPOption 创建SSDP设备发现器时的可选设置，传给NewSSDPDiscoverer
+type SSDPOption func(*SSDPDiscoverer)
+
+// WithSearchTimeout 设置一次设备搜索的总时长，默认10秒，d不大于0时使用默认值
+// 网络较快时可以缩短，拥塞的网络中设备响应较慢时需要延长
+func WithSearchTimeout(d time.Duration) SSDPOption {
+	return func(sd *SSDPDiscoverer) {
+		if d > 0 {
+			sd.SearchTimeout = d
+		}
+	}
+}
+
+// WithDetailTimeout 设置获取每个设备描述的超时，默认3秒，d不大于0时使用默认值
+func WithDetailTimeout(d time.Duration) SSDPOption {
+	return func(sd *SSDPDiscoverer) {
+		if d > 0 {
+			sd.DetailTimeout = d
+		}
+	}
+}
+
+// WithRenderersOnly 设置是否只报告提供AVTransport服务的设备（媒体渲染器）
+// 开启时路由器、打印机和媒体服务器等无法投屏的设备不会出现在搜索结果中
+func WithRenderersOnly(enabled bool) SSDPOption {
+	return func(sd *SSDPDiscoverer) {
+		sd.RenderersOnly = enabled
+	}
+}
+
+// WithInterface 设置SSDP搜索和监听使用的网络接口，nameOrIP为接口名称（例如 "en0"）或该接口的IPv4地址
+// 有多个网卡（VPN、虚拟机网卡、Wi-Fi和有线同时连接）时，组播可能从错误的接口发出而找不到设备；
+// 为空时使用所有可用的接口
+func WithInterface(nameOrIP string) SSDPOption {
+	return func(sd *SSDPDiscoverer) {
+		sd.Interface = nameOrIP
+	}
+}
+
+// searchTimeout 返回设备搜索的总时长，没有设置时使用默认值
+func (sd *SSDPDiscoverer) searchTimeout() time.Duration {
+	if sd.SearchTimeout > 0 {
+		return sd.SearchTimeout
+	}
+	return DefaultSearchTimeout
+}
+
+// detailTimeout 返回获取设备描述的超时，没有设置时使用默认值
+func (sd *SSDPDiscoverer) detailTimeout() time.Duration {
+	if sd.DetailTimeout > 0 {
+		return sd.DetailTimeout
+	}
+	return DefaultDetailTimeout
+}
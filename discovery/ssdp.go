@@ -3,13 +3,15 @@ package discovery
 import (
 	"context"
 	"encoding/xml"
-	"io"
+	"fmt"
 	"log"
 	"net/http"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/koron/go-ssdp"
+	"GoCastify/dlna"
 	"GoCastify/interfaces"
 	"GoCastify/types"
 )
@@ -20,11 +22,24 @@ import (
 type SSDPDiscoverer struct {
 	devices        []types.DeviceInfo
 	devicesMutex   sync.RWMutex
+	// SearchTimeout 一次设备搜索的总时长，0表示使用DefaultSearchTimeout
+	SearchTimeout time.Duration
+	// DetailTimeout 获取一个设备描述的超时，0表示使用DefaultDetailTimeout
+	DetailTimeout time.Duration
+	// RenderersOnly 只报告设备描述中声明了AVTransport服务的设备
+	RenderersOnly bool
+	// Interface SSDP使用的网络接口名称或IPv4地址，为空时使用所有可用的接口
+	Interface string
 }
 
 // NewSSDPDiscoverer 创建一个新的SSDP设备发现器
-func NewSSDPDiscoverer() interfaces.DeviceDiscoverer {
-	return &SSDPDiscoverer{}
+// opts可以修改搜索和获取设备描述的超时，不指定时使用默认值
+func NewSSDPDiscoverer(opts ...SSDPOption) interfaces.DeviceDiscoverer {
+	sd := &SSDPDiscoverer{}
+	for _, opt := range opts {
+		opt(sd)
+	}
+	return sd
 }
 
 // StartSearchWithContext 开始搜索DLNA设备
@@ -35,7 +50,8 @@ func (sd *SSDPDiscoverer) StartSearchWithContext(ctx context.Context, onDeviceFo
 	sd.devicesMutex.Unlock()
 
 	// 创建一个带超时的上下文
-	timeout := 10 * time.Second
+	timeout := sd.searchTimeout()
+	detailTimeout := sd.detailTimeout()
 	searchCtx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
@@ -44,6 +60,19 @@ func (sd *SSDPDiscoverer) StartSearchWithContext(ctx context.Context, onDeviceFo
 	// 用于跟踪已经尝试获取详细信息的Location URL
 	processedLocations := make(map[string]bool)
 
+	// 没有可用于组播的网络接口时无法搜索，直接返回说明原因的错误
+	if err := checkMulticastAvailable(); err != nil {
+		return err
+	}
+	localAddr, err := useInterface(sd.Interface)
+	if err != nil {
+		return err
+	}
+	// searchErr 最近一次SSDP搜索的错误，succeededSearches 成功执行的搜索次数
+	// 所有搜索都失败时，搜索本身没有运行，需要与没有设备响应区分
+	var searchErr error
+	succeededSearches := 0
+
 	// 定义要搜索的多种设备类型，增加发现成功率
 	deviceTypes := []string{
 		"ssdp:all", // 搜索所有SSDP设备
@@ -58,7 +87,8 @@ func (sd *SSDPDiscoverer) StartSearchWithContext(ctx context.Context, onDeviceFo
 	semaphore := make(chan struct{}, 5) // 限制最多5个并发请求
 
 	// 搜索结果处理函数
-	processResult := func(res ssdp.Service) {
+	// candidates为同一设备通告的所有候选描述地址，依次尝试
+	processResult := func(res ssdp.Service, candidates []string) {
 		defer func() {
 			<-semaphore // 释放信号量
 			wg.Done()
@@ -70,26 +100,27 @@ func (sd *SSDPDiscoverer) StartSearchWithContext(ctx context.Context, onDeviceFo
 		}
 
 		// 创建一个带超时的上下文用于单个设备详情请求
-		detailCtx, cancelDetail := context.WithTimeout(searchCtx, 3*time.Second)
+		detailCtx, cancelDetail := context.WithTimeout(searchCtx, detailTimeout)
 		defer cancelDetail()
 
-		// 获取设备详情
-		detail, err := getDeviceDetailsWithContext(detailCtx, res.Location)
+		// 获取设备详情，失败时重试并尝试其他候选地址
+		detail, location, err := fetchDeviceDetails(detailCtx, candidates, detailTimeout)
 		if err != nil {
 			log.Printf("获取设备详情失败(%s): %v\n", res.Location, err)
 			return
 		}
 
-		// 创建设备信息
-		device := types.DeviceInfo{
-			FriendlyName: detail.Device.FriendlyName,
-			Location:     res.Location,
-			Manufacturer: extractManufacturerFromServer(res.Server),
-			ModelName:    extractModelFromServer(res.Server),
+		// 只报告媒体渲染器时跳过没有AVTransport服务的设备
+		if sd.RenderersOnly && !hasAVTransport(detail) {
+			log.Printf("跳过不是媒体渲染器的设备: %s\n", detail.Device.FriendlyName)
+			return
 		}
 
+		// 创建设备信息
+		device := newDeviceInfo(detail, location, res.Server)
+
 		// 使用UDN作为键进行去重
-		udn := detail.Device.UDN
+		udn := device.UDN
 		resultMutex.Lock()
 		if _, exists := allDevices[udn]; !exists {
 			allDevices[udn] = device
@@ -111,28 +142,57 @@ func (sd *SSDPDiscoverer) StartSearchWithContext(ctx context.Context, onDeviceFo
 
 		log.Printf("开始搜索设备类型: %s，超时时间: %v\n", deviceType, timeout/2)
 
-		// 执行搜索
-		results, err := ssdp.Search(deviceType, int((timeout/2).Seconds()), "")
+		// 执行搜索，上下文取消时立即返回
+		// go-ssdp以整秒等待响应，至少等待1秒
+		results, err := searchWithContext(searchCtx, deviceType, max(int((timeout/2).Seconds()), 1), localAddr)
 		if err != nil {
 			log.Printf("搜索设备类型 %s 失败: %v\n", deviceType, err)
+			if searchCtx.Err() == nil {
+				searchErr = explainSearchError(err)
+			}
 			continue
 		}
+		succeededSearches++
 
-		// 处理每个搜索结果
+		// 按设备分组收集候选描述地址，同一设备可能通告多个地址
+		candidatesByDevice := make(map[string][]string)
+		firstResult := make(map[string]ssdp.Service)
+		deviceOrder := []string{}
 		for _, res := range results {
+			id := deviceIDFromUSN(res.USN, res.Location)
+			if _, exists := firstResult[id]; !exists {
+				firstResult[id] = res
+				deviceOrder = append(deviceOrder, id)
+			}
+			for _, location := range serviceLocations(res) {
+				candidatesByDevice[id] = appendUnique(candidatesByDevice[id], location)
+			}
+		}
+
+		// 处理每个设备
+		for _, id := range deviceOrder {
 			// 避免重复处理同一Location
 			resultMutex.Lock()
-			if processedLocations[res.Location] {
-				resultMutex.Unlock()
-				continue
+			candidates := []string{}
+			for _, location := range candidatesByDevice[id] {
+				if !processedLocations[location] {
+					processedLocations[location] = true
+					candidates = append(candidates, location)
+				}
 			}
-			processedLocations[res.Location] = true
 			resultMutex.Unlock()
+			if len(candidates) == 0 {
+				continue
+			}
 
-			// 等待获取信号量
-			semaphore <- struct{}{}
+			// 等待获取信号量，上下文取消时不再启动新的详情请求
+			select {
+			case semaphore <- struct{}{}:
+			case <-searchCtx.Done():
+				continue
+			}
 			wg.Add(1)
-			go processResult(res)
+			go processResult(firstResult[id], candidates)
 		}
 	}
 
@@ -156,7 +216,10 @@ func (sd *SSDPDiscoverer) StartSearchWithContext(ctx context.Context, onDeviceFo
 		sd.devicesMutex.Lock()
 		sd.devices = devices
 		sd.devicesMutex.Unlock()
-		
+
+		if len(devices) == 0 && succeededSearches == 0 && searchErr != nil {
+			return searchErr
+		}
 		return nil
 	case <-searchCtx.Done():
 		// 如果超时或取消，返回已找到的设备
@@ -174,10 +237,35 @@ func (sd *SSDPDiscoverer) StartSearchWithContext(ctx context.Context, onDeviceFo
 		if len(devices) > 0 {
 			return nil
 		}
+		if succeededSearches == 0 && searchErr != nil {
+			return searchErr
+		}
 		return searchCtx.Err()
 	}
 }
 
+// searchWithContext 执行SSDP搜索，上下文取消时立即返回
+// ssdp.Search本身会阻塞waitSec秒且不支持取消，因此在后台执行并等待其结果或上下文结束
+// localAddr为M-SEARCH绑定的本地地址，为空时由系统选择
+func searchWithContext(ctx context.Context, searchType string, waitSec int, localAddr string) ([]ssdp.Service, error) {
+	type searchResult struct {
+		services []ssdp.Service
+		err      error
+	}
+	resultChan := make(chan searchResult, 1)
+	go func() {
+		services, err := ssdp.Search(searchType, waitSec, localAddr)
+		resultChan <- searchResult{services: services, err: err}
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case result := <-resultChan:
+		return result.services, result.err
+	}
+}
+
 // GetDevices 获取已发现的设备列表
 func (sd *SSDPDiscoverer) GetDevices() []types.DeviceInfo {
 	sd.devicesMutex.RLock()
@@ -192,14 +280,121 @@ func (sd *SSDPDiscoverer) GetDevices() []types.DeviceInfo {
 // 用于解析设备XML描述中的设备信息
 // 简化版结构，只提取我们需要的字段
 type deviceXML struct {
-	Device struct {
-		FriendlyName string `xml:"friendlyName"`
-		UDN          string `xml:"UDN"`
+	URLBase string `xml:"URLBase"`
+	Device  struct {
+		FriendlyName    string `xml:"friendlyName"`
+		DeviceType      string `xml:"deviceType"`
+		UDN             string `xml:"UDN"`
+		Manufacturer    string `xml:"manufacturer"`
+		ModelName       string `xml:"modelName"`
+		ModelNumber     string `xml:"modelNumber"`
+		SerialNumber    string `xml:"serialNumber"`
+		PresentationURL string `xml:"presentationURL"`
+		IconList        struct {
+			Icon []deviceIcon `xml:"icon"`
+		} `xml:"iconList"`
+		ServiceList struct {
+			Service []struct {
+				ServiceType string `xml:"serviceType"`
+			} `xml:"service"`
+		} `xml:"serviceList"`
 	} `xml:"device"`
 }
 
+// deviceIcon 设备描述中的一个图标
+type deviceIcon struct {
+	MimeType string `xml:"mimetype"`
+	Width    int    `xml:"width"`
+	Height   int    `xml:"height"`
+	URL      string `xml:"url"`
+}
+
+// 设备详情获取的重试参数
+const (
+	// 每个候选地址的最大尝试次数（首次请求+1次重试）
+	detailMaxAttempts = 2
+	// 重试前的退避时间
+	detailRetryBackoff = 300 * time.Millisecond
+)
+
+// fetchDeviceDetails 依次尝试所有候选地址获取设备详情
+// 每个地址失败后重试一次，所有尝试都受ctx约束，每次请求的超时为timeout，返回详情和成功的地址
+func fetchDeviceDetails(ctx context.Context, candidates []string, timeout time.Duration) (*deviceXML, string, error) {
+	var lastErr error
+	for _, location := range candidates {
+		for attempt := 1; attempt <= detailMaxAttempts; attempt++ {
+			if attempt > 1 {
+				select {
+				case <-ctx.Done():
+					return nil, "", ctx.Err()
+				case <-time.After(detailRetryBackoff):
+				}
+				log.Printf("重试获取设备详情(第%d次): %s\n", attempt, location)
+			}
+
+			detail, err := getDeviceDetailsWithContext(ctx, location, timeout)
+			if err == nil {
+				return detail, location, nil
+			}
+			lastErr = err
+
+			if ctx.Err() != nil {
+				return nil, "", ctx.Err()
+			}
+		}
+	}
+	if lastErr == nil {
+		lastErr = fmt.Errorf("没有可用的设备描述地址")
+	}
+	return nil, "", lastErr
+}
+
+// deviceIDFromUSN 从USN中提取设备UUID部分，用于将同一设备的多条响应归为一组
+// 例如 "uuid:1234::urn:schemas-upnp-org:device:MediaRenderer:1" 返回 "uuid:1234"
+func deviceIDFromUSN(usn, location string) string {
+	if usn == "" {
+		return location
+	}
+	if i := strings.Index(usn, "::"); i >= 0 {
+		return usn[:i]
+	}
+	return usn
+}
+
+// serviceLocations 返回搜索结果中的所有描述地址
+// 包括LOCATION头以及UPnP规范中可选的AL(备用地址)头，AL格式为 "<url1><url2>"
+func serviceLocations(res ssdp.Service) []string {
+	return descriptionLocations(res.Location, res.Header().Get("AL"))
+}
+
+// descriptionLocations 合并LOCATION地址和AL头中的备用地址，LOCATION排在最前，忽略空项和重复项
+func descriptionLocations(location, alternates string) []string {
+	locations := []string{}
+	if location != "" {
+		locations = append(locations, location)
+	}
+	for _, part := range strings.Split(alternates, ">") {
+		alternate := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(part), "<"))
+		if alternate != "" {
+			locations = appendUnique(locations, alternate)
+		}
+	}
+	return locations
+}
+
+// appendUnique 向切片追加尚不存在的元素
+func appendUnique(list []string, value string) []string {
+	for _, existing := range list {
+		if existing == value {
+			return list
+		}
+	}
+	return append(list, value)
+}
+
 // getDeviceDetailsWithContext 使用带上下文的HTTP请求获取设备详细信息
-func getDeviceDetailsWithContext(ctx context.Context, location string) (*deviceXML, error) {
+// timeout为HTTP请求的超时
+func getDeviceDetailsWithContext(ctx context.Context, location string, timeout time.Duration) (*deviceXML, error) {
 	log.Printf("正在获取设备详情: %s\n", location)
 	
 	// 创建HTTP请求
@@ -208,10 +403,11 @@ func getDeviceDetailsWithContext(ctx context.Context, location string) (*deviceX
 		log.Printf("创建HTTP请求失败: %v\n", err)
 		return nil, err
 	}
+	dlna.AcceptXMLEncodings(req)
 
 	// 设置HTTP请求的超时时间
 	client := http.Client{
-		Timeout: 3 * time.Second, // 明确设置超时时间
+		Timeout: timeout, // 明确设置超时时间
 	}
 	resp, err := client.Do(req)
 	if err != nil {
@@ -221,9 +417,14 @@ func getDeviceDetailsWithContext(ctx context.Context, location string) (*deviceX
 	defer resp.Body.Close()
 
 	log.Printf("获取设备详情成功，状态码: %d\n", resp.StatusCode)
-	
-	// 读取响应体
-	data, err := io.ReadAll(resp.Body)
+
+	// 非200响应视为可重试的失败
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("获取设备详情失败，状态码: %d", resp.StatusCode)
+	}
+
+	// 读取响应体，部分设备返回gzip压缩或带BOM的描述文件
+	data, err := dlna.ReadXMLBody(resp)
 	if err != nil {
 		log.Printf("读取响应体失败: %v\n", err)
 		return nil, err
@@ -241,16 +442,96 @@ func getDeviceDetailsWithContext(ctx context.Context, location string) (*deviceX
 	return &deviceXML, nil
 }
 
-// extractManufacturerFromServer 从Server头中提取制造商信息
+// newDeviceInfo 根据设备描述创建设备信息，设备描述中没有制造商和型号时从Server头中提取
+func newDeviceInfo(detail *deviceXML, location, server string) types.DeviceInfo {
+	device := types.DeviceInfo{
+		FriendlyName:    detail.Device.FriendlyName,
+		Location:        location,
+		Manufacturer:    strings.TrimSpace(detail.Device.Manufacturer),
+		ModelName:       strings.TrimSpace(detail.Device.ModelName),
+		DeviceType:      detail.Device.DeviceType,
+		UDN:             strings.TrimSpace(detail.Device.UDN),
+		ModelNumber:     strings.TrimSpace(detail.Device.ModelNumber),
+		SerialNumber:    strings.TrimSpace(detail.Device.SerialNumber),
+		PresentationURL: dlna.ResolvePresentationURL(location, detail.Device.PresentationURL),
+	}
+	if icon, ok := largestIcon(detail.Device.IconList.Icon); ok {
+		device.IconURL = dlna.ResolveDescriptionURL(location, detail.URLBase, icon.URL)
+	}
+	if device.Manufacturer == "" {
+		device.Manufacturer = extractManufacturerFromServer(server)
+	}
+	if device.ModelName == "" {
+		device.ModelName = extractModelFromServer(server)
+	}
+	return device
+}
+
+// hasAVTransport 判断设备描述中是否声明了AVTransport服务，只检查根设备的服务列表
+func hasAVTransport(detail *deviceXML) bool {
+	for _, service := range detail.Device.ServiceList.Service {
+		if strings.Contains(service.ServiceType, "AVTransport") {
+			return true
+		}
+	}
+	return false
+}
+
+// largestIcon 返回尺寸最大的PNG或JPEG图标，没有这类图标时第二个返回值为false
+func largestIcon(icons []deviceIcon) (deviceIcon, bool) {
+	best := deviceIcon{}
+	found := false
+	for _, icon := range icons {
+		mimeType := strings.ToLower(strings.TrimSpace(icon.MimeType))
+		if strings.TrimSpace(icon.URL) == "" || (mimeType != "image/png" && mimeType != "image/jpeg" && mimeType != "image/jpg") {
+			continue
+		}
+		if !found || icon.Width*icon.Height > best.Width*best.Height {
+			best = icon
+			found = true
+		}
+	}
+	return best, found
+}
+
+// extractManufacturerFromServer 从Server头中提取制造商信息，无法提取时返回空字符串
+// 例如 "Linux/3.10 UPnP/1.0 Sony-BDP/2.0" 返回 "Sony"
 func extractManufacturerFromServer(server string) string {
-	// 简化实现，实际项目中可能需要更复杂的解析逻辑
-	return "Unknown"
+	manufacturer, _ := parseServerProduct(server)
+	return manufacturer
 }
 
-// extractModelFromServer 从Server头中提取型号信息
+// extractModelFromServer 从Server头中提取型号信息，无法提取时返回空字符串
+// 例如 "Linux/3.10 UPnP/1.0 Sony-BDP/2.0" 返回 "BDP"
 func extractModelFromServer(server string) string {
-	// 简化实现，实际项目中可能需要更复杂的解析逻辑
-	return "Unknown"
+	_, model := parseServerProduct(server)
+	return model
+}
+
+// parseServerProduct 解析Server头中的产品标记
+// Server头的格式为 "<操作系统>/<版本> UPnP/<版本> <产品>/<版本>"，部分设备用逗号分隔；
+// 产品名称中的 "-" 或 "_" 之前为制造商，之后为型号，没有分隔符时整个名称作为制造商
+func parseServerProduct(server string) (manufacturer, model string) {
+	tokens := strings.FieldsFunc(server, func(r rune) bool {
+		return r == ' ' || r == ','
+	})
+	afterUPnP := false
+	for _, token := range tokens {
+		name, _, _ := strings.Cut(token, "/")
+		if strings.EqualFold(name, "UPnP") {
+			afterUPnP = true
+			continue
+		}
+		// DLNADOC标记声明的是DLNA版本，不是产品
+		if !afterUPnP || strings.EqualFold(name, "DLNADOC") || name == "" {
+			continue
+		}
+		if i := strings.IndexAny(name, "-_"); i > 0 {
+			return name[:i], name[i+1:]
+		}
+		return name, ""
+	}
+	return "", ""
 }
 
 // min 返回两个整数中的较小值
@@ -0,0 +1,232 @@
+package discovery
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"GoCastify/types"
+)
+
+const testDescription = `<?xml version="1.0"?>
+<root xmlns="urn:schemas-upnp-org:device-1-0">
+  <device>
+    <deviceType>urn:schemas-upnp-org:device:MediaRenderer:1</deviceType>
+    <friendlyName>Living Room TV</friendlyName>
+    <UDN>uuid:living-room</UDN>
+  </device>
+</root>`
+
+func TestDescriptionLocations(t *testing.T) {
+	tests := []struct {
+		name       string
+		location   string
+		alternates string
+		want       []string
+	}{
+		{name: "location only", location: "http://10.0.0.2/desc.xml", want: []string{"http://10.0.0.2/desc.xml"}},
+		{
+			name:       "alternate locations",
+			location:   "http://10.0.0.2/desc.xml",
+			alternates: "<http://192.168.1.2/desc.xml><http://[fe80::1]/desc.xml>",
+			want:       []string{"http://10.0.0.2/desc.xml", "http://192.168.1.2/desc.xml", "http://[fe80::1]/desc.xml"},
+		},
+		{
+			name:       "duplicate and blank alternates",
+			location:   "http://10.0.0.2/desc.xml",
+			alternates: " <http://10.0.0.2/desc.xml> <> <http://192.168.1.2/desc.xml> ",
+			want:       []string{"http://10.0.0.2/desc.xml", "http://192.168.1.2/desc.xml"},
+		},
+		{name: "alternates without location", alternates: "<http://192.168.1.2/desc.xml>", want: []string{"http://192.168.1.2/desc.xml"}},
+		{name: "nothing", want: []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := descriptionLocations(tt.location, tt.alternates)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("descriptionLocations() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// flakyDescriptionServer 前failures次请求返回500，之后返回设备描述
+func flakyDescriptionServer(t *testing.T, failures int32) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) <= failures {
+			http.Error(w, "busy", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/xml")
+		w.Write([]byte(testDescription))
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func TestFetchDeviceDetailsRetries(t *testing.T) {
+	tests := []struct {
+		name         string
+		failures     int32
+		wantErr      bool
+		wantRequests int32
+	}{
+		{name: "first attempt succeeds", failures: 0, wantRequests: 1},
+		{name: "fails once then succeeds", failures: 1, wantRequests: 2},
+		{name: "fails every attempt", failures: detailMaxAttempts, wantErr: true, wantRequests: detailMaxAttempts},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, requests := flakyDescriptionServer(t, tt.failures)
+			detail, location, err := fetchDeviceDetails(context.Background(), []string{server.URL}, time.Second)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("fetchDeviceDetails() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := requests.Load(); got != tt.wantRequests {
+				t.Errorf("requests = %d, want %d", got, tt.wantRequests)
+			}
+			if tt.wantErr {
+				return
+			}
+			if detail.Device.UDN != "uuid:living-room" || location != server.URL {
+				t.Errorf("fetchDeviceDetails() = %q from %q, want uuid:living-room from %q", detail.Device.UDN, location, server.URL)
+			}
+		})
+	}
+}
+
+func TestFetchDeviceDetailsFallsBackToAlternateLocation(t *testing.T) {
+	broken, brokenRequests := flakyDescriptionServer(t, detailMaxAttempts)
+	working, _ := flakyDescriptionServer(t, 0)
+
+	_, location, err := fetchDeviceDetails(context.Background(), []string{broken.URL, working.URL}, time.Second)
+	if err != nil {
+		t.Fatalf("fetchDeviceDetails() error = %v", err)
+	}
+	if location != working.URL {
+		t.Errorf("location = %q, want %q", location, working.URL)
+	}
+	if got := brokenRequests.Load(); got != detailMaxAttempts {
+		t.Errorf("requests to the broken location = %d, want %d", got, detailMaxAttempts)
+	}
+}
+
+func TestFetchDeviceDetailsStopsWhenContextDone(t *testing.T) {
+	server, requests := flakyDescriptionServer(t, detailMaxAttempts)
+	ctx, cancel := context.WithTimeout(context.Background(), detailRetryBackoff/3)
+	defer cancel()
+
+	if _, _, err := fetchDeviceDetails(ctx, []string{server.URL, server.URL + "/other"}, time.Second); err != context.DeadlineExceeded {
+		t.Fatalf("fetchDeviceDetails() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if got := requests.Load(); got != 1 {
+		t.Errorf("requests = %d, want 1 before the context expired", got)
+	}
+}
+
+func TestGetDeviceDetailsGzipAndBOM(t *testing.T) {
+	withBOM := append([]byte{0xEF, 0xBB, 0xBF}, []byte(testDescription)...)
+	var compressed bytes.Buffer
+	writer := gzip.NewWriter(&compressed)
+	writer.Write(withBOM)
+	writer.Close()
+
+	tests := []struct {
+		name            string
+		contentEncoding string
+		body            []byte
+	}{
+		{name: "BOM", body: withBOM},
+		{name: "gzip", contentEncoding: "gzip", body: compressed.Bytes()},
+		{name: "gzip without header", body: compressed.Bytes()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.contentEncoding != "" {
+					w.Header().Set("Content-Encoding", tt.contentEncoding)
+				}
+				w.Write(tt.body)
+			}))
+			defer server.Close()
+
+			detail, err := getDeviceDetailsWithContext(context.Background(), server.URL, time.Second)
+			if err != nil {
+				t.Fatalf("getDeviceDetailsWithContext() error = %v", err)
+			}
+			if detail.Device.FriendlyName != "Living Room TV" {
+				t.Errorf("FriendlyName = %q, want Living Room TV", detail.Device.FriendlyName)
+			}
+		})
+	}
+}
+
+// metadataDescription 包含序列号、型号编号和网页管理地址的设备描述
+const metadataDescription = `<?xml version="1.0"?>
+<root xmlns="urn:schemas-upnp-org:device-1-0">
+  <device>
+    <deviceType>urn:schemas-upnp-org:device:MediaRenderer:1</deviceType>
+    <friendlyName>Bedroom TV</friendlyName>
+    <manufacturer> Example Corp </manufacturer>
+    <modelName>Example 55</modelName>
+    <modelNumber> EX-55A </modelNumber>
+    <serialNumber>SN123456</serialNumber>
+    <UDN> uuid:bedroom-tv </UDN>
+    <presentationURL>/web/index.html</presentationURL>
+  </device>
+</root>`
+
+func TestNewDeviceInfoParsesMetadata(t *testing.T) {
+	tests := []struct {
+		name        string
+		description string
+		server      string
+		want        types.DeviceInfo
+	}{
+		{
+			name:        "full description",
+			description: metadataDescription,
+			want: types.DeviceInfo{
+				FriendlyName:    "Bedroom TV",
+				Location:        "http://192.168.1.20:8080/desc.xml",
+				Manufacturer:    "Example Corp",
+				ModelName:       "Example 55",
+				DeviceType:      "urn:schemas-upnp-org:device:MediaRenderer:1",
+				UDN:             "uuid:bedroom-tv",
+				ModelNumber:     "EX-55A",
+				SerialNumber:    "SN123456",
+				PresentationURL: "http://192.168.1.20:8080/web/index.html",
+			},
+		},
+		{
+			name:        "missing metadata",
+			description: testDescription,
+			want: types.DeviceInfo{
+				FriendlyName: "Living Room TV",
+				Location:     "http://192.168.1.20:8080/desc.xml",
+				DeviceType:   "urn:schemas-upnp-org:device:MediaRenderer:1",
+				UDN:          "uuid:living-room",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var detail deviceXML
+			if err := xml.Unmarshal([]byte(tt.description), &detail); err != nil {
+				t.Fatal(err)
+			}
+			if got := newDeviceInfo(&detail, "http://192.168.1.20:8080/desc.xml", tt.server); got != tt.want {
+				t.Errorf("newDeviceInfo() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
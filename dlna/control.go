@@ -4,11 +4,15 @@ import (
 	"bytes"
 	"context"
 	"encoding/xml"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"net/url"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"GoCastify/interfaces"
@@ -19,9 +23,9 @@ import (
 const (
 	// UPnP服务类型
 	uPNPAVTransportService = "urn:schemas-upnp-org:service:AVTransport:1"
-	// 默认HTTP请求超时
+	// 默认HTTP请求超时，可以通过WithTimeout修改
 	defaultHTTPTimeout = 5 * time.Second
-	// 设备准备播放所需的延迟时间
+	// 设备准备播放所需的默认延迟时间，可以通过WithReadyDelay修改
 	deviceReadyDelay = 2 * time.Second
 )
 
@@ -34,11 +38,23 @@ const (
     <u:SetAVTransportURI xmlns:u="urn:schemas-upnp-org:service:AVTransport:1">
       <InstanceID>0</InstanceID>
       <CurrentURI>%s</CurrentURI>
-      <CurrentURIMetaData></CurrentURIMetaData>
+      <CurrentURIMetaData>%s</CurrentURIMetaData>
     </u:SetAVTransportURI>
   </s:Body>
 </s:Envelope>`
 
+	// SetNextAVTransportURI请求模板
+	setNextAVTransportXMLTemplate = `<?xml version="1.0" encoding="utf-8"?>
+<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/">
+  <s:Body>
+    <u:SetNextAVTransportURI xmlns:u="urn:schemas-upnp-org:service:AVTransport:1">
+      <InstanceID>0</InstanceID>
+      <NextURI>%s</NextURI>
+      <NextURIMetaData>%s</NextURIMetaData>
+    </u:SetNextAVTransportURI>
+  </s:Body>
+</s:Envelope>`
+
 	// Play请求模板
 	playXML = `<?xml version="1.0" encoding="utf-8"?>
 <s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/">
@@ -49,26 +65,149 @@ const (
     </u:Play>
   </s:Body>
 </s:Envelope>`
+
+	// Pause请求模板
+	pauseXML = `<?xml version="1.0" encoding="utf-8"?>
+<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/">
+  <s:Body>
+    <u:Pause xmlns:u="urn:schemas-upnp-org:service:AVTransport:1">
+      <InstanceID>0</InstanceID>
+    </u:Pause>
+  </s:Body>
+</s:Envelope>`
+
+	// Seek请求模板，Target为HH:MM:SS格式的播放位置
+	seekXMLTemplate = `<?xml version="1.0" encoding="utf-8"?>
+<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/">
+  <s:Body>
+    <u:Seek xmlns:u="urn:schemas-upnp-org:service:AVTransport:1">
+      <InstanceID>0</InstanceID>
+      <Unit>REL_TIME</Unit>
+      <Target>%s</Target>
+    </u:Seek>
+  </s:Body>
+</s:Envelope>`
+
+	// Next请求模板
+	nextXML = `<?xml version="1.0" encoding="utf-8"?>
+<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/">
+  <s:Body>
+    <u:Next xmlns:u="urn:schemas-upnp-org:service:AVTransport:1">
+      <InstanceID>0</InstanceID>
+    </u:Next>
+  </s:Body>
+</s:Envelope>`
+
+	// Previous请求模板
+	previousXML = `<?xml version="1.0" encoding="utf-8"?>
+<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/">
+  <s:Body>
+    <u:Previous xmlns:u="urn:schemas-upnp-org:service:AVTransport:1">
+      <InstanceID>0</InstanceID>
+    </u:Previous>
+  </s:Body>
+</s:Envelope>`
+
+	// Stop请求模板
+	stopXML = `<?xml version="1.0" encoding="utf-8"?>
+<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/">
+  <s:Body>
+    <u:Stop xmlns:u="urn:schemas-upnp-org:service:AVTransport:1">
+      <InstanceID>0</InstanceID>
+    </u:Stop>
+  </s:Body>
+</s:Envelope>`
+
+	// GetTransportInfo请求模板
+	getTransportInfoXML = `<?xml version="1.0" encoding="utf-8"?>
+<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/">
+  <s:Body>
+    <u:GetTransportInfo xmlns:u="urn:schemas-upnp-org:service:AVTransport:1">
+      <InstanceID>0</InstanceID>
+    </u:GetTransportInfo>
+  </s:Body>
+</s:Envelope>`
 )
 
+// getTransportInfoResponse GetTransportInfo的SOAP响应
+type getTransportInfoResponse struct {
+	Body struct {
+		Response struct {
+			CurrentTransportState string `xml:"CurrentTransportState"`
+		} `xml:"GetTransportInfoResponse"`
+	} `xml:"Body"`
+}
+
+// 设备无法作为投屏目标时返回的错误
+var (
+	// ErrNotRenderer 设备是媒体服务器（内容来源）而不是媒体渲染器
+	ErrNotRenderer = errors.New("该设备不是媒体渲染器，无法投屏。\n请选择电视、音箱等播放设备，或在该设备上通过媒体库浏览并播放文件")
+	// ErrNoAVTransport 设备没有提供AVTransport服务，无法控制播放
+	ErrNoAVTransport = errors.New("该设备未提供AVTransport服务，无法控制播放。\n请确认设备已开启DLNA/投屏功能，或选择其他设备")
+)
+
+// IsSourceOnly 判断设备是否只是媒体服务器（内容来源），这类设备无法投屏
+// 设备类型未知时返回false，由创建控制器时进一步检查
+func IsSourceOnly(device types.DeviceInfo) bool {
+	return isMediaServerType(device.DeviceType)
+}
+
+// isMediaServerType 判断设备类型是否为UPnP MediaServer
+func isMediaServerType(deviceType string) bool {
+	return strings.Contains(deviceType, ":device:MediaServer:")
+}
+
 // DeviceController 用于控制DLNA设备
 // 实现了interfaces.DLNAController接口
 type DeviceController struct {
-	ControlURL      string
-	EventURL        string
-	deviceInfo      types.DeviceInfo
-	subscriptionMgr *SubscriptionManager
+	ControlURL string
+	EventURL   string
+	// ServiceType 当前AVTransport服务声明的服务类型，SOAPAction和请求体使用该版本，为空时使用版本1
+	ServiceType string
+	// Transports 设备的所有AVTransport服务，多区域功放等设备可能有多个，
+	// ControlURL和EventURL对应当前选择的服务
+	Transports        []AVTransportService
+	selectedTransport int
+	// ConnectionManagerURL ConnectionManager服务的控制地址，设备没有该服务时为空
+	ConnectionManagerURL string
+	// RenderingControlURL RenderingControl服务的控制地址，设备没有该服务时为空
+	RenderingControlURL string
+	deviceInfo          types.DeviceInfo
+	subscriptionMgr     *SubscriptionManager
+	// Quirks 设备的兼容性特殊处理配置
+	Quirks Quirks
+	// PlayRetryAttempts 设置媒体地址后的Play返回状态切换错误时的重试次数，0表示不重试
+	PlayRetryAttempts int
+	// SOAPRetryAttempts AVTransport请求连接失败或设备返回5xx时的重试次数，0表示不重试
+	SOAPRetryAttempts int
+	// Timeout 每个SOAP请求的HTTP超时，0表示使用默认的5秒
+	Timeout time.Duration
+	// ReadyDelay SetAVTransportURI成功后、发送Play前等待设备准备的时间
+	ReadyDelay time.Duration
+	// started 已经通过该控制器设置过媒体地址，之后才能停止播放
+	started atomic.Bool
+	// onTransportState 收到设备推送的传输状态时的回调
+	eventMu          sync.Mutex
+	onTransportState func(TransportState)
 }
 
 // ParseDeviceDescription 解析设备描述XML
 type deviceDescription struct {
-	Device struct {
-		FriendlyName string `xml:"friendlyName"`
-		Manufacturer string `xml:"manufacturer"`
-		ModelName    string `xml:"modelName"`
-		ServiceList struct {
+	// URLBase 解析相对地址使用的基础地址，UPnP 1.0的设备可能提供，为空时使用描述文件的地址
+	URLBase string `xml:"URLBase"`
+	Device  struct {
+		DeviceType      string `xml:"deviceType"`
+		FriendlyName    string `xml:"friendlyName"`
+		Manufacturer    string `xml:"manufacturer"`
+		ModelName       string `xml:"modelName"`
+		UDN             string `xml:"UDN"`
+		ModelNumber     string `xml:"modelNumber"`
+		SerialNumber    string `xml:"serialNumber"`
+		PresentationURL string `xml:"presentationURL"`
+		ServiceList     struct {
 			Service []struct {
 				ServiceType string `xml:"serviceType"`
+				ServiceID   string `xml:"serviceId"`
 				ControlURL  string `xml:"controlURL"`
 				EventSubURL string `xml:"eventSubURL"`
 			} `xml:"service"`
@@ -77,43 +216,79 @@ type deviceDescription struct {
 }
 
 // NewDeviceControllerWithContext 创建一个带上下文支持的设备控制器
-func NewDeviceControllerWithContext(ctx context.Context, location string) (interfaces.DLNAController, error) {
+// opts可以修改HTTP超时和设备准备时间，不指定时使用默认值
+func NewDeviceControllerWithContext(ctx context.Context, location string, opts ...ControllerOption) (interfaces.DLNAController, error) {
+	options := newControllerOptions(opts)
+
 	// 获取设备描述
-	desc, err := getDeviceDescriptionWithContext(ctx, location)
+	desc, err := getDeviceDescriptionWithContext(ctx, location, options.timeout)
 	if err != nil {
 		return nil, fmt.Errorf("获取设备描述失败: %w", err)
 	}
 
-	// 查找AVTransport服务
-	controlURL := ""
-	eventURL := ""
+	// 解析控制地址和事件地址使用的基础地址
+	baseURL, err := descriptionBaseURL(location, desc.URLBase)
+	if err != nil {
+		return nil, err
+	}
+
+	// 查找所有AVTransport服务以及ConnectionManager、RenderingControl服务
+	transports := []AVTransportService{}
+	connectionManagerURL := ""
+	renderingControlURL := ""
 	for _, service := range desc.Device.ServiceList.Service {
-		if strings.Contains(service.ServiceType, "AVTransport") {
-			controlURL = service.ControlURL
-			eventURL = service.EventSubURL
-			break
+		if strings.Contains(service.ServiceType, "AVTransport") && strings.TrimSpace(service.ControlURL) != "" {
+			transports = append(transports, AVTransportService{
+				ServiceID:   service.ServiceID,
+				ControlURL:  resolveControlURL(baseURL, service.ControlURL),
+				EventURL:    resolveControlURL(baseURL, service.EventSubURL),
+				ServiceType: strings.TrimSpace(service.ServiceType),
+			})
+		}
+		if connectionManagerURL == "" && strings.Contains(service.ServiceType, "ConnectionManager") {
+			connectionManagerURL = service.ControlURL
+		}
+		if renderingControlURL == "" && strings.Contains(service.ServiceType, "RenderingControl") {
+			renderingControlURL = service.ControlURL
 		}
 	}
 
-	if controlURL == "" {
-		return nil, fmt.Errorf("未找到AVTransport服务")
+	if len(transports) == 0 {
+		// 媒体服务器（内容来源）没有AVTransport服务，永远无法作为投屏目标
+		if isMediaServerType(desc.Device.DeviceType) {
+			return nil, ErrNotRenderer
+		}
+		return nil, ErrNoAVTransport
 	}
 
-	// 构建完整的控制URL
-	baseURL := location[:strings.LastIndex(location, "/")+1]
-	fullControlURL := baseURL + strings.TrimPrefix(controlURL, "/")
-
+	// 默认使用第一个AVTransport服务
 	controller := &DeviceController{
-		ControlURL: fullControlURL,
-		EventURL:   eventURL,
+		ControlURL:           transports[0].ControlURL,
+		EventURL:             transports[0].EventURL,
+		ServiceType:          transports[0].ServiceType,
+		Transports:           transports,
+		ConnectionManagerURL: resolveControlURL(baseURL, connectionManagerURL),
+		RenderingControlURL:  resolveControlURL(baseURL, renderingControlURL),
 		deviceInfo: types.DeviceInfo{
-			FriendlyName: desc.Device.FriendlyName,
-			Manufacturer: desc.Device.Manufacturer,
-			ModelName:    desc.Device.ModelName,
-			Location:     location,
+			FriendlyName:    desc.Device.FriendlyName,
+			Manufacturer:    desc.Device.Manufacturer,
+			ModelName:       desc.Device.ModelName,
+			Location:        location,
+			DeviceType:      desc.Device.DeviceType,
+			UDN:             strings.TrimSpace(desc.Device.UDN),
+			ModelNumber:     strings.TrimSpace(desc.Device.ModelNumber),
+			SerialNumber:    strings.TrimSpace(desc.Device.SerialNumber),
+			PresentationURL: ResolvePresentationURL(location, desc.Device.PresentationURL),
 		},
+		PlayRetryAttempts: DefaultPlayRetryAttempts,
+		SOAPRetryAttempts: DefaultSOAPRetryAttempts,
+		Timeout:           options.timeout,
+		ReadyDelay:        options.readyDelay,
 	}
 
+	// 根据设备型号加载兼容性配置
+	controller.Quirks = QuirksForDevice(controller.deviceInfo)
+
 	// 初始化订阅管理器
 	controller.subscriptionMgr = newSubscriptionManager(controller)
 
@@ -130,16 +305,80 @@ func (dc *DeviceController) GetDeviceInfo() types.DeviceInfo {
 	return dc.deviceInfo
 }
 
+// ResolvePresentationURL 将设备描述中的presentationURL解析为完整地址
+// 相对地址相对于设备描述的地址解析，没有提供或无法解析时返回空字符串
+func ResolvePresentationURL(location, presentationURL string) string {
+	presentationURL = strings.TrimSpace(presentationURL)
+	if presentationURL == "" {
+		return ""
+	}
+	base, err := url.Parse(location)
+	if err != nil {
+		return ""
+	}
+	ref, err := url.Parse(presentationURL)
+	if err != nil {
+		return ""
+	}
+	resolved := base.ResolveReference(ref)
+	if resolved.Scheme != "http" && resolved.Scheme != "https" {
+		return ""
+	}
+	return resolved.String()
+}
+
+// ResolveDescriptionURL 将设备描述中的相对地址（例如图标地址）解析为完整地址
+// urlBase为描述中的URLBase，为空时相对于描述文件的地址；无法解析时返回空字符串
+func ResolveDescriptionURL(location, urlBase, ref string) string {
+	baseURL, err := descriptionBaseURL(location, urlBase)
+	if err != nil {
+		return ""
+	}
+	return resolveControlURL(baseURL, ref)
+}
+
+// descriptionBaseURL 返回解析服务地址使用的基础地址
+// 描述中有有效的URLBase时使用URLBase，否则使用描述文件本身的地址
+func descriptionBaseURL(location, urlBase string) (*url.URL, error) {
+	if urlBase = strings.TrimSpace(urlBase); urlBase != "" {
+		if base, err := url.Parse(urlBase); err == nil && (base.Scheme == "http" || base.Scheme == "https") && base.Host != "" {
+			return base, nil
+		}
+		log.Printf("忽略无效的URLBase: %s\n", urlBase)
+	}
+	base, err := url.Parse(location)
+	if err != nil {
+		return nil, fmt.Errorf("无效的设备描述地址: %w", err)
+	}
+	return base, nil
+}
+
+// resolveControlURL 将服务描述中的控制地址或事件地址解析为完整地址
+// 绝对地址原样使用，以/开头的地址相对于主机，其他地址相对于基础地址所在的目录；
+// 地址为空或无法解析时返回空字符串
+func resolveControlURL(baseURL *url.URL, controlURL string) string {
+	controlURL = strings.TrimSpace(controlURL)
+	if controlURL == "" {
+		return ""
+	}
+	ref, err := url.Parse(controlURL)
+	if err != nil {
+		return ""
+	}
+	return baseURL.ResolveReference(ref).String()
+}
+
 // getDeviceDescriptionWithContext 使用带上下文的HTTP请求获取设备描述
-func getDeviceDescriptionWithContext(ctx context.Context, location string) (*deviceDescription, error) {
+func getDeviceDescriptionWithContext(ctx context.Context, location string, timeout time.Duration) (*deviceDescription, error) {
 	client := http.Client{
-		Timeout: defaultHTTPTimeout,
+		Timeout: timeout,
 	}
 
 	req, err := http.NewRequestWithContext(ctx, "GET", location, nil)
 	if err != nil {
 		return nil, fmt.Errorf("创建HTTP请求失败: %w", err)
 	}
+	AcceptXMLEncodings(req)
 
 	resp, err := client.Do(req)
 	if err != nil {
@@ -152,9 +391,10 @@ func getDeviceDescriptionWithContext(ctx context.Context, location string) (*dev
 		return nil, fmt.Errorf("获取设备描述失败，状态码: %d", resp.StatusCode)
 	}
 
-	body, err := io.ReadAll(resp.Body)
+	// 部分设备返回gzip压缩或带BOM的描述文件
+	body, err := ReadXMLBody(resp)
 	if err != nil {
-		return nil, fmt.Errorf("读取响应体失败: %w", err)
+		return nil, err
 	}
 
 	desc := &deviceDescription{}
@@ -170,7 +410,7 @@ func getDeviceDescriptionWithContext(ctx context.Context, location string) (*dev
 
 // getDeviceDescription 获取设备描述
 func getDeviceDescription(location string) (*deviceDescription, error) {
-	return getDeviceDescriptionWithContext(context.Background(), location)
+	return getDeviceDescriptionWithContext(context.Background(), location, defaultHTTPTimeout)
 }
 
 // min 返回两个整数中的较小值
@@ -182,40 +422,100 @@ func min(a, b int) int {
 }
 
 // PlayMediaWithContext 带上下文支持的媒体播放函数
+// 以媒体地址中的文件名为标题、video/mp4为类型发送DIDL-Lite元数据
 func (dc *DeviceController) PlayMediaWithContext(ctx context.Context, mediaURL string) error {
+	return dc.PlayMediaWithMetadataContext(ctx, mediaURL, defaultMetadata(mediaURL))
+}
+
+// PlayMediaWithMetadataContext 播放媒体并随SetAVTransportURI发送DIDL-Lite元数据
+// metadata为nil时不发送元数据
+func (dc *DeviceController) PlayMediaWithMetadataContext(ctx context.Context, mediaURL string, metadata *MediaMetadata) error {
+	// 部分设备在播放状态下会忽略新的SetAVTransportURI，需要先停止当前播放
+	if dc.Quirks.StopBeforeSetURI {
+		dc.stopIfActive(ctx)
+	}
+	dc.subscribeAt(ctx, SubscribeBeforeSetURI)
+
 	// 设置AVTransport
-	setAVTransportXML := fmt.Sprintf(setAVTransportXMLTemplate, mediaURL)
+	// 媒体地址可能包含&等字符，需要转义后才能放入XML
+	didl := ""
+	if metadata != nil {
+		didl = metadata.didl(mediaURL)
+	}
+	setAVTransportXML := fmt.Sprintf(setAVTransportXMLTemplate, escapeXML(mediaURL), escapeXML(didl))
 
 	// 发送SetAVTransportURI请求
 	err := dc.sendSOAPRequestWithContext(ctx, "SetAVTransportURI", setAVTransportXML)
 	if err != nil {
 		return fmt.Errorf("设置AVTransport失败: %w", err)
 	}
+	dc.started.Store(true)
+	dc.subscribeAt(ctx, SubscribeAfterSetURI)
 
 	// 增加延迟时间，让设备有更充分的时间准备播放
 	// 检查上下文是否已取消
-	sleepCtx, cancel := context.WithTimeout(ctx, deviceReadyDelay)
-	defer cancel()
-	select {
-	case <-sleepCtx.Done():
-		// 上下文已取消或超时
-		return sleepCtx.Err()
-	case <-time.After(deviceReadyDelay):
-		// 延迟结束，继续执行
-	}
-
-	// 发送Play请求
-	err = dc.sendSOAPRequestWithContext(ctx, "Play", playXML)
+	if dc.ReadyDelay > 0 {
+		timer := time.NewTimer(dc.ReadyDelay)
+		select {
+		case <-ctx.Done():
+			// 上下文已取消或超时
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+			// 延迟结束，继续执行
+		}
+	}
+
+	// 发送Play请求，设备仍在加载媒体地址时短暂等待后重试
+	err = dc.playWithRetry(ctx)
 	if err != nil {
 		return err
 	}
 
-	// 启动事件订阅
-	if dc.subscriptionMgr != nil {
-		dc.subscriptionMgr.startSubscription(ctx)
+	dc.subscribeAt(ctx, SubscribeAfterPlay)
+	return nil
+}
+
+// subscribeAt 到达设备兼容性配置中的订阅时机时启动事件订阅
+// 订阅的生命周期与投屏请求的超时无关，订阅失败只记录日志，不影响投屏
+// 配置为提前订阅但订阅已经失败时，在Play之后再订阅一次
+func (dc *DeviceController) subscribeAt(ctx context.Context, timing SubscribeTiming) {
+	if dc.subscriptionMgr == nil {
+		return
+	}
+	switch {
+	case dc.Quirks.SubscribeTiming == timing:
+	case timing == SubscribeAfterPlay && dc.subscriptionMgr.hasFailed():
+		log.Printf("设备 %s 在%s订阅事件失败，开始播放后重新订阅\n", dc.deviceInfo.FriendlyName, dc.Quirks.SubscribeTiming)
+	default:
+		return
 	}
+	dc.subscriptionMgr.startSubscription(context.WithoutCancel(ctx), timing, dc.Quirks.SubscribeDelay)
+}
 
-	return nil
+// GetTransportStateWithContext 查询设备当前的传输状态，例如 "PLAYING"、"STOPPED"、"NO_MEDIA_PRESENT"
+func (dc *DeviceController) GetTransportStateWithContext(ctx context.Context) (string, error) {
+	state, err := dc.GetTransportInfo(ctx)
+	return string(state), err
+}
+
+// stopIfActive 查询设备的传输状态，正在播放或暂停时先发送Stop
+// 查询或停止失败只记录日志，不影响后续的投屏流程
+func (dc *DeviceController) stopIfActive(ctx context.Context) {
+	state, err := dc.GetTransportInfo(ctx)
+	if err != nil {
+		log.Printf("%v\n", err)
+		return
+	}
+
+	if state != TransportStatePlaying && state != TransportStatePaused {
+		return
+	}
+
+	log.Printf("设备当前状态为%s，先停止播放再设置新的媒体地址\n", state)
+	if err := dc.sendSOAPRequestWithContext(ctx, "Stop", stopXML); err != nil {
+		log.Printf("停止设备播放失败: %v\n", err)
+	}
 }
 
 // PlayMedia 播放指定的媒体文件（兼容旧接口）
@@ -227,7 +527,11 @@ func (dc *DeviceController) PlayMedia(mediaURL string) error {
 // 这是一个内部组件，负责处理设备事件通知
 type SubscriptionManager struct {
 	controller *DeviceController
+	// mu 保护cancelFunc，投屏流程和应用都可能启动或取消订阅
+	mu         sync.Mutex
 	cancelFunc context.CancelFunc
+	// failed 当前订阅在取消之前就失败了
+	failed bool
 }
 
 // newSubscriptionManager 创建一个新的订阅管理器
@@ -237,76 +541,144 @@ func newSubscriptionManager(controller *DeviceController) *SubscriptionManager {
 	}
 }
 
-// startSubscription 开始订阅设备事件
-func (sm *SubscriptionManager) startSubscription(ctx context.Context) {
+// startSubscription 开始订阅设备事件，timing为订阅所处的时机，delay为开始订阅前等待的时间
+// 设备没有事件订阅地址时不订阅，播放不受影响，只是没有实时状态
+func (sm *SubscriptionManager) startSubscription(ctx context.Context, timing SubscribeTiming, delay time.Duration) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
 	// 如果已经有活跃的订阅，先取消
 	if sm.cancelFunc != nil {
 		sm.cancelFunc()
+		sm.cancelFunc = nil
+	}
+	sm.failed = false
+	if sm.controller.EventURL == "" {
+		log.Printf("设备 %s 没有提供事件订阅地址，不订阅事件\n", sm.controller.deviceInfo.FriendlyName)
+		return
 	}
+	log.Printf("设备 %s 在%s订阅事件\n", sm.controller.deviceInfo.FriendlyName, timing)
 
 	// 创建一个子上下文用于订阅
 	subCtx, cancel := context.WithCancel(ctx)
 	sm.cancelFunc = cancel
 
 	// 在后台启动订阅处理
-	go sm.handleSubscription(subCtx)
+	go func() {
+		if delay > 0 {
+			timer := time.NewTimer(delay)
+			defer timer.Stop()
+			select {
+			case <-subCtx.Done():
+				return
+			case <-timer.C:
+			}
+		}
+		sm.handleSubscription(subCtx)
+	}()
 }
 
-// handleSubscription 处理事件订阅
-func (sm *SubscriptionManager) handleSubscription(ctx context.Context) {
-	// 简化实现，实际项目中可能需要实现真正的UPnP事件订阅
-	// 此处我们只是定期检查上下文是否已取消
-
-	ticker := time.NewTicker(30 * time.Second)
-	defer ticker.Stop()
-
-	log.Printf("开始事件订阅监控: %s", sm.controller.deviceInfo.FriendlyName)
+// stopSubscription 取消正在进行的事件订阅
+func (sm *SubscriptionManager) stopSubscription() {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	if sm.cancelFunc != nil {
+		sm.cancelFunc()
+		sm.cancelFunc = nil
+	}
+}
 
-	for {
-		select {
-		case <-ctx.Done():
-			log.Printf("停止事件订阅监控: %v", ctx.Err())
-			return
-		case <-ticker.C:
-			// 定期检查
+// handleSubscription 处理事件订阅，直到ctx取消
+// 订阅失败只记录日志，播放状态仍然可以通过查询得到
+func (sm *SubscriptionManager) handleSubscription(ctx context.Context) {
+	if err := runEventSubscription(ctx, sm.controller, sm.controller.EventURL); err != nil {
+		log.Printf("设备 %s 的事件订阅已结束: %v\n", sm.controller.deviceInfo.FriendlyName, err)
+		sm.mu.Lock()
+		// 已被取消的旧订阅不影响新订阅的状态，取消也在持有锁时进行
+		if ctx.Err() == nil {
+			sm.failed = true
 		}
+		sm.mu.Unlock()
 	}
 }
 
+// hasFailed 返回当前订阅是否已经失败
+func (sm *SubscriptionManager) hasFailed() bool {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	return sm.failed
+}
+
 // sendSOAPRequestWithContext 带上下文支持的SOAP请求发送函数
 func (dc *DeviceController) sendSOAPRequestWithContext(ctx context.Context, action string, body string) error {
+	_, err := dc.soapCallWithContext(ctx, action, body)
+	return err
+}
+
+// soapCallWithContext 向AVTransport服务发送SOAP请求并返回响应体，暂时性的失败会重试
+// 请求模板使用版本1的服务类型，设备声明了其他版本时SOAPAction和请求体都改用设备声明的版本
+func (dc *DeviceController) soapCallWithContext(ctx context.Context, action string, body string) ([]byte, error) {
+	serviceType := dc.avTransportServiceType()
+	if serviceType != uPNPAVTransportService {
+		body = strings.ReplaceAll(body, uPNPAVTransportService, serviceType)
+	}
+	return dc.soapCallWithRetry(ctx, func() ([]byte, error) {
+		return soapCallToServiceWithContext(ctx, dc.httpTimeout(), dc.ControlURL, serviceType, action, body)
+	})
+}
+
+// avTransportServiceType 返回当前AVTransport服务的服务类型，设备没有声明有效的类型时使用版本1
+func (dc *DeviceController) avTransportServiceType() string {
+	if strings.HasPrefix(dc.ServiceType, "urn:schemas-upnp-org:service:AVTransport:") {
+		return dc.ServiceType
+	}
+	return uPNPAVTransportService
+}
+
+// soapCallToServiceWithContext 向指定服务的控制地址发送SOAP请求并返回响应体
+func soapCallToServiceWithContext(ctx context.Context, timeout time.Duration, controlURL, serviceType, action, body string) ([]byte, error) {
 	client := http.Client{
-		Timeout: defaultHTTPTimeout,
+		Timeout: timeout,
 	}
 
-	req, err := http.NewRequestWithContext(ctx, "POST", dc.ControlURL, bytes.NewBufferString(body))
+	req, err := http.NewRequestWithContext(ctx, "POST", controlURL, bytes.NewBufferString(body))
 	if err != nil {
-		return fmt.Errorf("创建SOAP请求失败: %w", err)
+		return nil, fmt.Errorf("创建SOAP请求失败: %w", err)
 	}
 
 	// 设置SOAP请求头
-	soapAction := fmt.Sprintf(`"%s#%s"`, uPNPAVTransportService, action)
+	soapAction := fmt.Sprintf(`"%s#%s"`, serviceType, action)
 	req.Header.Set("Content-Type", "text/xml; charset=utf-8")
 	req.Header.Set("SOAPAction", soapAction)
 
 	resp, err := client.Do(req)
 	if err != nil {
-		return fmt.Errorf("发送SOAP请求失败: %w", err)
+		return nil, fmt.Errorf("发送SOAP请求失败: %w", err)
 	}
 	defer resp.Body.Close()
 
+	// 读取响应体
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("读取SOAP响应失败: %w", err)
+	}
+
 	// 检查响应状态
 	if resp.StatusCode != http.StatusOK {
-		// 读取响应体以获取更多错误信息
-		respBody, _ := io.ReadAll(resp.Body)
 		// 仅记录前200个字符，避免日志过长
 		respBodyPreview := string(respBody[:min(200, len(respBody))])
 		log.Printf("SOAP请求失败: %s, 状态码: %d, 响应预览: %s...\n", action, resp.StatusCode, respBodyPreview)
-		return fmt.Errorf("SOAP请求失败: %s, 状态码: %d", action, resp.StatusCode)
+		// 解析设备返回的UPnP错误码，生成可读的错误信息
+		return nil, newSOAPError(action, resp.StatusCode, respBody)
+	}
+
+	// 个别设备以200状态码返回SOAP错误，同样视为失败
+	if code, description, ok := parseSOAPFault(respBody); ok {
+		log.Printf("SOAP请求失败: %s, 设备以状态码200返回错误\n", action)
+		return nil, &SOAPError{Action: action, StatusCode: resp.StatusCode, Code: code, Description: description}
 	}
 
 	log.Printf("SOAP请求成功: %s\n", action)
-	return nil
+	return respBody, nil
 }
 
 // sendSOAPRequest 发送SOAP请求
@@ -0,0 +1,369 @@
+package dlna
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// descriptionDevice 提供设备描述并记录控制请求的假设备
+type descriptionDevice struct {
+	mu       sync.Mutex
+	requests []string
+	server   *httptest.Server
+}
+
+// newDescriptionDevice 在/desc.xml提供description，其他路径作为控制地址，响应空的成功结果
+// description中的{{base}}替换为服务器地址
+func newDescriptionDevice(t *testing.T, description string) *descriptionDevice {
+	t.Helper()
+	device := &descriptionDevice{}
+	device.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/desc.xml" {
+			w.Header().Set("Content-Type", "text/xml")
+			io.WriteString(w, strings.ReplaceAll(description, "{{base}}", device.server.URL))
+			return
+		}
+		io.Copy(io.Discard, r.Body)
+		device.mu.Lock()
+		device.requests = append(device.requests, r.URL.Path+" "+r.Header.Get("SOAPAction"))
+		device.mu.Unlock()
+		w.Header().Set("Content-Type", "text/xml")
+		io.WriteString(w, successResponse)
+	}))
+	t.Cleanup(device.server.Close)
+	return device
+}
+
+// Requests 返回按顺序收到的控制请求，格式为 "路径 SOAPAction"
+func (d *descriptionDevice) Requests() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]string(nil), d.requests...)
+}
+
+func (d *descriptionDevice) Location() string {
+	return d.server.URL + "/desc.xml"
+}
+
+const multiZoneDescription = `<?xml version="1.0"?>
+<root xmlns="urn:schemas-upnp-org:device-1-0">
+  <device>
+    <deviceType>urn:schemas-upnp-org:device:MediaRenderer:1</deviceType>
+    <friendlyName>AV Receiver</friendlyName>
+    <UDN>uuid:receiver</UDN>
+    <serviceList>
+      <service>
+        <serviceType>urn:schemas-upnp-org:service:RenderingControl:1</serviceType>
+        <serviceId>urn:upnp-org:serviceId:RenderingControl</serviceId>
+        <controlURL>/rc/control</controlURL>
+      </service>
+      <service>
+        <serviceType>urn:schemas-upnp-org:service:AVTransport:1</serviceType>
+        <serviceId>urn:upnp-org:serviceId:AVTransport</serviceId>
+        <controlURL>/zone1/avt</controlURL>
+        <eventSubURL>/zone1/event</eventSubURL>
+      </service>
+      <service>
+        <serviceType>urn:schemas-upnp-org:service:AVTransport:2</serviceType>
+        <serviceId>urn:upnp-org:serviceId:AVTransport2</serviceId>
+        <controlURL>zone2/avt</controlURL>
+        <eventSubURL>zone2/event</eventSubURL>
+      </service>
+      <service>
+        <serviceType>urn:schemas-upnp-org:service:ConnectionManager:1</serviceType>
+        <serviceId>urn:upnp-org:serviceId:ConnectionManager</serviceId>
+        <controlURL>/cm/control</controlURL>
+      </service>
+    </serviceList>
+  </device>
+</root>`
+
+func TestNewDeviceControllerMultipleTransports(t *testing.T) {
+	device := newDescriptionDevice(t, multiZoneDescription)
+	controller, err := NewDeviceControllerWithContext(context.Background(), device.Location())
+	if err != nil {
+		t.Fatalf("NewDeviceControllerWithContext() error = %v", err)
+	}
+	dc := controller.(*DeviceController)
+
+	base := device.server.URL
+	wantTransports := []AVTransportService{
+		{ServiceID: "urn:upnp-org:serviceId:AVTransport", ControlURL: base + "/zone1/avt", EventURL: base + "/zone1/event", ServiceType: "urn:schemas-upnp-org:service:AVTransport:1"},
+		{ServiceID: "urn:upnp-org:serviceId:AVTransport2", ControlURL: base + "/zone2/avt", EventURL: base + "/zone2/event", ServiceType: "urn:schemas-upnp-org:service:AVTransport:2"},
+	}
+	if len(dc.Transports) != len(wantTransports) {
+		t.Fatalf("Transports = %+v, want %+v", dc.Transports, wantTransports)
+	}
+	for i, want := range wantTransports {
+		if dc.Transports[i] != want {
+			t.Errorf("Transports[%d] = %+v, want %+v", i, dc.Transports[i], want)
+		}
+	}
+	if names := dc.Transports[0].Name() + "," + dc.Transports[1].Name(); names != "AVTransport,AVTransport2" {
+		t.Errorf("transport names = %s, want AVTransport,AVTransport2", names)
+	}
+	if dc.SelectedTransport() != 0 || dc.ControlURL != wantTransports[0].ControlURL {
+		t.Errorf("default transport = %d (%s), want the first one", dc.SelectedTransport(), dc.ControlURL)
+	}
+	if dc.ConnectionManagerURL != base+"/cm/control" || dc.RenderingControlURL != base+"/rc/control" {
+		t.Errorf("ConnectionManagerURL = %s, RenderingControlURL = %s", dc.ConnectionManagerURL, dc.RenderingControlURL)
+	}
+
+	if err := dc.SelectTransport(2); err == nil {
+		t.Error("SelectTransport(2) accepted an invalid index")
+	}
+	if err := dc.SelectTransport(1); err != nil {
+		t.Fatalf("SelectTransport(1) error = %v", err)
+	}
+	if _, err := dc.soapCallWithContext(context.Background(), "Stop", stopXML); err != nil {
+		t.Fatalf("Stop on the second zone error = %v", err)
+	}
+	want := []string{`/zone2/avt "urn:schemas-upnp-org:service:AVTransport:2#Stop"`}
+	if got := device.Requests(); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("requests = %q, want %q", got, want)
+	}
+}
+
+func TestNewDeviceControllerRejectsNonRenderers(t *testing.T) {
+	tests := []struct {
+		name       string
+		deviceType string
+		want       error
+	}{
+		{name: "media server", deviceType: "urn:schemas-upnp-org:device:MediaServer:1", want: ErrNotRenderer},
+		{name: "renderer without AVTransport", deviceType: "urn:schemas-upnp-org:device:MediaRenderer:1", want: ErrNoAVTransport},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			device := newDescriptionDevice(t, `<?xml version="1.0"?>
+<root xmlns="urn:schemas-upnp-org:device-1-0"><device>
+  <deviceType>`+tt.deviceType+`</deviceType>
+  <friendlyName>NAS</friendlyName>
+  <serviceList><service>
+    <serviceType>urn:schemas-upnp-org:service:ContentDirectory:1</serviceType>
+    <controlURL>/cd/control</controlURL>
+  </service></serviceList>
+</device></root>`)
+			_, err := NewDeviceControllerWithContext(context.Background(), device.Location())
+			if !errors.Is(err, tt.want) {
+				t.Errorf("NewDeviceControllerWithContext() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolvePresentationURL(t *testing.T) {
+	const location = "http://192.168.1.20:8080/dev/desc.xml"
+	tests := []struct {
+		name            string
+		presentationURL string
+		want            string
+	}{
+		{name: "absolute path", presentationURL: "/web/index.html", want: "http://192.168.1.20:8080/web/index.html"},
+		{name: "relative path", presentationURL: "index.html", want: "http://192.168.1.20:8080/dev/index.html"},
+		{name: "absolute URL", presentationURL: " http://192.168.1.20/ ", want: "http://192.168.1.20/"},
+		{name: "not a web page", presentationURL: "javascript:alert(1)", want: ""},
+		{name: "missing", presentationURL: "", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ResolvePresentationURL(location, tt.presentationURL); got != tt.want {
+				t.Errorf("ResolvePresentationURL(%q) = %q, want %q", tt.presentationURL, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewDeviceControllerParsesMetadata(t *testing.T) {
+	device := newDescriptionDevice(t, `<?xml version="1.0"?>
+<root xmlns="urn:schemas-upnp-org:device-1-0">
+  <device>
+    <deviceType>urn:schemas-upnp-org:device:MediaRenderer:1</deviceType>
+    <friendlyName>Bedroom TV</friendlyName>
+    <modelNumber>EX-55A</modelNumber>
+    <serialNumber> SN123456 </serialNumber>
+    <UDN>uuid:bedroom-tv</UDN>
+    <presentationURL>/web/</presentationURL>
+    <serviceList>
+      <service>
+        <serviceType>urn:schemas-upnp-org:service:AVTransport:1</serviceType>
+        <controlURL>/avt</controlURL>
+      </service>
+    </serviceList>
+  </device>
+</root>`)
+
+	controller, err := NewDeviceControllerWithContext(context.Background(), device.Location())
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := controller.GetDeviceInfo()
+	if info.UDN != "uuid:bedroom-tv" || info.ModelNumber != "EX-55A" || info.SerialNumber != "SN123456" {
+		t.Errorf("GetDeviceInfo() = %+v, want UDN, model number and serial number from the description", info)
+	}
+	if want := device.server.URL + "/web/"; info.PresentationURL != want {
+		t.Errorf("PresentationURL = %q, want %q", info.PresentationURL, want)
+	}
+}
+
+func TestResolveDescriptionURL(t *testing.T) {
+	const location = "http://192.168.1.20:8080/dev/desc.xml"
+	tests := []struct {
+		name    string
+		urlBase string
+		ref     string
+		want    string
+	}{
+		{name: "relative path", ref: "avt/control", want: "http://192.168.1.20:8080/dev/avt/control"},
+		{name: "host relative path", ref: "/avt/control", want: "http://192.168.1.20:8080/avt/control"},
+		{name: "absolute URL", ref: " http://192.168.1.30:9000/AVTransport/ctrl ", want: "http://192.168.1.30:9000/AVTransport/ctrl"},
+		{name: "URLBase on another host", urlBase: "http://192.168.1.21:49152/", ref: "avt/control", want: "http://192.168.1.21:49152/avt/control"},
+		{name: "URLBase without trailing slash", urlBase: "http://192.168.1.21:49152/upnp", ref: "avt/control", want: "http://192.168.1.21:49152/avt/control"},
+		{name: "URLBase with host relative path", urlBase: "http://192.168.1.21:49152/upnp/", ref: "/avt/control", want: "http://192.168.1.21:49152/avt/control"},
+		{name: "absolute URL ignores URLBase", urlBase: "http://192.168.1.21:49152/", ref: "http://192.168.1.30/ctrl", want: "http://192.168.1.30/ctrl"},
+		{name: "invalid URLBase", urlBase: "not a url", ref: "/avt/control", want: "http://192.168.1.20:8080/avt/control"},
+		{name: "missing", ref: "", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ResolveDescriptionURL(location, tt.urlBase, tt.ref); got != tt.want {
+				t.Errorf("ResolveDescriptionURL(%q, %q) = %q, want %q", tt.urlBase, tt.ref, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewDeviceControllerUsesURLBase(t *testing.T) {
+	// 描述文件和控制地址在不同的主机上，控制请求必须发往URLBase
+	control := newDescriptionDevice(t, "")
+	device := newDescriptionDevice(t, `<?xml version="1.0"?>
+<root xmlns="urn:schemas-upnp-org:device-1-0">
+  <URLBase>`+control.server.URL+`/upnp/</URLBase>
+  <device>
+    <deviceType>urn:schemas-upnp-org:device:MediaRenderer:1</deviceType>
+    <friendlyName>URLBase TV</friendlyName>
+    <serviceList>
+      <service>
+        <serviceType>urn:schemas-upnp-org:service:AVTransport:1</serviceType>
+        <serviceId>urn:upnp-org:serviceId:AVTransport</serviceId>
+        <controlURL>avt/control</controlURL>
+        <eventSubURL>/avt/event</eventSubURL>
+      </service>
+    </serviceList>
+  </device>
+</root>`)
+
+	controller, err := NewDeviceControllerWithContext(context.Background(), device.Location())
+	if err != nil {
+		t.Fatalf("NewDeviceControllerWithContext() error = %v", err)
+	}
+	dc := controller.(*DeviceController)
+	if want := control.server.URL + "/upnp/avt/control"; dc.ControlURL != want {
+		t.Errorf("ControlURL = %s, want %s", dc.ControlURL, want)
+	}
+	if want := control.server.URL + "/avt/event"; dc.Transports[0].EventURL != want {
+		t.Errorf("EventURL = %s, want %s", dc.Transports[0].EventURL, want)
+	}
+
+	if _, err := dc.soapCallWithContext(context.Background(), "Stop", stopXML); err != nil {
+		t.Fatalf("Stop error = %v", err)
+	}
+	if got := device.Requests(); len(got) != 0 {
+		t.Errorf("description host received control requests %q", got)
+	}
+	want := []string{`/upnp/avt/control "urn:schemas-upnp-org:service:AVTransport:1#Stop"`}
+	if got := control.Requests(); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("URLBase host requests = %q, want %q", got, want)
+	}
+}
+
+// subscriptionStarted 返回订阅管理器当前是否有启动的订阅
+func subscriptionStarted(sm *SubscriptionManager) bool {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	return sm.cancelFunc != nil
+}
+
+func TestSubscribeAtConfiguredStage(t *testing.T) {
+	stages := []SubscribeTiming{SubscribeBeforeSetURI, SubscribeAfterSetURI, SubscribeAfterPlay}
+	for _, configured := range stages {
+		t.Run(configured.String(), func(t *testing.T) {
+			// 订阅前等待很久，测试只检查订阅是否启动，不会向设备发送请求
+			dc := &DeviceController{
+				EventURL: "http://127.0.0.1:1/avt/event",
+				Quirks:   Quirks{SubscribeTiming: configured, SubscribeDelay: time.Hour},
+			}
+			dc.subscriptionMgr = newSubscriptionManager(dc)
+			defer dc.UnsubscribeEvents()
+
+			for _, stage := range stages {
+				dc.subscribeAt(context.Background(), stage)
+				if got, want := subscriptionStarted(dc.subscriptionMgr), stage == configured; got != want {
+					t.Errorf("subscription started at %s = %v, want %v", stage, got, want)
+				}
+				dc.UnsubscribeEvents()
+			}
+		})
+	}
+}
+
+func TestSubscribeAfterPlayRetriesFailedEarlySubscription(t *testing.T) {
+	var mu sync.Mutex
+	var methods []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		methods = append(methods, r.Method)
+		mu.Unlock()
+		w.WriteHeader(http.StatusPreconditionFailed)
+	}))
+	defer server.Close()
+
+	dc := &DeviceController{
+		EventURL: server.URL + "/avt/event",
+		Quirks:   Quirks{SubscribeTiming: SubscribeBeforeSetURI},
+	}
+	dc.subscriptionMgr = newSubscriptionManager(dc)
+	defer dc.UnsubscribeEvents()
+
+	dc.subscribeAt(context.Background(), SubscribeBeforeSetURI)
+	deadline := time.Now().Add(5 * time.Second)
+	for !dc.subscriptionMgr.hasFailed() {
+		if time.Now().After(deadline) {
+			t.Fatal("early subscription did not fail")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	dc.subscribeAt(context.Background(), SubscribeAfterSetURI)
+	// 重新启动订阅会清除失败状态
+	if !dc.subscriptionMgr.hasFailed() {
+		t.Error("subscription restarted after SetAVTransportURI, want only the retry after Play")
+	}
+	dc.subscribeAt(context.Background(), SubscribeAfterPlay)
+	for {
+		mu.Lock()
+		count := len(methods)
+		mu.Unlock()
+		if count >= 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("device received %d SUBSCRIBE requests, want 2", count)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	for i, method := range methods {
+		if method != "SUBSCRIBE" {
+			t.Errorf("request %d = %s, want SUBSCRIBE", i, method)
+		}
+	}
+}
@@ -0,0 +1,312 @@
+package dlna
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 事件订阅相关常量
+const (
+	// eventSubscribeTimeout 向设备请求的订阅时长
+	eventSubscribeTimeout = 30 * time.Minute
+	// eventCallbackPath 本地事件监听地址的路径
+	eventCallbackPath = "/dlna/event"
+	// eventMaxNotifyBody NOTIFY请求体的最大长度
+	eventMaxNotifyBody = 1 << 20
+)
+
+// eventPropertySet NOTIFY请求体，AVTransport服务只通过LastChange变量通知状态变化
+type eventPropertySet struct {
+	Properties []struct {
+		LastChange struct {
+			Text  string `xml:",chardata"`
+			Inner string `xml:",innerxml"`
+		} `xml:"LastChange"`
+	} `xml:"property"`
+}
+
+// lastChangeEvent LastChange变量中的事件，例如：
+//
+//	<Event xmlns="urn:schemas-upnp-org:metadata-1-0/AVT/">
+//	  <InstanceID val="0"><TransportState val="PLAYING"/></InstanceID>
+//	</Event>
+type lastChangeEvent struct {
+	InstanceIDs []struct {
+		Val            string `xml:"val,attr"`
+		TransportState *struct {
+			Val string `xml:"val,attr"`
+		} `xml:"TransportState"`
+	} `xml:"InstanceID"`
+}
+
+// SetOnTransportStateChanged 设置收到设备推送的传输状态时的回调
+// 回调在事件监听的goroutine中执行；设备不支持事件订阅或订阅失败时不会被调用
+func (dc *DeviceController) SetOnTransportStateChanged(callback func(TransportState)) {
+	dc.eventMu.Lock()
+	dc.onTransportState = callback
+	dc.eventMu.Unlock()
+}
+
+// UnsubscribeEvents 取消设备的事件订阅，没有订阅时不做任何事
+func (dc *DeviceController) UnsubscribeEvents() {
+	if dc.subscriptionMgr != nil {
+		dc.subscriptionMgr.stopSubscription()
+	}
+}
+
+// notifyTransportState 将设备推送的传输状态交给回调
+func (dc *DeviceController) notifyTransportState(state TransportState) {
+	dc.eventMu.Lock()
+	callback := dc.onTransportState
+	dc.eventMu.Unlock()
+	if callback != nil {
+		callback(state)
+	}
+}
+
+// eventSubscription 一次GENA事件订阅：本地监听NOTIFY请求，向设备订阅并在到期前续订
+type eventSubscription struct {
+	controller  *DeviceController
+	eventURL    string
+	callbackURL string
+
+	mu  sync.Mutex
+	sid string
+}
+
+// runEventSubscription 订阅设备的AVTransport事件，直到ctx取消时退订并关闭本地监听
+func runEventSubscription(ctx context.Context, controller *DeviceController, eventURL string) error {
+	listener, callbackURL, err := listenForEvents(eventURL)
+	if err != nil {
+		return err
+	}
+	sub := &eventSubscription{
+		controller:  controller,
+		eventURL:    eventURL,
+		callbackURL: callbackURL,
+	}
+	server := &http.Server{
+		Handler:           http.HandlerFunc(sub.handleNotify),
+		ReadHeaderTimeout: defaultHTTPTimeout,
+	}
+	go server.Serve(listener)
+	defer server.Close()
+
+	timeout, err := sub.subscribe(ctx)
+	if err != nil {
+		return err
+	}
+	defer sub.unsubscribe()
+
+	for {
+		timer := time.NewTimer(renewInterval(timeout))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil
+		case <-timer.C:
+		}
+
+		timeout, err = sub.renew(ctx)
+		if err == nil {
+			continue
+		}
+		// 设备重启或订阅已过期时续订会失败，重新订阅一次
+		log.Printf("续订设备事件失败，重新订阅: %v\n", err)
+		if timeout, err = sub.subscribe(ctx); err != nil {
+			return err
+		}
+	}
+}
+
+// listenForEvents 在设备可以访问的本地地址上监听NOTIFY请求，返回监听器和回调地址
+// 通过向设备地址建立UDP"连接"得到访问设备使用的本地IP，不会实际发送数据
+func listenForEvents(eventURL string) (net.Listener, string, error) {
+	u, err := url.Parse(eventURL)
+	if err != nil {
+		return nil, "", fmt.Errorf("无效的事件订阅地址: %w", err)
+	}
+	port := u.Port()
+	if port == "" {
+		port = "80"
+	}
+	conn, err := net.Dial("udp", net.JoinHostPort(u.Hostname(), port))
+	if err != nil {
+		return nil, "", fmt.Errorf("获取本地地址失败: %w", err)
+	}
+	localIP := conn.LocalAddr().(*net.UDPAddr).IP
+	conn.Close()
+
+	listener, err := net.Listen("tcp", net.JoinHostPort(localIP.String(), "0"))
+	if err != nil {
+		return nil, "", fmt.Errorf("监听设备事件失败: %w", err)
+	}
+	callbackURL := fmt.Sprintf("http://%s%s", listener.Addr().String(), eventCallbackPath)
+	return listener, callbackURL, nil
+}
+
+// subscribe 发送新的SUBSCRIBE请求，记录设备返回的SID，返回订阅时长
+func (s *eventSubscription) subscribe(ctx context.Context) (time.Duration, error) {
+	header := http.Header{}
+	header.Set("CALLBACK", "<"+s.callbackURL+">")
+	header.Set("NT", "upnp:event")
+	header.Set("TIMEOUT", formatSubscribeTimeout(eventSubscribeTimeout))
+	resp, err := s.send(ctx, "SUBSCRIBE", header)
+	if err != nil {
+		return 0, fmt.Errorf("订阅设备事件失败: %w", err)
+	}
+
+	sid := strings.TrimSpace(resp.Get("SID"))
+	if sid == "" {
+		return 0, fmt.Errorf("订阅设备事件失败: 设备没有返回SID")
+	}
+	s.mu.Lock()
+	s.sid = sid
+	s.mu.Unlock()
+	log.Printf("已订阅设备 %s 的事件: %s\n", s.controller.deviceInfo.FriendlyName, sid)
+	return parseSubscribeTimeout(resp.Get("TIMEOUT")), nil
+}
+
+// renew 使用当前的SID续订，返回新的订阅时长
+func (s *eventSubscription) renew(ctx context.Context) (time.Duration, error) {
+	header := http.Header{}
+	header.Set("SID", s.currentSID())
+	header.Set("TIMEOUT", formatSubscribeTimeout(eventSubscribeTimeout))
+	resp, err := s.send(ctx, "SUBSCRIBE", header)
+	if err != nil {
+		return 0, err
+	}
+	return parseSubscribeTimeout(resp.Get("TIMEOUT")), nil
+}
+
+// unsubscribe 退订当前的SID，订阅的ctx已经取消，因此使用单独的超时
+func (s *eventSubscription) unsubscribe() {
+	ctx, cancel := context.WithTimeout(context.Background(), s.controller.httpTimeout())
+	defer cancel()
+	header := http.Header{}
+	header.Set("SID", s.currentSID())
+	if _, err := s.send(ctx, "UNSUBSCRIBE", header); err != nil {
+		log.Printf("退订设备事件失败: %v\n", err)
+		return
+	}
+	log.Printf("已退订设备 %s 的事件\n", s.controller.deviceInfo.FriendlyName)
+}
+
+// send 向事件订阅地址发送GENA请求，状态码不是200时返回错误
+func (s *eventSubscription) send(ctx context.Context, method string, header http.Header) (http.Header, error) {
+	req, err := http.NewRequestWithContext(ctx, method, s.eventURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("创建%s请求失败: %w", method, err)
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+
+	client := http.Client{Timeout: s.controller.httpTimeout()}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("发送%s请求失败: %w", method, err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s请求失败，状态码: %d", method, resp.StatusCode)
+	}
+	return resp.Header, nil
+}
+
+// currentSID 返回当前订阅的SID
+func (s *eventSubscription) currentSID() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sid
+}
+
+// handleNotify 处理设备发送的NOTIFY请求，从LastChange中取出传输状态
+// 设备可能在SUBSCRIBE响应之前发送第一个NOTIFY，此时还不知道SID，不做校验
+func (s *eventSubscription) handleNotify(w http.ResponseWriter, req *http.Request) {
+	if req.Method != "NOTIFY" || req.URL.Path != eventCallbackPath {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	if sid := s.currentSID(); sid != "" && req.Header.Get("SID") != sid {
+		http.Error(w, "Precondition Failed", http.StatusPreconditionFailed)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(req.Body, eventMaxNotifyBody))
+	if err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+
+	if state, ok := parseTransportStateEvent(body); ok {
+		log.Printf("设备 %s 推送传输状态: %s\n", s.controller.deviceInfo.FriendlyName, state)
+		s.controller.notifyTransportState(state)
+	}
+}
+
+// parseTransportStateEvent 从NOTIFY请求体中解析InstanceID 0的TransportState
+// LastChange的内容通常是转义后的XML，也有设备直接嵌入未转义的XML
+func parseTransportStateEvent(body []byte) (TransportState, bool) {
+	var propertySet eventPropertySet
+	if err := xml.Unmarshal(body, &propertySet); err != nil {
+		return "", false
+	}
+
+	for _, property := range propertySet.Properties {
+		lastChange := strings.TrimSpace(property.LastChange.Text)
+		if !strings.HasPrefix(lastChange, "<") {
+			lastChange = property.LastChange.Inner
+		}
+		var event lastChangeEvent
+		if err := xml.Unmarshal([]byte(lastChange), &event); err != nil {
+			continue
+		}
+		for _, instance := range event.InstanceIDs {
+			if instance.Val != "" && instance.Val != "0" {
+				continue
+			}
+			if instance.TransportState != nil && instance.TransportState.Val != "" {
+				return TransportState(instance.TransportState.Val), true
+			}
+		}
+	}
+	return "", false
+}
+
+// formatSubscribeTimeout 生成TIMEOUT请求头的值，例如 "Second-1800"
+func formatSubscribeTimeout(d time.Duration) string {
+	return fmt.Sprintf("Second-%d", int(d/time.Second))
+}
+
+// parseSubscribeTimeout 解析设备返回的TIMEOUT响应头
+// 无法解析或为infinite时按请求的订阅时长处理，仍然定期续订
+func parseSubscribeTimeout(value string) time.Duration {
+	seconds, ok := strings.CutPrefix(strings.TrimSpace(value), "Second-")
+	if !ok {
+		return eventSubscribeTimeout
+	}
+	n, err := strconv.Atoi(seconds)
+	if err != nil || n <= 0 {
+		return eventSubscribeTimeout
+	}
+	return time.Duration(n) * time.Second
+}
+
+// renewInterval 在订阅时长过半时续订，给网络延迟留出余量
+func renewInterval(timeout time.Duration) time.Duration {
+	return max(timeout/2, time.Second)
+}
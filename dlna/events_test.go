@@ -0,0 +1,218 @@
+package dlna
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// notifyBody 返回LastChange为lastChange的NOTIFY请求体
+func notifyBody(lastChange string) string {
+	return `<?xml version="1.0"?>
+<e:propertyset xmlns:e="urn:schemas-upnp-org:event-1-0">
+  <e:property><LastChange>` + lastChange + `</LastChange></e:property>
+</e:propertyset>`
+}
+
+func TestParseTransportStateEvent(t *testing.T) {
+	tests := []struct {
+		name      string
+		body      string
+		wantState TransportState
+		wantOK    bool
+	}{
+		{
+			name:      "escaped inner XML",
+			body:      notifyBody(`&lt;Event xmlns=&quot;urn:schemas-upnp-org:metadata-1-0/AVT/&quot;&gt;&lt;InstanceID val=&quot;0&quot;&gt;&lt;TransportState val=&quot;PLAYING&quot;/&gt;&lt;/InstanceID&gt;&lt;/Event&gt;`),
+			wantState: TransportStatePlaying,
+			wantOK:    true,
+		},
+		{
+			name:      "unescaped inner XML",
+			body:      notifyBody(`<Event xmlns="urn:schemas-upnp-org:metadata-1-0/AVT/"><InstanceID val="0"><TransportState val="PAUSED_PLAYBACK"/></InstanceID></Event>`),
+			wantState: TransportState("PAUSED_PLAYBACK"),
+			wantOK:    true,
+		},
+		{
+			name:      "CDATA inner XML",
+			body:      notifyBody(`<![CDATA[<Event><InstanceID val="0"><TransportState val="STOPPED"/></InstanceID></Event>]]>`),
+			wantState: TransportState("STOPPED"),
+			wantOK:    true,
+		},
+		{
+			name: "missing TransportState",
+			body: notifyBody(`&lt;Event&gt;&lt;InstanceID val=&quot;0&quot;&gt;&lt;RelativeTimePosition val=&quot;00:01:00&quot;/&gt;&lt;/InstanceID&gt;&lt;/Event&gt;`),
+		},
+		{
+			name: "other instance only",
+			body: notifyBody(`&lt;Event&gt;&lt;InstanceID val=&quot;1&quot;&gt;&lt;TransportState val=&quot;PLAYING&quot;/&gt;&lt;/InstanceID&gt;&lt;/Event&gt;`),
+		},
+		{name: "empty LastChange", body: notifyBody("")},
+		{name: "not XML", body: "PLAYING"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state, ok := parseTransportStateEvent([]byte(tt.body))
+			if state != tt.wantState || ok != tt.wantOK {
+				t.Errorf("parseTransportStateEvent() = %q, %v, want %q, %v", state, ok, tt.wantState, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestParseSubscribeTimeout(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{value: "Second-1800", want: 1800 * time.Second},
+		{value: " Second-300 ", want: 300 * time.Second},
+		{value: "infinite", want: eventSubscribeTimeout},
+		{value: "Second-infinite", want: eventSubscribeTimeout},
+		{value: "Second-0", want: eventSubscribeTimeout},
+		{value: "Second--5", want: eventSubscribeTimeout},
+		{value: "garbage", want: eventSubscribeTimeout},
+		{value: "", want: eventSubscribeTimeout},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			if got := parseSubscribeTimeout(tt.value); got != tt.want {
+				t.Errorf("parseSubscribeTimeout(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+// genaRequest 假设备收到的一个GENA请求
+type genaRequest struct {
+	method string
+	header http.Header
+}
+
+// genaDevice 记录SUBSCRIBE和UNSUBSCRIBE请求的假设备，订阅时长为timeout
+type genaDevice struct {
+	mu       sync.Mutex
+	requests []genaRequest
+	server   *httptest.Server
+}
+
+func newGENADevice(t *testing.T, sid, timeout string) *genaDevice {
+	t.Helper()
+	device := &genaDevice{}
+	device.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		device.mu.Lock()
+		device.requests = append(device.requests, genaRequest{method: r.Method, header: r.Header.Clone()})
+		device.mu.Unlock()
+		if r.Method == "SUBSCRIBE" {
+			w.Header().Set("SID", sid)
+			w.Header().Set("TIMEOUT", timeout)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(device.server.Close)
+	return device
+}
+
+// Requests 返回按顺序收到的请求
+func (d *genaDevice) Requests() []genaRequest {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]genaRequest(nil), d.requests...)
+}
+
+// waitForRequests 等待设备收到至少n个请求
+func (d *genaDevice) waitForRequests(t *testing.T, n int) []genaRequest {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if requests := d.Requests(); len(requests) >= n {
+			return requests
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("device received %d requests, want at least %d", len(d.Requests()), n)
+	return nil
+}
+
+func TestEventSubscriptionLifecycle(t *testing.T) {
+	const sid = "uuid:subscription-1"
+	device := newGENADevice(t, sid, "Second-2")
+	controller := &DeviceController{}
+	states := make(chan TransportState, 1)
+	controller.SetOnTransportStateChanged(func(state TransportState) { states <- state })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- runEventSubscription(ctx, controller, device.server.URL+"/avt/event") }()
+
+	// 第一次SUBSCRIBE带有回调地址和NT，不带SID
+	subscribe := device.waitForRequests(t, 1)[0]
+	callback := subscribe.header.Get("CALLBACK")
+	if subscribe.method != "SUBSCRIBE" || subscribe.header.Get("NT") != "upnp:event" || subscribe.header.Get("SID") != "" {
+		t.Errorf("first request = %s NT=%q SID=%q, want SUBSCRIBE with NT upnp:event and no SID", subscribe.method, subscribe.header.Get("NT"), subscribe.header.Get("SID"))
+	}
+	if !strings.HasPrefix(callback, "<http://") || !strings.HasSuffix(callback, eventCallbackPath+">") {
+		t.Fatalf("CALLBACK = %q, want <http://host:port%s>", callback, eventCallbackPath)
+	}
+	if got := subscribe.header.Get("TIMEOUT"); got != formatSubscribeTimeout(eventSubscribeTimeout) {
+		t.Errorf("TIMEOUT = %q, want %q", got, formatSubscribeTimeout(eventSubscribeTimeout))
+	}
+
+	// 订阅时长2秒，过半时使用记录的SID续订，续订不带CALLBACK和NT
+	renew := device.waitForRequests(t, 2)[1]
+	if renew.method != "SUBSCRIBE" || renew.header.Get("SID") != sid || renew.header.Get("CALLBACK") != "" || renew.header.Get("NT") != "" {
+		t.Errorf("renewal = %s SID=%q CALLBACK=%q NT=%q, want SUBSCRIBE with SID %s only", renew.method, renew.header.Get("SID"), renew.header.Get("CALLBACK"), renew.header.Get("NT"), sid)
+	}
+
+	// 回调地址接受带有正确SID的NOTIFY，拒绝其他SID
+	callbackURL := strings.Trim(callback, "<>")
+	for _, tt := range []struct {
+		sid  string
+		want int
+	}{
+		{sid: "uuid:other", want: http.StatusPreconditionFailed},
+		{sid: sid, want: http.StatusOK},
+	} {
+		req, err := http.NewRequest("NOTIFY", callbackURL, strings.NewReader(notifyBody(`&lt;Event&gt;&lt;InstanceID val=&quot;0&quot;&gt;&lt;TransportState val=&quot;PLAYING&quot;/&gt;&lt;/InstanceID&gt;&lt;/Event&gt;`)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("SID", tt.sid)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tt.want {
+			t.Errorf("NOTIFY with SID %s = %d, want %d", tt.sid, resp.StatusCode, tt.want)
+		}
+	}
+	select {
+	case state := <-states:
+		if state != TransportStatePlaying {
+			t.Errorf("notified state = %q, want PLAYING", state)
+		}
+	case <-time.After(time.Second):
+		t.Error("NOTIFY did not reach the transport state callback")
+	}
+
+	// 取消ctx后使用SID退订
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("runEventSubscription() error = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("runEventSubscription did not return after cancel")
+	}
+	requests := device.Requests()
+	last := requests[len(requests)-1]
+	if last.method != "UNSUBSCRIBE" || last.header.Get("SID") != sid {
+		t.Errorf("last request = %s SID=%q, want UNSUBSCRIBE with SID %s", last.method, last.header.Get("SID"), sid)
+	}
+}
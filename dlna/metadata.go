@@ -0,0 +1,71 @@
+package dlna
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// MediaMetadata 投屏时随媒体地址发送给设备的DIDL-Lite元数据
+type MediaMetadata struct {
+	// Title 设备上显示的标题
+	Title string
+	// MimeType 媒体的MIME类型，为空时使用video/mp4
+	MimeType string
+	// Class UPnP媒体类别，为空时使用object.item.videoItem
+	Class string
+	// SubtitleURL SRT格式的外挂字幕地址，为空表示没有外挂字幕
+	SubtitleURL string
+}
+
+// AudioItemClass 音乐曲目的UPnP媒体类别，用于仅投屏音频
+const AudioItemClass = "object.item.audioItem.musicTrack"
+
+// didl 生成媒体的DIDL-Lite描述
+// 有外挂字幕时同时写入三星设备使用的sec:CaptionInfoEx元素
+func (m MediaMetadata) didl(mediaURL string) string {
+	mimeType := m.MimeType
+	if mimeType == "" {
+		mimeType = "video/mp4"
+	}
+	class := m.Class
+	if class == "" {
+		class = "object.item.videoItem"
+	}
+
+	var b strings.Builder
+	b.WriteString(`<DIDL-Lite xmlns="urn:schemas-upnp-org:metadata-1-0/DIDL-Lite/" xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:upnp="urn:schemas-upnp-org:metadata-1-0/upnp/" xmlns:sec="http://www.sec.co.kr/">`)
+	b.WriteString(`<item id="0" parentID="-1" restricted="1">`)
+	fmt.Fprintf(&b, `<dc:title>%s</dc:title>`, escapeXML(m.Title))
+	fmt.Fprintf(&b, `<upnp:class>%s</upnp:class>`, escapeXML(class))
+	fmt.Fprintf(&b, `<res protocolInfo="http-get:*:%s:*">%s</res>`, escapeXML(mimeType), escapeXML(mediaURL))
+	if m.SubtitleURL != "" {
+		fmt.Fprintf(&b, `<sec:CaptionInfoEx sec:type="srt">%s</sec:CaptionInfoEx>`, escapeXML(m.SubtitleURL))
+	}
+	b.WriteString(`</item></DIDL-Lite>`)
+	return b.String()
+}
+
+// defaultMetadata 没有提供元数据时使用的元数据，标题为媒体地址中的文件名
+// 部分设备（例如三星、LG电视）在CurrentURIMetaData为空时拒绝播放或不显示标题
+func defaultMetadata(mediaURL string) *MediaMetadata {
+	title := mediaURL
+	if u, err := url.Parse(mediaURL); err == nil && u.Path != "" {
+		title = path.Base(u.Path)
+	}
+	return &MediaMetadata{Title: title}
+}
+
+// SupportsSidecarCaptions 设备是否支持通过CaptionInfo.sec加载外挂字幕
+func (dc *DeviceController) SupportsSidecarCaptions() bool {
+	return dc.Quirks.SidecarCaptions
+}
+
+// escapeXML 转义XML文本中的特殊字符
+func escapeXML(value string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(value))
+	return b.String()
+}
@@ -0,0 +1,59 @@
+package dlna
+
+import "time"
+
+// 设备控制器的默认设置，供调用方显示和保存默认值
+const (
+	// DefaultTimeout 获取设备描述和每个SOAP请求的默认HTTP超时
+	DefaultTimeout = defaultHTTPTimeout
+	// DefaultReadyDelay SetAVTransportURI成功后、发送Play前默认的等待时间
+	DefaultReadyDelay = deviceReadyDelay
+)
+
+// controllerOptions 创建设备控制器时的可选设置
+type controllerOptions struct {
+	timeout    time.Duration
+	readyDelay time.Duration
+}
+
+// ControllerOption 创建设备控制器时的可选设置，传给NewDeviceControllerWithContext
+type ControllerOption func(*controllerOptions)
+
+// WithTimeout 设置获取设备描述和每个SOAP请求的HTTP超时，默认5秒
+// 启动DMR应用较慢的电视可能需要更长的时间才会响应SetAVTransportURI，d不大于0时使用默认值
+func WithTimeout(d time.Duration) ControllerOption {
+	return func(o *controllerOptions) {
+		if d > 0 {
+			o.timeout = d
+		}
+	}
+}
+
+// WithReadyDelay 设置SetAVTransportURI成功后、发送Play前等待设备准备的时间，默认2秒，d为0时不等待
+func WithReadyDelay(d time.Duration) ControllerOption {
+	return func(o *controllerOptions) {
+		if d >= 0 {
+			o.readyDelay = d
+		}
+	}
+}
+
+// newControllerOptions 返回应用了opts的设置，没有指定的使用默认值
+func newControllerOptions(opts []ControllerOption) controllerOptions {
+	options := controllerOptions{
+		timeout:    defaultHTTPTimeout,
+		readyDelay: deviceReadyDelay,
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return options
+}
+
+// httpTimeout 返回控制器的HTTP超时，没有设置时使用默认值
+func (dc *DeviceController) httpTimeout() time.Duration {
+	if dc.Timeout > 0 {
+		return dc.Timeout
+	}
+	return defaultHTTPTimeout
+}
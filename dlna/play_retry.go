@@ -0,0 +1,48 @@
+package dlna
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+)
+
+// 初次Play的重试设置
+const (
+	// DefaultPlayRetryAttempts Play返回状态切换错误时默认的重试次数
+	DefaultPlayRetryAttempts = 3
+	// playRetryInitialDelay 第一次重试前的等待时间，之后每次翻倍
+	playRetryInitialDelay = 500 * time.Millisecond
+)
+
+// upnpTransitionNotAvailable 设备仍在加载媒体地址、暂时无法切换到播放状态时返回的UPnP错误码
+const upnpTransitionNotAvailable = 701
+
+// isTransitionNotAvailable 判断错误是否为设备返回的状态切换错误（701）
+func isTransitionNotAvailable(err error) bool {
+	var soapErr *SOAPError
+	return errors.As(err, &soapErr) && soapErr.Code == upnpTransitionNotAvailable
+}
+
+// playWithRetry 发送SetAVTransportURI之后的第一个Play请求
+// 设备仍在加载媒体地址时会返回状态切换错误，此时按递增的间隔重试，最多重试PlayRetryAttempts次，
+// 等待受ctx限制；其他错误直接返回
+func (dc *DeviceController) playWithRetry(ctx context.Context) error {
+	delay := playRetryInitialDelay
+	for attempt := 0; ; attempt++ {
+		err := dc.sendSOAPRequestWithContext(ctx, "Play", playXML)
+		if err == nil || attempt >= dc.PlayRetryAttempts || !isTransitionNotAvailable(err) {
+			return err
+		}
+
+		log.Printf("设备尚未准备好播放，%v后重试Play（第%d次）: %v\n", delay, attempt+1, err)
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+		delay *= 2
+	}
+}
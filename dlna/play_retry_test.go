@@ -0,0 +1,86 @@
+package dlna
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// faultUntil 前failures次收到Play时返回fault，之后成功
+func faultUntil(failures int, fault string) func(action string, attempt int) soapResponse {
+	return func(action string, attempt int) soapResponse {
+		if action == "Play" && attempt <= failures {
+			return soapResponse{status: http.StatusInternalServerError, body: fault}
+		}
+		return soapResponse{status: http.StatusOK}
+	}
+}
+
+func TestPlayWithRetry(t *testing.T) {
+	tests := []struct {
+		name         string
+		respond      func(action string, attempt int) soapResponse
+		retries      int
+		wantAttempts int
+		wantCode     int
+	}{
+		{name: "plays first time", respond: faultUntil(0, ""), retries: 3, wantAttempts: 1},
+		{name: "faults once then plays", respond: faultUntil(1, faultTransitionNotAvailable), retries: 3, wantAttempts: 2},
+		{name: "retries exhausted", respond: faultUntil(10, faultTransitionNotAvailable), retries: 1, wantAttempts: 2, wantCode: 701},
+		{name: "retry disabled", respond: faultUntil(1, faultTransitionNotAvailable), retries: 0, wantAttempts: 1, wantCode: 701},
+		{name: "other fault not retried", respond: faultUntil(1, faultResourceNotFound), retries: 3, wantAttempts: 1, wantCode: 716},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			device := newFakeSOAPDevice(t, tt.respond)
+			controller := device.Controller()
+			controller.PlayRetryAttempts = tt.retries
+
+			err := controller.playWithRetry(context.Background())
+			if attempts := len(device.Actions()); attempts != tt.wantAttempts {
+				t.Errorf("Play sent %d times, want %d", attempts, tt.wantAttempts)
+			}
+			if tt.wantCode == 0 {
+				if err != nil {
+					t.Fatalf("playWithRetry() error = %v", err)
+				}
+				return
+			}
+			var soapErr *SOAPError
+			if !errors.As(err, &soapErr) || soapErr.Code != tt.wantCode {
+				t.Errorf("playWithRetry() error = %v, want UPnP error %d", err, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestPlayWithRetryStopsWhenContextDone(t *testing.T) {
+	device := newFakeSOAPDevice(t, faultUntil(10, faultTransitionNotAvailable))
+	controller := device.Controller()
+	controller.PlayRetryAttempts = 3
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := controller.playWithRetry(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("playWithRetry() error = %v, want context.DeadlineExceeded", err)
+	}
+	if attempts := len(device.Actions()); attempts != 1 {
+		t.Errorf("Play sent %d times before the deadline, want 1", attempts)
+	}
+}
+
+func TestPlayMediaRetriesInitialPlay(t *testing.T) {
+	device := newFakeSOAPDevice(t, faultUntil(1, faultTransitionNotAvailable))
+	controller := device.Controller()
+	controller.PlayRetryAttempts = DefaultPlayRetryAttempts
+
+	if err := controller.PlayMediaWithContext(context.Background(), "http://192.168.1.2:8080/media/movie.mp4"); err != nil {
+		t.Fatalf("PlayMediaWithContext() error = %v", err)
+	}
+	if actions := strings.Join(device.Actions(), ","); !strings.HasSuffix(actions, "SetAVTransportURI,Play,Play") {
+		t.Errorf("actions = %s, want SetAVTransportURI followed by two Play requests", actions)
+	}
+}
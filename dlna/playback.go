@@ -0,0 +1,124 @@
+package dlna
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// ErrNotStarted 还没有通过该控制器在设备上开始投屏
+var ErrNotStarted = errors.New("尚未在该设备上开始投屏")
+
+// ErrSeekNotSupported 设备不支持按时间跳转（UPnP错误710）
+var ErrSeekNotSupported = errors.New("设备不支持按时间跳转")
+
+// upnpErrorSeekModeNotSupported 设备不支持请求的跳转方式
+const upnpErrorSeekModeNotSupported = 710
+
+// ErrActionNotSupported 设备没有实现请求的可选操作，例如不支持Next/Previous的设备
+// 调用方可以改为通过SetAVTransportURI逐个投屏
+var ErrActionNotSupported = errors.New("设备不支持该操作")
+
+// unsupportedActionErrors 表示设备没有实现该操作的UPnP错误码：
+// 401为无效操作，602为未实现的可选操作
+var unsupportedActionErrors = map[int]bool{
+	401: true,
+	602: true,
+}
+
+// PauseWithContext 暂停当前的播放，不需要重新连接设备
+// 设备尚未开始播放、已经暂停或已经停止时不发送Pause，直接返回nil；
+// 查询传输状态失败时仍然发送Pause，由设备决定；设备返回SOAP错误或非200状态码时返回说明原因的错误
+func (dc *DeviceController) PauseWithContext(ctx context.Context) error {
+	state, err := dc.GetTransportInfo(ctx)
+	if err == nil && state != "" && state != TransportStatePlaying && state != TransportStateTransitioning {
+		log.Printf("设备当前状态为%s，无需暂停\n", state)
+		return nil
+	}
+
+	if err := dc.sendSOAPRequestWithContext(ctx, "Pause", pauseXML); err != nil {
+		return fmt.Errorf("暂停播放失败: %w", err)
+	}
+	return nil
+}
+
+// StopWithContext 停止设备的播放，成功后结束事件订阅
+// 没有通过该控制器设置过媒体地址时返回ErrNotStarted；设备返回SOAP错误或非200状态码时返回说明原因的错误
+func (dc *DeviceController) StopWithContext(ctx context.Context) error {
+	if !dc.started.Load() {
+		return ErrNotStarted
+	}
+
+	if err := dc.sendSOAPRequestWithContext(ctx, "Stop", stopXML); err != nil {
+		return fmt.Errorf("停止播放失败: %w", err)
+	}
+	dc.started.Store(false)
+	if dc.subscriptionMgr != nil {
+		dc.subscriptionMgr.stopSubscription()
+	}
+	return nil
+}
+
+// SeekWithContext 跳转到当前媒体的指定位置，以REL_TIME方式发送HH:MM:SS格式的目标位置
+// position为负数时返回错误；设备不支持按时间跳转时返回包装了ErrSeekNotSupported的错误
+func (dc *DeviceController) SeekWithContext(ctx context.Context, position time.Duration) error {
+	if position < 0 {
+		return fmt.Errorf("无效的跳转位置: %s", position)
+	}
+
+	err := dc.sendSOAPRequestWithContext(ctx, "Seek", fmt.Sprintf(seekXMLTemplate, formatUPnPTime(position)))
+	var soapErr *SOAPError
+	if errors.As(err, &soapErr) && soapErr.Code == upnpErrorSeekModeNotSupported {
+		return fmt.Errorf("%w: %v", ErrSeekNotSupported, err)
+	}
+	if err != nil {
+		return fmt.Errorf("跳转失败: %w", err)
+	}
+	return nil
+}
+
+// formatUPnPTime 将时长格式化为UPnP使用的 H+:MM:SS，不足一秒的部分舍去
+func formatUPnPTime(d time.Duration) string {
+	seconds := int64(d / time.Second)
+	return fmt.Sprintf("%02d:%02d:%02d", seconds/3600, seconds%3600/60, seconds%60)
+}
+
+// NextWithContext 发送Next，让设备播放其播放列表中的下一项
+// 设备没有实现该操作时返回包装了ErrActionNotSupported的错误
+func (dc *DeviceController) NextWithContext(ctx context.Context) error {
+	return dc.sendOptionalAction(ctx, "Next", nextXML, "切换到下一项失败")
+}
+
+// PreviousWithContext 发送Previous，让设备播放其播放列表中的上一项
+// 设备没有实现该操作时返回包装了ErrActionNotSupported的错误
+func (dc *DeviceController) PreviousWithContext(ctx context.Context) error {
+	return dc.sendOptionalAction(ctx, "Previous", previousXML, "切换到上一项失败")
+}
+
+// sendOptionalAction 发送AVTransport的可选操作，将表示未实现的SOAP错误转换为ErrActionNotSupported
+func (dc *DeviceController) sendOptionalAction(ctx context.Context, action, body, failure string) error {
+	err := dc.sendSOAPRequestWithContext(ctx, action, body)
+	var soapErr *SOAPError
+	if errors.As(err, &soapErr) && (unsupportedActionErrors[soapErr.Code] || (soapErr.Code == 0 && soapErr.StatusCode == http.StatusNotImplemented)) {
+		return fmt.Errorf("%w: %v", ErrActionNotSupported, err)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", failure, err)
+	}
+	return nil
+}
+
+// SetNextMediaWithContext 发送SetNextAVTransportURI，让设备预先加载下一项媒体，
+// 当前媒体播放结束后无缝切换；metadata为DIDL-Lite元数据，为空时以地址中的文件名为标题生成
+// 应在当前媒体开始播放（Play成功）后立即调用，设备只会记住最后一次设置的下一项；
+// 设备没有实现该操作时返回包装了ErrActionNotSupported的错误，此时应在当前媒体结束后用SetAVTransportURI投屏
+func (dc *DeviceController) SetNextMediaWithContext(ctx context.Context, mediaURL, metadata string) error {
+	if metadata == "" {
+		metadata = defaultMetadata(mediaURL).didl(mediaURL)
+	}
+	body := fmt.Sprintf(setNextAVTransportXMLTemplate, escapeXML(mediaURL), escapeXML(metadata))
+	return dc.sendOptionalAction(ctx, "SetNextAVTransportURI", body, "设置下一项媒体失败")
+}
@@ -0,0 +1,85 @@
+package dlna
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// upnpFault 返回带有指定UPnP错误码的SOAP错误响应
+func upnpFault(code int) string {
+	return fmt.Sprintf(`<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/"><s:Body><s:Fault><faultcode>s:Client</faultcode><faultstring>UPnPError</faultstring><detail><UPnPError xmlns="urn:schemas-upnp-org:control-1-0"><errorCode>%d</errorCode></UPnPError></detail></s:Fault></s:Body></s:Envelope>`, code)
+}
+
+func TestNextPreviousUnsupportedMapping(t *testing.T) {
+	tests := []struct {
+		name            string
+		response        soapResponse
+		wantErr         bool
+		wantUnsupported bool
+	}{
+		{name: "success", response: soapResponse{status: http.StatusOK}},
+		{name: "invalid action 401", response: soapResponse{status: http.StatusInternalServerError, body: upnpFault(401)}, wantErr: true, wantUnsupported: true},
+		{name: "optional action not implemented 602", response: soapResponse{status: http.StatusInternalServerError, body: upnpFault(602)}, wantErr: true, wantUnsupported: true},
+		{name: "HTTP 501 without fault", response: soapResponse{status: http.StatusNotImplemented}, wantErr: true, wantUnsupported: true},
+		{name: "transition not available 701", response: soapResponse{status: http.StatusInternalServerError, body: upnpFault(701)}, wantErr: true},
+		{name: "HTTP 500 without fault", response: soapResponse{status: http.StatusInternalServerError}, wantErr: true},
+	}
+	actions := map[string]func(*DeviceController, context.Context) error{
+		"Next":     (*DeviceController).NextWithContext,
+		"Previous": (*DeviceController).PreviousWithContext,
+	}
+	for action, send := range actions {
+		for _, tt := range tests {
+			t.Run(action+"/"+tt.name, func(t *testing.T) {
+				device := newFakeSOAPDevice(t, func(string, int) soapResponse { return tt.response })
+
+				err := send(device.Controller(), context.Background())
+				if (err != nil) != tt.wantErr {
+					t.Fatalf("%sWithContext() error = %v, wantErr %v", action, err, tt.wantErr)
+				}
+				if got := errors.Is(err, ErrActionNotSupported); got != tt.wantUnsupported {
+					t.Errorf("errors.Is(%v, ErrActionNotSupported) = %v, want %v", err, got, tt.wantUnsupported)
+				}
+				if got := device.Actions(); len(got) != 1 || got[0] != action {
+					t.Errorf("actions = %v, want [%s]", got, action)
+				}
+			})
+		}
+	}
+}
+
+func TestSeekUnsupportedMapping(t *testing.T) {
+	tests := []struct {
+		name            string
+		position        time.Duration
+		response        soapResponse
+		wantErr         bool
+		wantUnsupported bool
+		wantSent        bool
+	}{
+		{name: "success", position: 90 * time.Second, response: soapResponse{status: http.StatusOK}, wantSent: true},
+		{name: "seek mode not supported 710", position: time.Minute, response: soapResponse{status: http.StatusInternalServerError, body: upnpFault(710)}, wantErr: true, wantUnsupported: true, wantSent: true},
+		{name: "illegal seek target 711", position: time.Hour, response: soapResponse{status: http.StatusInternalServerError, body: upnpFault(711)}, wantErr: true, wantSent: true},
+		{name: "negative position", position: -time.Second, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			device := newFakeSOAPDevice(t, func(string, int) soapResponse { return tt.response })
+
+			err := device.Controller().SeekWithContext(context.Background(), tt.position)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SeekWithContext() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := errors.Is(err, ErrSeekNotSupported); got != tt.wantUnsupported {
+				t.Errorf("errors.Is(%v, ErrSeekNotSupported) = %v, want %v", err, got, tt.wantUnsupported)
+			}
+			if sent := len(device.Actions()) > 0; sent != tt.wantSent {
+				t.Errorf("Seek sent = %v, want %v", sent, tt.wantSent)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package dlna
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// getPositionInfoXML GetPositionInfo请求模板
+const getPositionInfoXML = `<?xml version="1.0" encoding="utf-8"?>
+<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/">
+  <s:Body>
+    <u:GetPositionInfo xmlns:u="urn:schemas-upnp-org:service:AVTransport:1">
+      <InstanceID>0</InstanceID>
+    </u:GetPositionInfo>
+  </s:Body>
+</s:Envelope>`
+
+// getPositionInfoResponse GetPositionInfo的SOAP响应
+type getPositionInfoResponse struct {
+	Body struct {
+		Response struct {
+			TrackDuration string `xml:"TrackDuration"`
+			RelTime       string `xml:"RelTime"`
+		} `xml:"GetPositionInfoResponse"`
+	} `xml:"Body"`
+}
+
+// GetPositionInfo 发送GetPositionInfo查询当前的播放位置和媒体时长
+// 设备返回NOT_IMPLEMENTED、空值或0:00:00时对应的值为0，不视为错误；
+// 流式转码等时长未知的媒体通常没有时长
+func (dc *DeviceController) GetPositionInfo(ctx context.Context) (position, duration time.Duration, err error) {
+	respBody, err := dc.soapCallWithContext(ctx, "GetPositionInfo", getPositionInfoXML)
+	if err != nil {
+		return 0, 0, fmt.Errorf("获取播放位置失败: %w", err)
+	}
+
+	var info getPositionInfoResponse
+	if err := xml.Unmarshal(respBody, &info); err != nil {
+		return 0, 0, fmt.Errorf("解析播放位置失败: %w", err)
+	}
+	position, err = parseUPnPTime(info.Body.Response.RelTime)
+	if err != nil {
+		return 0, 0, err
+	}
+	duration, err = parseUPnPTime(info.Body.Response.TrackDuration)
+	if err != nil {
+		return 0, 0, err
+	}
+	return position, duration, nil
+}
+
+// parseUPnPTime 解析UPnP的时间格式 H+:MM:SS[.F+] 或 H+:MM:SS[.F0/F1]
+// 空值和NOT_IMPLEMENTED返回0
+func parseUPnPTime(text string) (time.Duration, error) {
+	text = strings.TrimSpace(text)
+	if text == "" || strings.EqualFold(text, "NOT_IMPLEMENTED") {
+		return 0, nil
+	}
+
+	parts := strings.Split(text, ":")
+	if len(parts) != 3 {
+		return 0, fmt.Errorf("无效的时间: %q", text)
+	}
+	hours, err := strconv.Atoi(strings.TrimPrefix(parts[0], "+"))
+	if err != nil || hours < 0 {
+		return 0, fmt.Errorf("无效的时间: %q", text)
+	}
+	minutes, err := strconv.Atoi(parts[1])
+	if err != nil || minutes < 0 || minutes > 59 {
+		return 0, fmt.Errorf("无效的时间: %q", text)
+	}
+
+	// 秒可以带小数部分，小数部分也可以是F0/F1形式的分数
+	secondsText, fraction, _ := strings.Cut(parts[2], ".")
+	seconds, err := strconv.Atoi(secondsText)
+	if err != nil || seconds < 0 || seconds > 59 {
+		return 0, fmt.Errorf("无效的时间: %q", text)
+	}
+	d := time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute + time.Duration(seconds)*time.Second
+	if numerator, denominator, ok := strings.Cut(fraction, "/"); ok {
+		n, errN := strconv.Atoi(numerator)
+		m, errM := strconv.Atoi(denominator)
+		if errN == nil && errM == nil && m > 0 {
+			d += time.Duration(n) * time.Second / time.Duration(m)
+		}
+	} else if fraction != "" {
+		if f, err := strconv.ParseFloat("0."+fraction, 64); err == nil {
+			d += time.Duration(f * float64(time.Second))
+		}
+	}
+	return d, nil
+}
@@ -0,0 +1,191 @@
+package dlna
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// uPNPConnectionManagerService ConnectionManager服务类型
+const uPNPConnectionManagerService = "urn:schemas-upnp-org:service:ConnectionManager:1"
+
+// getProtocolInfoXML GetProtocolInfo请求模板
+const getProtocolInfoXML = `<?xml version="1.0" encoding="utf-8"?>
+<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/">
+  <s:Body>
+    <u:GetProtocolInfo xmlns:u="urn:schemas-upnp-org:service:ConnectionManager:1">
+    </u:GetProtocolInfo>
+  </s:Body>
+</s:Envelope>`
+
+// getProtocolInfoResponse GetProtocolInfo的SOAP响应
+type getProtocolInfoResponse struct {
+	Body struct {
+		Response struct {
+			Source string `xml:"Source"`
+			Sink   string `xml:"Sink"`
+		} `xml:"GetProtocolInfoResponse"`
+	} `xml:"Body"`
+}
+
+// ProtocolInfo 渲染器支持的一种媒体格式
+// 对应protocolInfo字符串 "<protocol>:<network>:<contentFormat>:<additionalInfo>"，
+// 例如 "http-get:*:video/mp4:DLNA.ORG_PN=AVC_MP4_MP_HD_AAC"
+type ProtocolInfo struct {
+	Protocol       string
+	Network        string
+	ContentFormat  string
+	AdditionalInfo string
+}
+
+// Profile 返回additionalInfo中的DLNA.ORG_PN配置名称，没有时返回空字符串
+func (p ProtocolInfo) Profile() string {
+	for _, param := range strings.Split(p.AdditionalInfo, ";") {
+		if value, ok := strings.CutPrefix(strings.TrimSpace(param), "DLNA.ORG_PN="); ok {
+			return value
+		}
+	}
+	return ""
+}
+
+// ParseProtocolInfoList 解析以逗号分隔的protocolInfo列表，忽略格式不正确的项
+func ParseProtocolInfoList(list string) []ProtocolInfo {
+	infos := []ProtocolInfo{}
+	for _, item := range strings.Split(list, ",") {
+		parts := strings.SplitN(strings.TrimSpace(item), ":", 4)
+		if len(parts) != 4 || parts[2] == "" {
+			continue
+		}
+		infos = append(infos, ProtocolInfo{
+			Protocol:       parts[0],
+			Network:        parts[1],
+			ContentFormat:  strings.ToLower(parts[2]),
+			AdditionalInfo: parts[3],
+		})
+	}
+	return infos
+}
+
+// 视频编码名称（ffprobe的codec_name）对应的DLNA配置名称前缀
+var codecProfilePrefixes = map[string][]string{
+	"h264":       {"AVC_"},
+	"hevc":       {"HEVC_"},
+	"mpeg2video": {"MPEG_", "MPEG2_"},
+	"mpeg4":      {"MPEG4_"},
+	"vc1":        {"VC1_", "WMV"},
+}
+
+// SupportsMedia 判断渲染器是否原生支持指定MIME类型和视频编码的媒体
+// 协议必须为http-get且MIME类型匹配；声明了DLNA配置名称时还要求配置名称与视频编码对应，
+// 没有声明配置名称（"*"）时只按MIME类型判断
+func SupportsMedia(sink []ProtocolInfo, mimeType, videoCodec string) bool {
+	mimeType = strings.ToLower(mimeType)
+	videoCodec = strings.ToLower(videoCodec)
+	for _, info := range sink {
+		if info.Protocol != "http-get" || info.ContentFormat != mimeType {
+			continue
+		}
+		profile := info.Profile()
+		if profile == "" {
+			return true
+		}
+		for _, prefix := range codecProfilePrefixes[videoCodec] {
+			if strings.HasPrefix(strings.ToUpper(profile), prefix) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// 视频配置名称中表示分辨率等级的部分对应的最大视频高度
+// 例如 AVC_MP4_MP_HD_AAC 为高清（1080），MPEG_TS_SD_EU 为标清（576）
+var profileResolutionLevels = []struct {
+	marker    string
+	maxHeight int
+}{
+	{"_UHD", 0},
+	{"_4K", 0},
+	{"_HD", 1080},
+	{"_SD", 576},
+	{"MPEG_PS_PAL", 576},
+	{"MPEG_PS_NTSC", 480},
+}
+
+// MaxVideoHeight 根据渲染器声明的视频配置名称推断其能够播放的最大视频高度
+// 取所有视频配置中最高的等级；声明了超高清配置、存在没有配置名称（"*"）或无法识别等级的视频格式时，
+// 无法确定上限，返回0表示不限制
+func MaxVideoHeight(sink []ProtocolInfo) int {
+	maxHeight := 0
+	for _, info := range sink {
+		if info.Protocol != "http-get" || !strings.HasPrefix(info.ContentFormat, "video/") {
+			continue
+		}
+		profile := strings.ToUpper(info.Profile())
+		if profile == "" {
+			return 0
+		}
+		level := -1
+		for _, candidate := range profileResolutionLevels {
+			if strings.Contains(profile, candidate.marker) {
+				level = candidate.maxHeight
+				break
+			}
+		}
+		if level <= 0 {
+			return 0
+		}
+		if level > maxHeight {
+			maxHeight = level
+		}
+	}
+	return maxHeight
+}
+
+// GetProtocolInfoWithContext 通过ConnectionManager服务获取渲染器支持的媒体格式（Sink）
+func (dc *DeviceController) GetProtocolInfoWithContext(ctx context.Context) ([]ProtocolInfo, error) {
+	if dc.ConnectionManagerURL == "" {
+		return nil, fmt.Errorf("设备没有ConnectionManager服务")
+	}
+
+	respBody, err := soapCallToServiceWithContext(ctx, dc.httpTimeout(), dc.ConnectionManagerURL, uPNPConnectionManagerService, "GetProtocolInfo", getProtocolInfoXML)
+	if err != nil {
+		return nil, fmt.Errorf("获取设备支持的格式失败: %w", err)
+	}
+
+	var resp getProtocolInfoResponse
+	if err := xml.Unmarshal(respBody, &resp); err != nil {
+		return nil, fmt.Errorf("解析GetProtocolInfo响应失败: %w", err)
+	}
+	return ParseProtocolInfoList(resp.Body.Response.Sink), nil
+}
+
+// CapabilityCache 按设备缓存渲染器支持的媒体格式，避免每次投屏都查询
+type CapabilityCache struct {
+	mu      sync.RWMutex
+	entries map[string][]ProtocolInfo
+}
+
+// NewCapabilityCache 创建一个新的设备能力缓存
+func NewCapabilityCache() *CapabilityCache {
+	return &CapabilityCache{
+		entries: make(map[string][]ProtocolInfo),
+	}
+}
+
+// Get 获取设备的缓存能力，没有缓存时第二个返回值为false
+func (c *CapabilityCache) Get(deviceKey string) ([]ProtocolInfo, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	sink, ok := c.entries[deviceKey]
+	return sink, ok
+}
+
+// Set 缓存设备的能力
+func (c *CapabilityCache) Set(deviceKey string, sink []ProtocolInfo) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[deviceKey] = sink
+}
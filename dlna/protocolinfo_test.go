@@ -0,0 +1,129 @@
+package dlna
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+// 录制自一台电视的GetProtocolInfo响应（已截短）
+const testSinkProtocols = "http-get:*:video/mp4:DLNA.ORG_PN=AVC_MP4_MP_HD_AAC;DLNA.ORG_OP=01," +
+	"http-get:*:video/x-matroska:DLNA.ORG_PN=HEVC_MKV_MAIN_HD," +
+	"http-get:*:video/mpeg:DLNA.ORG_PN=MPEG_PS_PAL," +
+	"http-get:*:audio/mpeg:*," +
+	"rtsp-rtp-udp:*:video/mp4:*," +
+	"broken-entry"
+
+func TestParseProtocolInfoList(t *testing.T) {
+	got := ParseProtocolInfoList(" http-get:*:Video/MP4:DLNA.ORG_PN=AVC_MP4_MP_HD_AAC;DLNA.ORG_OP=01 , http-get:*:audio/mpeg:*,http-get:*::*,broken")
+	want := []ProtocolInfo{
+		{Protocol: "http-get", Network: "*", ContentFormat: "video/mp4", AdditionalInfo: "DLNA.ORG_PN=AVC_MP4_MP_HD_AAC;DLNA.ORG_OP=01"},
+		{Protocol: "http-get", Network: "*", ContentFormat: "audio/mpeg", AdditionalInfo: "*"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseProtocolInfoList() = %+v, want %+v", got, want)
+	}
+	if profile := got[0].Profile(); profile != "AVC_MP4_MP_HD_AAC" {
+		t.Errorf("Profile() = %q, want AVC_MP4_MP_HD_AAC", profile)
+	}
+	if profile := got[1].Profile(); profile != "" {
+		t.Errorf("Profile() = %q, want empty", profile)
+	}
+}
+
+func TestSupportsMedia(t *testing.T) {
+	sink := ParseProtocolInfoList(testSinkProtocols)
+	tests := []struct {
+		name       string
+		mimeType   string
+		videoCodec string
+		want       bool
+	}{
+		{name: "h264 mp4", mimeType: "video/mp4", videoCodec: "h264", want: true},
+		{name: "hevc mp4 not declared", mimeType: "video/mp4", videoCodec: "hevc", want: false},
+		{name: "hevc mkv", mimeType: "video/x-matroska", videoCodec: "hevc", want: true},
+		{name: "h264 mkv not declared", mimeType: "video/x-matroska", videoCodec: "h264", want: false},
+		{name: "mpeg2", mimeType: "video/mpeg", videoCodec: "mpeg2video", want: true},
+		{name: "audio without profile", mimeType: "audio/mpeg", want: true},
+		{name: "mime type case", mimeType: "VIDEO/MP4", videoCodec: "H264", want: true},
+		{name: "unknown mime type", mimeType: "video/webm", videoCodec: "vp9", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SupportsMedia(sink, tt.mimeType, tt.videoCodec); got != tt.want {
+				t.Errorf("SupportsMedia(%q, %q) = %v, want %v", tt.mimeType, tt.videoCodec, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetProtocolInfoWithContext(t *testing.T) {
+	device := newFakeSOAPDevice(t, func(action string, attempt int) soapResponse {
+		return soapResponse{status: http.StatusOK, body: `<?xml version="1.0"?>
+<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/"><s:Body>
+<u:GetProtocolInfoResponse xmlns:u="urn:schemas-upnp-org:service:ConnectionManager:1">
+<Source></Source>
+<Sink>` + testSinkProtocols + `</Sink>
+</u:GetProtocolInfoResponse></s:Body></s:Envelope>`}
+	})
+	controller := device.Controller()
+	controller.ConnectionManagerURL = device.server.URL
+
+	sink, err := controller.GetProtocolInfoWithContext(context.Background())
+	if err != nil {
+		t.Fatalf("GetProtocolInfoWithContext() error = %v", err)
+	}
+	if len(sink) != 5 || !SupportsMedia(sink, "video/mp4", "h264") {
+		t.Errorf("GetProtocolInfoWithContext() = %+v, want the 5 valid sink entries", sink)
+	}
+
+	controller.ConnectionManagerURL = ""
+	if _, err := controller.GetProtocolInfoWithContext(context.Background()); err == nil {
+		t.Error("GetProtocolInfoWithContext() without ConnectionManager succeeded")
+	}
+}
+
+func TestCapabilityCache(t *testing.T) {
+	cache := NewCapabilityCache()
+	if _, ok := cache.Get("uuid:tv"); ok {
+		t.Fatal("Get() on an empty cache reported a hit")
+	}
+	sink := ParseProtocolInfoList(testSinkProtocols)
+	cache.Set("uuid:tv", sink)
+	got, ok := cache.Get("uuid:tv")
+	if !ok || !reflect.DeepEqual(got, sink) {
+		t.Errorf("Get() = %+v, %v, want the cached sink", got, ok)
+	}
+	cache.Set("uuid:tv", sink[:1])
+	if got, _ := cache.Get("uuid:tv"); len(got) != 1 {
+		t.Errorf("Get() after a second Set() = %+v, want the replaced sink", got)
+	}
+	if _, ok := cache.Get("uuid:other"); ok {
+		t.Error("Get() reported a hit for another device")
+	}
+}
+
+func TestMaxVideoHeight(t *testing.T) {
+	tests := []struct {
+		name string
+		sink string
+		want int
+	}{
+		{name: "HD profile", sink: "http-get:*:video/mp4:DLNA.ORG_PN=AVC_MP4_MP_HD_AAC,http-get:*:audio/mpeg:DLNA.ORG_PN=MP3", want: 1080},
+		{name: "unrecognised level", sink: "http-get:*:video/mpeg:DLNA.ORG_PN=MPEG_PS_PAL,http-get:*:video/mp4:DLNA.ORG_PN=AVC_MP4_BL_CIF15_AAC_520;DLNA.ORG_OP=01,http-get:*:video/mpeg:DLNA.ORG_PN=MPEG_TS_SD_EU", want: 0},
+		{name: "highest SD and HD level", sink: "http-get:*:video/mpeg:DLNA.ORG_PN=MPEG_TS_SD_EU,http-get:*:video/mp4:DLNA.ORG_PN=AVC_MP4_MP_HD_AAC", want: 1080},
+		{name: "NTSC only", sink: "http-get:*:video/mpeg:DLNA.ORG_PN=MPEG_PS_NTSC", want: 480},
+		{name: "UHD profile", sink: "http-get:*:video/mp4:DLNA.ORG_PN=AVC_MP4_HP_UHD_AAC,http-get:*:video/mp4:DLNA.ORG_PN=AVC_MP4_MP_HD_AAC", want: 0},
+		{name: "video without profile", sink: "http-get:*:video/mp4:*,http-get:*:video/mpeg:DLNA.ORG_PN=MPEG_TS_SD_EU", want: 0},
+		{name: "audio only", sink: "http-get:*:audio/mpeg:DLNA.ORG_PN=MP3", want: 0},
+		{name: "non HTTP protocol ignored", sink: "rtsp-rtp-udp:*:video/mp4:DLNA.ORG_PN=AVC_MP4_HP_UHD_AAC,http-get:*:video/mpeg:DLNA.ORG_PN=MPEG_TS_SD_EU", want: 576},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MaxVideoHeight(ParseProtocolInfoList(tt.sink)); got != tt.want {
+				t.Errorf("MaxVideoHeight() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package dlna
+
+import (
+	"strings"
+	"time"
+
+	"GoCastify/types"
+)
+
+// Quirks 针对特定渲染器的兼容性处理
+type Quirks struct {
+	// StopBeforeSetURI 设备处于播放状态时会忽略新的SetAVTransportURI，需要先发送Stop
+	StopBeforeSetURI bool
+	// SidecarCaptions 设备通过CaptionInfo.sec响应头和DIDL中的sec:CaptionInfoEx加载外挂字幕，
+	// 不需要将字幕转码进视频
+	SidecarCaptions bool
+	// SubscribeTiming 订阅设备事件的时机，默认在Play之后订阅
+	// 部分设备在设置媒体地址之前拒绝订阅，另一些设备则要求先订阅
+	// 提前订阅失败时会在Play之后再订阅一次
+	SubscribeTiming SubscribeTiming
+	// SubscribeDelay 到达订阅时机后再等待的时间，给加载媒体较慢的设备留出时间
+	SubscribeDelay time.Duration
+	// MaxVideoHeight 设备能够解码的最大视频高度，超过时需要转码缩小，0表示不限制
+	MaxVideoHeight int
+}
+
+// SubscribeTiming 事件订阅在投屏流程中的时机
+type SubscribeTiming int
+
+const (
+	// SubscribeAfterPlay 在Play成功之后订阅，默认值
+	SubscribeAfterPlay SubscribeTiming = iota
+	// SubscribeBeforeSetURI 在SetAVTransportURI之前订阅
+	SubscribeBeforeSetURI
+	// SubscribeAfterSetURI 在SetAVTransportURI成功之后、Play之前订阅
+	SubscribeAfterSetURI
+)
+
+// String 返回订阅时机的说明，用于日志
+func (t SubscribeTiming) String() string {
+	switch t {
+	case SubscribeBeforeSetURI:
+		return "设置媒体地址之前"
+	case SubscribeAfterSetURI:
+		return "设置媒体地址之后"
+	default:
+		return "开始播放之后"
+	}
+}
+
+// quirkRule 根据制造商和型号匹配的兼容性规则
+// 字段为空表示不限制，匹配时不区分大小写
+type quirkRule struct {
+	manufacturer string
+	modelName    string
+	quirks       Quirks
+}
+
+// 已知需要特殊处理的设备列表
+var knownQuirks = []quirkRule{
+	{manufacturer: "LG Electronics", quirks: Quirks{StopBeforeSetURI: true}},
+	{manufacturer: "Xiaomi", quirks: Quirks{StopBeforeSetURI: true}},
+	{manufacturer: "Hisense", quirks: Quirks{StopBeforeSetURI: true}},
+	{modelName: "Kodi", quirks: Quirks{StopBeforeSetURI: true}},
+	{manufacturer: "Samsung", quirks: Quirks{SidecarCaptions: true}},
+	{manufacturer: "Microsoft", modelName: "Xbox 360", quirks: Quirks{MaxVideoHeight: 1080}},
+	{modelName: "PlayStation 3", quirks: Quirks{MaxVideoHeight: 1080}},
+	{modelName: "WD TV", quirks: Quirks{MaxVideoHeight: 1080}},
+}
+
+// QuirksForDevice 根据设备信息返回其兼容性配置，未知设备返回零值
+func QuirksForDevice(info types.DeviceInfo) Quirks {
+	manufacturer := strings.ToLower(info.Manufacturer)
+	modelName := strings.ToLower(info.ModelName)
+
+	var result Quirks
+	for _, rule := range knownQuirks {
+		if rule.manufacturer != "" && !strings.Contains(manufacturer, strings.ToLower(rule.manufacturer)) {
+			continue
+		}
+		if rule.modelName != "" && !strings.Contains(modelName, strings.ToLower(rule.modelName)) {
+			continue
+		}
+		result.StopBeforeSetURI = result.StopBeforeSetURI || rule.quirks.StopBeforeSetURI
+		result.SidecarCaptions = result.SidecarCaptions || rule.quirks.SidecarCaptions
+		// 订阅时机取最后一条设置了非默认值的规则
+		if rule.quirks.SubscribeTiming != SubscribeAfterPlay {
+			result.SubscribeTiming = rule.quirks.SubscribeTiming
+		}
+		if rule.quirks.SubscribeDelay > result.SubscribeDelay {
+			result.SubscribeDelay = rule.quirks.SubscribeDelay
+		}
+		// 分辨率上限取所有匹配规则中最小的
+		if limit := rule.quirks.MaxVideoHeight; limit > 0 && (result.MaxVideoHeight == 0 || limit < result.MaxVideoHeight) {
+			result.MaxVideoHeight = limit
+		}
+	}
+	return result
+}
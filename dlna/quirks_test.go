@@ -0,0 +1,28 @@
+package dlna
+
+import (
+	"testing"
+
+	"GoCastify/types"
+)
+
+func TestQuirksMaxVideoHeight(t *testing.T) {
+	tests := []struct {
+		name   string
+		device types.DeviceInfo
+		want   int
+	}{
+		{name: "Xbox 360", device: types.DeviceInfo{Manufacturer: "Microsoft Corporation", ModelName: "Xbox 360"}, want: 1080},
+		{name: "PlayStation 3", device: types.DeviceInfo{Manufacturer: "Sony Computer Entertainment Inc.", ModelName: "PlayStation 3"}, want: 1080},
+		{name: "model match ignores case", device: types.DeviceInfo{ModelName: "wd tv live"}, want: 1080},
+		{name: "other Microsoft device", device: types.DeviceInfo{Manufacturer: "Microsoft Corporation", ModelName: "Xbox One"}, want: 0},
+		{name: "unknown device", device: types.DeviceInfo{Manufacturer: "Example", ModelName: "TV"}, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := QuirksForDevice(tt.device).MaxVideoHeight; got != tt.want {
+				t.Errorf("MaxVideoHeight = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
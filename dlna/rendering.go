@@ -0,0 +1,165 @@
+package dlna
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// uPNPRenderingControlService RenderingControl服务类型
+const uPNPRenderingControlService = "urn:schemas-upnp-org:service:RenderingControl:1"
+
+// ErrNoRenderingControl 设备没有提供RenderingControl服务，无法调节音量和静音
+var ErrNoRenderingControl = errors.New("该设备未提供RenderingControl服务，无法调节音量")
+
+// getVolumeXML GetVolume请求模板
+const getVolumeXML = `<?xml version="1.0" encoding="utf-8"?>
+<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/">
+  <s:Body>
+    <u:GetVolume xmlns:u="urn:schemas-upnp-org:service:RenderingControl:1">
+      <InstanceID>0</InstanceID>
+      <Channel>Master</Channel>
+    </u:GetVolume>
+  </s:Body>
+</s:Envelope>`
+
+// setVolumeXMLTemplate SetVolume请求模板，DesiredVolume为0-100的音量
+const setVolumeXMLTemplate = `<?xml version="1.0" encoding="utf-8"?>
+<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/">
+  <s:Body>
+    <u:SetVolume xmlns:u="urn:schemas-upnp-org:service:RenderingControl:1">
+      <InstanceID>0</InstanceID>
+      <Channel>Master</Channel>
+      <DesiredVolume>%d</DesiredVolume>
+    </u:SetVolume>
+  </s:Body>
+</s:Envelope>`
+
+// getMuteXML GetMute请求模板
+const getMuteXML = `<?xml version="1.0" encoding="utf-8"?>
+<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/">
+  <s:Body>
+    <u:GetMute xmlns:u="urn:schemas-upnp-org:service:RenderingControl:1">
+      <InstanceID>0</InstanceID>
+      <Channel>Master</Channel>
+    </u:GetMute>
+  </s:Body>
+</s:Envelope>`
+
+// setMuteXMLTemplate SetMute请求模板，DesiredMute为1（静音）或0（取消静音）
+const setMuteXMLTemplate = `<?xml version="1.0" encoding="utf-8"?>
+<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/">
+  <s:Body>
+    <u:SetMute xmlns:u="urn:schemas-upnp-org:service:RenderingControl:1">
+      <InstanceID>0</InstanceID>
+      <Channel>Master</Channel>
+      <DesiredMute>%d</DesiredMute>
+    </u:SetMute>
+  </s:Body>
+</s:Envelope>`
+
+// getVolumeResponse GetVolume的SOAP响应
+type getVolumeResponse struct {
+	Body struct {
+		Response struct {
+			CurrentVolume string `xml:"CurrentVolume"`
+		} `xml:"GetVolumeResponse"`
+	} `xml:"Body"`
+}
+
+// getMuteResponse GetMute的SOAP响应
+type getMuteResponse struct {
+	Body struct {
+		Response struct {
+			CurrentMute string `xml:"CurrentMute"`
+		} `xml:"GetMuteResponse"`
+	} `xml:"Body"`
+}
+
+// GetVolume 通过RenderingControl服务获取设备的主音量（0-100）
+// 设备没有RenderingControl服务时返回ErrNoRenderingControl
+func (dc *DeviceController) GetVolume(ctx context.Context) (int, error) {
+	if dc.RenderingControlURL == "" {
+		return 0, ErrNoRenderingControl
+	}
+
+	respBody, err := soapCallToServiceWithContext(ctx, dc.httpTimeout(), dc.RenderingControlURL, uPNPRenderingControlService, "GetVolume", getVolumeXML)
+	if err != nil {
+		return 0, fmt.Errorf("获取音量失败: %w", err)
+	}
+
+	var resp getVolumeResponse
+	if err := xml.Unmarshal(respBody, &resp); err != nil {
+		return 0, fmt.Errorf("解析GetVolume响应失败: %w", err)
+	}
+	volume, err := strconv.Atoi(strings.TrimSpace(resp.Body.Response.CurrentVolume))
+	if err != nil {
+		return 0, fmt.Errorf("无效的音量: %q", resp.Body.Response.CurrentVolume)
+	}
+	return volume, nil
+}
+
+// SetVolume 通过RenderingControl服务设置设备的主音量，level的范围为0-100
+// 设备没有RenderingControl服务时返回ErrNoRenderingControl
+func (dc *DeviceController) SetVolume(ctx context.Context, level int) error {
+	if dc.RenderingControlURL == "" {
+		return ErrNoRenderingControl
+	}
+	if level < 0 || level > 100 {
+		return fmt.Errorf("无效的音量: %d", level)
+	}
+
+	body := fmt.Sprintf(setVolumeXMLTemplate, level)
+	if _, err := soapCallToServiceWithContext(ctx, dc.httpTimeout(), dc.RenderingControlURL, uPNPRenderingControlService, "SetVolume", body); err != nil {
+		return fmt.Errorf("设置音量失败: %w", err)
+	}
+	return nil
+}
+
+// GetMute 通过RenderingControl服务获取设备的主声道是否静音
+// 设备没有RenderingControl服务时返回ErrNoRenderingControl
+func (dc *DeviceController) GetMute(ctx context.Context) (bool, error) {
+	if dc.RenderingControlURL == "" {
+		return false, ErrNoRenderingControl
+	}
+
+	respBody, err := soapCallToServiceWithContext(ctx, dc.httpTimeout(), dc.RenderingControlURL, uPNPRenderingControlService, "GetMute", getMuteXML)
+	if err != nil {
+		return false, fmt.Errorf("获取静音状态失败: %w", err)
+	}
+
+	var resp getMuteResponse
+	if err := xml.Unmarshal(respBody, &resp); err != nil {
+		return false, fmt.Errorf("解析GetMute响应失败: %w", err)
+	}
+	// UPnP的布尔值可以是1/0、true/false或yes/no
+	switch strings.ToLower(strings.TrimSpace(resp.Body.Response.CurrentMute)) {
+	case "1", "true", "yes":
+		return true, nil
+	case "0", "false", "no":
+		return false, nil
+	default:
+		return false, fmt.Errorf("无效的静音状态: %q", resp.Body.Response.CurrentMute)
+	}
+}
+
+// SetMute 通过RenderingControl服务设置设备的主声道静音或取消静音
+// 设备没有RenderingControl服务时返回ErrNoRenderingControl
+func (dc *DeviceController) SetMute(ctx context.Context, mute bool) error {
+	if dc.RenderingControlURL == "" {
+		return ErrNoRenderingControl
+	}
+
+	desiredMute := 0
+	if mute {
+		desiredMute = 1
+	}
+	body := fmt.Sprintf(setMuteXMLTemplate, desiredMute)
+	if _, err := soapCallToServiceWithContext(ctx, dc.httpTimeout(), dc.RenderingControlURL, uPNPRenderingControlService, "SetMute", body); err != nil {
+		return fmt.Errorf("设置静音失败: %w", err)
+	}
+	return nil
+}
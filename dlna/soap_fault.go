@@ -0,0 +1,136 @@
+package dlna
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// soapFaultEnvelope UPnP设备返回的SOAP错误响应
+// 例如：
+//
+//	<s:Envelope><s:Body><s:Fault>
+//	  <faultcode>s:Client</faultcode><faultstring>UPnPError</faultstring>
+//	  <detail><UPnPError><errorCode>716</errorCode><errorDescription>Resource not found</errorDescription></UPnPError></detail>
+//	</s:Fault></s:Body></s:Envelope>
+type soapFaultEnvelope struct {
+	Body struct {
+		Fault *struct {
+			FaultCode   string `xml:"faultcode"`
+			FaultString string `xml:"faultstring"`
+			Detail      struct {
+				UPnPError struct {
+					ErrorCode        string `xml:"errorCode"`
+					ErrorDescription string `xml:"errorDescription"`
+				} `xml:"UPnPError"`
+			} `xml:"detail"`
+		} `xml:"Fault"`
+	} `xml:"Body"`
+}
+
+// upnpErrorMessages 常见UPnP错误码对应的说明
+// 401-501和602为UPnP通用错误码，7xx为AVTransport服务定义的错误码
+var upnpErrorMessages = map[int]string{
+	401: "设备不支持该操作",
+	402: "请求参数无效",
+	501: "设备执行操作失败",
+	602: "设备未实现该可选操作",
+	701: "当前状态下无法执行该操作",
+	702: "设备中没有可播放的内容",
+	703: "设备读取媒体失败",
+	704: "设备不支持该媒体格式",
+	705: "设备的播放被锁定",
+	706: "设备写入失败",
+	710: "设备不支持该跳转方式",
+	711: "跳转位置无效",
+	714: "设备不支持该媒体类型（MIME类型）",
+	715: "媒体资源正忙",
+	716: "设备无法访问媒体地址",
+	718: "无效的实例ID",
+}
+
+// SOAPError SOAP请求失败时返回的错误，包含设备返回的UPnP错误信息
+type SOAPError struct {
+	// Action 失败的SOAP动作
+	Action string
+	// StatusCode HTTP状态码
+	StatusCode int
+	// Code UPnP错误码，设备没有返回错误码时为0
+	Code int
+	// Description 设备返回的错误描述
+	Description string
+}
+
+// Error 实现error接口，优先显示错误码对应的说明
+func (e *SOAPError) Error() string {
+	if e.Code == 0 {
+		if e.Description != "" {
+			return fmt.Sprintf("SOAP请求失败: %s, 状态码: %d, %s", e.Action, e.StatusCode, e.Description)
+		}
+		return fmt.Sprintf("SOAP请求失败: %s, 状态码: %d", e.Action, e.StatusCode)
+	}
+
+	message, ok := upnpErrorMessages[e.Code]
+	if !ok {
+		message = "设备返回错误"
+	}
+	if e.Description != "" {
+		return fmt.Sprintf("%s: %s (UPnP错误 %d: %s)", e.Action, message, e.Code, e.Description)
+	}
+	return fmt.Sprintf("%s: %s (UPnP错误 %d)", e.Action, message, e.Code)
+}
+
+// SOAPFault SOAPError的别名，可以通过errors.As取得设备返回的UPnP错误码，例如：
+//
+//	var fault *dlna.SOAPFault
+//	if errors.As(err, &fault) && fault.Code == 701 { ... }
+type SOAPFault = SOAPError
+
+// newSOAPError 根据HTTP状态码和响应体创建SOAP错误，响应体中的UPnP错误信息会被解析
+func newSOAPError(action string, statusCode int, body []byte) *SOAPError {
+	soapErr := &SOAPError{Action: action, StatusCode: statusCode}
+	code, description, ok := parseSOAPFault(body)
+	if ok {
+		soapErr.Code = code
+		soapErr.Description = description
+	}
+	return soapErr
+}
+
+// parseSOAPFault 解析SOAP错误响应中的UPnP错误码和描述
+// 响应体不是SOAP错误时第三个返回值为false
+func parseSOAPFault(body []byte) (int, string, bool) {
+	var envelope soapFaultEnvelope
+	if err := xml.Unmarshal(body, &envelope); err != nil || envelope.Body.Fault == nil {
+		return 0, "", false
+	}
+
+	fault := envelope.Body.Fault
+	description := strings.TrimSpace(fault.Detail.UPnPError.ErrorDescription)
+	code, err := strconv.Atoi(strings.TrimSpace(fault.Detail.UPnPError.ErrorCode))
+	if err != nil {
+		// 没有UPnP错误码时使用SOAP的faultstring
+		if description == "" {
+			description = strings.TrimSpace(fault.FaultString)
+		}
+		return 0, description, true
+	}
+	return code, description, true
+}
+
+// transientUPnPErrors 设备状态切换或资源暂时被占用时返回的错误码，稍后重试通常可以成功
+var transientUPnPErrors = map[int]bool{
+	501: true,
+	701: true,
+	715: true,
+}
+
+// Temporary 判断错误是否为暂时性的：设备正在切换状态、资源忙，
+// 或没有UPnP错误码的5xx响应；格式不支持、地址无法访问等错误重试也不会成功
+func (e *SOAPError) Temporary() bool {
+	if e.Code != 0 {
+		return transientUPnPErrors[e.Code]
+	}
+	return e.StatusCode >= 500
+}
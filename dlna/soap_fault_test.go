@@ -0,0 +1,226 @@
+package dlna
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 录制自真实设备的SOAP错误响应
+const (
+	faultResourceNotFound = `<?xml version="1.0" encoding="utf-8"?>
+<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/">
+  <s:Body>
+    <s:Fault>
+      <faultcode>s:Client</faultcode>
+      <faultstring>UPnPError</faultstring>
+      <detail>
+        <UPnPError xmlns="urn:schemas-upnp-org:control-1-0">
+          <errorCode>716</errorCode>
+          <errorDescription>Resource not found</errorDescription>
+        </UPnPError>
+      </detail>
+    </s:Fault>
+  </s:Body>
+</s:Envelope>`
+	faultTransitionNotAvailable = `<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/"><s:Body><s:Fault><faultcode>s:Client</faultcode><faultstring>UPnPError</faultstring><detail><UPnPError xmlns="urn:schemas-upnp-org:control-1-0"><errorCode> 701 </errorCode></UPnPError></detail></s:Fault></s:Body></s:Envelope>`
+	faultWithoutUPnPError       = `<SOAP-ENV:Envelope xmlns:SOAP-ENV="http://schemas.xmlsoap.org/soap/envelope/"><SOAP-ENV:Body><SOAP-ENV:Fault><faultcode>SOAP-ENV:Server</faultcode><faultstring>Internal Error</faultstring></SOAP-ENV:Fault></SOAP-ENV:Body></SOAP-ENV:Envelope>`
+	successResponse             = `<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/"><s:Body><u:PlayResponse xmlns:u="urn:schemas-upnp-org:service:AVTransport:1"/></s:Body></s:Envelope>`
+)
+
+var testSOAPActionPattern = regexp.MustCompile(`#(\w+)"?$`)
+
+// soapResponse 假设备对一个SOAP操作的响应
+type soapResponse struct {
+	status int
+	body   string
+}
+
+// fakeSOAPDevice 按操作名称返回预设响应的假设备，记录收到的操作
+type fakeSOAPDevice struct {
+	mu      sync.Mutex
+	actions []string
+	// respond 根据操作名称和第几次收到该操作（从1开始）返回响应
+	respond func(action string, attempt int) soapResponse
+	server  *httptest.Server
+}
+
+func newFakeSOAPDevice(t *testing.T, respond func(action string, attempt int) soapResponse) *fakeSOAPDevice {
+	t.Helper()
+	device := &fakeSOAPDevice{respond: respond}
+	device.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		match := testSOAPActionPattern.FindStringSubmatch(r.Header.Get("SOAPAction"))
+		if match == nil {
+			http.Error(w, "missing SOAPAction", http.StatusBadRequest)
+			return
+		}
+		action := match[1]
+		device.mu.Lock()
+		device.actions = append(device.actions, action)
+		attempt := 0
+		for _, received := range device.actions {
+			if received == action {
+				attempt++
+			}
+		}
+		device.mu.Unlock()
+
+		response := device.respond(action, attempt)
+		if response.body == "" && response.status == http.StatusOK {
+			response.body = fmt.Sprintf(`<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/"><s:Body><u:%sResponse xmlns:u="urn:schemas-upnp-org:service:AVTransport:1"/></s:Body></s:Envelope>`, action)
+		}
+		w.Header().Set("Content-Type", `text/xml; charset="utf-8"`)
+		w.WriteHeader(response.status)
+		io.WriteString(w, response.body)
+	}))
+	t.Cleanup(device.server.Close)
+	return device
+}
+
+// Actions 返回按顺序收到的SOAP操作
+func (d *fakeSOAPDevice) Actions() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]string(nil), d.actions...)
+}
+
+// Controller 返回控制该假设备的控制器
+func (d *fakeSOAPDevice) Controller() *DeviceController {
+	return &DeviceController{
+		ControlURL:  d.server.URL,
+		ServiceType: uPNPAVTransportService,
+	}
+}
+
+func TestParseSOAPFault(t *testing.T) {
+	tests := []struct {
+		name            string
+		body            string
+		wantCode        int
+		wantDescription string
+		wantOK          bool
+	}{
+		{name: "resource not found", body: faultResourceNotFound, wantCode: 716, wantDescription: "Resource not found", wantOK: true},
+		{name: "code without description", body: faultTransitionNotAvailable, wantCode: 701, wantOK: true},
+		{name: "fault without UPnPError", body: faultWithoutUPnPError, wantDescription: "Internal Error", wantOK: true},
+		{name: "success response", body: successResponse},
+		{name: "not XML", body: "Internal Server Error"},
+		{name: "empty body", body: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, description, ok := parseSOAPFault([]byte(tt.body))
+			if code != tt.wantCode || description != tt.wantDescription || ok != tt.wantOK {
+				t.Errorf("parseSOAPFault() = %d, %q, %v, want %d, %q, %v", code, description, ok, tt.wantCode, tt.wantDescription, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestSOAPErrorMessage(t *testing.T) {
+	tests := []struct {
+		name          string
+		err           *SOAPError
+		want          string
+		wantTemporary bool
+	}{
+		{
+			name: "known code with description",
+			err:  newSOAPError("SetAVTransportURI", 500, []byte(faultResourceNotFound)),
+			want: "SetAVTransportURI: 设备无法访问媒体地址 (UPnP错误 716: Resource not found)",
+		},
+		{
+			name:          "transient code",
+			err:           newSOAPError("Play", 500, []byte(faultTransitionNotAvailable)),
+			want:          "Play: 当前状态下无法执行该操作 (UPnP错误 701)",
+			wantTemporary: true,
+		},
+		{
+			name: "unknown code",
+			err:  &SOAPError{Action: "Play", StatusCode: 500, Code: 799},
+			want: "Play: 设备返回错误 (UPnP错误 799)",
+		},
+		{
+			name:          "fault string only",
+			err:           newSOAPError("Stop", 500, []byte(faultWithoutUPnPError)),
+			want:          "SOAP请求失败: Stop, 状态码: 500, Internal Error",
+			wantTemporary: true,
+		},
+		{
+			name: "client error without body",
+			err:  newSOAPError("Stop", 404, nil),
+			want: "SOAP请求失败: Stop, 状态码: 404",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.err.Error(); got != tt.want {
+				t.Errorf("Error() = %q, want %q", got, tt.want)
+			}
+			if got := tt.err.Temporary(); got != tt.wantTemporary {
+				t.Errorf("Temporary() = %v, want %v", got, tt.wantTemporary)
+			}
+		})
+	}
+}
+
+func TestSOAPCallReturnsFaultFromErrorStatus(t *testing.T) {
+	device := newFakeSOAPDevice(t, func(action string, attempt int) soapResponse {
+		return soapResponse{status: http.StatusInternalServerError, body: faultResourceNotFound}
+	})
+
+	_, err := soapCallToServiceWithContext(context.Background(), time.Second, device.server.URL, uPNPAVTransportService, "SetAVTransportURI", "<body/>")
+	var fault *SOAPFault
+	if !errors.As(err, &fault) {
+		t.Fatalf("soapCallToServiceWithContext() error = %v, want a SOAPFault", err)
+	}
+	if fault.Code != 716 || fault.StatusCode != http.StatusInternalServerError {
+		t.Errorf("fault = %+v, want code 716 with status 500", fault)
+	}
+}
+
+func TestSOAPCallFaultWithSuccessStatus(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		wantCode int
+		wantErr  bool
+	}{
+		{name: "UPnP fault", body: faultResourceNotFound, wantCode: 716, wantErr: true},
+		{name: "fault without UPnPError", body: faultWithoutUPnPError, wantErr: true},
+		{name: "success response", body: successResponse},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			device := newFakeSOAPDevice(t, func(action string, attempt int) soapResponse {
+				return soapResponse{status: http.StatusOK, body: tt.body}
+			})
+
+			body, err := soapCallToServiceWithContext(context.Background(), time.Second, device.server.URL, uPNPAVTransportService, "Play", "<body/>")
+			if !tt.wantErr {
+				if err != nil || string(body) != tt.body {
+					t.Fatalf("soapCallToServiceWithContext() = %q, %v, want the response body", body, err)
+				}
+				return
+			}
+			var soapErr *SOAPError
+			if !errors.As(err, &soapErr) {
+				t.Fatalf("soapCallToServiceWithContext() error = %v, want a SOAPError", err)
+			}
+			if soapErr.StatusCode != http.StatusOK || soapErr.Code != tt.wantCode || soapErr.Action != "Play" {
+				t.Errorf("error = %+v, want Play with status 200 and code %d", soapErr, tt.wantCode)
+			}
+			if body != nil {
+				t.Errorf("body = %q, want nil with the fault", body)
+			}
+		})
+	}
+}
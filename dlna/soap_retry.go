@@ -0,0 +1,64 @@
+package dlna
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// SOAP请求的重试设置
+const (
+	// DefaultSOAPRetryAttempts 连接失败或设备返回5xx时默认的重试次数
+	DefaultSOAPRetryAttempts = 3
+	// soapRetryInitialDelay 第一次重试前的等待时间，之后每次翻倍
+	soapRetryInitialDelay = 500 * time.Millisecond
+)
+
+// isRetryableSOAPError 判断SOAP请求失败后是否值得重试
+// 只重试连接失败（http.Client返回的*url.Error或读取响应时的net.Error）和没有UPnP错误码的5xx响应，
+// 这通常是设备还在启动媒体应用；4xx响应、设备返回的UPnP错误以及创建请求失败等本地错误
+// 是确定的结果，重试也不会成功；ctx取消或超时时不再重试
+func isRetryableSOAPError(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var soapErr *SOAPError
+	if errors.As(err, &soapErr) {
+		// 501表示设备没有实现该操作，同样是确定的结果
+		return soapErr.Code == 0 && soapErr.StatusCode >= 500 && soapErr.StatusCode != http.StatusNotImplemented
+	}
+	// 地址无法解析时同样返回*url.Error，这是确定的结果
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return urlErr.Op != "parse"
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// soapCallWithRetry 发送SOAP请求，暂时性的失败按递增的间隔重试，最多重试SOAPRetryAttempts次，
+// 等待受ctx限制
+// 这里只重试单个请求，与Play的重试和app层的投屏重试叠加时请求次数会相乘
+func (dc *DeviceController) soapCallWithRetry(ctx context.Context, call func() ([]byte, error)) ([]byte, error) {
+	delay := soapRetryInitialDelay
+	for attempt := 0; ; attempt++ {
+		respBody, err := call()
+		if err == nil || attempt >= dc.SOAPRetryAttempts || !isRetryableSOAPError(err) {
+			return respBody, err
+		}
+
+		log.Printf("SOAP请求失败，%v后重试（第%d次）: %v\n", delay, attempt+1, err)
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+		delay *= 2
+	}
+}
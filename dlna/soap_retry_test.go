@@ -0,0 +1,99 @@
+package dlna
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestIsRetryableSOAPError(t *testing.T) {
+	// 连接被拒绝时http.Client返回的错误
+	_, connErr := soapCallToServiceWithContext(context.Background(), time.Second, "http://127.0.0.1:1/", uPNPAVTransportService, "Play", "<body/>")
+	// 地址无效时创建请求失败
+	_, buildErr := soapCallToServiceWithContext(context.Background(), time.Second, "http://bad host/", uPNPAVTransportService, "Play", "<body/>")
+
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "connection refused", err: connErr, want: true},
+		{name: "5xx without fault", err: newSOAPError("Play", http.StatusServiceUnavailable, nil), want: true},
+		{name: "5xx with fault string only", err: newSOAPError("Play", http.StatusInternalServerError, []byte(faultWithoutUPnPError)), want: true},
+		{name: "UPnP fault", err: newSOAPError("Play", http.StatusInternalServerError, []byte(faultTransitionNotAvailable))},
+		{name: "4xx", err: newSOAPError("Play", http.StatusNotFound, nil)},
+		{name: "501 not implemented", err: newSOAPError("Next", http.StatusNotImplemented, nil)},
+		{name: "request not created", err: buildErr},
+		{name: "response not decoded", err: fmt.Errorf("解析响应失败: %w", errors.New("XML syntax error"))},
+		{name: "canceled", err: fmt.Errorf("发送SOAP请求失败: %w", context.Canceled)},
+		{name: "deadline exceeded", err: context.DeadlineExceeded},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.err == nil {
+				t.Fatal("test error is nil")
+			}
+			if got := isRetryableSOAPError(tt.err); got != tt.want {
+				t.Errorf("isRetryableSOAPError(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSOAPCallRetries(t *testing.T) {
+	tests := []struct {
+		name         string
+		status       int
+		body         string
+		failures     int
+		retries      int
+		wantAttempts int
+		wantErr      bool
+	}{
+		{name: "5xx then success", status: http.StatusServiceUnavailable, failures: 1, retries: 3, wantAttempts: 2},
+		{name: "retries exhausted", status: http.StatusInternalServerError, failures: 10, retries: 1, wantAttempts: 2, wantErr: true},
+		{name: "retry disabled", status: http.StatusInternalServerError, failures: 1, retries: 0, wantAttempts: 1, wantErr: true},
+		{name: "4xx not retried", status: http.StatusBadRequest, failures: 1, retries: 3, wantAttempts: 1, wantErr: true},
+		{name: "UPnP fault not retried", status: http.StatusInternalServerError, body: faultResourceNotFound, failures: 1, retries: 3, wantAttempts: 1, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			device := newFakeSOAPDevice(t, func(action string, attempt int) soapResponse {
+				if attempt <= tt.failures {
+					return soapResponse{status: tt.status, body: tt.body}
+				}
+				return soapResponse{status: http.StatusOK}
+			})
+			controller := device.Controller()
+			controller.SOAPRetryAttempts = tt.retries
+
+			_, err := controller.soapCallWithContext(context.Background(), "Stop", stopXML)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("soapCallWithContext() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if attempts := len(device.Actions()); attempts != tt.wantAttempts {
+				t.Errorf("Stop sent %d times, want %d", attempts, tt.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestSOAPCallRetryStopsWhenContextDone(t *testing.T) {
+	device := newFakeSOAPDevice(t, func(action string, attempt int) soapResponse {
+		return soapResponse{status: http.StatusServiceUnavailable}
+	})
+	controller := device.Controller()
+	controller.SOAPRetryAttempts = 3
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if _, err := controller.soapCallWithContext(ctx, "Stop", stopXML); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("soapCallWithContext() error = %v, want context.DeadlineExceeded", err)
+	}
+	if attempts := len(device.Actions()); attempts != 1 {
+		t.Errorf("Stop sent %d times before the deadline, want 1", attempts)
+	}
+}
@@ -0,0 +1,47 @@
+package dlna
+
+import (
+	"fmt"
+	"strings"
+)
+
+// AVTransportService 设备描述中的一个AVTransport服务
+// 多区域功放等复合设备为每个区域提供单独的AVTransport服务
+type AVTransportService struct {
+	// ServiceID 服务标识，例如 "urn:upnp-org:serviceId:AVTransport2"
+	ServiceID string
+	// ControlURL 完整的控制地址
+	ControlURL string
+	// EventURL 事件订阅地址
+	EventURL string
+	// ServiceType 设备声明的服务类型，例如 "urn:schemas-upnp-org:service:AVTransport:2"
+	ServiceType string
+}
+
+// Name 返回用于显示的服务名称，没有服务标识时使用控制地址
+func (s AVTransportService) Name() string {
+	if s.ServiceID == "" {
+		return s.ControlURL
+	}
+	if i := strings.LastIndex(s.ServiceID, ":"); i >= 0 && i < len(s.ServiceID)-1 {
+		return s.ServiceID[i+1:]
+	}
+	return s.ServiceID
+}
+
+// SelectTransport 选择之后的控制操作使用的AVTransport服务
+func (dc *DeviceController) SelectTransport(index int) error {
+	if index < 0 || index >= len(dc.Transports) {
+		return fmt.Errorf("无效的AVTransport服务序号: %d", index)
+	}
+	dc.selectedTransport = index
+	dc.ControlURL = dc.Transports[index].ControlURL
+	dc.EventURL = dc.Transports[index].EventURL
+	dc.ServiceType = dc.Transports[index].ServiceType
+	return nil
+}
+
+// SelectedTransport 返回当前使用的AVTransport服务序号
+func (dc *DeviceController) SelectedTransport() int {
+	return dc.selectedTransport
+}
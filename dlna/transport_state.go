@@ -0,0 +1,35 @@
+package dlna
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"strings"
+)
+
+// TransportState AVTransport服务的传输状态（CurrentTransportState）
+// 设备可能返回规范之外的状态，未知的状态原样保留
+type TransportState string
+
+// 常见的传输状态
+const (
+	TransportStatePlaying        TransportState = "PLAYING"
+	TransportStatePaused         TransportState = "PAUSED_PLAYBACK"
+	TransportStateStopped        TransportState = "STOPPED"
+	TransportStateTransitioning  TransportState = "TRANSITIONING"
+	TransportStateNoMediaPresent TransportState = "NO_MEDIA_PRESENT"
+)
+
+// GetTransportInfo 发送GetTransportInfo查询设备当前的传输状态
+func (dc *DeviceController) GetTransportInfo(ctx context.Context) (TransportState, error) {
+	respBody, err := dc.soapCallWithContext(ctx, "GetTransportInfo", getTransportInfoXML)
+	if err != nil {
+		return "", fmt.Errorf("获取设备传输状态失败: %w", err)
+	}
+
+	var info getTransportInfoResponse
+	if err := xml.Unmarshal(respBody, &info); err != nil {
+		return "", fmt.Errorf("解析传输状态失败: %w", err)
+	}
+	return TransportState(strings.TrimSpace(info.Body.Response.CurrentTransportState)), nil
+}
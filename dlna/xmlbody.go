@@ -0,0 +1,46 @@
+package dlna
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// utf8BOM UTF-8字节序标记，部分设备会在XML声明前输出
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// gzipMagic gzip数据的文件头
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// AcceptXMLEncodings 为获取设备描述的请求显式声明接受gzip编码
+// 显式设置后由ReadXMLBody负责解压，不依赖传输层的自动解压
+func AcceptXMLEncodings(req *http.Request) {
+	req.Header.Set("Accept-Encoding", "gzip")
+}
+
+// ReadXMLBody 读取设备返回的XML响应体
+// 响应为gzip编码（包括未声明Content-Encoding但内容为gzip的情况）时自动解压，
+// 并去掉XML声明前的UTF-8 BOM，分块传输的响应由net/http处理
+func ReadXMLBody(resp *http.Response) ([]byte, error) {
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("读取响应体失败: %w", err)
+	}
+
+	if strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") || bytes.HasPrefix(body, gzipMagic) {
+		reader, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, fmt.Errorf("解压响应体失败: %w", err)
+		}
+		defer reader.Close()
+		body, err = io.ReadAll(reader)
+		if err != nil {
+			return nil, fmt.Errorf("解压响应体失败: %w", err)
+		}
+	}
+
+	return bytes.TrimPrefix(body, utf8BOM), nil
+}
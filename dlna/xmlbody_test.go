@@ -0,0 +1,103 @@
+package dlna
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func gzipData(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	if _, err := writer.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestReadXMLBody(t *testing.T) {
+	xmlData := []byte(`<?xml version="1.0"?><root/>`)
+	withBOM := append(append([]byte{}, utf8BOM...), xmlData...)
+
+	tests := []struct {
+		name            string
+		contentEncoding string
+		body            []byte
+		chunked         bool
+		wantErr         bool
+	}{
+		{name: "plain", body: xmlData},
+		{name: "BOM", body: withBOM},
+		{name: "gzip with header", contentEncoding: "gzip", body: gzipData(t, xmlData)},
+		{name: "gzip without header", body: gzipData(t, xmlData)},
+		{name: "gzip with BOM", contentEncoding: "GZIP", body: gzipData(t, withBOM)},
+		{name: "chunked", body: withBOM, chunked: true},
+		{name: "corrupt gzip", contentEncoding: "gzip", body: []byte("not gzip"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("Accept-Encoding") != "gzip" {
+					t.Errorf("Accept-Encoding = %q, want gzip", r.Header.Get("Accept-Encoding"))
+				}
+				if tt.contentEncoding != "" {
+					w.Header().Set("Content-Encoding", tt.contentEncoding)
+				}
+				if !tt.chunked {
+					w.Write(tt.body)
+					return
+				}
+				// 分两次写入并刷新，使响应以分块编码传输
+				half := len(tt.body) / 2
+				w.Write(tt.body[:half])
+				w.(http.Flusher).Flush()
+				w.Write(tt.body[half:])
+			}))
+			defer server.Close()
+
+			req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+			AcceptXMLEncodings(req)
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			if tt.chunked && len(resp.TransferEncoding) == 0 {
+				t.Errorf("response was not chunked")
+			}
+
+			got, err := ReadXMLBody(resp)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ReadXMLBody() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !bytes.Equal(got, xmlData) {
+				t.Errorf("ReadXMLBody() = %q, want %q", got, xmlData)
+			}
+		})
+	}
+}
+
+func TestNewDeviceControllerGzipDescription(t *testing.T) {
+	body := gzipData(t, append(append([]byte{}, utf8BOM...), []byte(multiZoneDescription)...))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/xml")
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Write(body)
+	}))
+	defer server.Close()
+
+	controller, err := NewDeviceControllerWithContext(context.Background(), server.URL+"/desc.xml")
+	if err != nil {
+		t.Fatalf("NewDeviceControllerWithContext() error = %v", err)
+	}
+	if name := controller.GetDeviceInfo().FriendlyName; name != "AV Receiver" {
+		t.Errorf("FriendlyName = %q, want AV Receiver", name)
+	}
+}
@@ -4,6 +4,7 @@ go 1.24.2
 
 require (
 	fyne.io/fyne/v2 v2.5.4
+	github.com/fsnotify/fsnotify v1.7.0
 	github.com/koron/go-ssdp v0.1.0
 )
 
@@ -12,7 +13,6 @@ require (
 	github.com/BurntSushi/toml v1.4.0 // indirect
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/fredbi/uri v1.1.0 // indirect
-	github.com/fsnotify/fsnotify v1.7.0 // indirect
 	github.com/fyne-io/gl-js v0.0.0-20220119005834-d2da28d9ccfe // indirect
 	github.com/fyne-io/glfw-js v0.0.0-20241126112943-313d8a0fe1d0 // indirect
 	github.com/fyne-io/image v0.0.0-20220602074514-4956b0afb3d2 // indirect
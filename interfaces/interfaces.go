@@ -3,6 +3,7 @@ package interfaces
 import (
 	"context"
 	"net/http"
+	"time"
 	"GoCastify/types"
 )
 
@@ -12,6 +13,28 @@ type DLNAController interface {
 	PlayMediaWithContext(ctx context.Context, mediaURL string) error
 	// GetDeviceInfo 获取设备信息
 	GetDeviceInfo() types.DeviceInfo
+	// PauseWithContext 暂停当前的播放，设备没有在播放时不做任何事
+	PauseWithContext(ctx context.Context) error
+	// StopWithContext 停止设备的播放，没有通过该控制器开始投屏时返回错误
+	StopWithContext(ctx context.Context) error
+	// SeekWithContext 跳转到当前媒体的指定位置
+	SeekWithContext(ctx context.Context, position time.Duration) error
+	// NextWithContext 切换到设备播放列表中的下一项，设备不支持时返回错误
+	NextWithContext(ctx context.Context) error
+	// PreviousWithContext 切换到设备播放列表中的上一项，设备不支持时返回错误
+	PreviousWithContext(ctx context.Context) error
+	// SetNextMediaWithContext 预先设置播放结束后的下一项媒体，设备不支持时返回错误
+	SetNextMediaWithContext(ctx context.Context, mediaURL, metadata string) error
+	// GetVolume 获取设备的主音量（0-100），设备不支持调节音量时返回错误
+	GetVolume(ctx context.Context) (int, error)
+	// SetVolume 设置设备的主音量（0-100），设备不支持调节音量时返回错误
+	SetVolume(ctx context.Context, level int) error
+	// GetMute 获取设备是否静音，设备不支持调节音量时返回错误
+	GetMute(ctx context.Context) (bool, error)
+	// SetMute 设置设备静音或取消静音，设备不支持调节音量时返回错误
+	SetMute(ctx context.Context, mute bool) error
+	// GetPositionInfo 获取当前的播放位置和媒体时长，设备不提供的值为0
+	GetPositionInfo(ctx context.Context) (position, duration time.Duration, err error)
 }
 
 // MediaServer 媒体服务器接口
@@ -20,6 +43,16 @@ type MediaServer interface {
 	Start(mediaDir string) (string, error)
 	// Stop 停止媒体服务器
 	Stop() error
+	// StopServing 只停止HTTP服务并释放端口，保留转码器，之后可以再次调用Start
+	StopServing() error
+	// AllowFile 允许访问指定文件（及其同名外挂字幕）
+	AllowFile(filePath string)
+	// AliasFile 允许访问指定文件并为其注册不含原文件名的短别名，返回别名
+	AliasFile(filePath string) string
+	// ClearAllowedFiles 清空允许访问的文件列表和文件别名
+	ClearAllowedFiles()
+	// NotifyFirstRequest 在设备第一次请求指定文件时调用callback，只调用一次
+	NotifyFirstRequest(filePath string, callback func())
 	// ServeHTTP 处理HTTP请求
 	ServeHTTP(w http.ResponseWriter, r *http.Request)
 }
@@ -42,6 +75,9 @@ type MediaTranscoder interface {
 type DeviceDiscoverer interface {
 	// StartSearchWithContext 开始搜索DLNA设备
 	StartSearchWithContext(ctx context.Context, onDeviceFound func(types.DeviceInfo)) error
+	// StartMonitor 持续监听设备的上线和离开通告，直到ctx取消
+	// 设备上线时调用onFound，离开或通告过期时以设备的UDN调用onLost
+	StartMonitor(ctx context.Context, onFound func(types.DeviceInfo), onLost func(udn string)) error
 	// GetDevices 获取已发现的设备列表
 	GetDevices() []types.DeviceInfo
 }
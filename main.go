@@ -18,8 +18,8 @@ func main() {
 	// 设置窗口大小
 	window.Resize(fyne.NewSize(800, 600))
 
-	// 初始化应用程序核心逻辑
-	appInstance, err := app.NewApp(myApp, window)
+	// 初始化应用程序核心逻辑，使用默认的媒体服务器
+	appInstance, err := app.NewApp(myApp, window, nil)
 	if err != nil {
 		log.Printf("初始化应用失败: %v\n", err)
 		return
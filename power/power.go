@@ -0,0 +1,19 @@
+package power
+
+import "errors"
+
+// ErrUnsupported 当前系统不支持阻止休眠
+var ErrUnsupported = errors.New("当前系统不支持阻止休眠")
+
+// Inhibitor 阻止系统休眠的接口，各平台分别实现
+type Inhibitor interface {
+	// Inhibit 开始阻止系统因空闲而休眠，reason为向系统说明的原因；已经在阻止时不做任何事
+	Inhibit(reason string) error
+	// Release 解除阻止；没有在阻止时不做任何事
+	Release() error
+}
+
+// NewInhibitor 创建当前系统的休眠阻止器
+func NewInhibitor() Inhibitor {
+	return newPlatformInhibitor()
+}
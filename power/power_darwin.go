@@ -0,0 +1,16 @@
+package power
+
+import (
+	"os"
+	"strconv"
+)
+
+// newPlatformInhibitor macOS上使用caffeinate持有IOPMAssertion
+// -i阻止空闲休眠，-w使caffeinate在本进程退出（包括崩溃）时自动结束
+func newPlatformInhibitor() Inhibitor {
+	return &commandInhibitor{
+		command: func(reason string) []string {
+			return []string{"caffeinate", "-i", "-w", strconv.Itoa(os.Getpid())}
+		},
+	}
+}
@@ -0,0 +1,23 @@
+package power
+
+import (
+	"os"
+	"strconv"
+)
+
+// newPlatformInhibitor Linux上使用systemd-inhibit持有idle和sleep锁
+// 被持有锁的命令在本进程退出（包括崩溃）时自动结束，锁随之释放
+func newPlatformInhibitor() Inhibitor {
+	return &commandInhibitor{
+		command: func(reason string) []string {
+			return []string{
+				"systemd-inhibit",
+				"--what=idle:sleep",
+				"--who=GoCastify",
+				"--why=" + reason,
+				"--mode=block",
+				"tail", "--pid=" + strconv.Itoa(os.Getpid()), "-f", "/dev/null",
+			}
+		},
+	}
+}
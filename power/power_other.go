@@ -0,0 +1,21 @@
+//go:build !darwin && !linux && !windows
+
+package power
+
+// newPlatformInhibitor 其他系统不支持阻止休眠
+func newPlatformInhibitor() Inhibitor {
+	return unsupportedInhibitor{}
+}
+
+// unsupportedInhibitor 不支持阻止休眠的系统使用的实现，Inhibit总是返回ErrUnsupported
+type unsupportedInhibitor struct{}
+
+// Inhibit 返回ErrUnsupported
+func (unsupportedInhibitor) Inhibit(reason string) error {
+	return ErrUnsupported
+}
+
+// Release 不做任何事
+func (unsupportedInhibitor) Release() error {
+	return nil
+}
@@ -0,0 +1,67 @@
+//go:build darwin || linux
+
+package power
+
+import (
+	"fmt"
+	"log"
+	"os/exec"
+	"sync"
+	"syscall"
+)
+
+// commandInhibitor 通过运行一个持有休眠锁的子进程阻止休眠，结束子进程即解除
+// 用于macOS的caffeinate和Linux的systemd-inhibit
+type commandInhibitor struct {
+	mu sync.Mutex
+	// command 根据原因生成要运行的命令及参数
+	command func(reason string) []string
+	cmd     *exec.Cmd
+}
+
+// Inhibit 启动持有休眠锁的子进程
+func (ci *commandInhibitor) Inhibit(reason string) error {
+	ci.mu.Lock()
+	defer ci.mu.Unlock()
+	if ci.cmd != nil {
+		return nil
+	}
+
+	args := ci.command(reason)
+	if _, err := exec.LookPath(args[0]); err != nil {
+		return fmt.Errorf("未找到%s: %w", args[0], err)
+	}
+	cmd := exec.Command(args[0], args[1:]...)
+	// 子进程放在单独的进程组中，解除时连同其启动的命令一起结束
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("启动%s失败: %w", args[0], err)
+	}
+	ci.cmd = cmd
+	// 子进程意外退出时清除记录，下次投屏重新启动
+	go func() {
+		err := cmd.Wait()
+		ci.mu.Lock()
+		defer ci.mu.Unlock()
+		if ci.cmd == cmd {
+			log.Printf("阻止休眠的进程已退出: %v\n", err)
+			ci.cmd = nil
+		}
+	}()
+	return nil
+}
+
+// Release 结束持有休眠锁的子进程
+func (ci *commandInhibitor) Release() error {
+	ci.mu.Lock()
+	defer ci.mu.Unlock()
+	if ci.cmd == nil {
+		return nil
+	}
+	cmd := ci.cmd
+	ci.cmd = nil
+	if err := syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL); err != nil {
+		return fmt.Errorf("结束阻止休眠的进程失败: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package power
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"syscall"
+)
+
+// SetThreadExecutionState使用的标志
+const (
+	esContinuous     = 0x80000000
+	esSystemRequired = 0x00000001
+)
+
+var procSetThreadExecutionState = syscall.NewLazyDLL("kernel32.dll").NewProc("SetThreadExecutionState")
+
+// executionStateInhibitor Windows上通过SetThreadExecutionState阻止休眠
+// 该状态属于调用线程，因此在一个锁定到系统线程的goroutine中设置并保持，解除时在同一线程上恢复
+type executionStateInhibitor struct {
+	mu      sync.Mutex
+	release chan struct{}
+	done    chan struct{}
+}
+
+// newPlatformInhibitor Windows上使用SetThreadExecutionState
+func newPlatformInhibitor() Inhibitor {
+	return &executionStateInhibitor{}
+}
+
+// Inhibit 在专用线程上设置ES_SYSTEM_REQUIRED，阻止系统因空闲而休眠
+func (ei *executionStateInhibitor) Inhibit(reason string) error {
+	ei.mu.Lock()
+	defer ei.mu.Unlock()
+	if ei.release != nil {
+		return nil
+	}
+
+	release := make(chan struct{})
+	done := make(chan struct{})
+	result := make(chan error, 1)
+	go func() {
+		defer close(done)
+		runtime.LockOSThread()
+		defer runtime.UnlockOSThread()
+
+		if r, _, err := procSetThreadExecutionState.Call(esContinuous | esSystemRequired); r == 0 {
+			result <- fmt.Errorf("SetThreadExecutionState失败: %w", err)
+			return
+		}
+		result <- nil
+		<-release
+		procSetThreadExecutionState.Call(esContinuous)
+	}()
+	if err := <-result; err != nil {
+		return err
+	}
+	ei.release = release
+	ei.done = done
+	return nil
+}
+
+// Release 在设置状态的线程上恢复默认的休眠行为
+func (ei *executionStateInhibitor) Release() error {
+	ei.mu.Lock()
+	defer ei.mu.Unlock()
+	if ei.release == nil {
+		return nil
+	}
+	close(ei.release)
+	<-ei.done
+	ei.release = nil
+	ei.done = nil
+	return nil
+}
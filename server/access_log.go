@@ -0,0 +1,161 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// accessLogSize 访问日志保留的最近请求数量
+const accessLogSize = 200
+
+// AccessLogEntry 媒体服务器处理的一个HTTP请求
+type AccessLogEntry struct {
+	// Time 收到请求的时间
+	Time time.Time
+	// Method 请求方法
+	Method string
+	// Path 请求路径，包括查询参数
+	Path string
+	// Range 请求的Range头，没有时为空
+	Range string
+	// Status 响应状态码
+	Status int
+	// Bytes 响应体的字节数
+	Bytes int64
+	// RemoteAddr 请求方的地址
+	RemoteAddr string
+	// Duration 处理请求的耗时，流式响应包括整个传输过程
+	Duration time.Duration
+}
+
+// String 以一行文本描述请求，例如 "20:15:04 192.168.1.5:50012 GET /media/a.mkv Range=bytes=0- 206 1048576B 1.2s"
+func (e AccessLogEntry) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s %s %s", e.Time.Format("15:04:05"), e.RemoteAddr, e.Method, e.Path)
+	if e.Range != "" {
+		fmt.Fprintf(&b, " Range=%s", e.Range)
+	}
+	fmt.Fprintf(&b, " %d %dB %s", e.Status, e.Bytes, e.Duration.Round(time.Millisecond))
+	return b.String()
+}
+
+// accessLog 保存最近请求的环形缓冲区
+type accessLog struct {
+	mu      sync.Mutex
+	entries []AccessLogEntry
+	// next 下一条记录写入的位置，缓冲区写满后覆盖最早的记录
+	next int
+}
+
+// add 记录一个请求
+func (l *accessLog) add(entry AccessLogEntry) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.entries) < accessLogSize {
+		l.entries = append(l.entries, entry)
+		return
+	}
+	l.entries[l.next] = entry
+	l.next = (l.next + 1) % accessLogSize
+}
+
+// snapshot 按时间顺序返回所有记录的副本
+func (l *accessLog) snapshot() []AccessLogEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	entries := make([]AccessLogEntry, 0, len(l.entries))
+	entries = append(entries, l.entries[l.next:]...)
+	entries = append(entries, l.entries[:l.next]...)
+	return entries
+}
+
+// clear 清空记录
+func (l *accessLog) clear() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = nil
+	l.next = 0
+}
+
+// AccessLog 获取最近的请求记录，按时间从早到晚排列
+// 用于排查设备实际发送了哪些请求（探测、范围请求等）
+func (ms *MediaServer) AccessLog() []AccessLogEntry {
+	return ms.accessLog.snapshot()
+}
+
+// ClearAccessLog 清空请求记录
+func (ms *MediaServer) ClearAccessLog() {
+	ms.accessLog.clear()
+}
+
+// logAccess 记录经过next处理的请求；网页遥控器的轮询请求不记录，避免淹没设备的请求
+func (ms *MediaServer) logAccess(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, RemotePathPrefix) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		start := time.Now()
+		recorder := &accessRecorder{ResponseWriter: w}
+		next.ServeHTTP(recorder, r)
+		ms.accessLog.add(AccessLogEntry{
+			Time:       start,
+			Method:     r.Method,
+			Path:       r.URL.RequestURI(),
+			Range:      r.Header.Get("Range"),
+			Status:     recorder.status(),
+			Bytes:      recorder.bytes,
+			RemoteAddr: r.RemoteAddr,
+			Duration:   time.Since(start),
+		})
+	})
+}
+
+// accessRecorder 记录响应状态码和字节数的ResponseWriter
+type accessRecorder struct {
+	http.ResponseWriter
+	statusCode int
+	bytes      int64
+}
+
+// WriteHeader 记录状态码
+func (r *accessRecorder) WriteHeader(statusCode int) {
+	if r.statusCode == 0 {
+		r.statusCode = statusCode
+	}
+	r.ResponseWriter.WriteHeader(statusCode)
+}
+
+// Write 记录写入的字节数
+func (r *accessRecorder) Write(p []byte) (int, error) {
+	if r.statusCode == 0 {
+		r.statusCode = http.StatusOK
+	}
+	n, err := r.ResponseWriter.Write(p)
+	r.bytes += int64(n)
+	return n, err
+}
+
+// Flush 支持流式转码及时发送数据
+func (r *accessRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap 返回原始的ResponseWriter，供http.ResponseController使用
+func (r *accessRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// status 返回响应状态码，处理器没有写入任何内容时为200
+func (r *accessRecorder) status() int {
+	if r.statusCode == 0 {
+		return http.StatusOK
+	}
+	return r.statusCode
+}
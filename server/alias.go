@@ -0,0 +1,48 @@
+package server
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+// aliasHashLength 短别名中哈希部分的十六进制字符数
+const aliasHashLength = 12
+
+// MediaAlias 根据文件路径生成稳定的短别名，例如 "3f2a9c01b4d7.mkv"
+// 同一路径总是得到相同的别名；保留扩展名，部分设备根据扩展名判断媒体类型，
+// 扩展名需要转义时省略
+func MediaAlias(filePath string) string {
+	sum := sha1.Sum([]byte(filepath.Clean(filePath)))
+	alias := hex.EncodeToString(sum[:])[:aliasHashLength]
+	if ext := strings.ToLower(filepath.Ext(filePath)); ext != "" && url.PathEscape(ext) == ext {
+		alias += ext
+	}
+	return alias
+}
+
+// AliasFile 允许访问文件并为其注册短别名，返回别名
+// 设备通过 MediaPathPrefix+别名 访问文件，用于文件名过长或包含设备无法处理的字符的情况；
+// 别名与允许访问列表一起由ClearAllowedFiles清除
+func (ms *MediaServer) AliasFile(filePath string) string {
+	ms.AllowFile(filePath)
+
+	alias := MediaAlias(filePath)
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	ms.aliases[alias] = filepath.Clean(filePath)
+	return alias
+}
+
+// resolveMediaPath 将去掉前缀的请求路径解析为文件路径
+// 已注册的别名解析为对应的文件，其他路径相对于媒体目录
+func (ms *MediaServer) resolveMediaPath(requestPath string) string {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	if filePath, ok := ms.aliases[strings.TrimPrefix(requestPath, "/")]; ok {
+		return filePath
+	}
+	return filepath.Join(ms.mediaPath, requestPath)
+}
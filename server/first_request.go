@@ -0,0 +1,34 @@
+package server
+
+import (
+	"log"
+	"net/http"
+)
+
+// NotifyFirstRequest 在filePath下一次被设备以GET请求时调用callback，只调用一次
+// 用于区分设备已接受投屏地址但尚未开始读取和正在传输；预检使用的HEAD请求不会触发；
+// 再次调用会替换之前尚未触发的回调，callback为nil时取消等待；callback在单独的goroutine中执行
+func (ms *MediaServer) NotifyFirstRequest(filePath string, callback func()) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	ms.firstRequestFile = filePath
+	ms.onFirstRequest = callback
+}
+
+// signalFirstRequest 请求的是等待中的文件时触发回调
+func (ms *MediaServer) signalFirstRequest(r *http.Request, filePath string) {
+	if r.Method != http.MethodGet {
+		return
+	}
+	ms.mu.Lock()
+	callback := ms.onFirstRequest
+	if callback == nil || ms.firstRequestFile != filePath {
+		ms.mu.Unlock()
+		return
+	}
+	ms.onFirstRequest = nil
+	ms.mu.Unlock()
+
+	log.Printf("设备开始请求媒体文件: %s\n", filePath)
+	go callback()
+}
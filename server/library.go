@@ -0,0 +1,215 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"GoCastify/transcoder"
+)
+
+// 文件变化事件的防抖间隔，下载等操作会在短时间内产生大量事件
+const libraryDebounceInterval = 500 * time.Millisecond
+
+// Library 媒体库目录
+// 打开时扫描一次目录中的媒体文件，之后监视目录变化并自动更新文件列表
+// 只扫描和监视目录本身，不包含子目录
+type Library struct {
+	root    string
+	mu      sync.RWMutex
+	files   []string
+	watcher *fsnotify.Watcher
+	// debounce 合并短时间内的多个文件变化事件
+	debounce *time.Timer
+	// onChanged 文件列表变化时的回调，参数为新增和被删除的文件
+	onChanged func(added, removed []string)
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+// OpenLibrary 打开媒体库目录并扫描其中的媒体文件
+// onChanged在监视到文件增加、删除或重命名后调用；当前平台不支持文件监视时只扫描一次
+func OpenLibrary(root string, onChanged func(added, removed []string)) (*Library, error) {
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, fmt.Errorf("打开媒体库目录失败: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s 不是目录", root)
+	}
+
+	lib := &Library{
+		root:      filepath.Clean(root),
+		onChanged: onChanged,
+		closed:    make(chan struct{}),
+	}
+
+	files, err := scanLibrary(lib.root)
+	if err != nil {
+		return nil, err
+	}
+	lib.files = files
+
+	// 文件监视不可用时继续使用扫描结果，只是不会自动更新
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		log.Printf("当前平台不支持监视媒体库目录，仅扫描一次: %v\n", err)
+		return lib, nil
+	}
+	if err := watcher.Add(lib.root); err != nil {
+		log.Printf("监视媒体库目录失败，仅扫描一次: %v\n", err)
+		watcher.Close()
+		return lib, nil
+	}
+	lib.watcher = watcher
+	go lib.watch()
+
+	return lib, nil
+}
+
+// Root 获取媒体库目录
+func (lib *Library) Root() string {
+	return lib.root
+}
+
+// Files 获取媒体库中的媒体文件列表（按文件名排序）
+func (lib *Library) Files() []string {
+	lib.mu.RLock()
+	defer lib.mu.RUnlock()
+	files := make([]string, len(lib.files))
+	copy(files, lib.files)
+	return files
+}
+
+// Watching 返回媒体库是否在监视目录变化
+func (lib *Library) Watching() bool {
+	return lib.watcher != nil
+}
+
+// Rescan 重新扫描媒体库目录
+func (lib *Library) Rescan() error {
+	files, err := scanLibrary(lib.root)
+	if err != nil {
+		return err
+	}
+
+	lib.mu.Lock()
+	added, removed := diffFiles(lib.files, files)
+	lib.files = files
+	lib.mu.Unlock()
+
+	if (len(added) > 0 || len(removed) > 0) && lib.onChanged != nil {
+		lib.onChanged(added, removed)
+	}
+	return nil
+}
+
+// Close 停止监视媒体库目录
+func (lib *Library) Close() error {
+	var err error
+	lib.closeOnce.Do(func() {
+		close(lib.closed)
+		lib.mu.Lock()
+		if lib.debounce != nil {
+			lib.debounce.Stop()
+		}
+		lib.mu.Unlock()
+		if lib.watcher != nil {
+			err = lib.watcher.Close()
+		}
+	})
+	return err
+}
+
+// watch 处理文件监视事件，合并短时间内的事件后重新扫描
+func (lib *Library) watch() {
+	for {
+		select {
+		case <-lib.closed:
+			return
+		case event, ok := <-lib.watcher.Events:
+			if !ok {
+				return
+			}
+			// 只关心文件的增加、删除和重命名
+			if !event.Has(fsnotify.Create) && !event.Has(fsnotify.Remove) && !event.Has(fsnotify.Rename) {
+				continue
+			}
+			lib.scheduleRescan()
+		case err, ok := <-lib.watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Printf("监视媒体库目录出错: %v\n", err)
+		}
+	}
+}
+
+// scheduleRescan 在防抖间隔结束后重新扫描目录
+func (lib *Library) scheduleRescan() {
+	lib.mu.Lock()
+	defer lib.mu.Unlock()
+
+	if lib.debounce != nil {
+		lib.debounce.Stop()
+	}
+	lib.debounce = time.AfterFunc(libraryDebounceInterval, func() {
+		select {
+		case <-lib.closed:
+			return
+		default:
+		}
+		if err := lib.Rescan(); err != nil {
+			log.Printf("重新扫描媒体库目录失败: %v\n", err)
+		}
+	})
+}
+
+// scanLibrary 列出目录中受支持的媒体文件
+func scanLibrary(root string) ([]string, error) {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return nil, fmt.Errorf("读取媒体库目录失败: %w", err)
+	}
+
+	files := []string{}
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		filePath := filepath.Join(root, entry.Name())
+		if supported, _ := transcoder.IsSupportedFormat(filePath); supported {
+			files = append(files, filePath)
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// diffFiles 比较两次扫描的结果，返回新增和被删除的文件
+func diffFiles(before, after []string) (added, removed []string) {
+	beforeSet := make(map[string]bool, len(before))
+	for _, file := range before {
+		beforeSet[file] = true
+	}
+	afterSet := make(map[string]bool, len(after))
+	for _, file := range after {
+		afterSet[file] = true
+		if !beforeSet[file] {
+			added = append(added, file)
+		}
+	}
+	for _, file := range before {
+		if !afterSet[file] {
+			removed = append(removed, file)
+		}
+	}
+	return added, removed
+}
@@ -7,8 +7,10 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"mime"
 	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strconv"
@@ -21,91 +23,263 @@ import (
 const (
 	defaultBufferSize    = 32 * 1024  // 32KB 缓冲区
 	httpReadTimeout      = 30 * time.Second
-	httpWriteTimeout     = 30 * time.Second
-	httpIdleTimeout      = 120 * time.Second
+	// 不限制写超时：按需转码可能远超30秒才能输出第一个字节，长视频的传输也会持续很久
+	httpWriteTimeout     = 0
 	serverShutdownTimeout = 5 * time.Second
 )
 
+// DefaultIdleTimeout 保持空闲的keep-alive连接的默认时间
+// 渲染器暂停播放时通常保持连接但不发送请求，超时断开后恢复播放需要重新连接，部分设备会因此失败；
+// 较长的超时使暂停后的连接保持可用，代价是已离开的设备留下的空闲连接要更久才会释放
+const DefaultIdleTimeout = 10 * time.Minute
+
+// ServeMode 媒体服务器的文件提供模式
+type ServeMode int
+
+const (
+	// ServeModeSingleFile 仅提供被显式允许的文件（及其外挂字幕），默认模式
+	ServeModeSingleFile ServeMode = iota
+	// ServeModeDirectory 提供媒体目录下的所有文件，用于媒体库浏览，需要显式开启
+	ServeModeDirectory
+)
+
+// MediaPathPrefix 媒体文件的URL路径前缀，例如 http://host:port/media/movie.mp4
+// 其他路径保留给状态检查和后续的接口使用
+const MediaPathPrefix = "/media/"
+
+// SubtitlePathPrefix 外挂字幕的URL路径前缀，路径为媒体文件名加.srt，
+// 例如 http://host:port/subtitles/movie.mkv.srt?track=0
+const SubtitlePathPrefix = "/subtitles/"
+
+// captionInfoHeader 三星等设备读取外挂字幕地址使用的响应头
+const captionInfoHeader = "CaptionInfo.sec"
+
+// 与媒体文件同名的外挂字幕扩展名
+var sidecarSubtitleExts = []string{".srt", ".ass", ".ssa", ".vtt", ".sub"}
+
 // MediaServer 提供媒体文件的HTTP服务器
 // 实现interfaces.MediaServer接口
 type MediaServer struct {
 	httpServer *http.Server
 	port       int
+	// boundPort 当前实际监听的端口，未运行时为0
+	// 配置的端口被占用或为0时与port不同
+	boundPort  int
 	mediaPath  string
 	isRunning  bool
 	mu         sync.Mutex
 	transcoder interfaces.MediaTranscoder
+	// 文件提供模式及单文件模式下允许访问的文件列表
+	serveMode   ServeMode
+	servedFiles map[string]bool
+	// aliases 短别名到实际文件路径的映射，别名文件同时位于允许访问列表中
+	aliases map[string]string
+	// handler 路由所有请求的HTTP处理器
+	handler http.Handler
+	// 网页遥控器的控制接口和访问令牌，未启用时为空
+	remote      RemoteController
+	remoteToken string
+	// streamTranscode 边转码边输出，不等待转码完成
+	streamTranscode bool
+	// tryOriginalFirst 需要转码的文件先提供原文件，带transcode=1的请求才转码
+	tryOriginalFirst bool
+	// accessLog 最近的请求记录
+	accessLog accessLog
+	// rateLimit 上传限速（字节/秒），0表示不限速
+	// rateLimitPerConnection为true时每个连接单独限速，否则所有连接共享sharedLimiter
+	rateLimit              int64
+	rateLimitPerConnection bool
+	sharedLimiter          *rateLimiter
+	// idleTimeout 保持空闲连接的时间，在下次启动时生效
+	idleTimeout time.Duration
+	// preferredInterface 媒体地址优先使用的网络接口名称或IPv4地址，为空时自动选择
+	preferredInterface string
+	// 等待第一次请求的文件及其回调，触发后清空
+	firstRequestFile string
+	onFirstRequest   func()
 }
 
+// 确保MediaServer实现了interfaces.MediaServer接口
+var _ interfaces.MediaServer = (*MediaServer)(nil)
+
 // NewMediaServer 创建一个新的媒体服务器
 // 使用依赖注入模式，接受一个转码器参数
 func NewMediaServer(port int, mediaTranscoder interfaces.MediaTranscoder) *MediaServer {
 	// 如果没有提供转码器，使用默认转码器
 	if mediaTranscoder == nil {
-		defaultTranscoder, _ := transcoder.NewTranscoder()
+		defaultTranscoder, _ := transcoder.NewTranscoder(transcoder.DefaultTranscodeSettings())
 		mediaTranscoder = defaultTranscoder
 	}
 
-	return &MediaServer{
-		port:       port,
-		transcoder: mediaTranscoder,
+	ms := &MediaServer{
+		port:        port,
+		transcoder:  mediaTranscoder,
+		serveMode:   ServeModeSingleFile,
+		servedFiles: make(map[string]bool),
+		aliases:     make(map[string]string),
+		idleTimeout: DefaultIdleTimeout,
 	}
+	ms.handler = ms.newHandler()
+	return ms
+}
+
+// newHandler 创建HTTP路由：媒体文件位于MediaPathPrefix下，网页遥控器位于RemotePathPrefix下，根路径用于状态检查
+func (ms *MediaServer) newHandler() http.Handler {
+	handler := http.NewServeMux()
+	handler.Handle(MediaPathPrefix, http.StripPrefix(strings.TrimSuffix(MediaPathPrefix, "/"), http.HandlerFunc(ms.handleMediaRequest)))
+	handler.Handle(SubtitlePathPrefix, http.StripPrefix(strings.TrimSuffix(SubtitlePathPrefix, "/"), http.HandlerFunc(ms.handleSubtitleRequest)))
+	handler.HandleFunc(RemotePathPrefix, ms.handleRemotePage)
+	handler.HandleFunc(RemotePathPrefix+"/api/status", ms.handleRemoteStatus)
+	handler.HandleFunc(RemotePathPrefix+"/api/command", ms.handleRemoteCommand)
+	handler.HandleFunc("/", ms.handleRoot)
+	return ms.logAccess(handler)
+}
+
+// SetServeMode 设置文件提供模式，默认为ServeModeSingleFile
+func (ms *MediaServer) SetServeMode(mode ServeMode) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	ms.serveMode = mode
+}
+
+// AllowFile 将文件及其同名外挂字幕加入允许访问列表
+// 单文件模式下只有加入列表的文件才会被提供，媒体目录中的其他文件返回404
+func (ms *MediaServer) AllowFile(filePath string) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	cleanPath := filepath.Clean(filePath)
+	ms.servedFiles[cleanPath] = true
+
+	// 同时允许同名的外挂字幕文件
+	basePath := strings.TrimSuffix(cleanPath, filepath.Ext(cleanPath))
+	for _, ext := range sidecarSubtitleExts {
+		ms.servedFiles[basePath+ext] = true
+	}
+}
+
+// ClearAllowedFiles 清空允许访问的文件列表和文件别名
+func (ms *MediaServer) ClearAllowedFiles() {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	ms.servedFiles = make(map[string]bool)
+	ms.aliases = make(map[string]string)
+}
+
+// isFileAllowed 检查文件在当前模式下是否允许被访问
+// 目录模式下允许媒体目录中的所有文件，目录之外的文件仍然只允许列表中的文件
+func (ms *MediaServer) isFileAllowed(filePath string) bool {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	filePath = filepath.Clean(filePath)
+	if ms.serveMode == ServeModeDirectory && ms.mediaPath != "" {
+		if rel, err := filepath.Rel(ms.mediaPath, filePath); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return ms.servedFiles[filePath]
+}
+
+// currentMediaPath 获取当前的媒体路径，服务器运行期间可能被Start切换
+func (ms *MediaServer) currentMediaPath() string {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	return ms.mediaPath
 }
 
 // Start 启动媒体服务器
+// 返回时端口已经开始监听，调用方可以立即把媒体地址发送给设备；端口被占用等错误直接返回
 func (ms *MediaServer) Start(mediaPath string) (string, error) {
 	ms.mu.Lock()
 	defer ms.mu.Unlock()
 
 	if ms.isRunning {
-		// 如果服务器已经在运行，检查媒体路径是否相同
-		if ms.mediaPath == mediaPath {
-			// 路径相同，直接返回当前服务器URL
-			return ms.GetServerURL(), nil
-		}
-		// 路径不同，先停止服务器
-		ms.Stop()
+		// 服务器已经在运行时只切换媒体路径，请求处理时读取新的路径，
+		// 不需要重启服务器，也不会清理转码缓存
+		ms.mediaPath = mediaPath
+		return ms.serverURLLocked(), nil
 	}
 
 	// 设置媒体路径
 	ms.mediaPath = mediaPath
 
-	// 创建HTTP处理器
-	handler := http.NewServeMux()
-	// 处理根路径，提供媒体文件的目录列表
-		handler.HandleFunc("/", ms.handleMediaRequest)
-
-		// 创建HTTP服务器
+	// 先同步绑定端口，避免服务器尚未开始监听时设备就请求媒体地址
+	// 配置的端口被占用时改用系统分配的空闲端口
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", ms.port))
+	if err != nil && ms.port != 0 {
+		log.Printf("监听端口%d失败: %v，改用空闲端口\n", ms.port, err)
+		listener, err = net.Listen("tcp", ":0")
+	}
+	if err != nil {
+		return "", fmt.Errorf("监听端口%d失败: %w", ms.port, err)
+	}
+	ms.boundPort = listener.Addr().(*net.TCPAddr).Port
 
 	// 创建HTTP服务器
-	ms.httpServer = &http.Server{
-		Addr:         fmt.Sprintf(":%d", ms.port),
-		Handler:      handler,
-		ReadTimeout:  httpReadTimeout,
-		WriteTimeout: httpWriteTimeout,
-		IdleTimeout:  httpIdleTimeout,
-	}
+	httpServer := ms.newHTTPServer()
+	ms.httpServer = httpServer
 
-	// 在后台启动服务器
-	go func() {
-		log.Printf("媒体服务器启动在端口: %d\n", ms.port)
-		if err := ms.httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+	// 在后台处理请求
+	go func(port int) {
+		log.Printf("媒体服务器启动在端口: %d\n", port)
+		if err := httpServer.Serve(listener); err != nil && err != http.ErrServerClosed {
 			log.Printf("媒体服务器错误: %v\n", err)
 			ms.mu.Lock()
-			ms.isRunning = false
+			if ms.httpServer == httpServer {
+				ms.isRunning = false
+				ms.boundPort = 0
+			}
 			ms.mu.Unlock()
 		}
-	}()
+	}(ms.boundPort)
 
 	// 标记服务器为运行状态
 	ms.isRunning = true
 
-	// 返回服务器的URL
-	return ms.GetServerURL(), nil
+	// 返回服务器的URL，端口为本次实际监听的端口
+	return ms.serverURLLocked(), nil
 }
 
-// Stop 停止媒体服务器
+// Stop 停止媒体服务器并清理转码器资源，用于退出应用
 func (ms *MediaServer) Stop() error {
+	if err := ms.StopServing(); err != nil {
+		return err
+	}
+
+	// 清理转码器资源，服务器之前已经通过StopServing停止时也需要清理
+	if ms.transcoder != nil {
+		if cleanupErr := ms.transcoder.Cleanup(); cleanupErr != nil {
+			log.Printf("转码器清理错误: %v\n", cleanupErr)
+		}
+	}
+	return nil
+}
+
+// SetIdleTimeout 设置保持空闲连接的时间，timeout不大于0时使用DefaultIdleTimeout
+// 运行中的http.Server不能修改超时，新的值在媒体服务器下次启动时生效
+func (ms *MediaServer) SetIdleTimeout(timeout time.Duration) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	if timeout <= 0 {
+		timeout = DefaultIdleTimeout
+	}
+	ms.idleTimeout = timeout
+}
+
+// newHTTPServer 按当前的设置创建HTTP服务器，调用方需持有ms.mu
+func (ms *MediaServer) newHTTPServer() *http.Server {
+	return &http.Server{
+		Handler:      ms.handler,
+		ReadTimeout:  httpReadTimeout,
+		WriteTimeout: httpWriteTimeout,
+		IdleTimeout:  ms.idleTimeout,
+	}
+}
+
+// StopServing 停止HTTP服务并释放端口，转码器及其缓存保持可用，之后可以再次调用Start
+// 仍在传输的请求（例如流式转码）在serverShutdownTimeout内没有结束时被强制断开
+func (ms *MediaServer) StopServing() error {
 	ms.mu.Lock()
 	defer ms.mu.Unlock()
 
@@ -117,34 +291,75 @@ func (ms *MediaServer) Stop() error {
 	ctx, cancel := context.WithTimeout(context.Background(), serverShutdownTimeout)
 	defer cancel()
 
-	// 关闭服务器
-	err := ms.httpServer.Shutdown(ctx)
-	if err != nil {
-		log.Printf("媒体服务器关闭错误: %v\n", err)
-		return err
-	}
-
-	// 清理转码器资源
-	if ms.transcoder != nil {
-		if cleanupErr := ms.transcoder.Cleanup(); cleanupErr != nil {
-			log.Printf("转码器清理错误: %v\n", cleanupErr)
+	// 关闭服务器，等待超时后强制关闭剩余的连接
+	if err := ms.httpServer.Shutdown(ctx); err != nil {
+		log.Printf("媒体服务器关闭错误: %v，强制关闭\n", err)
+		if closeErr := ms.httpServer.Close(); closeErr != nil {
+			return closeErr
 		}
 	}
 
 	ms.isRunning = false
+	ms.boundPort = 0
+	ms.onFirstRequest = nil
 	log.Println("媒体服务器已停止")
 	return nil
 }
 
 // GetServerURL 获取媒体服务器的URL
+// 运行时使用当前实际监听的端口，每次重新启动后端口可能变化，调用方不应缓存返回值
 func (ms *MediaServer) GetServerURL() string {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	return ms.serverURLLocked()
+}
+
+// serverURLLocked 构建媒体服务器的URL，调用方需持有ms.mu
+// 未运行时使用配置的端口
+func (ms *MediaServer) serverURLLocked() string {
 	// 获取本地IP地址
-	ip := getLocalIP()
+	ip := getLocalIP(ms.preferredInterface)
 	if ip == "" {
 		ip = "localhost"
 	}
 
-	return fmt.Sprintf("http://%s:%d", ip, ms.port)
+	port := ms.boundPort
+	if port == 0 {
+		port = ms.port
+	}
+	return fmt.Sprintf("http://%s:%d", ip, port)
+}
+
+// SetPreferredInterface 设置媒体地址优先使用的网络接口，nameOrIP为接口名称或该接口的IPv4地址
+// 应与设备发现使用的接口一致，使发送给设备的地址在设备所在的网络中可以访问；
+// 为空或接口不可用时自动选择
+func (ms *MediaServer) SetPreferredInterface(nameOrIP string) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	ms.preferredInterface = nameOrIP
+}
+
+// ServeHTTP 处理HTTP请求，使MediaServer可以直接作为http.Handler使用
+func (ms *MediaServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ms.handler.ServeHTTP(w, r)
+}
+
+// handleRoot 处理媒体路径前缀以外的请求
+// 根路径返回服务器状态；为兼容旧版本发送的不带前缀的地址，被允许的文件仍然可以访问；
+// 其他请求（例如设备探测的/favicon.ico、/description.xml）直接返回404，不记录日志
+func (ms *MediaServer) handleRoot(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		fmt.Fprintln(w, "GoCastify媒体服务器运行中")
+		return
+	}
+
+	mediaPath := ms.currentMediaPath()
+	if mediaPath != "" && ms.isFileAllowed(filepath.Join(mediaPath, r.URL.Path)) {
+		ms.handleMediaRequest(w, r)
+		return
+	}
+	http.NotFound(w, r)
 }
 
 // handleMediaRequest 处理媒体文件请求
@@ -152,8 +367,15 @@ func (ms *MediaServer) handleMediaRequest(w http.ResponseWriter, r *http.Request
 	// 记录请求
 	log.Printf("收到请求: %s %s\n", r.Method, r.URL.Path)
 
-	// 获取请求的文件路径
-	filePath := filepath.Join(ms.mediaPath, r.URL.Path)
+	// 获取请求的文件路径，短别名解析为对应的文件
+	filePath := ms.resolveMediaPath(r.URL.Path)
+
+	// 单文件模式下拒绝访问未被允许的文件
+	if !ms.isFileAllowed(filePath) {
+		log.Printf("拒绝访问未被允许的文件: %s\n", filePath)
+		http.NotFound(w, r)
+		return
+	}
 
 	// 检查文件是否存在
 	if !ms.fileExists(filePath) {
@@ -170,6 +392,27 @@ func (ms *MediaServer) handleMediaRequest(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	// 设备开始读取当前投屏的文件
+	ms.signalFirstRequest(r, filePath)
+
+	// 使用外挂字幕时，通过响应头告知设备字幕地址，字幕不再转码进视频
+	if sidecarSubtitleIndex(r) >= 0 {
+		w.Header().Set(captionInfoHeader, captionURL(r, sidecarSubtitleIndex(r)))
+	}
+
+	// 仅投屏音频（audioonly=1）时只提供提取的音轨
+	if r.URL.Query().Get("audioonly") == "1" {
+		ms.handleAudioOnlyMedia(w, r, filePath)
+		return
+	}
+
+	// 设备原生支持该文件或用户选择强制投屏（original=1）时，不检查格式直接提供原文件，
+	// 是否能够播放由设备决定
+	if r.URL.Query().Get("original") == "1" {
+		ms.serveFileEfficiently(w, r, filePath)
+		return
+	}
+
 	// 检查是否需要转码
 	supported, needTranscode := transcoder.IsSupportedFormat(filePath)
 	if !supported {
@@ -178,12 +421,28 @@ func (ms *MediaServer) handleMediaRequest(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	// 如果不需要转码，直接提供文件
-	if !needTranscode {
+	// 不需要转码且没有指定起始位置和分辨率上限时直接提供文件
+	if !needTranscode && castOptions(r).IsZero() {
 		ms.serveFileEfficiently(w, r, filePath)
 		return
 	}
 
+	// 先尝试原文件：设备可能原生支持该文件，转码留给带transcode=1的回退请求
+	if ms.servesOriginalFirst(r) {
+		ms.serveFileEfficiently(w, r, filePath)
+		return
+	}
+
+	// HEAD请求只确认文件可以提供，不触发转码
+	// 范围支持与GET响应保持一致：完整的缓存文件支持范围请求，流式输出不支持
+	if r.Method == http.MethodHead {
+		subtitleTrackIndex, audioTrackIndex := ms.transcodeTracks(r)
+		w.Header().Set("Content-Type", "video/mp4")
+		setRangeSupportHeader(w, !ms.streamsTranscode(filePath, subtitleTrackIndex, audioTrackIndex, castOptions(r)))
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
 	// 处理需要转码的文件
 	ms.handleTranscodedMedia(w, r, filePath)
 }
@@ -224,11 +483,28 @@ func (ms *MediaServer) handleTranscodedMedia(w http.ResponseWriter, r *http.Requ
 	}
 
 	// 获取URL中的字幕轨道和音频轨道参数
-	subtitleTrackIndex := ms.parseTrackIndex(r.URL.Query().Get("subtitle"), "字幕")
-	audioTrackIndex := ms.parseTrackIndex(r.URL.Query().Get("audio"), "音频")
+	subtitleTrackIndex, audioTrackIndex := ms.transcodeTracks(r)
+	cast := castOptions(r)
 
-	// 转码文件
-	transcodedFile, err := ms.transcoder.TranscodeToMp4(filePath, subtitleTrackIndex, audioTrackIndex)
+	// 流式转码模式下边转码边输出，已有完整的转码缓存时仍然提供缓存文件
+	if ms.streamsTranscode(filePath, subtitleTrackIndex, audioTrackIndex, cast) {
+		ms.streamTranscodedMedia(w, r, filePath, subtitleTrackIndex, audioTrackIndex, cast)
+		return
+	}
+
+	// 转码文件，等待转码完成后提供完整的输出文件，因此可以正常响应跳转时的范围请求
+	// 设备在转码完成前断开连接时放弃等待，没有其他请求等待同一结果时终止转码
+	var transcodedFile string
+	var err error
+	if caster, ok := ms.transcoder.(castTranscoder); ok {
+		transcodedFile, err = caster.TranscodeToMp4ForCastWithContext(r.Context(), filePath, subtitleTrackIndex, audioTrackIndex, cast, nil)
+	} else {
+		transcodedFile, err = ms.transcoder.TranscodeToMp4(filePath, subtitleTrackIndex, audioTrackIndex)
+	}
+	if err != nil && r.Context().Err() != nil {
+		log.Printf("客户端已断开连接，停止等待转码: %s\n", filePath)
+		return
+	}
 	if err != nil {
 		http.Error(w, fmt.Sprintf("转码失败: %v", err), http.StatusInternalServerError)
 		log.Printf("转码失败: %v\n", err)
@@ -239,6 +515,144 @@ func (ms *MediaServer) handleTranscodedMedia(w http.ResponseWriter, r *http.Requ
 	ms.serveFileEfficiently(w, r, transcodedFile)
 }
 
+// transcodeTracks 获取请求中需要转码进视频的字幕轨道和音频轨道
+func (ms *MediaServer) transcodeTracks(r *http.Request) (subtitleTrackIndex, audioTrackIndex int) {
+	subtitleTrackIndex = ms.parseTrackIndex(r.URL.Query().Get("subtitle"), "字幕")
+	if sidecarSubtitleIndex(r) >= 0 {
+		// 字幕由设备通过外挂字幕地址单独加载
+		subtitleTrackIndex = -1
+	}
+	audioTrackIndex = ms.parseTrackIndex(r.URL.Query().Get("audio"), "音频")
+	return subtitleTrackIndex, audioTrackIndex
+}
+
+// castTranscoder 能够按本次投屏的起始位置和分辨率上限转码，并在请求取消时停止等待的转码器
+type castTranscoder interface {
+	TranscodeToMp4ForCastWithContext(ctx context.Context, inputFile string, subtitleTrackIndex int, audioTrackIndex int, cast transcoder.CastOptions, onProgress func(percent float64)) (string, error)
+}
+
+// castOptions 获取请求中本次投屏的转码选项：起始位置（start）和最大视频高度（maxheight）
+func castOptions(r *http.Request) transcoder.CastOptions {
+	cast := transcoder.CastOptions{StartOffset: startOffset(r)}
+	if maxHeight, err := strconv.Atoi(r.URL.Query().Get("maxheight")); err == nil && maxHeight > 0 {
+		cast.MaxHeight = maxHeight
+	}
+	return cast
+}
+
+// startOffset 获取请求中的起始位置（start参数，单位为秒），没有指定或无效时返回0
+func startOffset(r *http.Request) time.Duration {
+	seconds, err := strconv.ParseFloat(r.URL.Query().Get("start"), 64)
+	if err != nil || seconds <= 0 {
+		return 0
+	}
+	return time.Duration(seconds * float64(time.Second))
+}
+
+// audioExtractor 能够只转码媒体文件音轨的转码器
+type audioExtractor interface {
+	ExtractAudio(inputFile string, audioTrackIndex int) (string, error)
+}
+
+// handleAudioOnlyMedia 只提供媒体文件的音轨，用于仅投屏音频
+// audio参数指定音轨，提取结果为MP3文件
+func (ms *MediaServer) handleAudioOnlyMedia(w http.ResponseWriter, r *http.Request, filePath string) {
+	// HEAD请求只确认可以提供，不触发提取
+	if r.Method == http.MethodHead {
+		w.Header().Set("Content-Type", transcoder.AudioOnlyMIMEType)
+		setRangeSupportHeader(w, true)
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	extractor, ok := ms.transcoder.(audioExtractor)
+	if !ok {
+		http.Error(w, "不支持仅提取音频", http.StatusNotImplemented)
+		return
+	}
+	audioTrackIndex := ms.parseTrackIndex(r.URL.Query().Get("audio"), "音频")
+	audioFile, err := extractor.ExtractAudio(filePath, audioTrackIndex)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("提取音频失败: %v", err), http.StatusInternalServerError)
+		log.Printf("提取音频失败: %v\n", err)
+		return
+	}
+
+	ms.serveFileEfficiently(w, r, audioFile)
+}
+
+// subtitleExtractor 能够将内嵌字幕提取为SRT文件的转码器
+type subtitleExtractor interface {
+	ExtractSubtitle(inputFile string, subtitleTrackIndex int) (string, error)
+}
+
+// sidecarSubtitleIndex 返回请求中以外挂方式提供的字幕轨道，未使用外挂字幕时返回-1
+// 媒体地址带有sidecar=1时，subtitle参数指定的字幕以外挂字幕提供
+func sidecarSubtitleIndex(r *http.Request) int {
+	query := r.URL.Query()
+	if query.Get("sidecar") != "1" {
+		return -1
+	}
+	index, err := strconv.Atoi(query.Get("subtitle"))
+	if err != nil || index < 0 {
+		return -1
+	}
+	return index
+}
+
+// captionURL 构建媒体请求对应的外挂字幕地址，r.URL.Path为去掉媒体路径前缀后的文件路径
+func captionURL(r *http.Request, subtitleTrackIndex int) string {
+	return fmt.Sprintf("http://%s%s%s.srt?track=%d", r.Host, SubtitlePathPrefix,
+		url.PathEscape(strings.TrimPrefix(r.URL.Path, "/")), subtitleTrackIndex)
+}
+
+// handleSubtitleRequest 提供从媒体文件中提取的SRT外挂字幕
+// 请求路径为媒体文件路径加.srt，track参数为字幕轨道序号
+func (ms *MediaServer) handleSubtitleRequest(w http.ResponseWriter, r *http.Request) {
+	log.Printf("收到字幕请求: %s %s\n", r.Method, r.URL.Path)
+
+	mediaName, ok := strings.CutSuffix(r.URL.Path, ".srt")
+	track, err := strconv.Atoi(r.URL.Query().Get("track"))
+	if !ok || err != nil || track < 0 {
+		http.NotFound(w, r)
+		return
+	}
+
+	filePath := ms.resolveMediaPath(mediaName)
+	if !ms.isFileAllowed(filePath) || !ms.fileExists(filePath) {
+		http.NotFound(w, r)
+		return
+	}
+	ms.setCORSHeaders(w)
+
+	extractor, ok := ms.transcoder.(subtitleExtractor)
+	if !ok {
+		http.Error(w, "不支持提取字幕", http.StatusNotImplemented)
+		return
+	}
+	subtitleFile, err := extractor.ExtractSubtitle(filePath, track)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("提取字幕失败: %v", err), http.StatusInternalServerError)
+		log.Printf("提取字幕失败: %v\n", err)
+		return
+	}
+
+	file, err := os.Open(subtitleFile)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("无法打开字幕文件: %v", err), http.StatusInternalServerError)
+		return
+	}
+	defer file.Close()
+	fileInfo, err := file.Stat()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("无法读取字幕文件: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/srt; charset=utf-8")
+	http.ServeContent(w, r, fileInfo.Name(), fileInfo.ModTime(), file)
+}
+
 // parseTrackIndex 解析轨道索引参数
 func (ms *MediaServer) parseTrackIndex(param string, trackType string) int {
 	if param == "" {
@@ -255,7 +669,10 @@ func (ms *MediaServer) parseTrackIndex(param string, trackType string) int {
 }
 
 // serveFileEfficiently 高效地提供文件服务，支持范围请求和缓冲传输
+// 设置了上传限速时，响应体按限速发送
 func (ms *MediaServer) serveFileEfficiently(w http.ResponseWriter, req *http.Request, filePath string) {
+	w = ms.throttle(w, req)
+
 	// 检查文件是否存在
 	fileInfo, err := os.Stat(filePath)
 	if err != nil {
@@ -272,24 +689,7 @@ func (ms *MediaServer) serveFileEfficiently(w http.ResponseWriter, req *http.Req
 	defer file.Close()
 
 	// 设置内容类型
-	contentType := "application/octet-stream"
-	ext := strings.ToLower(filepath.Ext(filePath))
-	supportedMimeTypes := map[string]string{
-		".mp4":  "video/mp4",
-		".mkv":  "video/x-matroska",
-		".avi":  "video/x-msvideo",
-		".mov":  "video/quicktime",
-		".mp3":  "audio/mpeg",
-		".aac":  "audio/aac",
-		".flac": "audio/flac",
-		".jpg":  "image/jpeg",
-		".jpeg": "image/jpeg",
-		".png":  "image/png",
-	}
-	if mimeType, exists := supportedMimeTypes[ext]; exists {
-		contentType = mimeType
-	}
-	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Type", MIMEType(filePath))
 
 	// 文件大小
 	fileSize := fileInfo.Size()
@@ -300,7 +700,7 @@ func (ms *MediaServer) serveFileEfficiently(w http.ResponseWriter, req *http.Req
 	// 如果没有范围请求，使用http.ServeContent提供文件
 	if rangeHeader == "" {
 		w.Header().Set("Content-Length", strconv.FormatInt(fileSize, 10))
-		w.Header().Set("Accept-Ranges", "bytes")
+		setRangeSupportHeader(w, true)
 		http.ServeContent(w, req, fileInfo.Name(), fileInfo.ModTime(), file)
 		return
 	}
@@ -312,7 +712,7 @@ func (ms *MediaServer) serveFileEfficiently(w http.ResponseWriter, req *http.Req
 // handleRangeRequest 处理HTTP范围请求
 func (ms *MediaServer) handleRangeRequest(w http.ResponseWriter, req *http.Request, file *os.File, fileSize int64) {
 	// 设置接受范围头
-	w.Header().Set("Accept-Ranges", "bytes")
+	setRangeSupportHeader(w, true)
 
 	// 简单的范围请求处理逻辑
 	start := int64(0)
@@ -322,14 +722,24 @@ func (ms *MediaServer) handleRangeRequest(w http.ResponseWriter, req *http.Reque
 	rangeHeader := req.Header.Get("Range")
 	if len(rangeHeader) > 6 && rangeHeader[:6] == "bytes=" {
 		parts := strings.Split(rangeHeader[6:], "-")
-		if len(parts) > 0 && parts[0] != "" {
-			if s, err := strconv.ParseInt(parts[0], 10, 64); err == nil {
-				start = s
+		if len(parts) > 1 && parts[0] == "" && parts[1] != "" {
+			// 后缀范围 "bytes=-N" 表示文件的最后N个字节
+			if n, err := strconv.ParseInt(parts[1], 10, 64); err == nil && n > 0 {
+				start = fileSize - n
+				if start < 0 {
+					start = 0
+				}
 			}
-		}
-		if len(parts) > 1 && parts[1] != "" {
-			if e, err := strconv.ParseInt(parts[1], 10, 64); err == nil {
-				end = e
+		} else {
+			if len(parts) > 0 && parts[0] != "" {
+				if s, err := strconv.ParseInt(parts[0], 10, 64); err == nil {
+					start = s
+				}
+			}
+			if len(parts) > 1 && parts[1] != "" {
+				if e, err := strconv.ParseInt(parts[1], 10, 64); err == nil {
+					end = e
+				}
 			}
 		}
 	}
@@ -359,8 +769,53 @@ func (ms *MediaServer) handleRangeRequest(w http.ResponseWriter, req *http.Reque
 	io.CopyBuffer(w, reader, buffer)
 }
 
+// supportedMimeTypes 媒体文件扩展名对应的MIME类型
+var supportedMimeTypes = map[string]string{
+	".mp4":  "video/mp4",
+	".m4v":  "video/mp4",
+	".mkv":  "video/x-matroska",
+	".avi":  "video/x-msvideo",
+	".mov":  "video/quicktime",
+	".wmv":  "video/x-ms-wmv",
+	".flv":  "video/x-flv",
+	".mpg":  "video/mpeg",
+	".mpeg": "video/mpeg",
+	".webm": "video/webm",
+	".mp3":  "audio/mpeg",
+	".aac":  "audio/aac",
+	".flac": "audio/flac",
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".png":  "image/png",
+}
+
+// MIMEType 根据扩展名获取媒体文件的MIME类型
+// 不在支持列表中的扩展名按系统的MIME类型表推测，仍然未知时返回application/octet-stream
+func MIMEType(filePath string) string {
+	ext := strings.ToLower(filepath.Ext(filePath))
+	if mimeType, exists := supportedMimeTypes[ext]; exists {
+		return mimeType
+	}
+	if mimeType := mime.TypeByExtension(ext); mimeType != "" {
+		return mimeType
+	}
+	return "application/octet-stream"
+}
+
+// setRangeSupportHeader 声明响应是否支持范围请求
+// 完整的文件可以按字节跳转；边转码边输出的流无法满足任意范围，必须声明为none，
+// 否则设备跳转时发送的范围请求会导致播放失败
+func setRangeSupportHeader(w http.ResponseWriter, seekable bool) {
+	if seekable {
+		w.Header().Set("Accept-Ranges", "bytes")
+	} else {
+		w.Header().Set("Accept-Ranges", "none")
+	}
+}
+
 // getLocalIP 获取本地IP地址
-func getLocalIP() string {
+// preferred为网络接口名称或IPv4地址时优先返回该接口的地址，找不到时与为空时一样使用第一个可用的地址
+func getLocalIP(preferred string) string {
 	// 获取所有网络接口
 	interfaces, err := net.Interfaces()
 	if err != nil {
@@ -368,6 +823,13 @@ func getLocalIP() string {
 		return ""
 	}
 
+	if preferred != "" {
+		if ip := interfaceIPv4(interfaces, preferred); ip != "" {
+			return ip
+		}
+		log.Printf("网络接口 %s 不可用，自动选择媒体服务器地址\n", preferred)
+	}
+
 	// 遍历所有网络接口
 	for _, iface := range interfaces {
 		// 跳过无效的网络接口
@@ -399,4 +861,27 @@ func getLocalIP() string {
 	}
 
 	return ""
-}
\ No newline at end of file
+}
+
+// interfaceIPv4 返回名称或IPv4地址与nameOrIP相同的已启用网络接口的IPv4地址，找不到时返回空字符串
+func interfaceIPv4(interfaces []net.Interface, nameOrIP string) string {
+	for _, iface := range interfaces {
+		if iface.Flags&net.FlagUp == 0 {
+			continue
+		}
+		addresses, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addresses {
+			ipNet, ok := addr.(*net.IPNet)
+			if !ok || ipNet.IP.To4() == nil {
+				continue
+			}
+			if iface.Name == nameOrIP || ipNet.IP.String() == nameOrIP {
+				return ipNet.IP.To4().String()
+			}
+		}
+	}
+	return ""
+}
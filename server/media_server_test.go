@@ -0,0 +1,300 @@
+package server
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"GoCastify/types"
+)
+
+// fakeTranscoder 不执行转码的转码器，用于只测试文件提供逻辑
+type fakeTranscoder struct{}
+
+func (fakeTranscoder) GetSubtitleTracks(filePath string) ([]types.SubtitleTrack, error) {
+	return nil, nil
+}
+
+func (fakeTranscoder) GetAudioTracks(filePath string) ([]types.AudioTrack, error) {
+	return nil, nil
+}
+
+func (fakeTranscoder) TranscodeToMp4(inputFile string, subtitleTrackIndex int, audioTrackIndex int) (string, error) {
+	return inputFile, nil
+}
+
+func (fakeTranscoder) StreamTranscode(inputFile string, subtitleTrackIndex int, audioTrackIndex int) (string, error) {
+	return inputFile, nil
+}
+
+func (fakeTranscoder) Cleanup() error {
+	return nil
+}
+
+// newTestServer 创建以dir为媒体目录、不监听端口的媒体服务器，并在dir中创建files中的文件
+func newTestServer(t *testing.T, files map[string]string) (*MediaServer, string) {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ms := NewMediaServer(0, fakeTranscoder{})
+	ms.mediaPath = dir
+	return ms, dir
+}
+
+func TestMediaServerServesOnlyAllowedFiles(t *testing.T) {
+	ms, dir := newTestServer(t, map[string]string{
+		"movie.mp4":   "movie",
+		"movie.srt":   "1\n00:00:01,000 --> 00:00:02,000\nhello\n",
+		"private.mp4": "private",
+		"notes.txt":   "notes",
+	})
+	ms.AllowFile(filepath.Join(dir, "movie.mp4"))
+
+	tests := []struct {
+		name string
+		path string
+		want int
+	}{
+		{name: "allowed file", path: "/media/movie.mp4", want: http.StatusOK},
+		{name: "sibling media file", path: "/media/private.mp4", want: http.StatusNotFound},
+		{name: "sibling other file", path: "/media/notes.txt", want: http.StatusNotFound},
+		{name: "missing file", path: "/media/missing.mp4", want: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			ms.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if recorder.Code != tt.want {
+				t.Errorf("GET %s = %d, want %d", tt.path, recorder.Code, tt.want)
+			}
+		})
+	}
+
+	// 同名外挂字幕随媒体文件一起允许，其他文件的字幕不允许
+	if !ms.isFileAllowed(filepath.Join(dir, "movie.srt")) {
+		t.Error("sidecar subtitle of the allowed file is not allowed")
+	}
+	if ms.isFileAllowed(filepath.Join(dir, "private.srt")) {
+		t.Error("sidecar subtitle of a sibling file is allowed")
+	}
+
+	ms.ClearAllowedFiles()
+	recorder := httptest.NewRecorder()
+	ms.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/media/movie.mp4", nil))
+	if recorder.Code != http.StatusNotFound {
+		t.Errorf("GET after ClearAllowedFiles = %d, want %d", recorder.Code, http.StatusNotFound)
+	}
+}
+
+func TestMediaServerServeModes(t *testing.T) {
+	tests := []struct {
+		name string
+		mode ServeMode
+		path string
+		want int
+	}{
+		{name: "single file allowed file", mode: ServeModeSingleFile, path: "/media/movie.mp4", want: http.StatusOK},
+		{name: "single file sibling", mode: ServeModeSingleFile, path: "/media/other.mp4", want: http.StatusNotFound},
+		{name: "directory allowed file", mode: ServeModeDirectory, path: "/media/movie.mp4", want: http.StatusOK},
+		{name: "directory sibling", mode: ServeModeDirectory, path: "/media/other.mp4", want: http.StatusOK},
+		{name: "directory missing file", mode: ServeModeDirectory, path: "/media/missing.mp4", want: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ms, dir := newTestServer(t, map[string]string{"movie.mp4": "movie", "other.mp4": "other"})
+			ms.SetServeMode(tt.mode)
+			ms.AllowFile(filepath.Join(dir, "movie.mp4"))
+
+			recorder := httptest.NewRecorder()
+			ms.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if recorder.Code != tt.want {
+				t.Errorf("GET %s = %d, want %d", tt.path, recorder.Code, tt.want)
+			}
+		})
+	}
+
+	// 目录模式只放开媒体目录，目录之外的文件仍然不允许
+	ms, dir := newTestServer(t, nil)
+	ms.SetServeMode(ServeModeDirectory)
+	outside := filepath.Join(filepath.Dir(dir), "outside.mp4")
+	if ms.isFileAllowed(outside) || ms.isFileAllowed(filepath.Join(dir, "..", "outside.mp4")) {
+		t.Errorf("directory mode allows %s outside the media directory %s", outside, dir)
+	}
+}
+
+func TestMediaServerRoutes(t *testing.T) {
+	ms, dir := newTestServer(t, map[string]string{
+		"movie.mp4":   "movie",
+		"favicon.ico": "icon",
+	})
+	ms.AllowFile(filepath.Join(dir, "movie.mp4"))
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		want     int
+		wantBody string
+	}{
+		{name: "status page", method: http.MethodGet, path: "/", want: http.StatusOK, wantBody: "GoCastify媒体服务器运行中\n"},
+		{name: "media prefix", method: http.MethodGet, path: "/media/movie.mp4", want: http.StatusOK, wantBody: "movie"},
+		{name: "media prefix head", method: http.MethodHead, path: "/media/movie.mp4", want: http.StatusOK},
+		{name: "legacy root path", method: http.MethodGet, path: "/movie.mp4", want: http.StatusOK, wantBody: "movie"},
+		{name: "favicon probe", method: http.MethodGet, path: "/favicon.ico", want: http.StatusNotFound},
+		{name: "description probe", method: http.MethodGet, path: "/description.xml", want: http.StatusNotFound},
+		{name: "random path", method: http.MethodGet, path: "/random", want: http.StatusNotFound},
+		{name: "empty media path", method: http.MethodGet, path: "/media/", want: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			ms.ServeHTTP(recorder, httptest.NewRequest(tt.method, tt.path, nil))
+			if recorder.Code != tt.want {
+				t.Fatalf("%s %s = %d, want %d", tt.method, tt.path, recorder.Code, tt.want)
+			}
+			if tt.wantBody != "" && recorder.Body.String() != tt.wantBody {
+				t.Errorf("%s %s body = %q, want %q", tt.method, tt.path, recorder.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
+
+// urlPort 返回媒体服务器地址中的端口
+func urlPort(t *testing.T, serverURL string) string {
+	t.Helper()
+	parsed, err := url.Parse(serverURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return parsed.Port()
+}
+
+func TestMediaServerRestartReportsBoundPort(t *testing.T) {
+	// 占用配置的端口，第一次启动只能改用空闲端口
+	blocker, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := blocker.Addr().(*net.TCPAddr).Port
+	configuredPort := strconv.Itoa(port)
+	ms := NewMediaServer(port, fakeTranscoder{})
+	t.Cleanup(func() { ms.StopServing() })
+
+	first, err := ms.Start(t.TempDir())
+	if err != nil {
+		t.Fatalf("Start() with the port in use: %v", err)
+	}
+	firstPort := urlPort(t, first)
+	if firstPort == configuredPort {
+		t.Fatalf("Start() = %s while port %s is in use", first, configuredPort)
+	}
+	if got := ms.GetServerURL(); got != first {
+		t.Errorf("GetServerURL() = %s, want the bound address %s", got, first)
+	}
+	checkServing(t, firstPort)
+
+	if err := ms.StopServing(); err != nil {
+		t.Fatal(err)
+	}
+	if got := urlPort(t, ms.GetServerURL()); got != configuredPort {
+		t.Errorf("GetServerURL() after stop uses port %s, want the configured port %s", got, configuredPort)
+	}
+
+	// 端口释放后重新启动，新的地址使用配置的端口
+	blocker.Close()
+	second, err := ms.Start(t.TempDir())
+	if err != nil {
+		t.Fatalf("Start() after restart: %v", err)
+	}
+	if got := urlPort(t, second); got != configuredPort {
+		t.Errorf("Start() after restart = %s, want port %s", second, configuredPort)
+	}
+	if got := ms.GetServerURL(); got != second {
+		t.Errorf("GetServerURL() = %s, want %s", got, second)
+	}
+	checkServing(t, configuredPort)
+}
+
+// checkServing 确认媒体服务器在本机的port端口上响应
+func checkServing(t *testing.T, port string) {
+	t.Helper()
+	resp, err := http.Get("http://127.0.0.1:" + port + "/")
+	if err != nil {
+		t.Fatalf("media server is not listening on %s: %v", port, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("GET / on port %s = %d, want %d", port, resp.StatusCode, http.StatusOK)
+	}
+}
+
+func TestMediaServerIdleTimeout(t *testing.T) {
+	tests := []struct {
+		name    string
+		timeout time.Duration
+		set     bool
+		want    time.Duration
+	}{
+		{name: "default", want: DefaultIdleTimeout},
+		{name: "configured", timeout: 30 * time.Minute, set: true, want: 30 * time.Minute},
+		{name: "zero uses default", timeout: 0, set: true, want: DefaultIdleTimeout},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ms := NewMediaServer(0, fakeTranscoder{})
+			t.Cleanup(func() { ms.StopServing() })
+			if tt.set {
+				ms.SetIdleTimeout(tt.timeout)
+			}
+			if _, err := ms.Start(t.TempDir()); err != nil {
+				t.Fatalf("Start() error = %v", err)
+			}
+			if got := idleTimeout(ms); got != tt.want {
+				t.Errorf("http.Server IdleTimeout = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMediaServerIdleTimeoutAppliesOnRestart(t *testing.T) {
+	ms := NewMediaServer(0, fakeTranscoder{})
+	t.Cleanup(func() { ms.StopServing() })
+	if _, err := ms.Start(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+
+	// 运行中修改的超时在下次启动时生效
+	ms.SetIdleTimeout(time.Hour)
+	if got := idleTimeout(ms); got != DefaultIdleTimeout {
+		t.Errorf("IdleTimeout while running = %v, want %v until restart", got, DefaultIdleTimeout)
+	}
+	if err := ms.StopServing(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ms.Start(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	if got := idleTimeout(ms); got != time.Hour {
+		t.Errorf("IdleTimeout after restart = %v, want %v", got, time.Hour)
+	}
+}
+
+// idleTimeout 返回运行中的http.Server使用的空闲连接超时
+func idleTimeout(ms *MediaServer) time.Duration {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	if ms.httpServer == nil {
+		return 0
+	}
+	return ms.httpServer.IdleTimeout
+}
@@ -0,0 +1,151 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+// RemotePathPrefix 网页遥控器的URL路径，页面为 /remote，接口位于 /remote/api/ 下
+const RemotePathPrefix = "/remote"
+
+// remoteTokenParam 访问网页遥控器及其接口时携带令牌使用的查询参数和请求头
+const (
+	remoteTokenParam  = "token"
+	remoteTokenHeader = "X-Remote-Token"
+)
+
+// ErrRemoteCommandUnsupported 当前设备或版本不支持该遥控命令
+var ErrRemoteCommandUnsupported = errors.New("不支持该遥控命令")
+
+//go:embed assets/remote.html
+var remotePage []byte
+
+// RemoteStatus 网页遥控器显示的播放状态
+type RemoteStatus struct {
+	Playing  bool    `json:"playing"`
+	Device   string  `json:"device"`
+	File     string  `json:"file"`
+	State    string  `json:"state"`
+	Position float64 `json:"position"` // 秒
+	Duration float64 `json:"duration"` // 秒
+}
+
+// RemoteController 网页遥控器控制播放的接口，由应用实现
+type RemoteController interface {
+	// RemoteStatus 获取当前的播放状态
+	RemoteStatus() RemoteStatus
+	// RemoteCommand 执行遥控命令，action为play、pause、stop、seek或volume，
+	// value为seek的目标位置（秒）或volume的音量（0-100）
+	RemoteCommand(ctx context.Context, action string, value float64) error
+}
+
+// SetRemote 启用网页遥控器，token为访问页面和接口所需的令牌
+// controller为nil或token为空时关闭网页遥控器
+func (ms *MediaServer) SetRemote(controller RemoteController, token string) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	if controller == nil || token == "" {
+		ms.remote = nil
+		ms.remoteToken = ""
+		return
+	}
+	ms.remote = controller
+	ms.remoteToken = token
+}
+
+// RemoteURL 返回网页遥控器的地址，未启用时返回空字符串
+func (ms *MediaServer) RemoteURL() string {
+	ms.mu.Lock()
+	token := ms.remoteToken
+	ms.mu.Unlock()
+	if token == "" {
+		return ""
+	}
+	return ms.GetServerURL() + RemotePathPrefix + "?" + remoteTokenParam + "=" + token
+}
+
+// authorizedRemote 校验请求携带的令牌，通过时返回遥控接口
+// 未启用遥控器或令牌错误时返回nil
+func (ms *MediaServer) authorizedRemote(r *http.Request) RemoteController {
+	ms.mu.Lock()
+	controller, token := ms.remote, ms.remoteToken
+	ms.mu.Unlock()
+	if controller == nil {
+		return nil
+	}
+
+	provided := r.Header.Get(remoteTokenHeader)
+	if provided == "" {
+		provided = r.URL.Query().Get(remoteTokenParam)
+	}
+	if subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+		return nil
+	}
+	return controller
+}
+
+// handleRemotePage 提供网页遥控器页面
+func (ms *MediaServer) handleRemotePage(w http.ResponseWriter, r *http.Request) {
+	if ms.authorizedRemote(r) == nil {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(remotePage)
+}
+
+// handleRemoteStatus 以JSON返回当前的播放状态
+func (ms *MediaServer) handleRemoteStatus(w http.ResponseWriter, r *http.Request) {
+	controller := ms.authorizedRemote(r)
+	if controller == nil {
+		http.Error(w, "未授权", http.StatusUnauthorized)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(controller.RemoteStatus())
+}
+
+// handleRemoteCommand 执行网页遥控器发送的命令
+// 请求为POST，参数action为命令，value为可选的数值
+func (ms *MediaServer) handleRemoteCommand(w http.ResponseWriter, r *http.Request) {
+	controller := ms.authorizedRemote(r)
+	if controller == nil {
+		http.Error(w, "未授权", http.StatusUnauthorized)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "只支持POST请求", http.StatusMethodNotAllowed)
+		return
+	}
+
+	action := r.FormValue("action")
+	value := 0.0
+	if text := r.FormValue("value"); text != "" {
+		parsed, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			http.Error(w, "无效的参数: "+text, http.StatusBadRequest)
+			return
+		}
+		value = parsed
+	}
+
+	log.Printf("网页遥控器命令: %s %v\n", action, value)
+	if err := controller.RemoteCommand(r.Context(), action, value); err != nil {
+		status := http.StatusBadGateway
+		if errors.Is(err, ErrRemoteCommandUnsupported) {
+			status = http.StatusNotImplemented
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
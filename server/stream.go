@@ -0,0 +1,106 @@
+package server
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"GoCastify/transcoder"
+)
+
+// streamTranscoder 能够边转码边输出的转码器
+type streamTranscoder interface {
+	StreamTranscodeTo(ctx context.Context, w io.Writer, inputFile string, subtitleTrackIndex int, audioTrackIndex int, cast transcoder.CastOptions) error
+}
+
+// cachedTranscoder 能够查询已完成转码结果的转码器
+type cachedTranscoder interface {
+	CachedTranscode(inputFile string, subtitleTrackIndex int, audioTrackIndex int, cast transcoder.CastOptions) (string, bool)
+}
+
+// SetStreamingTranscode 设置需要转码的文件是否边转码边输出
+// 流式输出可以更快开始播放，但总大小未知，设备无法跳转
+func (ms *MediaServer) SetStreamingTranscode(enabled bool) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	ms.streamTranscode = enabled
+}
+
+// streamsTranscode 判断该转码请求是否以流式输出提供
+// 需要开启流式转码、转码器支持，且还没有完整的转码缓存
+func (ms *MediaServer) streamsTranscode(filePath string, subtitleTrackIndex, audioTrackIndex int, cast transcoder.CastOptions) bool {
+	ms.mu.Lock()
+	enabled := ms.streamTranscode
+	ms.mu.Unlock()
+	if !enabled {
+		return false
+	}
+	if _, ok := ms.transcoder.(streamTranscoder); !ok {
+		return false
+	}
+	if cached, ok := ms.transcoder.(cachedTranscoder); ok {
+		if _, valid := cached.CachedTranscode(filePath, subtitleTrackIndex, audioTrackIndex, cast); valid {
+			return false
+		}
+	}
+	return true
+}
+
+// streamTranscodedMedia 边转码边输出分片MP4
+// 总大小未知，不设置Content-Length，由net/http使用分块传输编码；
+// 同时声明不支持范围请求，避免设备按字节跳转；设置了上传限速时与文件响应一样按限速发送
+func (ms *MediaServer) streamTranscodedMedia(w http.ResponseWriter, r *http.Request, filePath string, subtitleTrackIndex, audioTrackIndex int, cast transcoder.CastOptions) {
+	w = ms.throttle(w, r)
+
+	// 流式输出只能从头开始，无法满足其他位置的范围请求
+	if rangeHeader := r.Header.Get("Range"); rangeHeader != "" && !strings.HasPrefix(rangeHeader, "bytes=0-") {
+		setRangeSupportHeader(w, false)
+		http.Error(w, "流式转码不支持范围请求", http.StatusRequestedRangeNotSatisfiable)
+		return
+	}
+
+	w.Header().Set("Content-Type", "video/mp4")
+	w.Header().Del("Content-Length")
+	setRangeSupportHeader(w, false)
+	w.WriteHeader(http.StatusOK)
+
+	writer := newFlushWriter(w)
+	// 立即发送响应头，设备不必等待第一个分片
+	writer.Flush()
+
+	err := ms.transcoder.(streamTranscoder).StreamTranscodeTo(r.Context(), writer, filePath, subtitleTrackIndex, audioTrackIndex, cast)
+	if err != nil && r.Context().Err() == nil {
+		// 响应头已经发出，只能记录错误并断开
+		log.Printf("流式转码失败: %v\n", err)
+	}
+}
+
+// flushWriter 每次写入后立即刷新，使转码输出及时发送给设备
+type flushWriter struct {
+	w       io.Writer
+	flusher http.Flusher
+}
+
+// newFlushWriter 创建flushWriter，w不支持刷新时只写入
+func newFlushWriter(w http.ResponseWriter) *flushWriter {
+	flusher, _ := w.(http.Flusher)
+	return &flushWriter{w: w, flusher: flusher}
+}
+
+// Write 写入数据并刷新
+func (fw *flushWriter) Write(p []byte) (int, error) {
+	n, err := fw.w.Write(p)
+	if err == nil {
+		fw.Flush()
+	}
+	return n, err
+}
+
+// Flush 刷新已写入的数据
+func (fw *flushWriter) Flush() {
+	if fw.flusher != nil {
+		fw.flusher.Flush()
+	}
+}
@@ -0,0 +1,143 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"GoCastify/transcoder"
+)
+
+// fakeStreamTranscoder 以固定的分片模拟边转码边输出的转码器
+type fakeStreamTranscoder struct {
+	fakeTranscoder
+	chunks []string
+}
+
+func (f fakeStreamTranscoder) StreamTranscodeTo(ctx context.Context, w io.Writer, inputFile string, subtitleTrackIndex int, audioTrackIndex int, cast transcoder.CastOptions) error {
+	for _, chunk := range f.chunks {
+		if _, err := io.WriteString(w, chunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// useFakeFFmpeg 使CheckFFmpeg通过：测试中的转码器不会真正执行FFmpeg，使用测试程序本身作为可执行文件
+func useFakeFFmpeg(t *testing.T) {
+	t.Helper()
+	executable, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	transcoder.SetBinaryPaths(executable, "")
+	t.Cleanup(func() { transcoder.SetBinaryPaths("", "") })
+}
+
+// newStreamTestServer 创建提供一个需要转码的文件的媒体服务器，返回服务器地址和文件的请求路径
+func newStreamTestServer(t *testing.T, streaming bool) (*MediaServer, string, string) {
+	t.Helper()
+	useFakeFFmpeg(t)
+	ms, dir := newTestServer(t, map[string]string{"movie.mkv": "transcoded movie"})
+	ms.transcoder = fakeStreamTranscoder{chunks: []string{"fragment-1", "fragment-2"}}
+	ms.AllowFile(filepath.Join(dir, "movie.mkv"))
+	ms.SetStreamingTranscode(streaming)
+	server := httptest.NewServer(ms)
+	t.Cleanup(server.Close)
+	return ms, server.URL, "/media/movie.mkv"
+}
+
+func TestStreamingTranscodeHeaders(t *testing.T) {
+	tests := []struct {
+		name              string
+		streaming         bool
+		wantBody          string
+		wantContentLength int64
+		wantChunked       bool
+		wantAcceptRanges  string
+	}{
+		{
+			name:              "streaming transcode",
+			streaming:         true,
+			wantBody:          "fragment-1fragment-2",
+			wantContentLength: -1,
+			wantChunked:       true,
+			wantAcceptRanges:  "none",
+		},
+		{
+			name:              "file transcode",
+			streaming:         false,
+			wantBody:          "transcoded movie",
+			wantContentLength: int64(len("transcoded movie")),
+			wantAcceptRanges:  "bytes",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, serverURL, path := newStreamTestServer(t, tt.streaming)
+			resp, err := http.Get(serverURL + path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			body, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusOK)
+			}
+			if string(body) != tt.wantBody {
+				t.Errorf("body = %q, want %q", body, tt.wantBody)
+			}
+			if resp.ContentLength != tt.wantContentLength {
+				t.Errorf("ContentLength = %d, want %d", resp.ContentLength, tt.wantContentLength)
+			}
+			chunked := len(resp.TransferEncoding) == 1 && resp.TransferEncoding[0] == "chunked"
+			if chunked != tt.wantChunked {
+				t.Errorf("TransferEncoding = %v, want chunked %v", resp.TransferEncoding, tt.wantChunked)
+			}
+			if got := resp.Header.Get("Accept-Ranges"); got != tt.wantAcceptRanges {
+				t.Errorf("Accept-Ranges = %q, want %q", got, tt.wantAcceptRanges)
+			}
+		})
+	}
+}
+
+func TestStreamingTranscodeRangeRequests(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		rangeValue string
+		want       int
+	}{
+		{name: "head", method: http.MethodHead, want: http.StatusOK},
+		{name: "range from start", method: http.MethodGet, rangeValue: "bytes=0-", want: http.StatusOK},
+		{name: "range from middle", method: http.MethodGet, rangeValue: "bytes=100-", want: http.StatusRequestedRangeNotSatisfiable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, serverURL, path := newStreamTestServer(t, true)
+			req, _ := http.NewRequest(tt.method, serverURL+path, nil)
+			if tt.rangeValue != "" {
+				req.Header.Set("Range", tt.rangeValue)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.want {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.want)
+			}
+			if got := resp.Header.Get("Accept-Ranges"); got != "none" {
+				t.Errorf("Accept-Ranges = %q, want none", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// throttleChunkSize 限速时每次写入的最大字节数，较小的分块使发送速率更平稳
+const throttleChunkSize = 16 * 1024
+
+// rateLimiter 令牌桶限速器，最多积累一秒的发送量
+// 写入量超过当前令牌时记为欠账，后续的写入需要等待更久，多个连接共享时限制总速率
+type rateLimiter struct {
+	mu             sync.Mutex
+	bytesPerSecond int64
+	tokens         float64
+	last           time.Time
+}
+
+// newRateLimiter 创建每秒最多发送bytesPerSecond字节的限速器
+func newRateLimiter(bytesPerSecond int64) *rateLimiter {
+	return &rateLimiter{
+		bytesPerSecond: bytesPerSecond,
+		tokens:         float64(bytesPerSecond),
+		last:           time.Now(),
+	}
+}
+
+// wait 预留n字节的发送量，令牌不足时等待补足，ctx取消时返回其错误
+func (l *rateLimiter) wait(ctx context.Context, n int) error {
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * float64(l.bytesPerSecond)
+	if burst := float64(l.bytesPerSecond); l.tokens > burst {
+		l.tokens = burst
+	}
+	l.last = now
+	l.tokens -= float64(n)
+	deficit := -l.tokens
+	l.mu.Unlock()
+
+	if deficit <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(time.Duration(deficit / float64(l.bytesPerSecond) * float64(time.Second)))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// SetRateLimit 设置媒体服务器的上传限速，bytesPerSecond为0时不限速
+// perConnection为true时每个连接单独限速，否则所有连接共享同一个总速率
+func (ms *MediaServer) SetRateLimit(bytesPerSecond int64, perConnection bool) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	ms.rateLimit = bytesPerSecond
+	ms.rateLimitPerConnection = perConnection
+	ms.sharedLimiter = nil
+	if bytesPerSecond > 0 && !perConnection {
+		ms.sharedLimiter = newRateLimiter(bytesPerSecond)
+	}
+}
+
+// throttle 按当前的限速设置包装响应，没有限速时直接返回w
+func (ms *MediaServer) throttle(w http.ResponseWriter, req *http.Request) http.ResponseWriter {
+	ms.mu.Lock()
+	limiter := ms.sharedLimiter
+	if ms.rateLimit > 0 && ms.rateLimitPerConnection {
+		limiter = newRateLimiter(ms.rateLimit)
+	}
+	ms.mu.Unlock()
+
+	if limiter == nil {
+		return w
+	}
+	return &throttledResponseWriter{ResponseWriter: w, ctx: req.Context(), limiter: limiter}
+}
+
+// throttledResponseWriter 按限速器分块写入响应体的ResponseWriter
+type throttledResponseWriter struct {
+	http.ResponseWriter
+	ctx     context.Context
+	limiter *rateLimiter
+}
+
+// Write 分块等待限速器后写入，请求被取消时停止写入
+func (tw *throttledResponseWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		chunk := p
+		if len(chunk) > throttleChunkSize {
+			chunk = chunk[:throttleChunkSize]
+		}
+		if err := tw.limiter.wait(tw.ctx, len(chunk)); err != nil {
+			return written, err
+		}
+		n, err := tw.ResponseWriter.Write(chunk)
+		written += n
+		if err != nil {
+			return written, err
+		}
+		p = p[len(chunk):]
+	}
+	return written, nil
+}
+
+// Flush 刷新已写入的数据
+func (tw *throttledResponseWriter) Flush() {
+	if flusher, ok := tw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,132 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 限速测试的参数：限速器最多积累一秒的发送量，发送1.5秒的量至少需要0.5秒
+const (
+	testRateLimit    = 32 * 1024
+	testThrottleSize = testRateLimit * 3 / 2
+	minThrottledTime = 400 * time.Millisecond
+)
+
+// newThrottleTestServer 创建提供一个不需要转码的文件和一个需要转码的文件的媒体服务器
+func newThrottleTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	useFakeFFmpeg(t)
+	content := strings.Repeat("x", testThrottleSize)
+	ms, dir := newTestServer(t, map[string]string{"movie.mp4": content, "movie.mkv": content})
+	chunk := strings.Repeat("f", throttleChunkSize)
+	ms.transcoder = fakeStreamTranscoder{chunks: []string{chunk, chunk, chunk}}
+	ms.AllowFile(filepath.Join(dir, "movie.mp4"))
+	ms.AllowFile(filepath.Join(dir, "movie.mkv"))
+	ms.SetStreamingTranscode(true)
+	ms.SetRateLimit(testRateLimit, false)
+	server := httptest.NewServer(ms)
+	t.Cleanup(server.Close)
+	return server
+}
+
+// timedGet 请求path并读取整个响应体，返回读取的字节数和耗时
+func timedGet(t *testing.T, serverURL, path, rangeValue string) (int, time.Duration) {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodGet, serverURL+path, nil)
+	if rangeValue != "" {
+		req.Header.Set("Range", rangeValue)
+	}
+	start := time.Now()
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Error(err)
+		return 0, 0
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Error(err)
+	}
+	return len(body), time.Since(start)
+}
+
+func TestRateLimitAppliesToAllResponses(t *testing.T) {
+	tests := []struct {
+		name       string
+		path       string
+		rangeValue string
+		wantBytes  int
+	}{
+		{name: "whole file", path: "/media/movie.mp4", wantBytes: testThrottleSize},
+		{name: "range request", path: "/media/movie.mp4", rangeValue: "bytes=0-", wantBytes: testThrottleSize},
+		{name: "streaming transcode", path: "/media/movie.mkv", wantBytes: 3 * throttleChunkSize},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newThrottleTestServer(t)
+			n, elapsed := timedGet(t, server.URL, tt.path, tt.rangeValue)
+			if n != tt.wantBytes {
+				t.Fatalf("read %d bytes, want %d", n, tt.wantBytes)
+			}
+			if elapsed < minThrottledTime {
+				t.Errorf("response took %v with a %d B/s limit, want at least %v", elapsed, testRateLimit, minThrottledTime)
+			}
+		})
+	}
+}
+
+func TestRateLimitSharedAndPerConnection(t *testing.T) {
+	tests := []struct {
+		name          string
+		perConnection bool
+		wantThrottled bool
+	}{
+		// 两个连接各发送0.75秒的量：共享限速时合计超过积累的发送量，单独限速时都不需要等待
+		{name: "shared", perConnection: false, wantThrottled: true},
+		{name: "per connection", perConnection: true, wantThrottled: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			half := strings.Repeat("x", testThrottleSize/2)
+			ms, dir := newTestServer(t, map[string]string{"a.mp4": half, "b.mp4": half})
+			ms.AllowFile(filepath.Join(dir, "a.mp4"))
+			ms.AllowFile(filepath.Join(dir, "b.mp4"))
+			ms.SetRateLimit(testRateLimit, tt.perConnection)
+			server := httptest.NewServer(ms)
+			defer server.Close()
+
+			start := time.Now()
+			var wg sync.WaitGroup
+			for _, path := range []string{"/media/a.mp4", "/media/b.mp4"} {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					timedGet(t, server.URL, path, "")
+				}()
+			}
+			wg.Wait()
+			if throttled := time.Since(start) >= minThrottledTime; throttled != tt.wantThrottled {
+				t.Errorf("two responses took %v, want throttled %v", time.Since(start), tt.wantThrottled)
+			}
+		})
+	}
+}
+
+func TestRateLimitDisabledByDefault(t *testing.T) {
+	ms, dir := newTestServer(t, map[string]string{"movie.mp4": strings.Repeat("x", testThrottleSize)})
+	ms.AllowFile(filepath.Join(dir, "movie.mp4"))
+	recorder := httptest.NewRecorder()
+	ms.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/media/movie.mp4", nil))
+	if _, ok := ms.throttle(recorder, httptest.NewRequest(http.MethodGet, "/", nil)).(*throttledResponseWriter); ok {
+		t.Error("responses are throttled without a rate limit")
+	}
+	if recorder.Body.Len() != testThrottleSize {
+		t.Errorf("served %d bytes, want %d", recorder.Body.Len(), testThrottleSize)
+	}
+}
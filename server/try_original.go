@@ -0,0 +1,24 @@
+package server
+
+import "net/http"
+
+// SetTryOriginalFirst 设置需要转码的文件是否先尝试直接提供原文件
+// 开启后，不需要转码音轨、字幕，也没有起始位置和分辨率上限的请求直接得到原文件，由设备尝试播放；
+// 带有transcode=1的请求才提供转码后的文件，用于原文件播放失败后的回退
+func (ms *MediaServer) SetTryOriginalFirst(enabled bool) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	ms.tryOriginalFirst = enabled
+}
+
+// servesOriginalFirst 判断需要转码的文件是否对该请求先提供原文件
+func (ms *MediaServer) servesOriginalFirst(r *http.Request) bool {
+	ms.mu.Lock()
+	enabled := ms.tryOriginalFirst
+	ms.mu.Unlock()
+	if !enabled || r.URL.Query().Get("transcode") == "1" {
+		return false
+	}
+	subtitleTrackIndex, audioTrackIndex := ms.transcodeTracks(r)
+	return subtitleTrackIndex < 0 && audioTrackIndex < 0 && castOptions(r).IsZero()
+}
@@ -0,0 +1,65 @@
+package transcoder
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// AudioOnlyMIMEType 仅音频输出的MIME类型
+// 输出为MP3，DLNA音频设备普遍支持
+const AudioOnlyMIMEType = "audio/mpeg"
+
+// ExtractAudio 只转码媒体文件的音轨，输出MP3文件，跳过所有视频处理
+// audioTrackIndex为音频流在输入文件所有音频流中的序号，-1表示默认音轨
+// 结果与转码结果共用缓存，源文件变化后失效
+func (t *Transcoder) ExtractAudio(inputFile string, audioTrackIndex int) (string, error) {
+	modTime, size, err := sourceStamp(inputFile)
+	if err != nil {
+		return "", fmt.Errorf("读取源文件信息失败: %w", err)
+	}
+	cacheKey := fmt.Sprintf("%s_%d_%d_audioonly_%d", inputFile, modTime, size, audioTrackIndex)
+	if outputFile, valid := t.getCachedOutput(cacheKey); valid {
+		return outputFile, nil
+	}
+
+	if !CheckFFmpeg() {
+		return "", fmt.Errorf("未找到FFmpeg，请先安装FFmpeg")
+	}
+
+	// 与视频转码共用并发限制
+	t.queue.acquire(context.Background())
+	defer t.queue.release()
+
+	baseName := strings.TrimSuffix(filepath.Base(inputFile), filepath.Ext(inputFile))
+	outputFile := filepath.Join(t.tempDir, fmt.Sprintf("%s_audio_%s.mp3", baseName, shortHash(cacheKey)))
+
+	audioMap := "0:a:0"
+	if audioTrackIndex >= 0 {
+		audioMap = fmt.Sprintf("0:a:%d", audioTrackIndex)
+	}
+	args := []string{
+		"-y",
+		"-hide_banner",
+		"-loglevel", "error",
+		"-i", inputFile,
+		"-map", audioMap,
+		"-vn",
+		"-c:a", "libmp3lame",
+		"-b:a", "192k",
+		outputFile,
+	}
+	log.Printf("开始提取音频: %s 到 %s", inputFile, outputFile)
+	output, err := exec.Command(ffmpegCommand(), args...).CombinedOutput()
+	if err != nil {
+		os.Remove(outputFile)
+		return "", fmt.Errorf("提取音频失败: %w, %s", err, strings.TrimSpace(string(output)))
+	}
+
+	t.storeCachedOutput(cacheKey, outputFile, inputFile, modTime, size)
+	return outputFile, nil
+}
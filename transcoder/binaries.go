@@ -0,0 +1,140 @@
+package transcoder
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 默认的可执行文件名，在系统PATH中查找
+const (
+	defaultFFmpegBinary  = "ffmpeg"
+	defaultFFprobeBinary = "ffprobe"
+)
+
+// versionCheckTimeout 执行 -version 检查可执行文件的超时时间
+const versionCheckTimeout = 10 * time.Second
+
+// 当前使用的FFmpeg和ffprobe可执行文件，由SetBinaryPaths修改
+var (
+	binaryMu      sync.RWMutex
+	ffmpegBinary  = defaultFFmpegBinary
+	ffprobeBinary = defaultFFprobeBinary
+)
+
+// SetBinaryPaths 设置FFmpeg和ffprobe可执行文件的路径，为空时使用系统PATH中的ffmpeg和ffprobe
+// 调用方应先通过ValidateBinary检查路径是否有效
+func SetBinaryPaths(ffmpegPath, ffprobePath string) {
+	binaryMu.Lock()
+	defer binaryMu.Unlock()
+	ffmpegBinary = defaultFFmpegBinary
+	if ffmpegPath != "" {
+		ffmpegBinary = ffmpegPath
+	}
+	ffprobeBinary = defaultFFprobeBinary
+	if ffprobePath != "" {
+		ffprobeBinary = ffprobePath
+	}
+}
+
+// ffmpegCommand 返回当前使用的FFmpeg可执行文件
+func ffmpegCommand() string {
+	binaryMu.RLock()
+	defer binaryMu.RUnlock()
+	return ffmpegBinary
+}
+
+// ffprobeCommand 返回当前使用的ffprobe可执行文件
+func ffprobeCommand() string {
+	binaryMu.RLock()
+	defer binaryMu.RUnlock()
+	return ffprobeBinary
+}
+
+// NormalizeBinaryPath 规范化用户输入的可执行文件路径
+// 去掉首尾空白和引号，展开开头的 "~"；指向目录且目录中有名为name的可执行文件时，返回该文件的路径
+func NormalizeBinaryPath(path, name string) string {
+	path = strings.Trim(strings.TrimSpace(path), `"'`)
+	if path == "" {
+		return ""
+	}
+	if rest, ok := strings.CutPrefix(path, "~"); ok && (rest == "" || os.IsPathSeparator(rest[0])) {
+		if home, err := os.UserHomeDir(); err == nil {
+			path = home + rest
+		}
+	}
+	path = filepath.Clean(path)
+
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		candidate := filepath.Join(path, executableName(name))
+		if info, err := os.Stat(candidate); err == nil && !info.IsDir() {
+			return candidate
+		}
+	}
+	return path
+}
+
+// executableName 返回当前系统上的可执行文件名，Windows上带.exe扩展名
+func executableName(name string) string {
+	if runtime.GOOS == "windows" {
+		return name + ".exe"
+	}
+	return name
+}
+
+// ValidateBinary 检查path是否为可用的name（ffmpeg或ffprobe）可执行文件，返回其报告的版本号
+// path为空时检查系统PATH中的name；文件不存在、是目录、没有执行权限、
+// 无法执行 -version 或输出不是name的版本信息时返回说明原因的错误
+func ValidateBinary(path, name string) (string, error) {
+	if path == "" {
+		found, err := exec.LookPath(name)
+		if err != nil {
+			return "", fmt.Errorf("系统PATH中没有找到%s", name)
+		}
+		path = found
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", fmt.Errorf("%s路径不存在: %s", name, path)
+		}
+		return "", fmt.Errorf("无法访问%s路径: %w", name, err)
+	}
+	if info.IsDir() {
+		return "", fmt.Errorf("%s路径是目录而不是可执行文件: %s", name, path)
+	}
+	// Windows没有执行权限位，由下面的 -version 检查是否可以执行
+	if runtime.GOOS != "windows" && info.Mode().Perm()&0111 == 0 {
+		return "", fmt.Errorf("%s没有执行权限: %s", name, path)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), versionCheckTimeout)
+	defer cancel()
+	output, err := exec.CommandContext(ctx, path, "-version").Output()
+	if err != nil {
+		return "", fmt.Errorf("无法执行%s -version: %w", path, err)
+	}
+	version, ok := parseVersionLine(string(output), name)
+	if !ok {
+		return "", fmt.Errorf("%s不是%s: 无法识别其版本信息", path, name)
+	}
+	return version, nil
+}
+
+// parseVersionLine 从 -version 输出的第一行中解析版本号
+// 例如 "ffmpeg version 6.1.1-3ubuntu5 Copyright (c) ..." 返回 "6.1.1-3ubuntu5"
+func parseVersionLine(output, name string) (string, bool) {
+	firstLine, _, _ := strings.Cut(strings.TrimSpace(output), "\n")
+	fields := strings.Fields(firstLine)
+	if len(fields) < 3 || fields[0] != name || fields[1] != "version" {
+		return "", false
+	}
+	return fields[2], true
+}
@@ -0,0 +1,157 @@
+package transcoder
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// 持久化缓存索引文件名
+const cacheIndexFileName = "cache_index.json"
+
+// cacheIndexEntry 缓存索引中的一条记录
+// 记录源文件的修改时间和大小，源文件变化后缓存失效
+type cacheIndexEntry struct {
+	Key           string `json:"key"`
+	Output        string `json:"output"`
+	SourcePath    string `json:"source_path"`
+	SourceModTime int64  `json:"source_mod_time"`
+	SourceSize    int64  `json:"source_size"`
+	// OutputSize 输出文件的大小，用于限制缓存的总大小
+	OutputSize int64 `json:"output_size,omitempty"`
+	// LastUsed 最近一次使用缓存的时间（Unix纳秒），超过大小上限时先删除最久未使用的缓存
+	LastUsed int64 `json:"last_used,omitempty"`
+}
+
+// sourceStamp 获取源文件的修改时间(纳秒)和大小
+func sourceStamp(filePath string) (int64, int64, error) {
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return 0, 0, err
+	}
+	return info.ModTime().UnixNano(), info.Size(), nil
+}
+
+// DefaultPersistentCacheDir 返回持久化转码缓存的默认目录
+func DefaultPersistentCacheDir() (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("获取用户缓存目录失败: %w", err)
+	}
+	return filepath.Join(cacheDir, "GoCastify", "transcode"), nil
+}
+
+// EnablePersistentCache 启用跨会话的持久化转码缓存
+// 转码输出写入dir目录，缓存索引保存在该目录下；加载时逐条校验，
+// 源文件已变化或输出文件已丢失的记录会被清理。持久化的缓存不按时间过期。
+func (t *Transcoder) EnablePersistentCache(dir string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("创建缓存目录失败: %w", err)
+	}
+
+	t.cacheMutex.Lock()
+	defer t.cacheMutex.Unlock()
+
+	if t.persistentCache && t.tempDir == dir {
+		return nil
+	}
+
+	// 原临时目录中尚无持久化内容，直接删除
+	if t.tempDir != "" && !t.persistentCache {
+		os.RemoveAll(t.tempDir)
+	}
+	t.tempDir = dir
+	t.persistentCache = true
+
+	entries, err := loadCacheIndex(dir)
+	if err != nil {
+		log.Printf("读取转码缓存索引失败，将重新建立: %v", err)
+		entries = nil
+	}
+
+	loaded := 0
+	for _, entry := range entries {
+		if !entry.isValid() {
+			// 源文件已变化或输出文件丢失，删除残留输出
+			os.Remove(entry.Output)
+			continue
+		}
+		// 旧版本的索引没有记录输出文件大小
+		if entry.OutputSize == 0 {
+			entry.OutputSize = outputSize(entry.Output)
+		}
+		t.transcodingCache[entry.Key] = entry.Output
+		t.cacheSources[entry.Key] = entry
+		delete(t.cacheExpiry, entry.Key)
+		loaded++
+	}
+	log.Printf("已加载 %d 条持久化转码缓存，清理 %d 条失效记录", loaded, len(entries)-loaded)
+
+	// 加载的缓存可能已超过大小上限；回调在锁外执行，此处只记录日志
+	for _, entry := range t.evictCacheLocked("") {
+		log.Printf("转码缓存超过大小上限，删除最久未使用的缓存: %s", entry.Output)
+	}
+	return t.saveCacheIndexLocked()
+}
+
+// isValid 检查缓存记录对应的源文件未变化且输出文件仍然存在
+func (e cacheIndexEntry) isValid() bool {
+	if _, err := os.Stat(e.Output); err != nil {
+		return false
+	}
+	modTime, size, err := sourceStamp(e.SourcePath)
+	if err != nil {
+		return false
+	}
+	return modTime == e.SourceModTime && size == e.SourceSize
+}
+
+// loadCacheIndex 从缓存目录读取缓存索引
+func loadCacheIndex(dir string) ([]cacheIndexEntry, error) {
+	data, err := os.ReadFile(filepath.Join(dir, cacheIndexFileName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []cacheIndexEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// saveCacheIndexLocked 将缓存索引写入磁盘，调用方需持有cacheMutex
+// 未启用持久化缓存时不做任何操作
+func (t *Transcoder) saveCacheIndexLocked() error {
+	if !t.persistentCache || t.tempDir == "" {
+		return nil
+	}
+
+	entries := make([]cacheIndexEntry, 0, len(t.cacheSources))
+	for key, entry := range t.cacheSources {
+		if _, exists := t.transcodingCache[key]; exists {
+			entries = append(entries, entry)
+		}
+	}
+
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化转码缓存索引失败: %w", err)
+	}
+
+	// 先写入临时文件再重命名，避免写入中断导致索引损坏
+	indexPath := filepath.Join(t.tempDir, cacheIndexFileName)
+	tmpPath := indexPath + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0o644); err != nil {
+		return fmt.Errorf("写入转码缓存索引失败: %w", err)
+	}
+	if err := os.Rename(tmpPath, indexPath); err != nil {
+		return fmt.Errorf("保存转码缓存索引失败: %w", err)
+	}
+	return nil
+}
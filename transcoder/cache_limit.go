@@ -0,0 +1,125 @@
+package transcoder
+
+import (
+	"log"
+	"os"
+	"sort"
+	"time"
+)
+
+// SetMaxCacheSize 设置转码缓存的总大小上限（字节），不大于0时不限制
+// 超过上限时按最近使用时间删除最久未使用的缓存，刚完成的转码结果不会被删除；
+// 设置后立即按新的上限清理，时间过期的清理仍然有效
+func (t *Transcoder) SetMaxCacheSize(bytes int64) {
+	t.cacheMutex.Lock()
+	t.maxCacheSize = bytes
+	evicted := t.evictCacheLocked("")
+	t.cacheMutex.Unlock()
+	t.notifyCacheEvicted(evicted)
+}
+
+// SetOnCacheEvict 设置因超过大小上限删除缓存后的回调，参数为被删除的输出文件和它的大小
+// 回调在释放缓存锁之后执行
+func (t *Transcoder) SetOnCacheEvict(callback func(outputFile string, size int64)) {
+	t.cacheMutex.Lock()
+	defer t.cacheMutex.Unlock()
+	t.onCacheEvict = callback
+}
+
+// CacheSize 返回当前所有转码缓存输出文件的总大小（字节）
+func (t *Transcoder) CacheSize() int64 {
+	t.cacheMutex.Lock()
+	defer t.cacheMutex.Unlock()
+	return t.cacheSizeLocked()
+}
+
+// cacheSizeLocked 返回缓存输出文件的总大小，调用方需持有cacheMutex
+func (t *Transcoder) cacheSizeLocked() int64 {
+	total := int64(0)
+	for key := range t.transcodingCache {
+		total += t.cacheSources[key].OutputSize
+	}
+	return total
+}
+
+// touchCacheLocked 记录缓存刚被使用，调用方需持有cacheMutex
+// 只更新内存中的记录，持久化缓存的使用时间在下次保存索引时写入
+func (t *Transcoder) touchCacheLocked(cacheKey string) {
+	if entry, exists := t.cacheSources[cacheKey]; exists {
+		entry.LastUsed = time.Now().UnixNano()
+		t.cacheSources[cacheKey] = entry
+	}
+}
+
+// evictCacheLocked 缓存总大小超过上限时按最近使用时间从旧到新删除缓存，直到不超过上限
+// keep为不删除的缓存键（刚完成的转码）；删除失败（例如文件正被占用）的缓存保留。
+// 返回被删除的记录，调用方需持有cacheMutex，并在释放锁后调用notifyCacheEvicted
+func (t *Transcoder) evictCacheLocked(keep string) []cacheIndexEntry {
+	if t.maxCacheSize <= 0 {
+		return nil
+	}
+	total := t.cacheSizeLocked()
+	if total <= t.maxCacheSize {
+		return nil
+	}
+
+	keys := make([]string, 0, len(t.transcodingCache))
+	for key := range t.transcodingCache {
+		if key != keep {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return t.cacheSources[keys[i]].LastUsed < t.cacheSources[keys[j]].LastUsed
+	})
+
+	evicted := []cacheIndexEntry{}
+	for _, key := range keys {
+		if total <= t.maxCacheSize {
+			break
+		}
+		entry := t.cacheSources[key]
+		if err := os.Remove(t.transcodingCache[key]); err != nil && !os.IsNotExist(err) {
+			log.Printf("删除转码缓存失败: %v", err)
+			continue
+		}
+		delete(t.transcodingCache, key)
+		delete(t.cacheExpiry, key)
+		delete(t.cacheSources, key)
+		total -= entry.OutputSize
+		evicted = append(evicted, entry)
+	}
+
+	if len(evicted) > 0 && t.persistentCache {
+		if err := t.saveCacheIndexLocked(); err != nil {
+			log.Printf("保存转码缓存索引失败: %v", err)
+		}
+	}
+	return evicted
+}
+
+// notifyCacheEvicted 记录并通知因超过大小上限被删除的缓存，调用方不能持有cacheMutex
+func (t *Transcoder) notifyCacheEvicted(evicted []cacheIndexEntry) {
+	if len(evicted) == 0 {
+		return
+	}
+	t.cacheMutex.Lock()
+	callback := t.onCacheEvict
+	t.cacheMutex.Unlock()
+
+	for _, entry := range evicted {
+		log.Printf("转码缓存超过大小上限，删除最久未使用的缓存: %s", entry.Output)
+		if callback != nil {
+			callback(entry.Output, entry.OutputSize)
+		}
+	}
+}
+
+// outputSize 返回缓存输出文件的大小，文件不存在时返回0
+func outputSize(outputFile string) int64 {
+	info, err := os.Stat(outputFile)
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
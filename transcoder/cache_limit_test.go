@@ -0,0 +1,146 @@
+package transcoder
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// storeTestOutput 在转码器的临时目录中创建size字节的输出文件并记录为缓存key，
+// lastUsed不为0时覆盖记录的最近使用时间，使淘汰顺序确定
+func storeTestOutput(t *testing.T, transcoder *Transcoder, key string, size int, lastUsed int64) string {
+	t.Helper()
+	output := filepath.Join(transcoder.tempDir, key+".mp4")
+	if err := os.WriteFile(output, []byte(strings.Repeat("x", size)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	transcoder.storeCachedOutput(key, output, "/videos/"+key+".mkv", 1, 1)
+	if lastUsed != 0 {
+		transcoder.cacheMutex.Lock()
+		entry := transcoder.cacheSources[key]
+		entry.LastUsed = lastUsed
+		transcoder.cacheSources[key] = entry
+		transcoder.cacheMutex.Unlock()
+	}
+	return output
+}
+
+// evictionRecorder 记录SetOnCacheEvict回调收到的文件名和大小
+type evictionRecorder struct {
+	mu      sync.Mutex
+	evicted []string
+}
+
+func (r *evictionRecorder) record(outputFile string, size int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.evicted = append(r.evicted, strings.TrimSuffix(filepath.Base(outputFile), ".mp4"))
+}
+
+func (r *evictionRecorder) Evicted() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.evicted...)
+}
+
+// cachedKeys 返回仍在缓存中且输出文件存在的键
+func cachedKeys(t *testing.T, transcoder *Transcoder, keys ...string) []string {
+	t.Helper()
+	var cached []string
+	for _, key := range keys {
+		transcoder.cacheMutex.Lock()
+		output, exists := transcoder.transcodingCache[key]
+		transcoder.cacheMutex.Unlock()
+		if !exists {
+			continue
+		}
+		if _, err := os.Stat(output); err != nil {
+			t.Errorf("cache %s is recorded but its output is missing: %v", key, err)
+			continue
+		}
+		cached = append(cached, key)
+	}
+	return cached
+}
+
+func TestSetMaxCacheSizeEvictsLeastRecentlyUsed(t *testing.T) {
+	tests := []struct {
+		name        string
+		limit       int64
+		wantEvicted []string
+		wantCached  []string
+	}{
+		{name: "no limit", limit: 0, wantCached: []string{"a", "b", "c"}},
+		{name: "under limit", limit: 300, wantCached: []string{"a", "b", "c"}},
+		{name: "evict oldest", limit: 250, wantEvicted: []string{"a"}, wantCached: []string{"b", "c"}},
+		{name: "evict until it fits", limit: 150, wantEvicted: []string{"a", "b"}, wantCached: []string{"c"}},
+		{name: "evict all", limit: 50, wantEvicted: []string{"a", "b", "c"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transcoder := newTestTranscoder(t)
+			recorder := &evictionRecorder{}
+			transcoder.SetOnCacheEvict(recorder.record)
+			// 存入顺序和最近使用顺序不同：b最先存入，a最久未使用
+			storeTestOutput(t, transcoder, "b", 100, 2)
+			storeTestOutput(t, transcoder, "a", 100, 1)
+			storeTestOutput(t, transcoder, "c", 100, 3)
+
+			transcoder.SetMaxCacheSize(tt.limit)
+			if got := recorder.Evicted(); !reflect.DeepEqual(got, tt.wantEvicted) {
+				t.Errorf("evicted %v, want %v", got, tt.wantEvicted)
+			}
+			if got := cachedKeys(t, transcoder, "a", "b", "c"); !reflect.DeepEqual(got, tt.wantCached) {
+				t.Errorf("cached %v, want %v", got, tt.wantCached)
+			}
+			if got, want := transcoder.CacheSize(), int64(100*len(tt.wantCached)); got != want {
+				t.Errorf("CacheSize() = %d, want %d", got, want)
+			}
+		})
+	}
+}
+
+func TestStoreCachedOutputEvictsOtherEntries(t *testing.T) {
+	transcoder := newTestTranscoder(t)
+	recorder := &evictionRecorder{}
+	transcoder.SetOnCacheEvict(recorder.record)
+	transcoder.SetMaxCacheSize(250)
+
+	storeTestOutput(t, transcoder, "a", 100, 1)
+	storeTestOutput(t, transcoder, "b", 100, 2)
+	// 再次使用a后，b成为最久未使用的缓存
+	if _, ok := transcoder.getCachedOutput("a"); !ok {
+		t.Fatal("cache a missing before the limit was reached")
+	}
+	storeTestOutput(t, transcoder, "c", 100, 0)
+
+	if got := recorder.Evicted(); !reflect.DeepEqual(got, []string{"b"}) {
+		t.Errorf("evicted %v, want [b]", got)
+	}
+	if got := cachedKeys(t, transcoder, "a", "b", "c"); !reflect.DeepEqual(got, []string{"a", "c"}) {
+		t.Errorf("cached %v, want [a c]", got)
+	}
+}
+
+func TestStoreCachedOutputKeepsNewOutputOverLimit(t *testing.T) {
+	transcoder := newTestTranscoder(t)
+	recorder := &evictionRecorder{}
+	transcoder.SetOnCacheEvict(recorder.record)
+	transcoder.SetMaxCacheSize(50)
+
+	// 刚完成的转码即使单独超过上限也保留，下一次转码完成时才被删除
+	storeTestOutput(t, transcoder, "a", 100, 0)
+	if got := cachedKeys(t, transcoder, "a"); len(got) != 1 || len(recorder.Evicted()) != 0 {
+		t.Fatalf("new output over the limit: cached %v, evicted %v, want it kept", got, recorder.Evicted())
+	}
+	storeTestOutput(t, transcoder, "b", 100, 0)
+	if got := recorder.Evicted(); !reflect.DeepEqual(got, []string{"a"}) {
+		t.Errorf("evicted %v, want [a]", got)
+	}
+	if got := cachedKeys(t, transcoder, "a", "b"); !reflect.DeepEqual(got, []string{"b"}) {
+		t.Errorf("cached %v, want [b]", got)
+	}
+}
@@ -0,0 +1,155 @@
+package transcoder
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"GoCastify/types"
+)
+
+// 大多数DLNA设备可以直接解码的视频编码
+var compatibleVideoCodecs = map[string]bool{
+	"h264": true,
+	"hevc": true,
+}
+
+// copyablePixelFormats 可以直接复制的视频流的像素格式
+// 大多数设备只能硬件解码8位4:2:0的H.264，支持HEVC的设备通常也支持10位的Main10
+var copyablePixelFormats = map[string]map[string]bool{
+	"h264": {"yuv420p": true, "yuvj420p": true},
+	"hevc": {"yuv420p": true, "yuvj420p": true, "yuv420p10le": true},
+}
+
+// videoCopyName 直接复制视频流、不重新编码时FFmpeg的视频编码参数
+const videoCopyName = "copy"
+
+// copyVideoEncoder 返回直接复制视频流的编码器，HEVC标记为hvc1，使更多设备能够识别
+func copyVideoEncoder(codec string) videoEncoder {
+	return videoEncoder{
+		name: videoCopyName,
+		args: func(TranscodeSettings) []string {
+			if codec == "hevc" {
+				return []string{"-tag:v", "hvc1"}
+			}
+			return nil
+		},
+	}
+}
+
+// canCopyVideo 判断源视频能否不重新编码，直接封装进MP4
+// 视频编码必须是设备普遍支持的H.264/HEVC，并且不需要烧录字幕、缩小画面或改变帧率；
+// 没有探测到像素格式时按可以复制处理
+func canCopyVideo(mediaInfo map[string]string, subtitleTrackIndex int, options TranscodeOptions) bool {
+	codec := strings.ToLower(mediaInfo["video_codec"])
+	if !compatibleVideoCodecs[codec] {
+		return false
+	}
+	if pixFmt := mediaInfo["pix_fmt"]; pixFmt != "" && !copyablePixelFormats[codec][pixFmt] {
+		return false
+	}
+	if subtitleTrackIndex >= 0 && options.BurnSubtitles {
+		return false
+	}
+	return scaleFilter(mediaInfo, options.MaxHeight) == "" && len(frameRateArgs(mediaInfo, options.MaxFrameRate)) == 0
+}
+
+// CompatibilityReport 媒体文件的容器和编码兼容性分析，用于向用户说明是否需要转码
+type CompatibilityReport struct {
+	// Container 容器格式（扩展名，不含点）
+	Container string
+	// ContainerCompatible 容器是否为设备普遍支持的MP4
+	ContainerCompatible bool
+	// VideoCodec 视频编码，没有视频流时为空
+	VideoCodec string
+	// VideoCompatible 视频编码是否为H.264/HEVC
+	VideoCompatible bool
+	// AudioCodec 默认音轨的音频编码，没有音频流时为空
+	AudioCodec string
+	// AudioNeedsTranscode 音频编码是否需要转码为AAC
+	AudioNeedsTranscode bool
+}
+
+// CompatibilityReport 根据媒体信息和音轨列表分析文件的兼容性
+func (t *Transcoder) CompatibilityReport(filePath string) (CompatibilityReport, error) {
+	mediaInfo, err := t.GetMediaInfo(filePath)
+	if err != nil {
+		return CompatibilityReport{}, err
+	}
+	audioTracks, err := t.GetAudioTracks(filePath)
+	if err != nil {
+		return CompatibilityReport{}, err
+	}
+	return newCompatibilityReport(filePath, mediaInfo, audioTracks), nil
+}
+
+// newCompatibilityReport 根据扩展名、媒体信息和音轨列表生成兼容性分析
+// 音频编码取默认音轨，没有默认音轨时取第一条音轨
+func newCompatibilityReport(filePath string, mediaInfo map[string]string, audioTracks []types.AudioTrack) CompatibilityReport {
+	_, needTranscode := IsSupportedFormat(filePath)
+	report := CompatibilityReport{
+		Container:           strings.TrimPrefix(strings.ToLower(filepath.Ext(filePath)), "."),
+		ContainerCompatible: !needTranscode,
+		VideoCodec:          strings.ToLower(mediaInfo["video_codec"]),
+		AudioCodec:          strings.ToLower(mediaInfo["audio_codec"]),
+	}
+	report.VideoCompatible = compatibleVideoCodecs[report.VideoCodec]
+
+	for i, track := range audioTracks {
+		if track.CodecName != "" && (track.IsDefault || i == 0) {
+			report.AudioCodec = strings.ToLower(track.CodecName)
+		}
+		if track.IsDefault {
+			break
+		}
+	}
+	report.AudioNeedsTranscode = needTranscodeAudioFormats[report.AudioCodec]
+	return report
+}
+
+// Reasons 返回需要转码的原因，可以直接播放时返回空列表
+func (r CompatibilityReport) Reasons() []string {
+	reasons := []string{}
+	if !r.ContainerCompatible {
+		reasons = append(reasons, "容器为 "+strings.ToUpper(r.Container))
+	}
+	if r.VideoCodec != "" && !r.VideoCompatible {
+		reasons = append(reasons, "视频为 "+strings.ToUpper(r.VideoCodec))
+	}
+	if r.AudioNeedsTranscode {
+		reasons = append(reasons, "音频为 "+strings.ToUpper(r.AudioCodec))
+	}
+	return reasons
+}
+
+// Verdict 返回一句话结论，例如 "可直接播放" 或 "需要转码：音频为 DTS"
+func (r CompatibilityReport) Verdict() string {
+	reasons := r.Reasons()
+	if len(reasons) == 0 {
+		return "可直接播放"
+	}
+	return "需要转码：" + strings.Join(reasons, "，")
+}
+
+// Summary 返回多行的兼容性摘要，包含容器、视频、音频和结论
+func (r CompatibilityReport) Summary() string {
+	lines := []string{
+		fmt.Sprintf("容器: %s (%s)", strings.ToUpper(r.Container), compatibilityMark(r.ContainerCompatible)),
+	}
+	if r.VideoCodec != "" {
+		lines = append(lines, fmt.Sprintf("视频: %s (%s)", strings.ToUpper(r.VideoCodec), compatibilityMark(r.VideoCompatible)))
+	}
+	if r.AudioCodec != "" {
+		lines = append(lines, fmt.Sprintf("音频: %s (%s)", strings.ToUpper(r.AudioCodec), compatibilityMark(!r.AudioNeedsTranscode)))
+	}
+	lines = append(lines, r.Verdict())
+	return strings.Join(lines, "\n")
+}
+
+// compatibilityMark 返回兼容性的简短说明
+func compatibilityMark(compatible bool) string {
+	if compatible {
+		return "兼容"
+	}
+	return "需转码"
+}
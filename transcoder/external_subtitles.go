@@ -0,0 +1,208 @@
+package transcoder
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"GoCastify/types"
+)
+
+// ExternalSubtitleIndexBase 外部字幕文件使用的字幕序号起点
+// 序号ExternalSubtitleIndexBase+i表示FindExternalSubtitles返回的第i个文件，
+// 与内嵌字幕的序号不会冲突，因此可以沿用按序号选择字幕的所有流程
+const ExternalSubtitleIndexBase = 1000
+
+// externalSubtitleExts FFmpeg可以直接读取的外部字幕文件扩展名
+var externalSubtitleExts = map[string]bool{
+	".srt": true,
+	".ass": true,
+	".ssa": true,
+	".vtt": true,
+}
+
+// subtitleFolderNames 常见的字幕子目录名称，比较时不区分大小写
+var subtitleFolderNames = map[string]bool{
+	"subs":      true,
+	"sub":       true,
+	"subtitles": true,
+	"subtitle":  true,
+}
+
+// subtitleLanguageTags 字幕文件名中常见的语言标记及其对应的语言代码
+var subtitleLanguageTags = map[string]string{
+	"en": "en", "eng": "en", "english": "en",
+	"zh": "zh", "chi": "zh", "zho": "zh", "chinese": "zh",
+	"chs": "zh-Hans", "sc": "zh-Hans", "gb": "zh-Hans", "zh-hans": "zh-Hans", "zh-cn": "zh-CN", "简体": "zh-Hans", "简中": "zh-Hans",
+	"cht": "zh-Hant", "tc": "zh-Hant", "big5": "zh-Hant", "zh-hant": "zh-Hant", "zh-tw": "zh-TW", "zh-hk": "zh-HK", "繁体": "zh-Hant", "繁中": "zh-Hant",
+	"ja": "ja", "jp": "ja", "jpn": "ja", "japanese": "ja",
+	"ko": "ko", "kor": "ko", "korean": "ko",
+	"fr": "fr", "fre": "fr", "fra": "fr", "french": "fr",
+	"de": "de", "ger": "de", "deu": "de", "german": "de",
+	"es": "es", "spa": "es", "spanish": "es",
+	"it": "it", "ita": "it", "italian": "it",
+	"pt": "pt", "por": "pt", "portuguese": "pt", "pt-br": "pt-BR",
+	"ru": "ru", "rus": "ru", "russian": "ru",
+}
+
+// ExternalSubtitle 媒体文件对应的外部字幕文件
+type ExternalSubtitle struct {
+	Path     string // 字幕文件路径
+	Language string // 从文件名推断的语言代码，无法推断时为空
+	Forced   bool   // 文件名带有forced标记
+}
+
+// FindExternalSubtitles 查找媒体文件对应的外部字幕文件，支持以下命名方式：
+// 同目录下的 "movie.srt"、"movie.en.srt"、"movie.chs.ass"；
+// 字幕子目录（Subs、Subtitles等）中的 "movie.en.srt" 或只以语言命名的 "English.srt"；
+// 字幕子目录中以媒体文件名命名的目录里的所有字幕文件，例如 "Subs/movie/2_English.srt"
+// 结果按路径排序，同一文件多次查找的顺序不变
+func FindExternalSubtitles(mediaFile string) []ExternalSubtitle {
+	dir := filepath.Dir(mediaFile)
+	baseName := strings.TrimSuffix(filepath.Base(mediaFile), filepath.Ext(mediaFile))
+
+	subtitles := []ExternalSubtitle{}
+	subtitles = append(subtitles, matchingSubtitles(dir, baseName)...)
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return subtitles
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() || !subtitleFolderNames[strings.ToLower(entry.Name())] {
+			continue
+		}
+		folder := filepath.Join(dir, entry.Name())
+		subtitles = append(subtitles, matchingSubtitles(folder, baseName)...)
+		subtitles = append(subtitles, languageNamedSubtitles(folder)...)
+		subtitles = append(subtitles, folderSubtitles(filepath.Join(folder, baseName))...)
+	}
+
+	sort.SliceStable(subtitles, func(i, j int) bool {
+		return subtitles[i].Path < subtitles[j].Path
+	})
+	return subtitles
+}
+
+// matchingSubtitles 返回dir中以媒体文件名开头的字幕文件，语言从文件名剩余的部分推断
+func matchingSubtitles(dir, baseName string) []ExternalSubtitle {
+	subtitles := []ExternalSubtitle{}
+	for _, name := range subtitleFileNames(dir) {
+		stem := strings.TrimSuffix(name, filepath.Ext(name))
+		if stem != baseName && !strings.HasPrefix(stem, baseName+".") {
+			continue
+		}
+		language, forced := parseSubtitleTags(strings.TrimPrefix(stem, baseName))
+		subtitles = append(subtitles, ExternalSubtitle{Path: filepath.Join(dir, name), Language: language, Forced: forced})
+	}
+	return subtitles
+}
+
+// languageNamedSubtitles 返回dir中只以语言命名的字幕文件，例如 "English.srt"、"chs.ass"
+// 这种命名通常出现在只有一部影片的目录中
+func languageNamedSubtitles(dir string) []ExternalSubtitle {
+	subtitles := []ExternalSubtitle{}
+	for _, name := range subtitleFileNames(dir) {
+		stem := strings.TrimSuffix(name, filepath.Ext(name))
+		language, ok := subtitleLanguageTags[strings.ToLower(stem)]
+		if !ok {
+			continue
+		}
+		subtitles = append(subtitles, ExternalSubtitle{Path: filepath.Join(dir, name), Language: language})
+	}
+	return subtitles
+}
+
+// folderSubtitles 返回dir中的所有字幕文件，dir不存在时返回空列表
+func folderSubtitles(dir string) []ExternalSubtitle {
+	subtitles := []ExternalSubtitle{}
+	for _, name := range subtitleFileNames(dir) {
+		language, forced := parseSubtitleTags(strings.TrimSuffix(name, filepath.Ext(name)))
+		subtitles = append(subtitles, ExternalSubtitle{Path: filepath.Join(dir, name), Language: language, Forced: forced})
+	}
+	return subtitles
+}
+
+// subtitleFileNames 返回dir中扩展名为字幕格式的文件名，忽略隐藏文件
+func subtitleFileNames(dir string) []string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	names := []string{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || strings.HasPrefix(name, ".") || !externalSubtitleExts[strings.ToLower(filepath.Ext(name))] {
+			continue
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+// parseSubtitleTags 从字幕文件名中去掉扩展名后的部分推断语言和强制标记
+// 按 "." 分隔逐段识别，例如 ".en.forced" 为英语强制字幕；
+// 整段无法识别时再按 "_"、"-"、空格 拆分，例如 "2_English"
+func parseSubtitleTags(tags string) (language string, forced bool) {
+	for _, tag := range strings.Split(strings.ToLower(tags), ".") {
+		if tag == "" {
+			continue
+		}
+		if tag == "forced" {
+			forced = true
+			continue
+		}
+		if lang, ok := subtitleLanguageTags[tag]; ok {
+			if language == "" {
+				language = lang
+			}
+			continue
+		}
+		for _, part := range strings.FieldsFunc(tag, func(r rune) bool {
+			return r == '_' || r == '-' || r == ' '
+		}) {
+			if part == "forced" {
+				forced = true
+			} else if lang, ok := subtitleLanguageTags[part]; ok && language == "" {
+				language = lang
+			}
+		}
+	}
+	return language, forced
+}
+
+// ExternalSubtitleTracks 将媒体文件对应的外部字幕文件转换为字幕轨道
+// 轨道序号从ExternalSubtitleIndexBase开始，标题为字幕文件名，ExternalPath为字幕文件路径
+func ExternalSubtitleTracks(mediaFile string) []types.SubtitleTrack {
+	tracks := []types.SubtitleTrack{}
+	for i, subtitle := range FindExternalSubtitles(mediaFile) {
+		tracks = append(tracks, types.SubtitleTrack{
+			Index:        ExternalSubtitleIndexBase + i,
+			Language:     subtitle.Language,
+			Title:        filepath.Base(subtitle.Path),
+			IsForced:     subtitle.Forced,
+			ExternalPath: subtitle.Path,
+		})
+	}
+	return tracks
+}
+
+// IsExternalSubtitleIndex 判断字幕序号是否表示外部字幕文件
+func IsExternalSubtitleIndex(subtitleTrackIndex int) bool {
+	return subtitleTrackIndex >= ExternalSubtitleIndexBase
+}
+
+// externalSubtitlePath 返回字幕序号对应的外部字幕文件路径
+// 序号不表示外部字幕或对应的文件已不存在时第二个返回值为false
+func externalSubtitlePath(mediaFile string, subtitleTrackIndex int) (string, bool) {
+	if !IsExternalSubtitleIndex(subtitleTrackIndex) {
+		return "", false
+	}
+	subtitles := FindExternalSubtitles(mediaFile)
+	i := subtitleTrackIndex - ExternalSubtitleIndexBase
+	if i >= len(subtitles) {
+		return "", false
+	}
+	return subtitles[i].Path, true
+}
@@ -0,0 +1,98 @@
+package transcoder
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"GoCastify/types"
+)
+
+// writeFiles 在dir中创建指定相对路径的空文件
+func writeFiles(t *testing.T, dir string, names ...string) {
+	t.Helper()
+	for _, name := range names {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestFindExternalSubtitles(t *testing.T) {
+	tests := []struct {
+		name  string
+		files []string
+		want  []ExternalSubtitle
+	}{
+		{name: "same base name", files: []string{"Movie.srt"}, want: []ExternalSubtitle{{Path: "Movie.srt"}}},
+		{name: "language tag", files: []string{"Movie.en.srt"}, want: []ExternalSubtitle{{Path: "Movie.en.srt", Language: "en"}}},
+		{name: "Chinese tag", files: []string{"Movie.chs.ass"}, want: []ExternalSubtitle{{Path: "Movie.chs.ass", Language: "zh-Hans"}}},
+		{name: "region tag", files: []string{"Movie.zh-TW.vtt"}, want: []ExternalSubtitle{{Path: "Movie.zh-TW.vtt", Language: "zh-TW"}}},
+		{name: "forced tag", files: []string{"Movie.eng.forced.srt"}, want: []ExternalSubtitle{{Path: "Movie.eng.forced.srt", Language: "en", Forced: true}}},
+		{name: "unknown tag", files: []string{"Movie.director.srt"}, want: []ExternalSubtitle{{Path: "Movie.director.srt"}}},
+		{
+			name:  "other files ignored",
+			files: []string{"Other.en.srt", "Movie2.srt", "Movie.txt", "Movie.idx", ".Movie.srt"},
+			want:  []ExternalSubtitle{},
+		},
+		{name: "subfolder with media name", files: []string{"Subs/Movie.ja.srt"}, want: []ExternalSubtitle{{Path: "Subs/Movie.ja.srt", Language: "ja"}}},
+		{name: "subfolder with language name", files: []string{"subtitles/English.srt"}, want: []ExternalSubtitle{{Path: "subtitles/English.srt", Language: "en"}}},
+		{name: "subfolder named after media", files: []string{"Subs/Movie/2_English.srt", "Subs/Movie/3_Chinese-forced.ass"}, want: []ExternalSubtitle{
+			{Path: "Subs/Movie/2_English.srt", Language: "en"},
+			{Path: "Subs/Movie/3_Chinese-forced.ass", Language: "zh", Forced: true},
+		}},
+		{name: "other folders ignored", files: []string{"Extras/Movie.en.srt", "Subs/notes.srt"}, want: []ExternalSubtitle{}},
+		{
+			name:  "sorted by path",
+			files: []string{"Subs/Movie.en.srt", "Movie.zh.srt", "Movie.en.srt"},
+			want: []ExternalSubtitle{
+				{Path: "Movie.en.srt", Language: "en"},
+				{Path: "Movie.zh.srt", Language: "zh"},
+				{Path: "Subs/Movie.en.srt", Language: "en"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, append([]string{"Movie.mkv"}, tt.files...)...)
+			want := make([]ExternalSubtitle, len(tt.want))
+			for i, subtitle := range tt.want {
+				subtitle.Path = filepath.Join(dir, filepath.FromSlash(subtitle.Path))
+				want[i] = subtitle
+			}
+
+			if got := FindExternalSubtitles(filepath.Join(dir, "Movie.mkv")); !reflect.DeepEqual(got, want) {
+				t.Errorf("FindExternalSubtitles() = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestExternalSubtitleTracks(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, "Movie.mkv", "Movie.en.srt", "Subs/Movie.chs.forced.ass")
+	mediaFile := filepath.Join(dir, "Movie.mkv")
+
+	want := []types.SubtitleTrack{
+		{Index: ExternalSubtitleIndexBase, Language: "en", Title: "Movie.en.srt", ExternalPath: filepath.Join(dir, "Movie.en.srt")},
+		{Index: ExternalSubtitleIndexBase + 1, Language: "zh-Hans", Title: "Movie.chs.forced.ass", IsForced: true, ExternalPath: filepath.Join(dir, "Subs", "Movie.chs.forced.ass")},
+	}
+	tracks := ExternalSubtitleTracks(mediaFile)
+	if !reflect.DeepEqual(tracks, want) {
+		t.Fatalf("ExternalSubtitleTracks() = %+v, want %+v", tracks, want)
+	}
+	for _, track := range tracks {
+		if path, ok := externalSubtitlePath(mediaFile, track.Index); !ok || path != track.ExternalPath {
+			t.Errorf("externalSubtitlePath(%d) = %q, %v, want %q", track.Index, path, ok, track.ExternalPath)
+		}
+	}
+	if _, ok := externalSubtitlePath(mediaFile, ExternalSubtitleIndexBase+2); ok {
+		t.Error("externalSubtitlePath() found a subtitle past the end of the list")
+	}
+}
@@ -0,0 +1,42 @@
+package transcoder
+
+import (
+	"context"
+	"fmt"
+)
+
+// FullProbe 返回ffprobe输出的完整媒体信息（JSON，包含容器格式和所有流）
+// 用于排查无法播放的文件；结果按文件路径、修改时间和大小缓存
+func (t *Transcoder) FullProbe(filePath string) (string, error) {
+	modTime, size, err := sourceStamp(filePath)
+	if err != nil {
+		return "", fmt.Errorf("读取源文件信息失败: %w", err)
+	}
+	cacheKey := fmt.Sprintf("%s_%d_%d", filePath, modTime, size)
+
+	t.probeMutex.Lock()
+	cached, exists := t.fullProbes[cacheKey]
+	t.probeMutex.Unlock()
+	if exists {
+		return cached, nil
+	}
+
+	if !CheckFFmpeg() {
+		return "", fmt.Errorf("未找到FFmpeg，请先安装FFmpeg")
+	}
+
+	output, err := runProbe(context.Background(),
+		"-v", "error",
+		"-show_format",
+		"-show_streams",
+		"-of", "json",
+		filePath)
+	if err != nil {
+		return "", err
+	}
+
+	t.probeMutex.Lock()
+	t.fullProbes[cacheKey] = string(output)
+	t.probeMutex.Unlock()
+	return string(output), nil
+}
@@ -0,0 +1,225 @@
+package transcoder
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 视频编码器的选择，用于TranscodeOptions.VideoEncoder
+const (
+	// VideoEncoderAuto 优先使用检测到的硬件编码器，没有时使用软件编码
+	VideoEncoderAuto = ""
+	// VideoEncoderSoftware 始终使用软件H.264编码
+	VideoEncoderSoftware = "h264"
+)
+
+// encoderProbeTimeout 检测一个硬件编码器是否可用的超时时间
+const encoderProbeTimeout = 10 * time.Second
+
+// videoEncoder 一个H.264编码器及其参数
+type videoEncoder struct {
+	// name FFmpeg的编码器名称
+	name string
+	// inputArgs 放在-i之前的参数，例如指定硬件设备
+	inputArgs []string
+	// filter 追加在视频滤镜最后的滤镜，例如把画面上传到GPU
+	filter string
+	// args 根据转码参数返回编码参数，硬件编码器使用与CRF对应的质量参数
+	args func(quality TranscodeSettings) []string
+}
+
+// softwareEncoder 软件H.264编码：兼容性更好的配置，速度和画质由转码参数决定
+var softwareEncoder = videoEncoder{
+	name: VideoEncoderSoftware,
+	args: func(quality TranscodeSettings) []string {
+		return []string{"-preset", quality.Preset, "-crf", strconv.Itoa(quality.CRF), "-profile:v", "main", "-level", "4.0"}
+	},
+}
+
+// hardwareEncoders 支持的硬件H.264编码器，按自动选择时的优先级排列
+var hardwareEncoders = []videoEncoder{
+	// macOS，只支持按码率编码，没有设置码率上限时使用8Mbps
+	{name: "h264_videotoolbox", args: func(quality TranscodeSettings) []string {
+		bitrate := "8M"
+		if quality.VideoBitrate > 0 {
+			bitrate = fmt.Sprintf("%dk", quality.VideoBitrate)
+		}
+		return []string{"-profile:v", "main", "-b:v", bitrate}
+	}},
+	// NVIDIA
+	{name: "h264_nvenc", args: func(quality TranscodeSettings) []string {
+		return []string{"-preset", "fast", "-rc", "vbr", "-cq", strconv.Itoa(quality.CRF), "-profile:v", "main"}
+	}},
+	// Intel Quick Sync
+	{name: "h264_qsv", args: func(quality TranscodeSettings) []string {
+		return []string{"-preset", "veryfast", "-global_quality", strconv.Itoa(quality.CRF), "-profile:v", "main"}
+	}},
+	// Linux VA-API，需要先把画面上传到GPU
+	{
+		name:      "h264_vaapi",
+		inputArgs: []string{"-vaapi_device", "/dev/dri/renderD128"},
+		filter:    "format=nv12,hwupload",
+		args: func(quality TranscodeSettings) []string {
+			return []string{"-qp", strconv.Itoa(quality.CRF), "-profile:v", "main"}
+		},
+	},
+}
+
+// HardwareEncoderNames 返回支持的硬件编码器名称，用于让用户强制使用某个编码器
+func HardwareEncoderNames() []string {
+	names := make([]string, 0, len(hardwareEncoders))
+	for _, encoder := range hardwareEncoders {
+		names = append(names, encoder.name)
+	}
+	return names
+}
+
+// 硬件编码器检测结果，按FFmpeg可执行文件缓存
+var (
+	encoderProbeMu      sync.Mutex
+	encoderProbeBinary  string
+	encoderProbeResults []string
+	encoderProbeDone    bool
+)
+
+// AvailableHardwareEncoders 返回当前FFmpeg中可以使用的硬件编码器名称，按优先级排列
+// 先从 "ffmpeg -encoders" 中找出编译进FFmpeg的编码器，再对每个编码器做一次极短的试编码，
+// 排除没有对应硬件或驱动的编码器；结果按FFmpeg可执行文件缓存，首次调用可能需要几秒
+func AvailableHardwareEncoders() []string {
+	binary := ffmpegCommand()
+	encoderProbeMu.Lock()
+	defer encoderProbeMu.Unlock()
+	if encoderProbeDone && encoderProbeBinary == binary {
+		return encoderProbeResults
+	}
+
+	available := []string{}
+	listed := listFFmpegEncoders(binary)
+	for _, encoder := range hardwareEncoders {
+		if !listed[encoder.name] {
+			continue
+		}
+		if err := probeEncoder(binary, encoder); err != nil {
+			log.Printf("硬件编码器 %s 不可用: %v", encoder.name, err)
+			continue
+		}
+		available = append(available, encoder.name)
+	}
+	log.Printf("可用的硬件编码器: %v", available)
+
+	encoderProbeBinary = binary
+	encoderProbeResults = available
+	encoderProbeDone = true
+	return available
+}
+
+// listFFmpegEncoders 解析 "ffmpeg -encoders" 的输出，返回所有视频编码器的名称
+// 输出的每行形如 " V....D h264_nvenc           NVIDIA NVENC H.264 encoder"
+func listFFmpegEncoders(binary string) map[string]bool {
+	ctx, cancel := context.WithTimeout(context.Background(), encoderProbeTimeout)
+	defer cancel()
+	output, err := exec.CommandContext(ctx, binary, "-hide_banner", "-encoders").Output()
+	if err != nil {
+		log.Printf("获取FFmpeg编码器列表失败: %v", err)
+		return nil
+	}
+
+	encoders := make(map[string]bool)
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && strings.HasPrefix(fields[0], "V") && len(fields[0]) == 6 {
+			encoders[fields[1]] = true
+		}
+	}
+	return encoders
+}
+
+// probeEncoder 用编码器编码一帧测试画面，检查硬件和驱动是否可用
+func probeEncoder(binary string, encoder videoEncoder) error {
+	ctx, cancel := context.WithTimeout(context.Background(), encoderProbeTimeout)
+	defer cancel()
+
+	args := []string{"-hide_banner", "-loglevel", "error"}
+	args = append(args, encoder.inputArgs...)
+	args = append(args, "-f", "lavfi", "-i", "color=c=black:s=256x256:d=0.1")
+	if encoder.filter != "" {
+		args = append(args, "-vf", encoder.filter)
+	}
+	args = append(args, "-frames:v", "1", "-c:v", encoder.name)
+	args = append(args, encoder.args(DefaultTranscodeSettings())...)
+	args = append(args, "-f", "null", "-")
+
+	output, err := exec.CommandContext(ctx, binary, args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// videoEncoderForSource 选择转码源文件使用的视频编码器
+// 源视频已经是设备支持的编码且不需要处理画面时直接复制视频流，只重新封装，否则按选项选择编码器
+func (t *Transcoder) videoEncoderForSource(mediaInfo map[string]string, subtitleTrackIndex int, options TranscodeOptions) videoEncoder {
+	if canCopyVideo(mediaInfo, subtitleTrackIndex, options) {
+		return copyVideoEncoder(strings.ToLower(mediaInfo["video_codec"]))
+	}
+	return t.videoEncoderFor(options)
+}
+
+// videoEncoderFor 根据选项选择本次转码使用的视频编码器
+// 自动选择时跳过本次运行中转码失败过的硬件编码器；强制使用的编码器未知时使用软件编码
+func (t *Transcoder) videoEncoderFor(options TranscodeOptions) videoEncoder {
+	switch options.VideoEncoder {
+	case VideoEncoderSoftware:
+		return softwareEncoder
+	case VideoEncoderAuto:
+		for _, name := range AvailableHardwareEncoders() {
+			if !t.encoderFailed(name) {
+				encoder, _ := hardwareEncoderByName(name)
+				return encoder
+			}
+		}
+		return softwareEncoder
+	default:
+		if encoder, ok := hardwareEncoderByName(options.VideoEncoder); ok {
+			return encoder
+		}
+		log.Printf("未知的视频编码器 %s，使用软件编码", options.VideoEncoder)
+		return softwareEncoder
+	}
+}
+
+// hardwareEncoderByName 按名称查找硬件编码器
+func hardwareEncoderByName(name string) (videoEncoder, bool) {
+	for _, encoder := range hardwareEncoders {
+		if encoder.name == name {
+			return encoder, true
+		}
+	}
+	return videoEncoder{}, false
+}
+
+// markEncoderFailed 记录转码失败的硬件编码器，之后自动选择时不再使用
+func (t *Transcoder) markEncoderFailed(name string) {
+	t.optionsMutex.Lock()
+	defer t.optionsMutex.Unlock()
+	if t.failedEncoders == nil {
+		t.failedEncoders = make(map[string]bool)
+	}
+	t.failedEncoders[name] = true
+}
+
+// encoderFailed 硬件编码器是否在本次运行中转码失败过
+func (t *Transcoder) encoderFailed(name string) bool {
+	t.optionsMutex.RLock()
+	defer t.optionsMutex.RUnlock()
+	return t.failedEncoders[name]
+}
@@ -0,0 +1,73 @@
+package transcoder
+
+import (
+	"log"
+	"os"
+	"time"
+)
+
+// StartCacheJanitor 启动后台定期清理转码缓存的任务，interval为清理间隔
+// 再次调用会以新的间隔重新启动；interval不大于0时只停止已有的清理任务。
+// 清理任务在Cleanup时停止
+func (t *Transcoder) StartCacheJanitor(interval time.Duration) {
+	t.cacheMutex.Lock()
+	defer t.cacheMutex.Unlock()
+
+	t.stopCacheJanitorLocked()
+	if interval <= 0 {
+		return
+	}
+
+	stop := make(chan struct{})
+	t.janitorStop = stop
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				t.pruneCache()
+			}
+		}
+	}()
+}
+
+// stopCacheJanitorLocked 停止后台清理任务，调用方需持有cacheMutex
+func (t *Transcoder) stopCacheJanitorLocked() {
+	if t.janitorStop != nil {
+		close(t.janitorStop)
+		t.janitorStop = nil
+	}
+}
+
+// pruneCache 清理过期的缓存，以及源文件已变化或输出文件已丢失的缓存记录
+func (t *Transcoder) pruneCache() {
+	t.cacheMutex.Lock()
+	defer t.cacheMutex.Unlock()
+
+	before := len(t.transcodingCache)
+	t.cleanupExpiredCache()
+
+	changed := false
+	for key, entry := range t.cacheSources {
+		if entry.isValid() {
+			continue
+		}
+		os.Remove(entry.Output)
+		delete(t.transcodingCache, key)
+		delete(t.cacheExpiry, key)
+		delete(t.cacheSources, key)
+		changed = true
+	}
+	if changed && t.persistentCache {
+		if err := t.saveCacheIndexLocked(); err != nil {
+			log.Printf("保存转码缓存索引失败: %v", err)
+		}
+	}
+
+	if removed := before - len(t.transcodingCache); removed > 0 {
+		log.Printf("定期清理转码缓存: 删除 %d 条记录", removed)
+	}
+}
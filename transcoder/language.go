@@ -0,0 +1,97 @@
+package transcoder
+
+import (
+	"slices"
+	"strings"
+
+	"GoCastify/types"
+)
+
+// ParseLanguageList 解析用户输入的首选语言列表，例如 "zh-CN, zh, en"
+// 以逗号（含中文逗号）或空白分隔，忽略空项和重复项，保持输入顺序
+func ParseLanguageList(text string) []string {
+	fields := strings.FieldsFunc(text, func(r rune) bool {
+		return r == ',' || r == '，' || r == ';' || r == '；' || r == ' ' || r == '\t'
+	})
+	languages := []string{}
+	for _, field := range fields {
+		if !slices.Contains(languages, field) {
+			languages = append(languages, field)
+		}
+	}
+	return languages
+}
+
+// SetPreferredLanguages 设置选择默认字幕和音轨时使用的首选语言，按优先级从高到低排列
+// 为空时按文件的默认标记和系统语言选择；修改后清除轨道信息缓存，下次读取时重新选择
+func (t *Transcoder) SetPreferredLanguages(languages []string) {
+	t.optionsMutex.Lock()
+	changed := !slices.Equal(t.preferredLanguages, languages)
+	t.preferredLanguages = slices.Clone(languages)
+	t.optionsMutex.Unlock()
+	if !changed {
+		return
+	}
+
+	t.subtitleMutex.Lock()
+	t.subtitleTracks = make(map[string][]types.SubtitleTrack)
+	t.subtitleMutex.Unlock()
+	t.audioMutex.Lock()
+	t.audioTracks = make(map[string][]types.AudioTrack)
+	t.audioMutex.Unlock()
+}
+
+// PreferredLanguages 获取首选语言列表
+func (t *Transcoder) PreferredLanguages() []string {
+	t.optionsMutex.RLock()
+	defer t.optionsMutex.RUnlock()
+	return slices.Clone(t.preferredLanguages)
+}
+
+// languageMatches 判断轨道语言是否符合首选语言
+// 两者都带有地区（如zh-CN与zh-TW）时要求完全一致，否则只比较主语言，
+// 因此 "zh" 可以匹配 "chi"、"zh-CN" 等轨道
+func languageMatches(trackLanguage, preferred string) bool {
+	trackTag := languageTag(trackLanguage)
+	preferredTag := languageTag(preferred)
+	if trackTag == "" || preferredTag == "" {
+		return false
+	}
+	if strings.Contains(trackTag, "-") && strings.Contains(preferredTag, "-") {
+		return trackTag == preferredTag
+	}
+	return normalizeLanguage(trackTag) == normalizeLanguage(preferredTag)
+}
+
+// languageTag 将语言标签规范化为小写并以连字符分隔，例如 "zh_CN" 规范化为 "zh-cn"
+func languageTag(lang string) string {
+	lang = strings.ToLower(strings.TrimSpace(lang))
+	if i := strings.Index(lang, "."); i >= 0 {
+		lang = lang[:i]
+	}
+	return strings.ReplaceAll(lang, "_", "-")
+}
+
+// preferredLanguageTrack 按首选语言的优先级选择轨道，返回其在languages中的位置
+// languages为各轨道的语言；同一语言有多条轨道时，fallback中标记的轨道（如强制字幕）排在其他轨道之后，
+// fallback为nil时按轨道顺序选择；没有轨道符合任何首选语言时第二个返回值为false
+func preferredLanguageTrack(languages []string, fallback []bool, preferred []string) (int, bool) {
+	for _, lang := range preferred {
+		found := -1
+		for i, trackLanguage := range languages {
+			if !languageMatches(trackLanguage, lang) {
+				continue
+			}
+			if fallback == nil || !fallback[i] {
+				return i, true
+			}
+			if found < 0 {
+				found = i
+			}
+		}
+		if found >= 0 {
+			return found, true
+		}
+	}
+	return -1, false
+}
@@ -0,0 +1,134 @@
+package transcoder
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"GoCastify/types"
+)
+
+func TestParseLanguageList(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{text: "", want: []string{}},
+		{text: "zh-CN, zh, en", want: []string{"zh-CN", "zh", "en"}},
+		{text: "ja，en；fr", want: []string{"ja", "en", "fr"}},
+		{text: "en  en,ja", want: []string{"en", "ja"}},
+	}
+	for _, tt := range tests {
+		if got := ParseLanguageList(tt.text); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseLanguageList(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestLanguageMatches(t *testing.T) {
+	tests := []struct {
+		track     string
+		preferred string
+		want      bool
+	}{
+		{track: "chi", preferred: "zh", want: true},
+		{track: "zh-CN", preferred: "zh", want: true},
+		{track: "zh_CN", preferred: "zh-cn", want: true},
+		{track: "zh-TW", preferred: "zh-CN", want: false},
+		{track: "chi", preferred: "zh-CN", want: true},
+		{track: "eng", preferred: "zh", want: false},
+		{track: "", preferred: "en", want: false},
+	}
+	for _, tt := range tests {
+		if got := languageMatches(tt.track, tt.preferred); got != tt.want {
+			t.Errorf("languageMatches(%q, %q) = %v, want %v", tt.track, tt.preferred, got, tt.want)
+		}
+	}
+}
+
+func TestSelectDefaultSubtitleTrackPreferredLanguages(t *testing.T) {
+	tracks := func() []types.SubtitleTrack {
+		return []types.SubtitleTrack{
+			{Language: "eng", IsDefault: true},
+			{Language: "jpn"},
+			{Language: "chi", IsForced: true},
+			{Language: "zh-TW"},
+			{Language: "chi"},
+		}
+	}
+	tests := []struct {
+		name      string
+		preferred []string
+		want      []int
+	}{
+		{name: "highest priority available language", preferred: []string{"ko", "ja", "en"}, want: []int{1}},
+		{name: "full subtitle before forced", preferred: []string{"zh"}, want: []int{3}},
+		{name: "region must match", preferred: []string{"zh-TW", "zh"}, want: []int{3}},
+		{name: "region falls back to plain track language", preferred: []string{"zh-CN"}, want: []int{4}},
+		{name: "no preferred language available keeps file default", preferred: []string{"ko"}, want: []int{0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tracks()
+			selectDefaultSubtitleTrack(got, tt.preferred, "")
+			if defaults := defaultTracks(got, func(track types.SubtitleTrack) bool { return track.IsDefault }); !reflect.DeepEqual(defaults, tt.want) {
+				t.Errorf("default subtitle tracks = %v, want %v", defaults, tt.want)
+			}
+		})
+	}
+}
+
+func TestSelectDefaultAudioTrackPreferredLanguages(t *testing.T) {
+	tracks := func() []types.AudioTrack {
+		return []types.AudioTrack{
+			{Language: "eng", Channels: 6, IsDefault: true},
+			{Language: "jpn", Channels: 2},
+			{Language: "chi", Channels: 2},
+		}
+	}
+	tests := []struct {
+		name      string
+		preferred []string
+		want      []int
+	}{
+		{name: "first preferred language", preferred: []string{"zh", "ja"}, want: []int{2}},
+		{name: "skips unavailable language", preferred: []string{"ko", "ja"}, want: []int{1}},
+		{name: "no preferred language available keeps file default", preferred: []string{"ko"}, want: []int{0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tracks()
+			selectDefaultAudioTrack(got, tt.preferred, "")
+			if defaults := defaultTracks(got, func(track types.AudioTrack) bool { return track.IsDefault }); !reflect.DeepEqual(defaults, tt.want) {
+				t.Errorf("default audio tracks = %v, want %v", defaults, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetPreferredLanguagesReselectsCachedTracks(t *testing.T) {
+	useFakeFFprobe(t, forcedSubtitleFixture)
+	transcoder := newTestTranscoder(t)
+
+	defaultIndex := func() int {
+		t.Helper()
+		tracks, err := transcoder.GetSubtitleTracksWithContext(context.Background(), "movie.mkv")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, track := range tracks {
+			if track.IsDefault {
+				return track.Index
+			}
+		}
+		return -1
+	}
+
+	if got := defaultIndex(); got != 2 {
+		t.Fatalf("default subtitle = %d, want the file default 2", got)
+	}
+	transcoder.SetPreferredLanguages([]string{"zh"})
+	if got := defaultIndex(); got != 4 {
+		t.Errorf("default subtitle after preferring zh = %d, want 4", got)
+	}
+}
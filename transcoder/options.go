@@ -0,0 +1,355 @@
+package transcoder
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SubtitleStyle 烧录字幕时的渲染样式
+// 会被转换为FFmpeg subtitles滤镜的force_style参数
+type SubtitleStyle struct {
+	// FontName 字体名称，为空时使用libass默认字体
+	FontName string
+	// FontSize 字体大小
+	FontSize int
+	// PrimaryColor 字体颜色，格式为 "#RRGGBB"
+	PrimaryColor string
+	// OutlineWidth 描边宽度
+	OutlineWidth int
+	// MarginV 字幕距画面底部的边距
+	MarginV int
+}
+
+// DefaultSubtitleStyle 返回默认的字幕样式：白色字体、黑色描边
+func DefaultSubtitleStyle() SubtitleStyle {
+	return SubtitleStyle{
+		FontSize:     24,
+		PrimaryColor: "#FFFFFF",
+		OutlineWidth: 2,
+		MarginV:      20,
+	}
+}
+
+// ForceStyle 将字幕样式转换为subtitles滤镜的force_style参数值
+// 例如 "FontName=Arial,FontSize=24,PrimaryColour=&H00FFFFFF,Outline=2,MarginV=20"
+func (s SubtitleStyle) ForceStyle() string {
+	defaults := DefaultSubtitleStyle()
+	parts := []string{}
+
+	if s.FontName != "" {
+		parts = append(parts, "FontName="+s.FontName)
+	}
+
+	fontSize := s.FontSize
+	if fontSize <= 0 {
+		fontSize = defaults.FontSize
+	}
+	parts = append(parts, fmt.Sprintf("FontSize=%d", fontSize))
+
+	color, ok := assColor(s.PrimaryColor)
+	if !ok {
+		color, _ = assColor(defaults.PrimaryColor)
+	}
+	parts = append(parts, "PrimaryColour="+color)
+
+	if s.OutlineWidth >= 0 {
+		parts = append(parts, fmt.Sprintf("Outline=%d", s.OutlineWidth))
+	}
+	if s.MarginV >= 0 {
+		parts = append(parts, fmt.Sprintf("MarginV=%d", s.MarginV))
+	}
+
+	return strings.Join(parts, ",")
+}
+
+// assColor 将 "#RRGGBB" 格式的颜色转换为ASS格式的 "&H00BBGGRR"
+func assColor(color string) (string, bool) {
+	hex := strings.TrimPrefix(strings.TrimSpace(color), "#")
+	if len(hex) != 6 {
+		return "", false
+	}
+	for _, c := range hex {
+		if !strings.ContainsRune("0123456789abcdefABCDEF", c) {
+			return "", false
+		}
+	}
+	hex = strings.ToUpper(hex)
+	return "&H00" + hex[4:6] + hex[2:4] + hex[0:2], true
+}
+
+// 转码画质预设，用于QualitySettings
+const (
+	// QualityFast 编码速度最快，画质较差，适合性能较弱的电脑
+	QualityFast = "fast"
+	// QualityBalanced 兼顾编码速度和画质
+	QualityBalanced = "balanced"
+	// QualityHigh 画质最好，编码速度较慢
+	QualityHigh = "high"
+)
+
+// TranscodeSettings 转码的速度和画质参数
+type TranscodeSettings struct {
+	// Preset 软件编码的x264预设，例如 "ultrafast"、"veryfast"、"medium"
+	Preset string
+	// CRF 恒定质量系数，越小画质越好、文件越大；硬件编码器使用对应的质量参数
+	CRF int
+	// VideoBitrate 视频码率上限（kbps），0表示不限制
+	VideoBitrate int
+	// AudioBitrate 音频需要转码时的AAC码率（kbps）
+	AudioBitrate int
+}
+
+// DefaultTranscodeSettings 返回默认的转码参数，与QualityFast相同
+func DefaultTranscodeSettings() TranscodeSettings {
+	return TranscodeSettings{
+		Preset:       "ultrafast",
+		CRF:          28,
+		VideoBitrate: 8000,
+		AudioBitrate: 128,
+	}
+}
+
+// QualitySettings 返回画质预设对应的转码参数，未知的预设按QualityFast处理
+// 码率上限避免画面复杂时的码率峰值超出无线网络或设备解码器的承受能力
+func QualitySettings(quality string) TranscodeSettings {
+	switch quality {
+	case QualityBalanced:
+		return TranscodeSettings{Preset: "veryfast", CRF: 23, VideoBitrate: 12000, AudioBitrate: 192}
+	case QualityHigh:
+		return TranscodeSettings{Preset: "medium", CRF: 20, VideoBitrate: 20000, AudioBitrate: 256}
+	default:
+		return DefaultTranscodeSettings()
+	}
+}
+
+// withDefaults 返回把未设置的参数替换为默认值后的转码参数
+func (s TranscodeSettings) withDefaults() TranscodeSettings {
+	defaults := DefaultTranscodeSettings()
+	if s.Preset == "" {
+		s.Preset = defaults.Preset
+	}
+	if s.CRF <= 0 {
+		s.CRF = defaults.CRF
+	}
+	if s.VideoBitrate < 0 {
+		s.VideoBitrate = 0
+	}
+	if s.AudioBitrate <= 0 {
+		s.AudioBitrate = defaults.AudioBitrate
+	}
+	return s
+}
+
+// bitrateArgs 设置了视频码率上限时返回限制码率的参数，缓冲区为上限的两倍
+func (s TranscodeSettings) bitrateArgs() []string {
+	if s.VideoBitrate <= 0 {
+		return nil
+	}
+	return []string{"-maxrate", fmt.Sprintf("%dk", s.VideoBitrate), "-bufsize", fmt.Sprintf("%dk", s.VideoBitrate*2)}
+}
+
+// TranscodeOptions 转码器的可配置选项，作用于之后的每一次转码
+type TranscodeOptions struct {
+	// BurnSubtitles 将选择的字幕烧录进视频画面，而不是作为软字幕封装
+	BurnSubtitles bool
+	// SubtitleStyle 烧录字幕时使用的样式
+	SubtitleStyle SubtitleStyle
+	// MaxFrameRate 输出帧率上限，0表示不限制
+	// 部分电视播放60fps的视频会卡顿，可以限制为30fps
+	MaxFrameRate int
+	// ExtraArgs 追加在输出文件之前的额外FFmpeg参数，供高级用户使用
+	// 设置前应使用ValidateExtraArgs校验；这些参数会原样传给FFmpeg，只应填写可信的内容
+	ExtraArgs []string
+	// StartOffset 从源文件的该位置开始转码，0表示从头开始
+	// 每次投屏单独指定，输出文件从该位置开始，设备不需要支持跳转
+	StartOffset time.Duration
+	// MaxHeight 输出视频的最大高度，源视频更高时按比例缩小，0表示不限制
+	// 每次投屏按设备单独指定
+	MaxHeight int
+	// MuxAllSubtitles 不烧录字幕时将所有文本字幕转换为mov_text一起封装，选择的字幕标记为默认，
+	// 设备可以在自己的字幕菜单中切换；图形字幕无法转换，被跳过
+	MuxAllSubtitles bool
+	// VideoEncoder 视频编码器：VideoEncoderAuto优先使用检测到的硬件编码器，
+	// VideoEncoderSoftware始终使用软件编码，也可以是HardwareEncoderNames中的某个编码器
+	// 硬件编码器转码失败时自动改用软件编码重试
+	VideoEncoder string
+	// Quality 编码速度和画质参数，未设置的项使用DefaultTranscodeSettings中的值
+	Quality TranscodeSettings
+}
+
+// CastOptions 每次投屏单独指定的转码选项，由媒体地址的查询参数传给媒体服务器
+type CastOptions struct {
+	// StartOffset 从源文件的该位置开始转码，0表示从头开始
+	StartOffset time.Duration
+	// MaxHeight 输出视频的最大高度，0表示不限制
+	MaxHeight int
+}
+
+// IsZero 是否没有指定任何投屏选项
+func (c CastOptions) IsZero() bool {
+	return c == CastOptions{}
+}
+
+// withCast 返回应用了投屏选项的转码选项
+func (o TranscodeOptions) withCast(cast CastOptions) TranscodeOptions {
+	o.StartOffset = cast.StartOffset
+	o.MaxHeight = cast.MaxHeight
+	return o
+}
+
+// DefaultTranscodeOptions 返回默认的转码选项
+func DefaultTranscodeOptions() TranscodeOptions {
+	return TranscodeOptions{
+		SubtitleStyle: DefaultSubtitleStyle(),
+		Quality:       DefaultTranscodeSettings(),
+	}
+}
+
+// cacheKey 返回影响转码输出的选项组成的缓存键片段
+// 不同选项产生的转码结果必须使用不同的缓存；字幕相关选项只在选择了字幕时生效
+func (o TranscodeOptions) cacheKey(hasSubtitle bool) string {
+	key := ""
+	if hasSubtitle && o.BurnSubtitles {
+		key += "_burn_" + o.SubtitleStyle.ForceStyle()
+	}
+	if o.MaxFrameRate > 0 {
+		key += fmt.Sprintf("_fps%d", o.MaxFrameRate)
+	}
+	if len(o.ExtraArgs) > 0 {
+		key += "_extra_" + strings.Join(o.ExtraArgs, " ")
+	}
+	if o.StartOffset > 0 {
+		key += fmt.Sprintf("_ss%d", o.StartOffset.Milliseconds())
+	}
+	if o.MaxHeight > 0 {
+		key += fmt.Sprintf("_h%d", o.MaxHeight)
+	}
+	if o.MuxAllSubtitles && !o.BurnSubtitles {
+		key += "_allsubs"
+	}
+	if quality := o.Quality.withDefaults(); quality != DefaultTranscodeSettings() {
+		key += fmt.Sprintf("_q%s_crf%d_vb%d_ab%d", quality.Preset, quality.CRF, quality.VideoBitrate, quality.AudioBitrate)
+	}
+	return key
+}
+
+// startOffsetArgs 返回放在-i之前的-ss输入选项，转码时FFmpeg按输入选项精确定位
+func startOffsetArgs(offset time.Duration) []string {
+	if offset <= 0 {
+		return nil
+	}
+	return []string{"-ss", formatSeconds(offset)}
+}
+
+// formatSeconds 以秒为单位格式化时长，保留毫秒，例如 "90.500"
+func formatSeconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', 3, 64)
+}
+
+// forbiddenExtraArgs 不允许出现在额外参数中的FFmpeg选项
+// 这些选项会读取或写入任意文件、覆盖输出格式或改变命令行的整体行为
+var forbiddenExtraArgs = map[string]bool{
+	"-i":                     true,
+	"-y":                     true,
+	"-n":                     true,
+	"-f":                     true,
+	"-map":                   true,
+	"-attach":                true,
+	"-dump_attachment":       true,
+	"-filter_script":         true,
+	"-filter_complex_script": true,
+	"-progress":              true,
+	"-report":                true,
+	"-vstats_file":           true,
+	"-passlogfile":           true,
+	"-fs":                    true,
+}
+
+// ParseExtraArgs 将用户输入的额外参数按空白拆分并校验
+// 不支持引号，参数值中不能包含空格
+func ParseExtraArgs(text string) ([]string, error) {
+	args := strings.Fields(text)
+	if len(args) == 0 {
+		return nil, nil
+	}
+	if err := ValidateExtraArgs(args); err != nil {
+		return nil, err
+	}
+	return args, nil
+}
+
+// ValidateExtraArgs 校验额外的FFmpeg参数
+// 每个参数都必须是选项或紧跟在选项之后的值，连续出现的两个非选项参数会被视为额外的输出文件而拒绝
+func ValidateExtraArgs(args []string) error {
+	previousWasOption := false
+	for _, arg := range args {
+		if isOptionArg(arg) {
+			// 去掉流说明符，例如 "-c:v" 按 "-c" 检查
+			name, _, _ := strings.Cut(arg, ":")
+			if forbiddenExtraArgs[strings.ToLower(name)] {
+				return fmt.Errorf("不允许使用参数: %s", arg)
+			}
+			previousWasOption = true
+			continue
+		}
+
+		if !previousWasOption {
+			return fmt.Errorf("参数 %s 不属于任何选项，额外参数不能指定输出文件", arg)
+		}
+		previousWasOption = false
+	}
+	return nil
+}
+
+// isOptionArg 判断参数是否为FFmpeg选项，负数被视为选项的值
+func isOptionArg(arg string) bool {
+	if len(arg) < 2 || arg[0] != '-' {
+		return false
+	}
+	if _, err := strconv.ParseFloat(arg, 64); err == nil {
+		return false
+	}
+	return true
+}
+
+// escapeFilterValue 转义FFmpeg滤镜参数值中的特殊字符
+func escapeFilterValue(value string) string {
+	replacer := strings.NewReplacer(
+		`\`, `\\`,
+		`'`, `\'`,
+		`:`, `\:`,
+		`,`, `\,`,
+		`[`, `\[`,
+		`]`, `\]`,
+		`;`, `\;`,
+	)
+	return replacer.Replace(value)
+}
+
+// scaleFilter 源视频高于maxHeight时返回按比例缩小到该高度的scale滤镜，否则返回空字符串
+// 宽度取-2使其保持宽高比并为偶数，满足H.264的要求
+func scaleFilter(mediaInfo map[string]string, maxHeight int) string {
+	height, err := strconv.Atoi(mediaInfo["height"])
+	if maxHeight <= 0 || err != nil || height <= maxHeight {
+		return ""
+	}
+	return fmt.Sprintf("scale=-2:%d", maxHeight)
+}
+
+// subtitleBurnFilter 构建烧录字幕的subtitles滤镜
+// subtitleTrackIndex为字幕流在输入文件所有字幕流中的序号；externalSubtitle不为空时改为渲染该外部字幕文件
+// 指定了起始位置时，-ss输入选项使视频时间戳从0开始，而字幕滤镜按原始时间读取字幕，
+// 因此先把时间戳恢复为源文件中的时间，渲染字幕后再从0开始
+func subtitleBurnFilter(inputFile string, subtitleTrackIndex int, externalSubtitle string, style SubtitleStyle, startOffset time.Duration) string {
+	source := fmt.Sprintf("filename=%s:si=%d", escapeFilterValue(inputFile), subtitleTrackIndex)
+	if externalSubtitle != "" {
+		source = "filename=" + escapeFilterValue(externalSubtitle)
+	}
+	filter := fmt.Sprintf("subtitles=%s:force_style=%s", source, escapeFilterValue(style.ForceStyle()))
+	if startOffset > 0 {
+		filter = fmt.Sprintf("setpts=PTS+%s/TB,%s,setpts=PTS-STARTPTS", formatSeconds(startOffset), filter)
+	}
+	return filter
+}
@@ -0,0 +1,145 @@
+package transcoder
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseExtraArgs(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		want    []string
+		wantErr bool
+	}{
+		{name: "empty", text: "  ", want: nil},
+		{name: "option with value", text: "-tune zerolatency", want: []string{"-tune", "zerolatency"}},
+		{name: "extra whitespace", text: " -tune\tzerolatency \n", want: []string{"-tune", "zerolatency"}},
+		{name: "stream specifier", text: "-b:a 192k", want: []string{"-b:a", "192k"}},
+		{name: "flag followed by option", text: "-an -tune film", want: []string{"-an", "-tune", "film"}},
+		{name: "negative value", text: "-itsoffset -1.5", want: []string{"-itsoffset", "-1.5"}},
+		{name: "extra input", text: "-i other.mkv", wantErr: true},
+		{name: "overwrite flag", text: "-y", wantErr: true},
+		{name: "upper case forbidden option", text: "-Y", wantErr: true},
+		{name: "forbidden option with stream specifier", text: "-map:v 0", wantErr: true},
+		{name: "output format", text: "-f matroska", wantErr: true},
+		{name: "filter script", text: "-filter_complex_script /tmp/f.txt", wantErr: true},
+		{name: "extra output file", text: "-tune zerolatency out.mp4", wantErr: true},
+		{name: "leading value", text: "out.mp4", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseExtraArgs(tt.text)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseExtraArgs(%q) error = %v, wantErr %v", tt.text, err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseExtraArgs(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateExtraArgsRejectsForbiddenOptions(t *testing.T) {
+	for option := range forbiddenExtraArgs {
+		if err := ValidateExtraArgs([]string{option, "value"}); err == nil {
+			t.Errorf("ValidateExtraArgs accepted forbidden option %s", option)
+		}
+	}
+}
+
+func TestSubtitleStyleForceStyle(t *testing.T) {
+	tests := []struct {
+		name  string
+		style SubtitleStyle
+		want  string
+	}{
+		{
+			name:  "defaults",
+			style: DefaultSubtitleStyle(),
+			want:  "FontSize=24,PrimaryColour=&H00FFFFFF,Outline=2,MarginV=20",
+		},
+		{
+			name:  "configured values",
+			style: SubtitleStyle{FontName: "Noto Sans CJK SC", FontSize: 36, PrimaryColor: "#ffcc00", OutlineWidth: 4, MarginV: 60},
+			want:  "FontName=Noto Sans CJK SC,FontSize=36,PrimaryColour=&H0000CCFF,Outline=4,MarginV=60",
+		},
+		{
+			name:  "color is converted to BGR",
+			style: SubtitleStyle{FontSize: 24, PrimaryColor: "#123456", OutlineWidth: 0, MarginV: 0},
+			want:  "FontSize=24,PrimaryColour=&H00563412,Outline=0,MarginV=0",
+		},
+		{
+			name:  "invalid values fall back to defaults",
+			style: SubtitleStyle{FontSize: 0, PrimaryColor: "white", OutlineWidth: -1, MarginV: -1},
+			want:  "FontSize=24,PrimaryColour=&H00FFFFFF",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.style.ForceStyle(); got != tt.want {
+				t.Errorf("ForceStyle() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSubtitleBurnFilter(t *testing.T) {
+	style := SubtitleStyle{FontName: "Arial", FontSize: 30, PrimaryColor: "#FFFFFF", OutlineWidth: 2, MarginV: 40}
+	tests := []struct {
+		name     string
+		input    string
+		track    int
+		external string
+		want     string
+	}{
+		{
+			name:  "embedded track",
+			input: "/videos/movie.mkv",
+			track: 1,
+			want:  `subtitles=filename=/videos/movie.mkv:si=1:force_style=FontName=Arial\,FontSize=30\,PrimaryColour=&H00FFFFFF\,Outline=2\,MarginV=40`,
+		},
+		{
+			name:  "special characters in path",
+			input: `C:\videos\it's [1080p].mkv`,
+			track: 0,
+			want:  `subtitles=filename=C\:\\videos\\it\'s \[1080p\].mkv:si=0:force_style=FontName=Arial\,FontSize=30\,PrimaryColour=&H00FFFFFF\,Outline=2\,MarginV=40`,
+		},
+		{
+			name:     "external subtitle file",
+			input:    "/videos/movie.mkv",
+			track:    0,
+			external: "/videos/movie.zh.srt",
+			want:     `subtitles=filename=/videos/movie.zh.srt:force_style=FontName=Arial\,FontSize=30\,PrimaryColour=&H00FFFFFF\,Outline=2\,MarginV=40`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := subtitleBurnFilter(tt.input, tt.track, tt.external, style, 0); got != tt.want {
+				t.Errorf("subtitleBurnFilter() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScaleFilter(t *testing.T) {
+	tests := []struct {
+		name      string
+		height    string
+		maxHeight int
+		want      string
+	}{
+		{name: "taller source", height: "2160", maxHeight: 1080, want: "scale=-2:1080"},
+		{name: "equal height", height: "1080", maxHeight: 1080, want: ""},
+		{name: "no limit", height: "2160", maxHeight: 0, want: ""},
+		{name: "unknown height", height: "", maxHeight: 1080, want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := scaleFilter(map[string]string{"height": tt.height}, tt.maxHeight); got != tt.want {
+				t.Errorf("scaleFilter() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,301 @@
+package transcoder
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"GoCastify/types"
+)
+
+// ffprobeStream ffprobe JSON输出中的单个流信息
+type ffprobeStream struct {
+	Index        int    `json:"index"`
+	CodecType    string `json:"codec_type"`
+	CodecName    string `json:"codec_name"`
+	Width        int    `json:"width"`
+	Height       int    `json:"height"`
+	Duration     string `json:"duration"`
+	Channels     int    `json:"channels"`
+	RFrameRate   string `json:"r_frame_rate"`
+	AvgFrameRate string `json:"avg_frame_rate"`
+	PixFmt       string `json:"pix_fmt"`
+	Disposition  struct {
+		Default int `json:"default"`
+		Forced  int `json:"forced"`
+	} `json:"disposition"`
+	Tags struct {
+		Language string `json:"language"`
+		Title    string `json:"title"`
+	} `json:"tags"`
+}
+
+// ffprobeFormat ffprobe JSON输出中的容器格式信息
+type ffprobeFormat struct {
+	Duration string `json:"duration"`
+}
+
+// ffprobeOutput ffprobe JSON输出的顶层结构
+type ffprobeOutput struct {
+	Streams []ffprobeStream `json:"streams"`
+	Format  ffprobeFormat   `json:"format"`
+}
+
+// DefaultProbeTimeout ffprobe探测的默认超时时间
+// 网络共享断开或响应很慢时ffprobe可能一直等待读取，调用方没有设置截止时间时使用该超时
+const DefaultProbeTimeout = 30 * time.Second
+
+// probeWaitDelay ffprobe被终止后等待其输出管道关闭的最长时间
+const probeWaitDelay = time.Second
+
+// ErrProbeTimeout ffprobe在超时时间内没有完成探测
+var ErrProbeTimeout = errors.New("探测超时")
+
+// runProbe 执行ffprobe并返回其标准输出
+// ctx没有截止时间时使用DefaultProbeTimeout；超时返回ErrProbeTimeout，被取消时返回ctx的错误
+func runProbe(ctx context.Context, args ...string) ([]byte, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, DefaultProbeTimeout)
+		defer cancel()
+	}
+
+	cmd := exec.CommandContext(ctx, ffprobeCommand(), args...)
+	// ffprobe被终止后，不再等待可能仍持有输出管道的子进程
+	cmd.WaitDelay = probeWaitDelay
+	output, err := cmd.Output()
+	if err != nil {
+		switch {
+		case errors.Is(ctx.Err(), context.DeadlineExceeded):
+			return nil, ErrProbeTimeout
+		case ctx.Err() != nil:
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("执行ffprobe失败: %w", err)
+	}
+	return output, nil
+}
+
+// probeStreams 使用ffprobe以JSON格式获取指定类型的流信息
+// streamSelector为ffprobe的-select_streams参数，例如"a"或"s"
+func probeStreams(ctx context.Context, filePath string, streamSelector string) ([]ffprobeStream, error) {
+	output, err := runProbe(ctx,
+		"-v", "error",
+		"-select_streams", streamSelector,
+		"-show_entries", "stream=index,codec_name,channels:stream_tags=language,title:stream_disposition=default,forced",
+		"-of", "json",
+		filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	var result ffprobeOutput
+	if err := json.Unmarshal(output, &result); err != nil {
+		return nil, fmt.Errorf("解析ffprobe输出失败: %w", err)
+	}
+	return result.Streams, nil
+}
+
+// probeMediaInfo 使用ffprobe以JSON格式获取所有流和容器格式的基本信息
+func probeMediaInfo(ctx context.Context, filePath string) (*ffprobeOutput, error) {
+	output, err := runProbe(ctx,
+		"-v", "error",
+		"-show_entries", "format=duration:stream=index,codec_type,codec_name,width,height,duration,r_frame_rate,avg_frame_rate,pix_fmt",
+		"-of", "json",
+		filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	var result ffprobeOutput
+	if err := json.Unmarshal(output, &result); err != nil {
+		return nil, fmt.Errorf("解析ffprobe输出失败: %w", err)
+	}
+	return &result, nil
+}
+
+// ISO 639-2 三字母语言代码到ISO 639-1两字母代码的映射
+// ffprobe通常输出三字母代码，而系统区域设置使用两字母代码
+var languageAliases = map[string]string{
+	"chi": "zh",
+	"zho": "zh",
+	"chs": "zh",
+	"cht": "zh",
+	"eng": "en",
+	"jpn": "ja",
+	"kor": "ko",
+	"fre": "fr",
+	"fra": "fr",
+	"ger": "de",
+	"deu": "de",
+	"spa": "es",
+	"ita": "it",
+	"rus": "ru",
+	"por": "pt",
+}
+
+// normalizeLanguage 将语言代码规范化为小写的两字母主语言代码
+// 例如 "zh-CN"、"zh_CN.UTF-8"、"chi" 都会被规范化为 "zh"
+func normalizeLanguage(lang string) string {
+	lang = strings.ToLower(strings.TrimSpace(lang))
+	if i := strings.IndexAny(lang, "-_."); i >= 0 {
+		lang = lang[:i]
+	}
+	if alias, ok := languageAliases[lang]; ok {
+		return alias
+	}
+	return lang
+}
+
+// systemLanguage 根据环境变量获取当前系统区域设置的语言
+func systemLanguage() string {
+	for _, key := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		if value := os.Getenv(key); value != "" && value != "C" && value != "POSIX" {
+			return normalizeLanguage(value)
+		}
+	}
+	return ""
+}
+
+// selectDefaultAudioTrack 选择默认音频轨道
+// 优先级：首选语言列表中优先级最高的语言 > 文件标记的默认轨道 > 与系统语言匹配 > 声道数最多 > 第一条
+func selectDefaultAudioTrack(tracks []types.AudioTrack, preferred []string, locale string) {
+	if len(tracks) == 0 {
+		return
+	}
+	languages := make([]string, len(tracks))
+	for i, track := range tracks {
+		languages[i] = track.Language
+	}
+	if best, ok := preferredLanguageTrack(languages, nil, preferred); ok {
+		for i := range tracks {
+			tracks[i].IsDefault = i == best
+		}
+		return
+	}
+
+	for _, track := range tracks {
+		if track.IsDefault {
+			return
+		}
+	}
+
+	if locale != "" {
+		for i := range tracks {
+			if normalizeLanguage(tracks[i].Language) == locale {
+				tracks[i].IsDefault = true
+				return
+			}
+		}
+	}
+
+	best := 0
+	for i := range tracks {
+		if tracks[i].Channels > tracks[best].Channels {
+			best = i
+		}
+	}
+	tracks[best].IsDefault = true
+}
+
+// selectDefaultSubtitleTrack 选择默认字幕轨道
+// 优先级：首选语言列表中优先级最高的语言 > 文件标记的默认轨道 > 与系统语言匹配 > 强制字幕 > 第一条
+func selectDefaultSubtitleTrack(tracks []types.SubtitleTrack, preferred []string, locale string) {
+	if len(tracks) == 0 {
+		return
+	}
+	// 同一语言优先选择完整字幕，强制字幕只在没有完整字幕时选择
+	languages := make([]string, len(tracks))
+	forced := make([]bool, len(tracks))
+	for i, track := range tracks {
+		languages[i] = track.Language
+		forced[i] = track.IsForced
+	}
+	if best, ok := preferredLanguageTrack(languages, forced, preferred); ok {
+		for i := range tracks {
+			tracks[i].IsDefault = i == best
+		}
+		return
+	}
+
+	for _, track := range tracks {
+		if track.IsDefault {
+			return
+		}
+	}
+
+	if locale != "" {
+		for i := range tracks {
+			if normalizeLanguage(tracks[i].Language) == locale {
+				tracks[i].IsDefault = true
+				return
+			}
+		}
+	}
+
+	for i := range tracks {
+		if tracks[i].IsForced {
+			tracks[i].IsDefault = true
+			return
+		}
+	}
+
+	tracks[0].IsDefault = true
+}
+
+// ForcedSubtitleTrack 从字幕轨道中选择强制字幕轨道，返回其在tracks中的位置
+// 有多个强制字幕时优先选择与系统语言一致的轨道；没有强制字幕时第二个返回值为false
+func ForcedSubtitleTrack(tracks []types.SubtitleTrack) (int, bool) {
+	locale := normalizeLanguage(systemLanguage())
+	found := -1
+	for i, track := range tracks {
+		if !track.IsForced {
+			continue
+		}
+		if locale != "" && normalizeLanguage(track.Language) == locale {
+			return i, true
+		}
+		if found < 0 {
+			found = i
+		}
+	}
+	return found, found >= 0
+}
+
+// parseFrameRate 解析ffprobe输出的帧率，例如 "30000/1001" 或 "25"
+// 无法解析或帧率未知（"0/0"）时返回0
+func parseFrameRate(value string) float64 {
+	value = strings.TrimSpace(value)
+	num, den, found := strings.Cut(value, "/")
+	n, err := strconv.ParseFloat(num, 64)
+	if err != nil || n <= 0 {
+		return 0
+	}
+	if !found {
+		return n
+	}
+	d, err := strconv.ParseFloat(den, 64)
+	if err != nil || d <= 0 {
+		return 0
+	}
+	return n / d
+}
+
+// isVariableFrameRate 根据ffprobe的r_frame_rate和avg_frame_rate判断是否为可变帧率
+// 恒定帧率的视频两者相同，屏幕录制等可变帧率视频的两者通常相差较大
+func isVariableFrameRate(rFrameRate, avgFrameRate float64) bool {
+	if rFrameRate <= 0 || avgFrameRate <= 0 {
+		return false
+	}
+	diff := rFrameRate - avgFrameRate
+	if diff < 0 {
+		diff = -diff
+	}
+	return diff/rFrameRate > 0.01
+}
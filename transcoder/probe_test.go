@@ -0,0 +1,390 @@
+package transcoder
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"GoCastify/types"
+)
+
+// useFakeFFprobe 将ffprobe替换为直接输出output的脚本，ffmpeg替换为什么都不做的脚本，测试结束后恢复
+func useFakeFFprobe(t *testing.T, output string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fake ffprobe script requires a POSIX shell")
+	}
+	dir := t.TempDir()
+	outputFile := filepath.Join(dir, "output.json")
+	if err := os.WriteFile(outputFile, []byte(output), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	ffprobe := filepath.Join(dir, "ffprobe")
+	if err := os.WriteFile(ffprobe, []byte("#!/bin/sh\ncat '"+outputFile+"'\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	ffmpeg := filepath.Join(dir, "ffmpeg")
+	if err := os.WriteFile(ffmpeg, []byte("#!/bin/sh\nexit 0\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	SetBinaryPaths(ffmpeg, ffprobe)
+	t.Cleanup(func() { SetBinaryPaths("", "") })
+}
+
+// newTestTranscoder 创建使用临时目录的转码器，测试结束后清理
+func newTestTranscoder(t *testing.T) *Transcoder {
+	t.Helper()
+	transcoder, err := NewTranscoder(DefaultTranscodeSettings())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { transcoder.Cleanup() })
+	return transcoder
+}
+
+// probedTrack 测试中比较的流字段
+type probedTrack struct {
+	Index    int
+	Codec    string
+	Channels int
+	Language string
+	Title    string
+	Default  bool
+	Forced   bool
+}
+
+func TestProbeStreams(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   []probedTrack
+	}{
+		{
+			name: "comma in title",
+			output: `{"streams": [
+				{"index": 2, "codec_name": "subrip", "disposition": {"default": 0, "forced": 0},
+				 "tags": {"language": "eng", "title": "Commentary, Director"}}
+			]}`,
+			want: []probedTrack{{Index: 2, Codec: "subrip", Language: "eng", Title: "Commentary, Director"}},
+		},
+		{
+			name: "non-ASCII titles",
+			output: `{"streams": [
+				{"index": 1, "codec_name": "dts", "channels": 6, "disposition": {"default": 1, "forced": 0},
+				 "tags": {"language": "fre", "title": "Français, 5.1"}},
+				{"index": 3, "codec_name": "ass", "disposition": {"default": 1, "forced": 0},
+				 "tags": {"language": "chi", "title": "简体中文，特效字幕"}},
+				{"index": 4, "codec_name": "subrip", "disposition": {"default": 0, "forced": 1},
+				 "tags": {"language": "jpn", "title": "日本語 \"signs\", songs"}}
+			]}`,
+			want: []probedTrack{
+				{Index: 1, Codec: "dts", Channels: 6, Language: "fre", Title: "Français, 5.1", Default: true},
+				{Index: 3, Codec: "ass", Language: "chi", Title: "简体中文，特效字幕", Default: true},
+				{Index: 4, Codec: "subrip", Language: "jpn", Title: `日本語 "signs", songs`, Forced: true},
+			},
+		},
+		{
+			name:   "missing tags",
+			output: `{"streams": [{"index": 0, "codec_name": "aac", "channels": 2}]}`,
+			want:   []probedTrack{{Index: 0, Codec: "aac", Channels: 2}},
+		},
+		{
+			name:   "no streams",
+			output: `{"streams": []}`,
+			want:   []probedTrack{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useFakeFFprobe(t, tt.output)
+			streams, err := probeStreams(context.Background(), "movie.mkv", "s")
+			if err != nil {
+				t.Fatalf("probeStreams() error = %v", err)
+			}
+			got := []probedTrack{}
+			for _, stream := range streams {
+				got = append(got, probedTrack{
+					Index:    stream.Index,
+					Codec:    stream.CodecName,
+					Channels: stream.Channels,
+					Language: stream.Tags.Language,
+					Title:    stream.Tags.Title,
+					Default:  stream.Disposition.Default == 1,
+					Forced:   stream.Disposition.Forced == 1,
+				})
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("probeStreams() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProbeStreamsInvalidOutput(t *testing.T) {
+	useFakeFFprobe(t, "Title, with comma")
+	if _, err := probeStreams(context.Background(), "movie.mkv", "s"); err == nil {
+		t.Fatal("probeStreams() accepted non-JSON output")
+	}
+}
+
+func TestNormalizeLanguage(t *testing.T) {
+	tests := []struct {
+		lang string
+		want string
+	}{
+		{lang: "zh-CN", want: "zh"},
+		{lang: "zh_CN.UTF-8", want: "zh"},
+		{lang: "chi", want: "zh"},
+		{lang: " ENG ", want: "en"},
+		{lang: "ger", want: "de"},
+		{lang: "ja", want: "ja"},
+		{lang: "und", want: "und"},
+		{lang: "", want: ""},
+	}
+	for _, tt := range tests {
+		if got := normalizeLanguage(tt.lang); got != tt.want {
+			t.Errorf("normalizeLanguage(%q) = %q, want %q", tt.lang, got, tt.want)
+		}
+	}
+}
+
+// defaultTracks 返回被标记为默认的轨道位置
+func defaultTracks[T any](tracks []T, isDefault func(T) bool) []int {
+	defaults := []int{}
+	for i, track := range tracks {
+		if isDefault(track) {
+			defaults = append(defaults, i)
+		}
+	}
+	return defaults
+}
+
+func TestSelectDefaultAudioTrack(t *testing.T) {
+	tests := []struct {
+		name   string
+		tracks []types.AudioTrack
+		locale string
+		want   []int
+	}{
+		{name: "no tracks", tracks: []types.AudioTrack{}, locale: "zh", want: []int{}},
+		{
+			name: "file default kept",
+			tracks: []types.AudioTrack{
+				{Language: "chi", Channels: 2},
+				{Language: "eng", Channels: 6, IsDefault: true},
+			},
+			locale: "zh",
+			want:   []int{1},
+		},
+		{
+			name: "locale match",
+			tracks: []types.AudioTrack{
+				{Language: "eng", Channels: 6},
+				{Language: "jpn", Channels: 2},
+				{Language: "chi", Channels: 2},
+			},
+			locale: "zh",
+			want:   []int{2},
+		},
+		{
+			name: "most channels without locale match",
+			tracks: []types.AudioTrack{
+				{Language: "eng", Channels: 2},
+				{Language: "jpn", Channels: 6},
+				{Language: "fre", Channels: 6},
+			},
+			locale: "zh",
+			want:   []int{1},
+		},
+		{
+			name: "first track when nothing differs",
+			tracks: []types.AudioTrack{
+				{Language: "eng", Channels: 2},
+				{Language: "jpn", Channels: 2},
+			},
+			want: []int{0},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			selectDefaultAudioTrack(tt.tracks, nil, tt.locale)
+			got := defaultTracks(tt.tracks, func(track types.AudioTrack) bool { return track.IsDefault })
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("default audio tracks = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSelectDefaultSubtitleTrack(t *testing.T) {
+	tests := []struct {
+		name   string
+		tracks []types.SubtitleTrack
+		locale string
+		want   []int
+	}{
+		{name: "no tracks", tracks: []types.SubtitleTrack{}, locale: "zh", want: []int{}},
+		{
+			name: "file default kept",
+			tracks: []types.SubtitleTrack{
+				{Language: "chi"},
+				{Language: "eng", IsDefault: true},
+			},
+			locale: "zh",
+			want:   []int{1},
+		},
+		{
+			name: "locale match among languages",
+			tracks: []types.SubtitleTrack{
+				{Language: "eng"},
+				{Language: "jpn"},
+				{Language: "zho"},
+			},
+			locale: "zh",
+			want:   []int{2},
+		},
+		{
+			name: "forced subtitle without locale match",
+			tracks: []types.SubtitleTrack{
+				{Language: "eng"},
+				{Language: "eng", IsForced: true},
+			},
+			locale: "zh",
+			want:   []int{1},
+		},
+		{
+			name: "first track when nothing matches",
+			tracks: []types.SubtitleTrack{
+				{Language: "eng"},
+				{Language: "jpn"},
+			},
+			locale: "zh",
+			want:   []int{0},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			selectDefaultSubtitleTrack(tt.tracks, nil, tt.locale)
+			got := defaultTracks(tt.tracks, func(track types.SubtitleTrack) bool { return track.IsDefault })
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("default subtitle tracks = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// 含强制字幕的文件：完整英文字幕、英文强制字幕和中文强制字幕
+const forcedSubtitleFixture = `{"streams": [
+	{"index": 2, "codec_name": "subrip", "disposition": {"default": 1, "forced": 0}, "tags": {"language": "eng", "title": "Full"}},
+	{"index": 3, "codec_name": "subrip", "disposition": {"default": 0, "forced": 1}, "tags": {"language": "eng", "title": "Forced"}},
+	{"index": 4, "codec_name": "ass", "disposition": {"default": 0, "forced": 1}, "tags": {"language": "chi", "title": "强制"}}
+]}`
+
+func TestGetSubtitleTracksForcedDisposition(t *testing.T) {
+	useFakeFFprobe(t, forcedSubtitleFixture)
+	tracks, err := newTestTranscoder(t).GetSubtitleTracksWithContext(context.Background(), "movie.mkv")
+	if err != nil {
+		t.Fatalf("GetSubtitleTracksWithContext() error = %v", err)
+	}
+	want := []types.SubtitleTrack{
+		{Index: 2, Language: "eng", Title: "Full", IsDefault: true},
+		{Index: 3, Language: "eng", Title: "Forced", IsForced: true},
+		{Index: 4, Language: "chi", Title: "强制", IsForced: true},
+	}
+	if !reflect.DeepEqual(tracks, want) {
+		t.Errorf("GetSubtitleTracksWithContext() = %+v, want %+v", tracks, want)
+	}
+}
+
+func TestForcedSubtitleTrack(t *testing.T) {
+	fullEnglish := types.SubtitleTrack{Index: 2, Language: "eng"}
+	forcedEnglish := types.SubtitleTrack{Index: 3, Language: "eng", IsForced: true}
+	forcedChinese := types.SubtitleTrack{Index: 4, Language: "chi", IsForced: true}
+
+	tests := []struct {
+		name   string
+		locale string
+		tracks []types.SubtitleTrack
+		want   int
+		wantOK bool
+	}{
+		{name: "no forced track", locale: "en_US.UTF-8", tracks: []types.SubtitleTrack{fullEnglish}, want: -1},
+		{name: "single forced track", locale: "en_US.UTF-8", tracks: []types.SubtitleTrack{fullEnglish, forcedEnglish}, want: 1, wantOK: true},
+		{name: "forced track in locale language", locale: "zh_CN.UTF-8", tracks: []types.SubtitleTrack{fullEnglish, forcedEnglish, forcedChinese}, want: 2, wantOK: true},
+		{name: "first forced track without locale match", locale: "ja_JP.UTF-8", tracks: []types.SubtitleTrack{fullEnglish, forcedEnglish, forcedChinese}, want: 1, wantOK: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("LC_ALL", tt.locale)
+			got, ok := ForcedSubtitleTrack(tt.tracks)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("ForcedSubtitleTrack() = %d, %v, want %d, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+// useSlowFFprobe 将ffprobe替换为长时间不返回的脚本，模拟网络共享断开时卡住的探测
+func useSlowFFprobe(t *testing.T) {
+	t.Helper()
+	useFakeFFprobe(t, "{}")
+	ffprobe := filepath.Join(t.TempDir(), "ffprobe")
+	if err := os.WriteFile(ffprobe, []byte("#!/bin/sh\nexec sleep 30\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	SetBinaryPaths(ffmpegCommand(), ffprobe)
+}
+
+func TestProbeTimeoutAndCancel(t *testing.T) {
+	useSlowFFprobe(t)
+	transcoder := newTestTranscoder(t)
+
+	probes := []struct {
+		name  string
+		probe func(ctx context.Context) error
+	}{
+		{name: "media info", probe: func(ctx context.Context) error {
+			_, err := transcoder.GetMediaInfoWithContext(ctx, "movie.mkv")
+			return err
+		}},
+		{name: "audio tracks", probe: func(ctx context.Context) error {
+			_, err := transcoder.GetAudioTracksWithContext(ctx, "movie.mkv")
+			return err
+		}},
+		{name: "subtitle tracks", probe: func(ctx context.Context) error {
+			_, err := transcoder.GetSubtitleTracksWithContext(ctx, "movie.mkv")
+			return err
+		}},
+	}
+	for _, probe := range probes {
+		t.Run(probe.name+" timeout", func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+			start := time.Now()
+			err := probe.probe(ctx)
+			if !errors.Is(err, ErrProbeTimeout) {
+				t.Fatalf("error = %v, want ErrProbeTimeout", err)
+			}
+			if !strings.Contains(err.Error(), "探测超时") {
+				t.Errorf("error = %q, want it to mention 探测超时", err)
+			}
+			if elapsed := time.Since(start); elapsed > 5*time.Second {
+				t.Errorf("probe returned after %v", elapsed)
+			}
+		})
+		t.Run(probe.name+" canceled", func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			if err := probe.probe(ctx); !errors.Is(err, context.Canceled) {
+				t.Fatalf("error = %v, want context.Canceled", err)
+			}
+		})
+	}
+}
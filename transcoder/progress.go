@@ -0,0 +1,81 @@
+package transcoder
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"log"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// FFmpeg进度输出中的时间字段，例如 "time=00:01:23.45"
+var progressTimeRegexp = regexp.MustCompile(`time=(\d+):(\d{2}):(\d{2}(?:\.\d+)?)`)
+
+// parseProgressTime 从FFmpeg的一行进度输出中解析已处理的媒体时长
+func parseProgressTime(line string) (time.Duration, bool) {
+	match := progressTimeRegexp.FindStringSubmatch(line)
+	if match == nil {
+		return 0, false
+	}
+	hours, _ := strconv.Atoi(match[1])
+	minutes, _ := strconv.Atoi(match[2])
+	seconds, _ := strconv.ParseFloat(match[3], 64)
+	total := time.Duration(hours)*time.Hour +
+		time.Duration(minutes)*time.Minute +
+		time.Duration(seconds*float64(time.Second))
+	return total, true
+}
+
+// parseDurationSeconds 解析ffprobe输出的以秒为单位的时长字符串
+func parseDurationSeconds(value string) time.Duration {
+	seconds, err := strconv.ParseFloat(value, 64)
+	if err != nil || seconds <= 0 {
+		return 0
+	}
+	return time.Duration(seconds * float64(time.Second))
+}
+
+// scanLinesOrCR 按\n或\r切分输出
+// FFmpeg使用\r刷新同一行的进度信息
+func scanLinesOrCR(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+	if i := bytes.IndexAny(data, "\r\n"); i >= 0 {
+		return i + 1, data[:i], nil
+	}
+	if atEOF {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
+// watchProgress 读取FFmpeg的stderr输出并报告转码进度
+// totalDuration未知(为0)时以-1报告进度，onProgress可以为nil
+func watchProgress(stderr io.Reader, totalDuration time.Duration, onProgress func(percent float64)) {
+	scanner := bufio.NewScanner(stderr)
+	scanner.Split(scanLinesOrCR)
+	for scanner.Scan() {
+		line := scanner.Text()
+		processed, ok := parseProgressTime(line)
+		if !ok {
+			if line != "" {
+				log.Printf("FFmpeg: %s", line)
+			}
+			continue
+		}
+
+		percent := -1.0
+		if totalDuration > 0 {
+			percent = float64(processed) / float64(totalDuration) * 100
+			if percent > 100 {
+				percent = 100
+			}
+		}
+		if onProgress != nil {
+			onProgress(percent)
+		}
+	}
+}
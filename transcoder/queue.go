@@ -0,0 +1,130 @@
+package transcoder
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// transcodeQueue 按请求到达的顺序分配转码名额的FIFO队列
+// 多个设备同时请求转码时，先到的请求先开始，不会因为调度顺序被后来的请求长时间饿死
+type transcodeQueue struct {
+	mu      sync.Mutex
+	limit   int
+	running int
+	waiters []chan struct{}
+}
+
+// newTranscodeQueue 创建最多同时运行limit个转码任务的队列
+func newTranscodeQueue(limit int) *transcodeQueue {
+	if limit < 1 {
+		limit = 1
+	}
+	return &transcodeQueue{limit: limit}
+}
+
+// acquire 等待轮到当前请求，返回nil后可以开始转码，结束后必须调用release
+// ctx在排队期间被取消时离开队列并返回错误
+func (q *transcodeQueue) acquire(ctx context.Context) error {
+	q.mu.Lock()
+	if q.running < q.limit {
+		q.running++
+		q.mu.Unlock()
+		return nil
+	}
+	ready := make(chan struct{})
+	q.waiters = append(q.waiters, ready)
+	q.mu.Unlock()
+
+	select {
+	case <-ready:
+		return nil
+	case <-ctx.Done():
+	}
+	q.mu.Lock()
+	for i, waiter := range q.waiters {
+		if waiter == ready {
+			q.waiters = append(q.waiters[:i], q.waiters[i+1:]...)
+			q.mu.Unlock()
+			return fmt.Errorf("转码已取消: %w", ctx.Err())
+		}
+	}
+	q.mu.Unlock()
+	// 取消的同时已经分配到名额，交给下一个请求
+	q.release()
+	return fmt.Errorf("转码已取消: %w", ctx.Err())
+}
+
+// release 结束一个转码任务，名额直接交给队列中最早等待的请求
+func (q *transcodeQueue) release() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.waiters) > 0 {
+		next := q.waiters[0]
+		q.waiters = q.waiters[1:]
+		close(next)
+		return
+	}
+	q.running--
+}
+
+// transcodeJob 一个正在进行的转码任务，相同缓存键的请求共享其结果
+type transcodeJob struct {
+	done   chan struct{}
+	output string
+	err    error
+	// ctx 执行转码使用的上下文，所有等待结果的请求都取消后被取消
+	ctx    context.Context
+	cancel context.CancelFunc
+	// waiters 仍在等待结果的请求数量
+	waiters int
+}
+
+// joinTranscode 查找相同缓存键的进行中任务
+// 没有进行中的任务时创建一个新任务并返回true，调用方负责执行转码并调用finishTranscode
+func (t *Transcoder) joinTranscode(cacheKey string) (*transcodeJob, bool) {
+	t.inFlightMutex.Lock()
+	defer t.inFlightMutex.Unlock()
+	if job, exists := t.inFlight[cacheKey]; exists {
+		job.waiters++
+		return job, false
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	job := &transcodeJob{done: make(chan struct{}), ctx: ctx, cancel: cancel, waiters: 1}
+	t.inFlight[cacheKey] = job
+	return job, true
+}
+
+// waitTranscode 等待转码任务的结果，ctx先被取消时放弃等待并返回错误
+// 等待同一任务的请求全部放弃后取消转码，之后相同的请求重新开始转码
+func (t *Transcoder) waitTranscode(ctx context.Context, cacheKey string, job *transcodeJob) (string, error) {
+	select {
+	case <-job.done:
+		return job.output, job.err
+	case <-ctx.Done():
+	}
+
+	t.inFlightMutex.Lock()
+	job.waiters--
+	if job.waiters == 0 {
+		if t.inFlight[cacheKey] == job {
+			delete(t.inFlight, cacheKey)
+		}
+		job.cancel()
+	}
+	t.inFlightMutex.Unlock()
+	return "", fmt.Errorf("转码已取消: %w", ctx.Err())
+}
+
+// finishTranscode 记录任务结果并唤醒等待同一结果的请求
+func (t *Transcoder) finishTranscode(cacheKey string, job *transcodeJob, output string, err error) {
+	t.inFlightMutex.Lock()
+	if t.inFlight[cacheKey] == job {
+		delete(t.inFlight, cacheKey)
+	}
+	t.inFlightMutex.Unlock()
+	job.output = output
+	job.err = err
+	close(job.done)
+	job.cancel()
+}
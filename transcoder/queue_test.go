@@ -0,0 +1,276 @@
+package transcoder
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// queueProbeOutput 可以直接复制视频流的H.264/AAC文件的ffprobe输出
+const queueProbeOutput = `{
+	"streams": [
+		{"index": 0, "codec_type": "video", "codec_name": "h264", "width": 1920, "height": 1080,
+		 "r_frame_rate": "25/1", "avg_frame_rate": "25/1", "pix_fmt": "yuv420p"},
+		{"index": 1, "codec_type": "audio", "codec_name": "aac", "channels": 2}
+	],
+	"format": {"duration": "60.0"}
+}`
+
+// useFakeTranscode 使用假的ffprobe和ffmpeg，ffmpeg创建输出文件（最后一个参数）并把每次运行记录到返回的文件中
+func useFakeTranscode(t *testing.T) string {
+	t.Helper()
+	useFakeFFprobe(t, queueProbeOutput)
+	dir := t.TempDir()
+	runs := filepath.Join(dir, "runs")
+	ffmpeg := filepath.Join(dir, "ffmpeg")
+	script := "#!/bin/sh\nfor last; do :; done\necho \"$last\" >> '" + runs + "'\n: > \"$last\"\n"
+	if err := os.WriteFile(ffmpeg, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	SetBinaryPaths(ffmpeg, ffprobeCommand())
+	return runs
+}
+
+// ffmpegRuns 返回假的ffmpeg运行的次数
+func ffmpegRuns(t *testing.T, runs string) int {
+	t.Helper()
+	data, err := os.ReadFile(runs)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Count(string(data), "\n")
+}
+
+// writeSourceFiles 在临时目录中创建指定名称的源文件
+func writeSourceFiles(t *testing.T, names ...string) []string {
+	t.Helper()
+	dir := t.TempDir()
+	paths := make([]string, len(names))
+	for i, name := range names {
+		paths[i] = filepath.Join(dir, name)
+		if err := os.WriteFile(paths[i], []byte(name), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return paths
+}
+
+// waitForWaiters 等待队列中至少有n个排队的请求
+func waitForWaiters(t *testing.T, q *transcodeQueue, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		q.mu.Lock()
+		waiting := len(q.waiters)
+		q.mu.Unlock()
+		if waiting >= n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d requests waiting, want %d", waiting, n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestTranscodeQueueFIFO(t *testing.T) {
+	q := newTranscodeQueue(1)
+	if err := q.acquire(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	order := []int{}
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := q.acquire(context.Background()); err != nil {
+				t.Error(err)
+				return
+			}
+			mu.Lock()
+			order = append(order, i)
+			mu.Unlock()
+			q.release()
+		}()
+		// 等待该请求进入队列后再发起下一个，确定到达顺序
+		waitForWaiters(t, q, i+1)
+	}
+
+	q.release()
+	wg.Wait()
+	if len(order) != 3 || order[0] != 0 || order[1] != 1 || order[2] != 2 {
+		t.Errorf("order = %v, want [0 1 2]", order)
+	}
+	if q.running != 0 {
+		t.Errorf("running = %d after all releases, want 0", q.running)
+	}
+}
+
+func TestTranscodeQueueCancelWhileWaiting(t *testing.T) {
+	q := newTranscodeQueue(1)
+	if err := q.acquire(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	result := make(chan error, 1)
+	go func() { result <- q.acquire(ctx) }()
+	waitForWaiters(t, q, 1)
+	cancel()
+	if err := <-result; !errors.Is(err, context.Canceled) {
+		t.Fatalf("acquire() error = %v, want context.Canceled", err)
+	}
+
+	// 取消的请求离开队列后，名额仍然可以正常交给之后的请求
+	q.release()
+	acquired := make(chan error, 1)
+	go func() { acquired <- q.acquire(context.Background()) }()
+	select {
+	case err := <-acquired:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("acquire() blocked after the cancelled waiter left the queue")
+	}
+}
+
+func TestConcurrentTranscodesWithSingleSlot(t *testing.T) {
+	runs := useFakeTranscode(t)
+	transcoder := newTestTranscoder(t)
+	transcoder.queue = newTranscodeQueue(1)
+	files := writeSourceFiles(t, "kitchen.mkv", "bedroom.mkv")
+
+	outputs := make([]string, len(files))
+	errs := make([]error, len(files))
+	var wg sync.WaitGroup
+	for i, file := range files {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			outputs[i], errs[i] = transcoder.TranscodeToMp4WithContext(context.Background(), file, -1, -1)
+		}()
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("concurrent transcodes did not finish with one transcode slot")
+	}
+
+	for i := range files {
+		if errs[i] != nil {
+			t.Fatalf("transcode %s: %v", files[i], errs[i])
+		}
+	}
+	if outputs[0] == outputs[1] {
+		t.Errorf("both transcodes wrote %s", outputs[0])
+	}
+	if n := ffmpegRuns(t, runs); n != 2 {
+		t.Errorf("ffmpeg ran %d times, want 2", n)
+	}
+}
+
+func TestCachedTranscodeNotBlockedByQueue(t *testing.T) {
+	runs := useFakeTranscode(t)
+	transcoder := newTestTranscoder(t)
+	transcoder.queue = newTranscodeQueue(1)
+	files := writeSourceFiles(t, "cached.mkv", "pending.mkv")
+
+	cached, err := transcoder.TranscodeToMp4WithContext(context.Background(), files[0], -1, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 占用唯一的转码名额，另一个设备的转码在队列中等待
+	if err := transcoder.queue.acquire(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	pending := make(chan error, 1)
+	go func() {
+		_, err := transcoder.TranscodeToMp4WithContext(context.Background(), files[1], -1, -1)
+		pending <- err
+	}()
+	waitForWaiters(t, transcoder.queue, 1)
+
+	got, err := transcoder.TranscodeToMp4WithContext(context.Background(), files[0], -1, -1)
+	if err != nil || got != cached {
+		t.Fatalf("cached transcode = %q, %v; want %q", got, err, cached)
+	}
+	select {
+	case err := <-pending:
+		t.Fatalf("queued transcode finished while the slot was held: %v", err)
+	default:
+	}
+
+	transcoder.queue.release()
+	if err := <-pending; err != nil {
+		t.Fatal(err)
+	}
+	if n := ffmpegRuns(t, runs); n != 2 {
+		t.Errorf("ffmpeg ran %d times, want 2", n)
+	}
+}
+
+func TestIdenticalTranscodesShareOneJob(t *testing.T) {
+	runs := useFakeTranscode(t)
+	transcoder := newTestTranscoder(t)
+	transcoder.queue = newTranscodeQueue(1)
+	file := writeSourceFiles(t, "movie.mkv")[0]
+
+	// 名额被占用时两个设备请求同一转码，释放后只执行一次
+	if err := transcoder.queue.acquire(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	outputs := make(chan string, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			output, err := transcoder.TranscodeToMp4WithContext(context.Background(), file, -1, -1)
+			if err != nil {
+				t.Error(err)
+			}
+			outputs <- output
+		}()
+	}
+	waitForWaiters(t, transcoder.queue, 1)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		transcoder.inFlightMutex.Lock()
+		waiters := 0
+		for _, job := range transcoder.inFlight {
+			waiters = job.waiters
+		}
+		transcoder.inFlightMutex.Unlock()
+		if waiters == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d requests joined the in-flight transcode, want 2", waiters)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	transcoder.queue.release()
+
+	first, second := <-outputs, <-outputs
+	if first == "" || first != second {
+		t.Errorf("outputs = %q and %q, want the same file", first, second)
+	}
+	if n := ffmpegRuns(t, runs); n != 1 {
+		t.Errorf("ffmpeg ran %d times, want 1", n)
+	}
+}
@@ -0,0 +1,65 @@
+package transcoder
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// ExtractSubtitle 将内嵌字幕轨道提取为SRT文件，供能够加载外挂字幕的设备使用
+// subtitleTrackIndex为字幕流在输入文件所有字幕流中的序号，表示外部字幕文件时将该文件转换为SRT；
+// 图形字幕（如PGS）无法转换为SRT；提取结果与转码结果共用缓存，源文件变化后失效
+func (t *Transcoder) ExtractSubtitle(inputFile string, subtitleTrackIndex int) (string, error) {
+	if subtitleTrackIndex < 0 {
+		return "", fmt.Errorf("无效的字幕轨道: %d", subtitleTrackIndex)
+	}
+
+	// 外部字幕文件只有一个字幕流，缓存随字幕文件的变化失效
+	source, stream := inputFile, fmt.Sprintf("0:s:%d", subtitleTrackIndex)
+	if IsExternalSubtitleIndex(subtitleTrackIndex) {
+		externalSubtitle, ok := externalSubtitlePath(inputFile, subtitleTrackIndex)
+		if !ok {
+			return "", fmt.Errorf("未找到外部字幕文件: %d", subtitleTrackIndex)
+		}
+		source, stream = externalSubtitle, "0:s:0"
+	}
+
+	modTime, size, err := sourceStamp(source)
+	if err != nil {
+		return "", fmt.Errorf("读取源文件信息失败: %w", err)
+	}
+	cacheKey := fmt.Sprintf("%s_%d_%d_srt_%d", source, modTime, size, subtitleTrackIndex)
+	if outputFile, valid := t.getCachedOutput(cacheKey); valid {
+		return outputFile, nil
+	}
+
+	if !CheckFFmpeg() {
+		return "", fmt.Errorf("未找到FFmpeg，请先安装FFmpeg")
+	}
+
+	baseName := strings.TrimSuffix(filepath.Base(inputFile), filepath.Ext(inputFile))
+	outputFile := filepath.Join(t.tempDir, fmt.Sprintf("%s_sub%d_%s.srt", baseName, subtitleTrackIndex, shortHash(cacheKey)))
+
+	args := []string{
+		"-y",
+		"-hide_banner",
+		"-loglevel", "error",
+		"-i", source,
+		"-map", stream,
+		"-c:s", "srt",
+		outputFile,
+	}
+	output, err := exec.Command(ffmpegCommand(), args...).CombinedOutput()
+	if err != nil {
+		os.Remove(outputFile)
+		return "", fmt.Errorf("提取字幕失败: %w, %s", err, strings.TrimSpace(string(output)))
+	}
+	log.Printf("已提取字幕轨道 %d: %s", subtitleTrackIndex, outputFile)
+
+	t.storeCachedOutput(cacheKey, outputFile, source, modTime, size)
+
+	return outputFile, nil
+}
@@ -0,0 +1,70 @@
+package transcoder
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os/exec"
+	"time"
+)
+
+// streamOutput 流式转码时FFmpeg的输出目标（标准输出）
+const streamOutput = "pipe:1"
+
+// movFlags 根据输出目标选择MP4的movflags
+// 写入文件时把moov移到文件开头以便快速启动；输出到管道时无法回写文件头，
+// 使用分片MP4，设备收到第一个分片即可开始播放
+func movFlags(outputFile string) string {
+	if outputFile == streamOutput {
+		return "frag_keyframe+empty_moov+default_base_moof"
+	}
+	return "+faststart"
+}
+
+// StreamTranscodeTo 按本次投屏的选项边转码边把分片MP4写入w，不生成缓存文件
+// 输出总大小事先未知，也无法按字节跳转；ctx取消（例如设备断开连接）时终止FFmpeg
+func (t *Transcoder) StreamTranscodeTo(ctx context.Context, w io.Writer, inputFile string, subtitleTrackIndex int, audioTrackIndex int, cast CastOptions) error {
+	if !CheckFFmpeg() {
+		return fmt.Errorf("未找到FFmpeg，请先安装FFmpeg")
+	}
+
+	mediaInfo, err := t.GetMediaInfoWithContext(ctx, inputFile)
+	if err != nil {
+		return fmt.Errorf("获取媒体信息失败: %w", err)
+	}
+
+	// 与文件转码共用并发限制，排队期间设备断开连接时不再转码
+	if err := t.queue.acquire(ctx); err != nil {
+		return err
+	}
+	defer t.queue.release()
+
+	options := t.Options().withCast(cast)
+	// 输出已经开始写给设备后无法改用其他编码器重试，只使用检测时试编码成功的编码器或直接复制视频流
+	args := t.buildOptimizedTranscodeArgs(inputFile, streamOutput, mediaInfo, subtitleTrackIndex, audioTrackIndex, options, t.videoEncoderForSource(mediaInfo, subtitleTrackIndex, options))
+	cmd := exec.CommandContext(ctx, ffmpegCommand(), args...)
+	cmd.Stdout = w
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return fmt.Errorf("创建标准错误管道失败: %w", err)
+	}
+
+	startTime := time.Now()
+	log.Printf("开始流式转码文件: %s", inputFile)
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("启动转码命令失败: %w", err)
+	}
+	// 只记录FFmpeg的警告和错误，流式转码不报告进度
+	watchProgress(stderr, 0, nil)
+
+	if err := cmd.Wait(); err != nil {
+		if ctx.Err() != nil {
+			log.Printf("流式转码已停止: %s, 持续: %v", inputFile, time.Since(startTime))
+			return ctx.Err()
+		}
+		return fmt.Errorf("流式转码失败: %w", err)
+	}
+	log.Printf("流式转码完成，耗时: %v", time.Since(startTime))
+	return nil
+}
@@ -0,0 +1,82 @@
+package transcoder
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// bitmapSubtitleCodecs 图形字幕格式，无法转换为mov_text文本字幕
+var bitmapSubtitleCodecs = map[string]bool{
+	"hdmv_pgs_subtitle": true,
+	"dvd_subtitle":      true,
+	"dvb_subtitle":      true,
+	"dvb_teletext":      true,
+	"xsub":              true,
+}
+
+// subtitleCodecs 返回mediaInfo中记录的字幕流编码，顺序与字幕流的序号一致
+func subtitleCodecs(mediaInfo map[string]string) []string {
+	if mediaInfo["subtitle_codecs"] == "" {
+		return nil
+	}
+	return strings.Split(mediaInfo["subtitle_codecs"], ",")
+}
+
+// subtitleMuxArgs 构建将字幕转换为mov_text封装进输出文件的参数
+// 默认只封装选择的字幕并标记为默认；muxAll为true时封装所有文本字幕，
+// 选择的字幕标记为默认，其余字幕保留在文件中供设备的字幕菜单切换，图形字幕被跳过；
+// external为true时选择的字幕来自作为第二个输入的外部字幕文件
+func subtitleMuxArgs(mediaInfo map[string]string, subtitleTrackIndex int, external, muxAll bool) []string {
+	selectedStream := fmt.Sprintf("0:s:%d", subtitleTrackIndex)
+	if external {
+		selectedStream = "1:s:0"
+	}
+	codecs := subtitleCodecs(mediaInfo)
+	if !muxAll || len(codecs) == 0 {
+		if subtitleTrackIndex < 0 {
+			return nil
+		}
+		return []string{
+			"-map", selectedStream, // 选择的字幕轨道
+			"-c:s", "mov_text", // 转换字幕为MP4兼容格式
+			"-disposition:s:0", "default", // 设置为默认字幕
+		}
+	}
+
+	args := []string{}
+	outputCount := 0
+	defaultOutput := -1
+	for i, codec := range codecs {
+		if bitmapSubtitleCodecs[codec] {
+			if i == subtitleTrackIndex && !external {
+				log.Printf("选择的字幕轨道 %d 为图形字幕(%s)，无法转换为文本字幕，已跳过", i, codec)
+			}
+			continue
+		}
+		if i == subtitleTrackIndex && !external {
+			defaultOutput = outputCount
+		}
+		args = append(args, "-map", fmt.Sprintf("0:s:%d", i))
+		outputCount++
+	}
+	if external {
+		defaultOutput = outputCount
+		args = append(args, "-map", selectedStream)
+		outputCount++
+	}
+	if outputCount == 0 {
+		return nil
+	}
+
+	args = append(args, "-c:s", "mov_text")
+	// 清除源文件中的默认标记，只有选择的字幕为默认字幕
+	for i := 0; i < outputCount; i++ {
+		disposition := "0"
+		if i == defaultOutput {
+			disposition = "default"
+		}
+		args = append(args, fmt.Sprintf("-disposition:s:%d", i), disposition)
+	}
+	return args
+}
@@ -0,0 +1,94 @@
+package transcoder
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSubtitleMuxArgs(t *testing.T) {
+	mixed := map[string]string{"subtitle_codecs": "subrip,hdmv_pgs_subtitle,ass,mov_text"}
+	tests := []struct {
+		name      string
+		mediaInfo map[string]string
+		index     int
+		external  bool
+		muxAll    bool
+		want      []string
+	}{
+		{
+			name:      "selected track only",
+			mediaInfo: mixed,
+			index:     2,
+			want:      []string{"-map", "0:s:2", "-c:s", "mov_text", "-disposition:s:0", "default"},
+		},
+		{name: "no subtitle selected", mediaInfo: mixed, index: -1, want: nil},
+		{
+			name:      "all text tracks with selected default",
+			mediaInfo: mixed,
+			index:     2,
+			muxAll:    true,
+			want: []string{
+				"-map", "0:s:0", "-map", "0:s:2", "-map", "0:s:3",
+				"-c:s", "mov_text",
+				"-disposition:s:0", "0", "-disposition:s:1", "default", "-disposition:s:2", "0",
+			},
+		},
+		{
+			name:      "all text tracks without selection",
+			mediaInfo: mixed,
+			index:     -1,
+			muxAll:    true,
+			want: []string{
+				"-map", "0:s:0", "-map", "0:s:2", "-map", "0:s:3",
+				"-c:s", "mov_text",
+				"-disposition:s:0", "0", "-disposition:s:1", "0", "-disposition:s:2", "0",
+			},
+		},
+		{
+			name:      "selected bitmap track skipped",
+			mediaInfo: mixed,
+			index:     1,
+			muxAll:    true,
+			want: []string{
+				"-map", "0:s:0", "-map", "0:s:2", "-map", "0:s:3",
+				"-c:s", "mov_text",
+				"-disposition:s:0", "0", "-disposition:s:1", "0", "-disposition:s:2", "0",
+			},
+		},
+		{
+			name:      "external subtitle added as default",
+			mediaInfo: map[string]string{"subtitle_codecs": "subrip,ass"},
+			index:     ExternalSubtitleIndexBase,
+			external:  true,
+			muxAll:    true,
+			want: []string{
+				"-map", "0:s:0", "-map", "0:s:1", "-map", "1:s:0",
+				"-c:s", "mov_text",
+				"-disposition:s:0", "0", "-disposition:s:1", "0", "-disposition:s:2", "default",
+			},
+		},
+		{
+			name:      "only bitmap tracks",
+			mediaInfo: map[string]string{"subtitle_codecs": "hdmv_pgs_subtitle,dvd_subtitle"},
+			index:     0,
+			muxAll:    true,
+			want:      nil,
+		},
+		{
+			name:      "no subtitle streams falls back to selected track",
+			mediaInfo: map[string]string{},
+			index:     ExternalSubtitleIndexBase,
+			external:  true,
+			muxAll:    true,
+			want:      []string{"-map", "1:s:0", "-c:s", "mov_text", "-disposition:s:0", "default"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := subtitleMuxArgs(tt.mediaInfo, tt.index, tt.external, tt.muxAll)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("subtitleMuxArgs() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package transcoder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ThumbnailAuto 作为GenerateThumbnail的atSeconds时，截取视频时长约10%处的画面
+const ThumbnailAuto = -1
+
+// ErrNoVideo 媒体文件没有视频流，无法生成缩略图
+var ErrNoVideo = errors.New("媒体文件没有视频流")
+
+// 缩略图的宽度和生成超时
+const (
+	thumbnailWidth   = 320
+	thumbnailTimeout = 15 * time.Second
+)
+
+// GenerateThumbnail 从视频第atSeconds秒截取一帧JPEG缩略图，保存在临时目录中并返回路径
+// atSeconds为ThumbnailAuto时截取时长约10%处的画面，避开片头的黑屏；时长未知时截取第一帧。
+// 结果按源文件的修改时间和大小复用，源文件变化后重新生成
+func (t *Transcoder) GenerateThumbnail(filePath string, atSeconds int) (string, error) {
+	if !CheckFFmpeg() {
+		return "", fmt.Errorf("未找到FFmpeg，请先安装FFmpeg")
+	}
+	modTime, size, err := sourceStamp(filePath)
+	if err != nil {
+		return "", fmt.Errorf("读取源文件信息失败: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), thumbnailTimeout)
+	defer cancel()
+	mediaInfo, err := t.GetMediaInfoWithContext(ctx, filePath)
+	if err != nil {
+		return "", err
+	}
+	if mediaInfo["video_codec"] == "" {
+		return "", ErrNoVideo
+	}
+	position := time.Duration(atSeconds) * time.Second
+	if atSeconds < 0 {
+		position = parseDurationSeconds(mediaInfo["duration"]) / 10
+	}
+
+	baseName := strings.TrimSuffix(filepath.Base(filePath), filepath.Ext(filePath))
+	key := fmt.Sprintf("%s_%d_%d_thumb_%d", filePath, modTime, size, position.Milliseconds())
+	outputFile := filepath.Join(t.GetTempDir(), fmt.Sprintf("%s_thumb_%s.jpg", baseName, shortHash(key)))
+	if _, err := os.Stat(outputFile); err == nil {
+		return outputFile, nil
+	}
+
+	// 先写入临时文件再重命名，同一文件同时生成时不会读到写了一半的图片
+	tmpFile, err := os.CreateTemp(t.GetTempDir(), "thumb_*.jpg")
+	if err != nil {
+		return "", fmt.Errorf("创建缩略图文件失败: %w", err)
+	}
+	tmpFile.Close()
+	defer os.Remove(tmpFile.Name())
+
+	args := []string{"-hide_banner", "-loglevel", "error"}
+	args = append(args, startOffsetArgs(position)...)
+	args = append(args,
+		"-i", filePath,
+		"-map", "0:v:0",
+		"-frames:v", "1",
+		"-vf", fmt.Sprintf("scale=%d:-2", thumbnailWidth),
+		"-q:v", "3",
+		"-y", tmpFile.Name(),
+	)
+	output, err := exec.CommandContext(ctx, ffmpegCommand(), args...).CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("生成缩略图失败: %w, %s", err, strings.TrimSpace(string(output)))
+	}
+	if info, err := os.Stat(tmpFile.Name()); err != nil || info.Size() == 0 {
+		return "", fmt.Errorf("生成缩略图失败: 第 %v 处没有画面", position)
+	}
+	if err := os.Rename(tmpFile.Name(), outputFile); err != nil {
+		return "", fmt.Errorf("保存缩略图失败: %w", err)
+	}
+	return outputFile, nil
+}
@@ -1,6 +1,9 @@
 package transcoder
 
 import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"log"
@@ -23,24 +26,46 @@ type Transcoder struct {
 	cacheMutex       sync.Mutex
 	// 缓存过期时间
 	cacheExpiry map[string]time.Time
-	// 临时文件存储
+	// 临时文件存储，启用持久化缓存时为持久化缓存目录
 	tempDir string
+	// 持久化缓存：缓存记录对应的源文件信息，用于跨会话校验
+	persistentCache bool
+	cacheSources    map[string]cacheIndexEntry
 	// 字幕轨道信息缓存
 	subtitleTracks map[string][]types.SubtitleTrack
 	subtitleMutex  sync.Mutex
 	// 音频轨道信息缓存
 	audioTracks map[string][]types.AudioTrack
 	audioMutex  sync.Mutex
-	// 限制并发转码任务数量
+	// 完整ffprobe输出缓存，键包含文件的修改时间和大小
+	fullProbes map[string]string
+	probeMutex sync.Mutex
+	// 限制并发转码任务数量，超出的请求按到达顺序排队
 	maxConcurrentTranscodes int
-	semaphore              chan struct{}
+	queue                   *transcodeQueue
+	// 进行中的转码任务，相同缓存键的请求等待同一个任务完成
+	inFlight      map[string]*transcodeJob
+	inFlightMutex sync.Mutex
+	// 转码选项和首选语言
+	options            TranscodeOptions
+	preferredLanguages []string
+	optionsMutex       sync.RWMutex
+	// failedEncoders 本次运行中转码失败过的硬件编码器，自动选择时跳过，由optionsMutex保护
+	failedEncoders map[string]bool
+	// janitorStop 关闭时停止后台缓存清理任务，由cacheMutex保护
+	janitorStop chan struct{}
+	// maxCacheSize 缓存输出文件的总大小上限（字节），0表示不限制，由cacheMutex保护
+	maxCacheSize int64
+	// onCacheEvict 因超过大小上限删除缓存后的回调，由cacheMutex保护
+	onCacheEvict func(outputFile string, size int64)
 }
 
 // 确保Transcoder实现了interfaces.MediaTranscoder接口
 var _ interfaces.MediaTranscoder = (*Transcoder)(nil)
 
-// NewTranscoder 创建一个新的转码器
-func NewTranscoder() (*Transcoder, error) {
+// NewTranscoder 创建一个新的转码器，settings为转码的速度和画质参数，未设置的项使用默认值
+// 之后可以通过SetOptions修改
+func NewTranscoder(settings TranscodeSettings) (*Transcoder, error) {
 	// 创建临时目录
 tempDir, err := os.MkdirTemp("", "gocastify_transcode_")
 	if err != nil {
@@ -54,21 +79,42 @@ tempDir, err := os.MkdirTemp("", "gocastify_transcode_")
 		maxConcurrentTranscodes = 1
 	}
 
+	options := DefaultTranscodeOptions()
+	options.Quality = settings
+
 	return &Transcoder{
 		transcodingCache:        make(map[string]string),
 		cacheMutex:              sync.Mutex{},
 		cacheExpiry:             make(map[string]time.Time),
 		tempDir:                 tempDir,
+		cacheSources:            make(map[string]cacheIndexEntry),
 		subtitleTracks:          make(map[string][]types.SubtitleTrack),
 		subtitleMutex:           sync.Mutex{},
 		audioTracks:             make(map[string][]types.AudioTrack),
 		audioMutex:              sync.Mutex{},
+		fullProbes:              make(map[string]string),
 		maxConcurrentTranscodes: maxConcurrentTranscodes,
-		semaphore:               make(chan struct{}, maxConcurrentTranscodes),
+		queue:                   newTranscodeQueue(maxConcurrentTranscodes),
+		inFlight:                make(map[string]*transcodeJob),
+		options:                 options,
 	},
 		nil
 }
 
+// SetOptions 设置转码选项，对之后开始的转码生效
+func (t *Transcoder) SetOptions(options TranscodeOptions) {
+	t.optionsMutex.Lock()
+	defer t.optionsMutex.Unlock()
+	t.options = options
+}
+
+// Options 获取当前的转码选项
+func (t *Transcoder) Options() TranscodeOptions {
+	t.optionsMutex.RLock()
+	defer t.optionsMutex.RUnlock()
+	return t.options
+}
+
 // 支持的可转码格式
 var supportedTranscodeFormats = map[string]bool{
 	".mkv": true,
@@ -101,63 +147,89 @@ func IsSupportedFormat(filePath string) (bool, bool) {
 	return false, false
 }
 
-// CheckFFmpeg 检查系统是否安装了FFmpeg
+// CheckFFmpeg 检查系统是否安装了FFmpeg，设置了FFmpeg路径时检查该路径
 func CheckFFmpeg() bool {
-	_, err := exec.LookPath("ffmpeg")
+	_, err := exec.LookPath(ffmpegCommand())
 	return err == nil
 }
 
-// GetMediaInfo 获取媒体文件信息
+// GetMediaInfo 获取媒体文件信息，探测超过DefaultProbeTimeout时返回ErrProbeTimeout
 func (t *Transcoder) GetMediaInfo(filePath string) (map[string]string, error) {
+	return t.GetMediaInfoWithContext(context.Background(), filePath)
+}
+
+// GetMediaInfoWithContext 获取媒体文件信息，ctx被取消时终止ffprobe
+func (t *Transcoder) GetMediaInfoWithContext(ctx context.Context, filePath string) (map[string]string, error) {
 	if !CheckFFmpeg() {
 		return nil, fmt.Errorf("未找到FFmpeg，请先安装FFmpeg")
 	}
 
-	cmd := exec.Command("ffprobe", 
-		"-v", "error",
-		"-select_streams", "v:0",
-		"-show_entries", "stream=codec_name,width,height,duration",
-		"-of", "default=noprint_wrappers=1:nokey=1",
-		filePath)
-
-	output, err := cmd.CombinedOutput()
+	probe, err := probeMediaInfo(ctx, filePath)
 	if err != nil {
-		return nil, fmt.Errorf("获取媒体信息失败: %w, 输出: %s", err, string(output))
+		return nil, fmt.Errorf("获取媒体信息失败: %w", err)
 	}
 
+	// 只写入ffprobe实际返回的字段，缺失的字段不出现在结果中
 	info := make(map[string]string)
-	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
-	if len(lines) > 0 {
-		info["video_codec"] = lines[0]
+	setIfPresent := func(key, value string) {
+		if value != "" && value != "N/A" {
+			info[key] = value
+		}
+	}
+
+	var videoStream, audioStream *ffprobeStream
+	subtitleCodecs := []string{}
+	for i := range probe.Streams {
+		stream := &probe.Streams[i]
+		switch stream.CodecType {
+		case "subtitle":
+			subtitleCodecs = append(subtitleCodecs, stream.CodecName)
+		case "video":
+			if videoStream == nil {
+				videoStream = stream
+			}
+		case "audio":
+			if audioStream == nil {
+				audioStream = stream
+			}
+		}
 	}
-	if len(lines) > 1 {
-		info["width"] = lines[1]
+
+	if videoStream != nil {
+		setIfPresent("video_codec", videoStream.CodecName)
+		// 纯音频文件或封面图等没有宽高信息时不写入
+		if videoStream.Width > 0 && videoStream.Height > 0 {
+			info["width"] = strconv.Itoa(videoStream.Width)
+			info["height"] = strconv.Itoa(videoStream.Height)
+		}
+		setIfPresent("duration", videoStream.Duration)
+		setIfPresent("r_frame_rate", videoStream.RFrameRate)
+		setIfPresent("avg_frame_rate", videoStream.AvgFrameRate)
+		setIfPresent("pix_fmt", videoStream.PixFmt)
 	}
-	if len(lines) > 2 {
-		info["height"] = lines[2]
+	if audioStream != nil {
+		setIfPresent("audio_codec", audioStream.CodecName)
 	}
-	if len(lines) > 3 {
-		info["duration"] = lines[3]
+	// 各字幕流的编码，按字幕流的顺序以逗号分隔，用于区分文本字幕和图形字幕
+	if len(subtitleCodecs) > 0 {
+		info["subtitle_codecs"] = strings.Join(subtitleCodecs, ",")
 	}
 
-	// 检查音频编解码器
-	audioCmd := exec.Command("ffprobe",
-		"-v", "error",
-		"-select_streams", "a:0",
-		"-show_entries", "stream=codec_name",
-		"-of", "default=noprint_wrappers=1:nokey=1",
-		filePath)
-	audioOutput, err := audioCmd.CombinedOutput()
-	if err == nil {
-		audioCodec := strings.TrimSpace(string(audioOutput))
-		info["audio_codec"] = audioCodec
+	// 许多容器（如MKV）只在format中记录时长
+	if _, ok := info["duration"]; !ok {
+		setIfPresent("duration", probe.Format.Duration)
 	}
 
 	return info, nil
 }
 
-// GetSubtitleTracks 获取媒体文件中的字幕轨道信息
+// GetSubtitleTracks 获取媒体文件中的字幕轨道信息，探测超过DefaultProbeTimeout时返回ErrProbeTimeout
 func (t *Transcoder) GetSubtitleTracks(filePath string) ([]types.SubtitleTrack, error) {
+	return t.GetSubtitleTracksWithContext(context.Background(), filePath)
+}
+
+// GetSubtitleTracksWithContext 获取媒体文件中的字幕轨道信息，ctx被取消时终止ffprobe
+func (t *Transcoder) GetSubtitleTracksWithContext(ctx context.Context, filePath string) ([]types.SubtitleTrack, error) {
 	// 检查缓存中是否已有该文件的字幕轨道信息
 	t.subtitleMutex.Lock()
 	cachedTracks, exists := t.subtitleTracks[filePath]
@@ -171,56 +243,26 @@ func (t *Transcoder) GetSubtitleTracks(filePath string) ([]types.SubtitleTrack,
 		return nil, fmt.Errorf("未找到FFmpeg，请先安装FFmpeg")
 	}
 
-	// 使用ffprobe获取所有字幕轨道信息
-	cmd := exec.Command("ffprobe",
-		"-v", "error",
-		"-select_streams", "s",
-		"-show_entries", "stream=index:stream_tags=language,title",
-		"-of", "csv=p=0",
-		filePath)
-
-	output, err := cmd.CombinedOutput()
+	// 使用ffprobe以JSON格式获取所有字幕轨道信息，包括完整的disposition标记
+	streams, err := probeStreams(ctx, filePath, "s")
 	if err != nil {
-		return nil, fmt.Errorf("获取字幕轨道信息失败: %w, 输出: %s", err, string(output))
+		return nil, fmt.Errorf("获取字幕轨道信息失败: %w", err)
 	}
 
 	tracks := []types.SubtitleTrack{}
-	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
-
-	for _, line := range lines {
-		if line == "" {
-			continue
-		}
-
-		// 解析CSV格式的输出: index,language,title
-		parts := strings.Split(line, ",")
-		track := types.SubtitleTrack{
-			IsDefault: false,
-		}
-
-		if len(parts) > 0 {
-			index, err := strconv.Atoi(parts[0])
-			if err == nil {
-				track.Index = index
-			}
-		}
-
-		if len(parts) > 1 {
-			track.Language = parts[1]
-		}
-
-		if len(parts) > 2 {
-			track.Title = parts[2]
-		}
-
-		// 如果是第一条字幕轨道，默认为选中
-		if len(tracks) == 0 && (track.Language == "zh" || track.Language == "zh-CN" || track.Language == "en") {
-			track.IsDefault = true
-		}
-
-		tracks = append(tracks, track)
+	for _, stream := range streams {
+		tracks = append(tracks, types.SubtitleTrack{
+			Index:     stream.Index,
+			Language:  stream.Tags.Language,
+			Title:     stream.Tags.Title,
+			IsDefault: stream.Disposition.Default == 1,
+			IsForced:  stream.Disposition.Forced == 1,
+		})
 	}
 
+	// 按首选语言选择默认字幕；没有符合的轨道且文件未标记默认字幕时，按系统语言和强制字幕标记选择
+	selectDefaultSubtitleTrack(tracks, t.PreferredLanguages(), systemLanguage())
+
 	// 缓存字幕轨道信息
 	t.subtitleMutex.Lock()
 	t.subtitleTracks[filePath] = tracks
@@ -229,8 +271,13 @@ func (t *Transcoder) GetSubtitleTracks(filePath string) ([]types.SubtitleTrack,
 	return tracks, nil
 }
 
-// GetAudioTracks 获取媒体文件中的音频轨道信息
+// GetAudioTracks 获取媒体文件中的音频轨道信息，探测超过DefaultProbeTimeout时返回ErrProbeTimeout
 func (t *Transcoder) GetAudioTracks(filePath string) ([]types.AudioTrack, error) {
+	return t.GetAudioTracksWithContext(context.Background(), filePath)
+}
+
+// GetAudioTracksWithContext 获取媒体文件中的音频轨道信息，ctx被取消时终止ffprobe
+func (t *Transcoder) GetAudioTracksWithContext(ctx context.Context, filePath string) ([]types.AudioTrack, error) {
 	// 检查缓存中是否已有该文件的音频轨道信息
 	t.audioMutex.Lock()
 	cachedTracks, exists := t.audioTracks[filePath]
@@ -244,60 +291,27 @@ func (t *Transcoder) GetAudioTracks(filePath string) ([]types.AudioTrack, error)
 		return nil, fmt.Errorf("未找到FFmpeg，请先安装FFmpeg")
 	}
 
-	// 使用ffprobe获取所有音频轨道信息
-	cmd := exec.Command("ffprobe",
-		"-v", "error",
-		"-select_streams", "a",
-		"-show_entries", "stream=index:stream_tags=language,title:stream=codec_name",
-		"-of", "csv=p=0",
-		filePath)
-
-	output, err := cmd.CombinedOutput()
+	// 使用ffprobe以JSON格式获取所有音频轨道信息，包括声道数和disposition标记
+	streams, err := probeStreams(ctx, filePath, "a")
 	if err != nil {
-		return nil, fmt.Errorf("获取音频轨道信息失败: %w, 输出: %s", err, string(output))
+		return nil, fmt.Errorf("获取音频轨道信息失败: %w", err)
 	}
 
 	tracks := []types.AudioTrack{}
-	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
-
-	for _, line := range lines {
-		if line == "" {
-			continue
-		}
-
-		// 解析CSV格式的输出: index,language,title,codec_name
-		parts := strings.Split(line, ",")
-		track := types.AudioTrack{
-			IsDefault: false,
-		}
-
-		if len(parts) > 0 {
-			index, err := strconv.Atoi(parts[0])
-			if err == nil {
-				track.Index = index
-			}
-		}
-
-		if len(parts) > 1 {
-			track.Language = parts[1]
-		}
-
-		if len(parts) > 2 {
-			track.Title = parts[2]
-		}
-
-		if len(parts) > 3 {
-			track.CodecName = parts[3]
-		}
-
-		// 如果是第一条音频轨道，默认为选中
-		if len(tracks) == 0 {
-			track.IsDefault = true
-		}
-
-		tracks = append(tracks, track)
+	for _, stream := range streams {
+		tracks = append(tracks, types.AudioTrack{
+			Index:     stream.Index,
+			Language:  stream.Tags.Language,
+			Title:     stream.Tags.Title,
+			CodecName: stream.CodecName,
+			Channels:  stream.Channels,
+			IsDefault: stream.Disposition.Default == 1,
+		})
 	}
 
+	// 按首选语言选择默认音轨；没有符合的轨道且文件未标记默认音轨时，按系统语言和声道数选择
+	selectDefaultAudioTrack(tracks, t.PreferredLanguages(), systemLanguage())
+
 	// 缓存音频轨道信息
 	t.audioMutex.Lock()
 	t.audioTracks[filePath] = tracks
@@ -309,12 +323,41 @@ func (t *Transcoder) GetAudioTracks(filePath string) ([]types.AudioTrack, error)
 // TranscodeToMp4 将媒体文件转码为MP4格式
 // 支持实时流输出，适用于投屏场景
 func (t *Transcoder) TranscodeToMp4(inputFile string, subtitleTrackIndex int, audioTrackIndex int) (string, error) {
-	// 生成带字幕和音频索引的缓存键
-	cacheKey := fmt.Sprintf("%s_subtitle_%d_audio_%d", inputFile, subtitleTrackIndex, audioTrackIndex)
+	return t.TranscodeToMp4WithProgress(inputFile, subtitleTrackIndex, audioTrackIndex, nil)
+}
+
+// TranscodeToMp4WithProgress 将媒体文件转码为MP4格式，并通过onProgress报告0-100的进度
+// 无法获取媒体总时长时以-1报告进度；命中缓存时直接报告100
+func (t *Transcoder) TranscodeToMp4WithProgress(inputFile string, subtitleTrackIndex int, audioTrackIndex int, onProgress func(percent float64)) (string, error) {
+	return t.TranscodeToMp4ForCast(inputFile, subtitleTrackIndex, audioTrackIndex, CastOptions{}, onProgress)
+}
+
+// TranscodeToMp4WithContext 将媒体文件转码为MP4格式，ctx被取消时终止FFmpeg并删除未完成的输出文件
+func (t *Transcoder) TranscodeToMp4WithContext(ctx context.Context, inputFile string, subtitleTrackIndex int, audioTrackIndex int) (string, error) {
+	return t.TranscodeToMp4ForCastWithContext(ctx, inputFile, subtitleTrackIndex, audioTrackIndex, CastOptions{}, nil)
+}
+
+// TranscodeToMp4ForCast 按本次投屏的选项转码为MP4：从cast.StartOffset位置开始，输出文件从该位置开始播放，
+// 视频高度不超过cast.MaxHeight；cast为零值时与TranscodeToMp4WithProgress相同，进度按剩余部分的时长计算
+func (t *Transcoder) TranscodeToMp4ForCast(inputFile string, subtitleTrackIndex int, audioTrackIndex int, cast CastOptions, onProgress func(percent float64)) (string, error) {
+	return t.TranscodeToMp4ForCastWithContext(context.Background(), inputFile, subtitleTrackIndex, audioTrackIndex, cast, onProgress)
+}
+
+// TranscodeToMp4ForCastWithContext 与TranscodeToMp4ForCast相同，ctx被取消时立即返回错误
+// 多个请求等待同一转码时，全部取消后才终止FFmpeg并删除未完成的输出文件
+func (t *Transcoder) TranscodeToMp4ForCastWithContext(ctx context.Context, inputFile string, subtitleTrackIndex int, audioTrackIndex int, cast CastOptions, onProgress func(percent float64)) (string, error) {
+	options := t.Options().withCast(cast)
+	cacheKey, modTime, size, err := transcodeCacheKey(inputFile, subtitleTrackIndex, audioTrackIndex, options)
+	if err != nil {
+		return "", err
+	}
 
 	// 检查是否已有缓存的转码结果
 	if outputFile, valid := t.getCachedOutput(cacheKey); valid {
 		log.Printf("使用缓存的转码结果: %s", outputFile)
+		if onProgress != nil {
+			onProgress(100)
+		}
 		return outputFile, nil
 	}
 
@@ -322,11 +365,63 @@ func (t *Transcoder) TranscodeToMp4(inputFile string, subtitleTrackIndex int, au
 		return "", fmt.Errorf("未找到FFmpeg，请先安装FFmpeg")
 	}
 
-	// 限制并发转码任务数量
-	t.semaphore <- struct{}{}
-	defer func() {
-		<-t.semaphore
-	}()
+	// 多个设备同时请求相同的转码时，只执行一次，其余请求等待结果
+	// 转码在任务自己的上下文中执行，发起的请求取消后其他请求仍可以继续等待
+	job, leader := t.joinTranscode(cacheKey)
+	if leader {
+		progress := onProgress
+		if onProgress != nil {
+			progress = func(percent float64) {
+				if ctx.Err() == nil {
+					onProgress(percent)
+				}
+			}
+		}
+		go func() {
+			outputFile, err := t.runTranscode(job.ctx, inputFile, subtitleTrackIndex, audioTrackIndex, options, cacheKey, modTime, size, progress)
+			t.finishTranscode(cacheKey, job, outputFile, err)
+		}()
+	} else {
+		log.Printf("等待进行中的相同转码任务: %s", inputFile)
+	}
+
+	outputFile, err := t.waitTranscode(ctx, cacheKey, job)
+	if err == nil && !leader && onProgress != nil {
+		onProgress(100)
+	}
+	return outputFile, err
+}
+
+// transcodeCacheKey 生成带字幕和音频索引以及转码选项的缓存键，同时返回源文件的修改时间和大小
+// 缓存键包含源文件的修改时间和大小，源文件变化后不会命中旧的缓存
+func transcodeCacheKey(inputFile string, subtitleTrackIndex, audioTrackIndex int, options TranscodeOptions) (string, int64, int64, error) {
+	modTime, size, err := sourceStamp(inputFile)
+	if err != nil {
+		return "", 0, 0, fmt.Errorf("读取源文件信息失败: %w", err)
+	}
+	cacheKey := fmt.Sprintf("%s_%d_%d_subtitle_%d_audio_%d", inputFile, modTime, size, subtitleTrackIndex, audioTrackIndex)
+	cacheKey += options.cacheKey(subtitleTrackIndex >= 0)
+	return cacheKey, modTime, size, nil
+}
+
+// CachedTranscode 获取按本次投屏选项已完成的转码结果，没有有效缓存时第二个返回值为false
+func (t *Transcoder) CachedTranscode(inputFile string, subtitleTrackIndex int, audioTrackIndex int, cast CastOptions) (string, bool) {
+	options := t.Options().withCast(cast)
+	cacheKey, _, _, err := transcodeCacheKey(inputFile, subtitleTrackIndex, audioTrackIndex, options)
+	if err != nil {
+		return "", false
+	}
+	return t.getCachedOutput(cacheKey)
+}
+
+// runTranscode 排队等待转码名额，然后执行转码并缓存结果
+// ctx被取消时终止FFmpeg并删除未完成的输出文件
+func (t *Transcoder) runTranscode(ctx context.Context, inputFile string, subtitleTrackIndex, audioTrackIndex int, options TranscodeOptions, cacheKey string, modTime, size int64, onProgress func(percent float64)) (string, error) {
+	// 限制并发转码任务数量，按请求顺序排队
+	if err := t.queue.acquire(ctx); err != nil {
+		return "", err
+	}
+	defer t.queue.release()
 
 	// 创建输出文件路径
 	baseName := strings.TrimSuffix(filepath.Base(inputFile), filepath.Ext(inputFile))
@@ -337,38 +432,71 @@ func (t *Transcoder) TranscodeToMp4(inputFile string, subtitleTrackIndex int, au
 	if audioTrackIndex >= 0 {
 		suffix += fmt.Sprintf("_audio%d", audioTrackIndex)
 	}
+	// 使用缓存键的哈希区分不同来源目录和不同转码选项的输出
+	suffix += "_" + shortHash(cacheKey)
 	outputFile := filepath.Join(t.tempDir, fmt.Sprintf("%s_transcoded%s.mp4", baseName, suffix))
 
 	// 获取媒体信息
-	mediaInfo, err := t.GetMediaInfo(inputFile)
+	mediaInfo, err := t.GetMediaInfoWithContext(ctx, inputFile)
 	if err != nil {
 		return "", fmt.Errorf("获取媒体信息失败: %w", err)
 	}
 
-	// 构建FFmpeg转码参数，优化性能
-	args := t.buildOptimizedTranscodeArgs(inputFile, outputFile, mediaInfo, subtitleTrackIndex, audioTrackIndex)
-
 	// 记录转码开始时间
 	startTime := time.Now()
 	log.Printf("开始转码文件: %s 到 %s", inputFile, outputFile)
 
+	// 直接复制视频流失败，或硬件编码器在运行时失败（例如驱动不支持源视频的分辨率）时改用软件编码重试
+	encoder := t.videoEncoderForSource(mediaInfo, subtitleTrackIndex, options)
+	totalDuration := parseDurationSeconds(mediaInfo["duration"]) - options.StartOffset
+	err = t.runFFmpeg(ctx, t.buildOptimizedTranscodeArgs(inputFile, outputFile, mediaInfo, subtitleTrackIndex, audioTrackIndex, options, encoder), totalDuration, onProgress)
+	if err != nil && ctx.Err() == nil && encoder.name != softwareEncoder.name {
+		if encoder.name == videoCopyName {
+			log.Printf("直接复制视频流失败，改为重新编码: %v", err)
+		} else {
+			log.Printf("硬件编码器 %s 转码失败，改用软件编码: %v", encoder.name, err)
+			t.markEncoderFailed(encoder.name)
+		}
+		err = t.runFFmpeg(ctx, t.buildOptimizedTranscodeArgs(inputFile, outputFile, mediaInfo, subtitleTrackIndex, audioTrackIndex, options, softwareEncoder), totalDuration, onProgress)
+	}
+	if err != nil {
+		// 转码失败或被取消，删除未完成的输出文件
+		if ctx.Err() != nil {
+			log.Printf("转码已取消: %s", inputFile)
+		}
+		os.Remove(outputFile)
+		return "", err
+	}
+
+	// 计算转码耗时
+	duration := time.Since(startTime)
+	log.Printf("转码完成，耗时: %v", duration)
+
+	// 缓存转码结果
+	t.storeCachedOutput(cacheKey, outputFile, inputFile, modTime, size)
+
+	return outputFile, nil
+}
+
+// runFFmpeg 执行一次FFmpeg转码，解析输出的进度并等待转码完成，ctx被取消时终止FFmpeg
+func (t *Transcoder) runFFmpeg(ctx context.Context, args []string, totalDuration time.Duration, onProgress func(percent float64)) error {
 	// 执行转码命令
-	cmd := exec.Command("ffmpeg", args...)
+	cmd := exec.CommandContext(ctx, ffmpegCommand(), args...)
 
 	// 捕获标准输出和错误输出
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
-		return "", fmt.Errorf("创建标准输出管道失败: %w", err)
+		return fmt.Errorf("创建标准输出管道失败: %w", err)
 	}
 
 	stderr, err := cmd.StderrPipe()
 	if err != nil {
-		return "", fmt.Errorf("创建标准错误管道失败: %w", err)
+		return fmt.Errorf("创建标准错误管道失败: %w", err)
 	}
 
 	// 启动命令
 	if err := cmd.Start(); err != nil {
-		return "", fmt.Errorf("启动转码命令失败: %w", err)
+		return fmt.Errorf("启动转码命令失败: %w", err)
 	}
 
 	// 并发读取输出
@@ -376,58 +504,61 @@ func (t *Transcoder) TranscodeToMp4(inputFile string, subtitleTrackIndex int, au
 		io.Copy(os.Stdout, stdout)
 	}()
 
+	// 处理FFmpeg输出，解析进度信息
+	progressDone := make(chan struct{})
 	go func() {
-		// 处理FFmpeg输出，提取进度信息
-		buf := make([]byte, 1024)
-		for {
-			n, err := stderr.Read(buf)
-			if n > 0 {
-				output := string(buf[:n])
-				// 这里可以添加进度解析逻辑
-				if strings.Contains(output, "time=") {
-					// 简单进度记录
-					log.Printf("转码中: %s", strings.TrimSpace(output))
-				}
-			}
-			if err != nil {
-				break
-			}
-		}
+		defer close(progressDone)
+		watchProgress(stderr, totalDuration, onProgress)
 	}()
 
+	// 等待进度输出读取完毕后再等待进程退出
+	<-progressDone
+
 	// 等待转码完成
 	if err := cmd.Wait(); err != nil {
-		// 转码失败，删除输出文件
-		os.Remove(outputFile)
-		return "", fmt.Errorf("转码失败: %w", err)
+		if ctx.Err() != nil {
+			return fmt.Errorf("转码已取消: %w", ctx.Err())
+		}
+		return fmt.Errorf("转码失败: %w", err)
 	}
+	return nil
+}
 
-	// 计算转码耗时
-	duration := time.Since(startTime)
-	log.Printf("转码完成，耗时: %v", duration)
-
-	// 缓存转码结果，设置24小时过期
+// storeCachedOutput 记录一个缓存的输出文件，设置24小时过期；持久化缓存只在源文件变化时失效
+// 超过缓存大小上限时删除最久未使用的其他缓存
+func (t *Transcoder) storeCachedOutput(cacheKey, outputFile, inputFile string, modTime, size int64) {
 	t.cacheMutex.Lock()
 	t.transcodingCache[cacheKey] = outputFile
-	t.cacheExpiry[cacheKey] = time.Now().Add(24 * time.Hour)
+	t.cacheSources[cacheKey] = cacheIndexEntry{
+		Key:           cacheKey,
+		Output:        outputFile,
+		SourcePath:    inputFile,
+		SourceModTime: modTime,
+		SourceSize:    size,
+		OutputSize:    outputSize(outputFile),
+		LastUsed:      time.Now().UnixNano(),
+	}
+	if t.persistentCache {
+		if err := t.saveCacheIndexLocked(); err != nil {
+			log.Printf("保存转码缓存索引失败: %v", err)
+		}
+	} else {
+		t.cacheExpiry[cacheKey] = time.Now().Add(24 * time.Hour)
+	}
+	evicted := t.evictCacheLocked(cacheKey)
 	t.cacheMutex.Unlock()
 
-	return outputFile, nil
+	t.notifyCacheEvicted(evicted)
 }
 
-// StreamTranscode 实时流式转码（适合大型文件）
+// StreamTranscode 转码并返回输出文件路径，保留用于interfaces.MediaTranscoder接口
+// 接口要求返回文件路径，因此与TranscodeToMp4相同，需要等待整个文件转码完成；
+// 真正的边转码边播放由StreamTranscodeTo实现，媒体服务器启用流式转码时把FFmpeg的分片MP4输出直接写入HTTP响应
 func (t *Transcoder) StreamTranscode(inputFile string, subtitleTrackIndex int, audioTrackIndex int) (string, error) {
-	// 这个方法将实现实时流式转码
-	// 对于大型文件，我们可以创建一个临时HTTP端点，通过FFmpeg实时转码并流式传输
-	// 此处简化实现，实际项目中需要更复杂的处理
-
 	// 检查FFmpeg是否安装
 	if !CheckFFmpeg() {
 		return "", fmt.Errorf("未找到FFmpeg，请先安装FFmpeg")
 	}
-
-	// 在这个简化版本中，我们直接使用TranscodeToMp4
-	// 实际项目中应该实现真正的流式转码
 	return t.TranscodeToMp4(inputFile, subtitleTrackIndex, audioTrackIndex)
 }
 
@@ -446,12 +577,21 @@ func (t *Transcoder) Cleanup() error {
 	t.cacheMutex.Lock()
 	defer t.cacheMutex.Unlock()
 
+	// 停止后台清理任务
+	t.stopCacheJanitorLocked()
+
 	// 清理过期缓存
 	t.cleanupExpiredCache()
 
+	// 持久化缓存保留输出文件和索引，供下次启动时复用
+	if t.persistentCache {
+		return t.saveCacheIndexLocked()
+	}
+
 	// 清理缓存记录
 	t.transcodingCache = make(map[string]string)
 	t.cacheExpiry = make(map[string]time.Time)
+	t.cacheSources = make(map[string]cacheIndexEntry)
 
 	// 清理临时目录
 	if t.tempDir != "" {
@@ -464,6 +604,31 @@ func (t *Transcoder) Cleanup() error {
 	return nil
 }
 
+// InvalidateSource 删除指定源文件的所有转码缓存，用于源文件被删除或重命名后
+func (t *Transcoder) InvalidateSource(filePath string) {
+	t.cacheMutex.Lock()
+	defer t.cacheMutex.Unlock()
+
+	cleanPath := filepath.Clean(filePath)
+	changed := false
+	for key, entry := range t.cacheSources {
+		if filepath.Clean(entry.SourcePath) != cleanPath {
+			continue
+		}
+		os.Remove(entry.Output)
+		delete(t.transcodingCache, key)
+		delete(t.cacheExpiry, key)
+		delete(t.cacheSources, key)
+		changed = true
+	}
+
+	if changed && t.persistentCache {
+		if err := t.saveCacheIndexLocked(); err != nil {
+			log.Printf("保存转码缓存索引失败: %v", err)
+		}
+	}
+}
+
 // 内部方法: 获取缓存的输出文件路径，如果缓存有效返回路径和true
 func (t *Transcoder) getCachedOutput(cacheKey string) (string, bool) {
 	t.cacheMutex.Lock()
@@ -483,9 +648,11 @@ func (t *Transcoder) getCachedOutput(cacheKey string) (string, bool) {
 		// 缓存文件不存在，移除缓存记录
 		delete(t.transcodingCache, cacheKey)
 		delete(t.cacheExpiry, cacheKey)
+		delete(t.cacheSources, cacheKey)
 		return "", false
 	}
 
+	t.touchCacheLocked(cacheKey)
 	return cachedOutput, true
 }
 
@@ -510,24 +677,44 @@ func (t *Transcoder) cleanupExpiredCache() {
 			delete(t.transcodingCache, key)
 		}
 		delete(t.cacheExpiry, key)
+		delete(t.cacheSources, key)
 	}
 }
 
 // 内部方法: 构建优化的转码参数
-func (t *Transcoder) buildOptimizedTranscodeArgs(inputFile, outputFile string, mediaInfo map[string]string, subtitleTrackIndex, audioTrackIndex int) []string {
-	// 基本参数：高质量、快速启动（适合流式传输）
-	args := []string{
-		"-i", inputFile,
-		"-c:v", "h264", // 使用H.264视频编码
-		"-preset", "ultrafast", // 最快的编码速度
-		"-crf", "28", // 较低的质量但更快的编码
-		"-profile:v", "main", // 兼容性更好的配置
-		"-level", "4.0",
-		"-movflags", "+faststart", // 快速启动，适合流式传输
+// encoder为视频编码器，硬件编码器需要的设备参数放在-i之前，上传画面的滤镜放在视频滤镜最后
+func (t *Transcoder) buildOptimizedTranscodeArgs(inputFile, outputFile string, mediaInfo map[string]string, subtitleTrackIndex, audioTrackIndex int, options TranscodeOptions, encoder videoEncoder) []string {
+	// 指定了起始位置时，-ss作为输入选项放在-i之前
+	args := append([]string{}, encoder.inputArgs...)
+	args = append(args, startOffsetArgs(options.StartOffset)...)
+	args = append(args, "-i", inputFile)
+
+	// 选择了外部字幕文件时，不烧录的字幕作为第二个输入，同样跳转到起始位置
+	externalSubtitle, external := externalSubtitlePath(inputFile, subtitleTrackIndex)
+	if IsExternalSubtitleIndex(subtitleTrackIndex) && !external {
+		log.Printf("未找到外部字幕文件(序号 %d)，不添加字幕", subtitleTrackIndex)
+		subtitleTrackIndex = -1
+	}
+	if external && !options.BurnSubtitles {
+		args = append(args, startOffsetArgs(options.StartOffset)...)
+		args = append(args, "-i", externalSubtitle)
+	}
+
+	// 基本参数：H.264视频编码、快速启动（适合流式传输），编码速度和画质由转码参数决定；
+	// 直接复制视频流时不限制码率
+	quality := options.Quality.withDefaults()
+	args = append(args, "-c:v", encoder.name)
+	args = append(args, encoder.args(quality)...)
+	if encoder.name != videoCopyName {
+		args = append(args, quality.bitrateArgs()...)
+	}
+	args = append(args,
+		"-movflags", movFlags(outputFile),
 		"-threads", strconv.Itoa(runtime.NumCPU()), // 使用多核加速
 		"-hide_banner", // 减少输出信息
 		"-loglevel", "warning", // 只显示警告和错误
-	}
+		"-stats", // 仍然输出进度信息，用于计算转码进度
+	)
 
 	// 构建映射参数
 	args = append(args, "-map", "0:v:0") // 视频流
@@ -540,28 +727,80 @@ func (t *Transcoder) buildOptimizedTranscodeArgs(inputFile, outputFile string, m
 	}
 
 	// 如果指定了字幕轨道，添加字幕处理参数
-	if subtitleTrackIndex >= 0 {
-		args = append(args, "-map", fmt.Sprintf("0:s:%d", subtitleTrackIndex)) // 选择的字幕轨道
-		args = append(args, "-c:s", "mov_text") // 转换字幕为MP4兼容格式
-		args = append(args, "-disposition:s:0", "default") // 设置为默认字幕
+	videoFilters := []string{}
+	if subtitleTrackIndex >= 0 && options.BurnSubtitles {
+		// 将字幕烧录进视频画面，按配置的样式渲染
+		videoFilters = append(videoFilters, subtitleBurnFilter(inputFile, subtitleTrackIndex, externalSubtitle, options.SubtitleStyle, options.StartOffset))
+	} else if subtitleTrackIndex >= 0 || (options.MuxAllSubtitles && !options.BurnSubtitles) {
+		// 封装选择的字幕，或者封装所有文本字幕并将选择的字幕设为默认
+		args = append(args, subtitleMuxArgs(mediaInfo, subtitleTrackIndex, external, options.MuxAllSubtitles)...)
+	}
+
+	// 超过设备支持的分辨率时缩小画面，放在字幕烧录之后使字幕随画面一起缩放
+	if scale := scaleFilter(mediaInfo, options.MaxHeight); scale != "" {
+		videoFilters = append(videoFilters, scale)
+	}
+	// 需要把画面上传到GPU的硬件编码器，上传放在所有软件滤镜之后
+	if encoder.filter != "" {
+		videoFilters = append(videoFilters, encoder.filter)
 	}
+	if len(videoFilters) > 0 {
+		args = append(args, "-vf", strings.Join(videoFilters, ","))
+	}
+
+	// 限制输出帧率，可变帧率的视频强制转为恒定帧率
+	args = append(args, frameRateArgs(mediaInfo, options.MaxFrameRate)...)
 
 	// 检查是否需要转码音频
 	audioCodec, audioExists := mediaInfo["audio_codec"]
 	if audioExists && needTranscodeAudioFormats[strings.ToLower(audioCodec)] {
 		// 转码为更通用的AAC格式
-		args = append(args, "-c:a", "aac", "-b:a", "128k")
+		args = append(args, "-c:a", "aac", "-b:a", fmt.Sprintf("%dk", quality.AudioBitrate))
 	} else {
 		// 复制音频流，节省资源
 		args = append(args, "-c:a", "copy")
 	}
 
-	// 添加输出文件
+	// 追加用户配置的额外参数，放在输出文件之前使其作用于该输出
+	args = append(args, options.ExtraArgs...)
+
+	// 添加输出文件，流式输出到管道时需要显式指定容器格式
+	if outputFile == streamOutput {
+		args = append(args, "-f", "mp4")
+	}
 	args = append(args, outputFile)
 
 	return args
 }
 
+// frameRateArgs 根据源视频帧率和帧率上限生成帧率相关的参数
+// 只有设置了上限且源帧率高于上限时才添加-r；源视频为可变帧率时添加-vsync cfr
+func frameRateArgs(mediaInfo map[string]string, maxFrameRate int) []string {
+	rFrameRate := parseFrameRate(mediaInfo["r_frame_rate"])
+	avgFrameRate := parseFrameRate(mediaInfo["avg_frame_rate"])
+
+	// 可变帧率视频的r_frame_rate可能远高于实际帧率，优先使用平均帧率
+	sourceFrameRate := avgFrameRate
+	if sourceFrameRate <= 0 {
+		sourceFrameRate = rFrameRate
+	}
+
+	args := []string{}
+	if maxFrameRate > 0 && sourceFrameRate > float64(maxFrameRate) {
+		args = append(args, "-r", strconv.Itoa(maxFrameRate))
+	}
+	if isVariableFrameRate(rFrameRate, avgFrameRate) {
+		args = append(args, "-vsync", "cfr")
+	}
+	return args
+}
+
+// shortHash 返回字符串的短哈希，用于生成唯一的输出文件名
+func shortHash(value string) string {
+	sum := sha1.Sum([]byte(value))
+	return hex.EncodeToString(sum[:])[:8]
+}
+
 // GetTempDir 获取临时目录路径
 func (t *Transcoder) GetTempDir() string {
 	return t.tempDir
@@ -0,0 +1,191 @@
+package transcoder
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFrameRateArgs(t *testing.T) {
+	tests := []struct {
+		name         string
+		rFrameRate   string
+		avgFrameRate string
+		maxFrameRate int
+		want         []string
+	}{
+		{name: "no cap", rFrameRate: "60/1", avgFrameRate: "60/1", maxFrameRate: 0, want: []string{}},
+		{name: "cap below source", rFrameRate: "60/1", avgFrameRate: "60/1", maxFrameRate: 30, want: []string{"-r", "30"}},
+		{name: "ntsc source above cap", rFrameRate: "60000/1001", avgFrameRate: "60000/1001", maxFrameRate: 30, want: []string{"-r", "30"}},
+		{name: "cap equal to source", rFrameRate: "30/1", avgFrameRate: "30/1", maxFrameRate: 30, want: []string{}},
+		{name: "cap above source", rFrameRate: "24000/1001", avgFrameRate: "24000/1001", maxFrameRate: 30, want: []string{}},
+		{name: "unknown frame rate", maxFrameRate: 30, want: []string{}},
+		{name: "variable frame rate", rFrameRate: "60/1", avgFrameRate: "29/1", maxFrameRate: 0, want: []string{"-vsync", "cfr"}},
+		{name: "variable frame rate capped by average", rFrameRate: "120/1", avgFrameRate: "45/1", maxFrameRate: 30, want: []string{"-r", "30", "-vsync", "cfr"}},
+		{name: "variable frame rate average below cap", rFrameRate: "120/1", avgFrameRate: "25/1", maxFrameRate: 30, want: []string{"-vsync", "cfr"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mediaInfo := map[string]string{"r_frame_rate": tt.rFrameRate, "avg_frame_rate": tt.avgFrameRate}
+			got := frameRateArgs(mediaInfo, tt.maxFrameRate)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("frameRateArgs() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// argIndex 返回参数在args中第一次出现的位置，不存在时返回-1
+func argIndex(args []string, arg string) int {
+	for i, value := range args {
+		if value == arg {
+			return i
+		}
+	}
+	return -1
+}
+
+// seekInputs 返回每个-ss选项的值及其后紧跟的-i输入
+func seekInputs(args []string) map[string]string {
+	inputs := map[string]string{}
+	for i := 0; i+3 < len(args); i++ {
+		if args[i] == "-ss" && args[i+2] == "-i" {
+			inputs[args[i+3]] = args[i+1]
+		}
+	}
+	return inputs
+}
+
+func TestStartOffsetArgsPlacement(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "movie.mkv")
+	subtitle := filepath.Join(dir, "movie.srt")
+	for _, file := range []string{input, subtitle} {
+		if err := os.WriteFile(file, []byte("x"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	transcoder := &Transcoder{}
+	mediaInfo := map[string]string{"video_codec": "hevc", "duration": "600"}
+
+	tests := []struct {
+		name               string
+		startOffset        time.Duration
+		subtitleTrackIndex int
+		want               map[string]string
+	}{
+		{name: "no offset", subtitleTrackIndex: -1, want: map[string]string{}},
+		{name: "offset before input", startOffset: 90500 * time.Millisecond, subtitleTrackIndex: -1, want: map[string]string{input: "90.500"}},
+		{
+			name:               "offset before external subtitle",
+			startOffset:        time.Minute,
+			subtitleTrackIndex: ExternalSubtitleIndexBase,
+			want:               map[string]string{input: "60.000", subtitle: "60.000"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := DefaultTranscodeOptions().withCast(CastOptions{StartOffset: tt.startOffset})
+			args := transcoder.buildOptimizedTranscodeArgs(input, filepath.Join(dir, "out.mp4"), mediaInfo, tt.subtitleTrackIndex, -1, options, softwareEncoder)
+			if got := seekInputs(args); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("-ss inputs = %v, want %v (args %q)", got, tt.want, args)
+			}
+			// -ss只作为输入选项出现，不能出现在第一个输入之后
+			first := argIndex(args, "-i")
+			for i, arg := range args {
+				if arg == "-ss" && i > first && (i+2 >= len(args) || args[i+2] != "-i") {
+					t.Errorf("-ss used as an output option at %d: %q", i, args)
+				}
+			}
+		})
+	}
+}
+
+func TestTranscodeCacheKeyStartOffset(t *testing.T) {
+	input := filepath.Join(t.TempDir(), "movie.mkv")
+	if err := os.WriteFile(input, []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cacheKey := func(offset time.Duration) string {
+		t.Helper()
+		key, _, _, err := transcodeCacheKey(input, -1, -1, DefaultTranscodeOptions().withCast(CastOptions{StartOffset: offset}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return key
+	}
+
+	fromStart := cacheKey(0)
+	if strings.Contains(fromStart, "_ss") {
+		t.Errorf("cache key without offset = %q, want no _ss", fromStart)
+	}
+	atMinute := cacheKey(time.Minute)
+	if !strings.HasSuffix(atMinute, "_ss60000") {
+		t.Errorf("cache key with offset = %q, want suffix _ss60000", atMinute)
+	}
+	if atMinute == cacheKey(2*time.Minute) || atMinute == fromStart {
+		t.Error("different start offsets share a cache key")
+	}
+}
+
+func TestNewTranscoderQualitySettings(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "movie.mkv")
+	if err := os.WriteFile(input, []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	mediaInfo := map[string]string{"video_codec": "hevc", "audio_codec": "dts", "duration": "600"}
+
+	tests := []struct {
+		quality     string
+		wantPreset  string
+		wantCRF     string
+		wantMaxrate string
+		wantAudio   string
+	}{
+		{quality: QualityFast, wantPreset: "ultrafast", wantCRF: "28", wantMaxrate: "8000k", wantAudio: "128k"},
+		{quality: QualityBalanced, wantPreset: "veryfast", wantCRF: "23", wantMaxrate: "12000k", wantAudio: "192k"},
+		{quality: QualityHigh, wantPreset: "medium", wantCRF: "20", wantMaxrate: "20000k", wantAudio: "256k"},
+	}
+	keys := make(map[string]string)
+	for _, tt := range tests {
+		t.Run(tt.quality, func(t *testing.T) {
+			transcoder, err := NewTranscoder(QualitySettings(tt.quality))
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { transcoder.Cleanup() })
+
+			options := transcoder.Options()
+			args := transcoder.buildOptimizedTranscodeArgs(input, filepath.Join(dir, "out.mp4"), mediaInfo, -1, -1, options, softwareEncoder)
+			for flag, want := range map[string]string{"-preset": tt.wantPreset, "-crf": tt.wantCRF, "-maxrate": tt.wantMaxrate, "-b:a": tt.wantAudio} {
+				if i := argIndex(args, flag); i < 0 || i+1 >= len(args) || args[i+1] != want {
+					t.Errorf("%s = %q, want %s (args %q)", flag, argValue(args, flag), want, args)
+				}
+			}
+
+			key, _, _, err := transcodeCacheKey(input, -1, -1, options)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for quality, other := range keys {
+				if other == key {
+					t.Errorf("%s shares the cache key of %s: %q", tt.quality, quality, key)
+				}
+			}
+			keys[tt.quality] = key
+		})
+	}
+}
+
+// argValue 返回参数flag后面的值，没有时返回空字符串
+func argValue(args []string, flag string) string {
+	if i := argIndex(args, flag); i >= 0 && i+1 < len(args) {
+		return args[i+1]
+	}
+	return ""
+}
@@ -6,6 +6,21 @@ type DeviceInfo struct {
 	Manufacturer string
 	ModelName    string
 	Location     string
+	// DeviceType 设备描述中的设备类型，例如 "urn:schemas-upnp-org:device:MediaRenderer:1"
+	DeviceType string
+	// UDN 设备描述中的唯一设备名称，例如 "uuid:4d696e69-444c-164e-9d41-b827eb5c8f2a"
+	// 设备地址可能随DHCP变化，UDN保持不变
+	UDN string
+	// ModelNumber 设备描述中的型号编号
+	ModelNumber string
+	// SerialNumber 设备描述中的序列号
+	SerialNumber string
+	// PresentationURL 设备网页管理界面的完整地址，设备没有提供时为空
+	PresentationURL string
+	// IconURL 设备描述中最大的PNG或JPEG图标的完整地址，设备没有提供图标时为空
+	IconURL string
+	// Offline 设备在最近一次完成的搜索中没有响应，仍保留在设备列表中
+	Offline bool
 }
 
 // SubtitleTrack 表示媒体文件中的字幕轨道信息
@@ -14,6 +29,9 @@ type SubtitleTrack struct {
 	Language  string
 	Title     string
 	IsDefault bool
+	IsForced  bool
+	// ExternalPath 外部字幕文件的路径，为空表示媒体文件内嵌的字幕
+	ExternalPath string
 }
 
 // AudioTrack 表示媒体文件中的音频轨道信息
@@ -22,5 +40,6 @@ type AudioTrack struct {
 	Language  string
 	Title     string
 	CodecName string
+	Channels  int
 	IsDefault bool
 }
\ No newline at end of file
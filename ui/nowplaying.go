@@ -0,0 +1,178 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+
+	"GoCastify/app"
+	"GoCastify/interfaces"
+)
+
+// nowPlayingCard "正在播放"状态卡片
+// 显示当前投屏的设备、文件、播放状态和进度，并提供播放控制
+type nowPlayingCard struct {
+	app           *app.App
+	card          fyne.CanvasObject
+	deviceLabel   *widget.Label
+	fileLabel     *widget.Label
+	stateLabel    *widget.Label
+	positionLabel *widget.Label
+	remoteLabel   *widget.Label
+	controls      *fyne.Container
+	// waitingBar 设备尚未开始请求媒体文件时显示的加载动画
+	waitingBar *widget.ProgressBarInfinite
+	// volumeSlider 设备音量，读取到设备的音量后才显示
+	volumeSlider *widget.Slider
+	muteCheck    *widget.Check
+	volumeRow    *fyne.Container
+	// volumeController 已经读取过音量的控制器，切换到新的投屏时重新读取
+	volumeController interfaces.DLNAController
+}
+
+// newNowPlayingCard 创建"正在播放"状态卡片，没有投屏时卡片隐藏
+func newNowPlayingCard(app *app.App) *nowPlayingCard {
+	npc := &nowPlayingCard{
+		app:           app,
+		deviceLabel:   widget.NewLabel(""),
+		fileLabel:     widget.NewLabel(""),
+		stateLabel:    widget.NewLabel(""),
+		positionLabel: widget.NewLabel(""),
+		remoteLabel:   widget.NewLabel(""),
+		controls:      container.NewHBox(),
+		waitingBar:    widget.NewProgressBarInfinite(),
+		volumeSlider:  widget.NewSlider(0, 100),
+		muteCheck:     widget.NewCheck("静音", nil),
+	}
+	npc.remoteLabel.Wrapping = fyne.TextWrapBreak
+	npc.fileLabel.Wrapping = fyne.TextTruncate
+
+	npc.volumeRow = container.NewBorder(nil, nil, widget.NewLabel("音量"), npc.muteCheck, npc.volumeSlider)
+	npc.volumeRow.Hide()
+
+	descLabel := widget.NewLabel("当前投屏的播放状态")
+	content := container.NewVBox(
+		npc.deviceLabel,
+		npc.fileLabel,
+		container.NewHBox(npc.stateLabel, npc.positionLabel),
+		npc.waitingBar,
+		npc.remoteLabel,
+		npc.volumeRow,
+		container.NewCenter(npc.controls),
+	)
+	npc.card = createCard("正在播放", descLabel, content)
+
+	// 播放控制按钮，SOAP请求在后台发送，避免阻塞界面
+	npc.controls.Add(widget.NewButton("暂停", func() {
+		go npc.runControl(app.PausePlayback)
+	}))
+	npc.controls.Add(widget.NewButton("停止", func() {
+		go npc.runControl(app.StopCasting)
+	}))
+	// 拖动结束后才发送SetVolume，避免拖动过程中发送大量请求
+	npc.volumeSlider.OnChangeEnded = func(value float64) {
+		go npc.runControl(func(ctx context.Context) error {
+			return app.SetPlaybackVolume(ctx, int(value))
+		})
+	}
+	npc.muteCheck.OnChanged = func(muted bool) {
+		go npc.runControl(func(ctx context.Context) error {
+			return app.SetPlaybackMuted(ctx, muted)
+		})
+	}
+
+	// 播放状态变化时刷新卡片
+	app.SetOnNowPlayingChanged(npc.refresh)
+	npc.refresh()
+
+	return npc
+}
+
+// refresh 根据当前的播放状态刷新卡片内容
+func (npc *nowPlayingCard) refresh() {
+	info, playing := npc.app.NowPlaying()
+	if !playing {
+		npc.waitingBar.Stop()
+		npc.volumeController = nil
+		npc.volumeRow.Hide()
+		npc.card.Hide()
+		return
+	}
+
+	npc.deviceLabel.SetText("设备: " + getFriendlyDeviceName(info.Device))
+	npc.fileLabel.SetText("文件: " + info.FileName)
+	npc.stateLabel.SetText("状态: " + info.State)
+	// 设备开始读取文件后停止加载动画
+	if info.AwaitingRequest {
+		npc.waitingBar.Show()
+		npc.waitingBar.Start()
+	} else {
+		npc.waitingBar.Stop()
+		npc.waitingBar.Hide()
+	}
+	if info.Duration > 0 {
+		npc.positionLabel.SetText(fmt.Sprintf("%s / %s", formatPlaybackTime(info.Position), formatPlaybackTime(info.Duration)))
+	} else {
+		npc.positionLabel.SetText("")
+	}
+	// 启用网页遥控器时显示其地址，方便在手机浏览器中打开
+	if remoteURL := npc.app.RemoteURL(); remoteURL != "" {
+		npc.remoteLabel.SetText("网页遥控器: " + remoteURL)
+		npc.remoteLabel.Show()
+	} else {
+		npc.remoteLabel.Hide()
+	}
+	if controller := npc.app.ActiveController(); controller != npc.volumeController {
+		npc.volumeController = controller
+		npc.volumeRow.Hide()
+		go npc.loadVolume(controller)
+	}
+	npc.card.Show()
+}
+
+// loadVolume 读取设备的当前音量和静音状态并显示音量滑块
+// 设备没有RenderingControl服务或读取失败时不显示滑块，只有静音状态读取失败时不显示静音选项
+func (npc *nowPlayingCard) loadVolume(controller interfaces.DLNAController) {
+	volume, err := npc.app.PlaybackVolume(context.Background())
+	if err != nil {
+		log.Printf("读取设备音量失败: %v\n", err)
+		return
+	}
+	// 读取期间已经切换到其他投屏时忽略结果
+	if npc.app.ActiveController() != controller {
+		return
+	}
+	npc.volumeSlider.SetValue(float64(volume))
+	// 直接设置Checked而不是调用SetChecked，避免把读取到的状态再发送给设备
+	if muted, err := npc.app.PlaybackMuted(context.Background()); err == nil {
+		npc.muteCheck.Checked = muted
+		npc.muteCheck.Refresh()
+		npc.muteCheck.Show()
+	} else {
+		log.Printf("读取设备静音状态失败: %v\n", err)
+		npc.muteCheck.Hide()
+	}
+	npc.volumeRow.Show()
+}
+
+// runControl 执行一个播放控制操作，失败时显示错误
+func (npc *nowPlayingCard) runControl(control func(ctx context.Context) error) {
+	if err := control(context.Background()); err != nil {
+		dialog.ShowError(err, npc.app.Window)
+	}
+}
+
+// formatPlaybackTime 将播放时间格式化为 HH:MM:SS
+func formatPlaybackTime(d time.Duration) string {
+	d = d.Round(time.Second)
+	hours := int(d / time.Hour)
+	minutes := int(d % time.Hour / time.Minute)
+	seconds := int(d % time.Minute / time.Second)
+	return fmt.Sprintf("%02d:%02d:%02d", hours, minutes, seconds)
+}
@@ -0,0 +1,441 @@
+package ui
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+
+	"GoCastify/app"
+	"GoCastify/discovery"
+	"GoCastify/transcoder"
+)
+
+// 设置对话框尺寸
+const (
+	settingsDialogWidth  = 480
+	settingsDialogHeight = 420
+)
+
+// 直接地址模式选项及其显示名称，第一项为关闭
+var (
+	directURLModes     = []app.DirectURLMode{app.DirectURLOff, app.DirectURLFile, app.DirectURLSMB}
+	directURLModeNames = []string{"关闭", "本机文件地址 (file://)", "网络共享地址 (SMB)"}
+)
+
+// 帧率上限选项及其显示名称，第一项为不限制
+var (
+	frameRateCaps     = []int{0, 60, 30, 25, 24}
+	frameRateCapNames = []string{"不限制", "60 fps", "30 fps", "25 fps", "24 fps"}
+)
+
+// 分辨率上限选项及其显示名称，第一项为按设备自动决定
+var (
+	maxVideoHeights     = []int{app.MaxVideoHeightAuto, app.MaxVideoHeightNone, 2160, 1080, 720}
+	maxVideoHeightNames = []string{"自动（按设备）", "不限制", "2160p", "1080p", "720p"}
+)
+
+// 视频编码器选项及其显示名称，前两项为自动和软件编码，之后为各个硬件编码器
+var (
+	videoEncoders     = append([]string{transcoder.VideoEncoderAuto, transcoder.VideoEncoderSoftware}, transcoder.HardwareEncoderNames()...)
+	videoEncoderNames = append([]string{"自动（优先使用硬件编码）", "软件编码"}, transcoder.HardwareEncoderNames()...)
+)
+
+// 转码画质预设及其显示名称，第一项为默认的快速
+var (
+	transcodeQualities    = []string{transcoder.QualityFast, transcoder.QualityBalanced, transcoder.QualityHigh}
+	transcodeQualityNames = []string{"快速", "平衡", "高质量"}
+)
+
+// 启用直接地址模式时的提示
+const directURLWarning = app.DirectURLWarning
+
+// showSettingsDialog 显示设置对话框，确认后保存并应用设置，之后调用onApplied刷新界面
+func showSettingsDialog(app *app.App, onApplied func()) {
+	settings := app.Settings
+
+	// 字幕烧录选项
+	burnCheck := widget.NewCheck("将字幕烧录进画面", nil)
+	burnCheck.SetChecked(settings.BurnSubtitles)
+	muxAllSubtitlesCheck := widget.NewCheck("封装所有文本字幕，可在设备上切换", nil)
+	muxAllSubtitlesCheck.SetChecked(settings.MuxAllSubtitles)
+
+	// 持久化转码缓存选项
+	persistCheck := widget.NewCheck("跨会话保留转码缓存", nil)
+	persistCheck.SetChecked(settings.PersistTranscodeCache)
+
+	// 流式转码选项
+	streamCheck := widget.NewCheck("边转码边播放（更快开始，无法跳转）", nil)
+	streamCheck.SetChecked(settings.StreamTranscode)
+
+	// 帧率上限选项
+	frameRateSelect := widget.NewSelect(frameRateCapNames, nil)
+	frameRateSelect.SetSelectedIndex(0)
+	for i, fps := range frameRateCaps {
+		if fps == settings.MaxFrameRate {
+			frameRateSelect.SetSelectedIndex(i)
+		}
+	}
+
+	// 分辨率上限选项，不在列表中的保存值按自动处理
+	maxHeightSelect := widget.NewSelect(maxVideoHeightNames, nil)
+	maxHeightSelect.SetSelectedIndex(0)
+	for i, height := range maxVideoHeights {
+		if height == settings.MaxVideoHeight {
+			maxHeightSelect.SetSelectedIndex(i)
+		}
+	}
+
+	// 视频编码器选项，不在列表中的保存值按自动处理
+	encoderSelect := widget.NewSelect(videoEncoderNames, nil)
+	encoderSelect.SetSelectedIndex(0)
+	for i, encoder := range videoEncoders {
+		if encoder == settings.VideoEncoder {
+			encoderSelect.SetSelectedIndex(i)
+		}
+	}
+
+	// 转码画质预设，不在列表中的保存值按快速处理
+	qualitySelect := widget.NewSelect(transcodeQualityNames, nil)
+	qualitySelect.SetSelectedIndex(0)
+	for i, quality := range transcodeQualities {
+		if quality == settings.TranscodeQuality {
+			qualitySelect.SetSelectedIndex(i)
+		}
+	}
+
+	// 定期清理转码缓存的间隔
+	cleanupIntervalEntry := newIntEntry(settings.CacheCleanupInterval)
+
+	// 转码缓存的总大小上限
+	maxCacheSizeEntry := newIntEntry(settings.MaxCacheSize)
+
+	// 高级：额外的FFmpeg参数
+	extraArgsEntry := widget.NewEntry()
+	extraArgsEntry.SetPlaceHolder("例如 -tune zerolatency")
+	extraArgsEntry.SetText(settings.ExtraFFmpegArgs)
+
+	// FFmpeg和ffprobe可执行文件路径，下方显示检测到的版本
+	ffmpegPathEntry := widget.NewEntry()
+	ffmpegPathEntry.SetPlaceHolder("留空使用系统PATH中的ffmpeg")
+	ffmpegPathEntry.SetText(settings.FFmpegPath)
+	ffprobePathEntry := widget.NewEntry()
+	ffprobePathEntry.SetPlaceHolder("留空使用系统PATH中的ffprobe")
+	ffprobePathEntry.SetText(settings.FFprobePath)
+	binaryVersionLabel := widget.NewLabel("正在检测版本...")
+	go func() {
+		binaryVersionLabel.SetText(binaryVersionsText(settings.FFmpegPath, settings.FFprobePath))
+	}()
+
+	// 默认字幕和音轨的首选语言
+	languagesEntry := widget.NewEntry()
+	languagesEntry.SetPlaceHolder("例如 zh-CN, zh, en")
+	languagesEntry.SetText(settings.PreferredLanguages)
+
+	// 先尝试原文件
+	tryOriginalCheck := widget.NewCheck("先尝试原文件，无法播放时再转码", nil)
+	tryOriginalCheck.SetChecked(settings.TryOriginalFirst)
+
+	// 短媒体地址
+	shortURLCheck := widget.NewCheck("使用短地址（不含文件名）", nil)
+	shortURLCheck.SetChecked(settings.ShortMediaURLs)
+
+	// 上传限速
+	rateLimitEntry := newIntEntry(settings.UploadRateLimit)
+	perConnectionCheck := widget.NewCheck("按连接分别限速", nil)
+	perConnectionCheck.SetChecked(settings.PerConnectionLimit)
+
+	// 空闲连接超时
+	idleTimeoutEntry := newIntEntry(settings.IdleTimeout)
+
+	// 再次搜索时保留没有响应的设备
+	keepMissingCheck := widget.NewCheck("保留未响应的设备（标记为离线）", nil)
+	keepMissingCheck.SetChecked(settings.KeepMissingDevices)
+
+	// 后台持续发现设备
+	backgroundDiscoveryCheck := widget.NewCheck("在后台持续发现设备", nil)
+	backgroundDiscoveryCheck.SetChecked(settings.BackgroundDiscovery)
+
+	// 只显示媒体渲染器
+	renderersOnlyCheck := widget.NewCheck("只显示可投屏的设备（媒体渲染器）", nil)
+	renderersOnlyCheck.SetChecked(settings.RenderersOnly)
+
+	// 设备发现和媒体地址使用的网络接口，可以选择接口名称或输入IP地址
+	interfaceEntry := widget.NewSelectEntry(discovery.MulticastInterfaces())
+	interfaceEntry.SetPlaceHolder("留空自动选择")
+	interfaceEntry.SetText(settings.NetworkInterface)
+
+	// 设备搜索超时
+	searchTimeoutEntry := newIntEntry(settings.SearchTimeout)
+
+	// 投屏期间阻止系统休眠
+	preventSleepCheck := widget.NewCheck("投屏时阻止系统休眠", nil)
+	preventSleepCheck.SetChecked(settings.PreventSleep)
+
+	// 网页遥控器
+	remoteCheck := widget.NewCheck("启用网页遥控器", nil)
+	remoteCheck.SetChecked(settings.RemoteEnabled)
+
+	// 投屏失败自动重试
+	retryAttemptsEntry := newIntEntry(settings.CastRetryAttempts)
+	retryDelayEntry := newIntEntry(settings.CastRetryDelay)
+
+	// 设备响应超时和准备时间，启动较慢的电视需要调大
+	deviceTimeoutEntry := newIntEntry(settings.DeviceTimeout)
+	readyDelayEntry := newIntEntry(settings.DeviceReadyDelay)
+
+	// 直接地址模式选项
+	directSelect := widget.NewSelect(directURLModeNames, nil)
+	for i, mode := range directURLModes {
+		if mode == settings.DirectURLMode {
+			directSelect.SetSelectedIndex(i)
+		}
+	}
+	smbRootEntry := widget.NewEntry()
+	smbRootEntry.SetPlaceHolder("本地共享目录，例如 /srv/media")
+	smbRootEntry.SetText(settings.SMBLocalRoot)
+	smbShareEntry := widget.NewEntry()
+	smbShareEntry.SetPlaceHolder("smb://nas/media")
+	smbShareEntry.SetText(settings.SMBShareURL)
+
+	// 字幕样式选项
+	fontNameEntry := widget.NewEntry()
+	fontNameEntry.SetPlaceHolder("默认字体")
+	fontNameEntry.SetText(settings.SubtitleStyle.FontName)
+
+	fontSizeEntry := newIntEntry(settings.SubtitleStyle.FontSize)
+	colorEntry := widget.NewEntry()
+	colorEntry.SetPlaceHolder("#FFFFFF")
+	colorEntry.SetText(settings.SubtitleStyle.PrimaryColor)
+	outlineEntry := newIntEntry(settings.SubtitleStyle.OutlineWidth)
+	marginEntry := newIntEntry(settings.SubtitleStyle.MarginV)
+
+	items := []*widget.FormItem{
+		widget.NewFormItem("转码缓存", persistCheck),
+		widget.NewFormItem("缓存清理间隔（分钟）", cleanupIntervalEntry),
+		widget.NewFormItem("缓存上限（MB，0为不限）", maxCacheSizeEntry),
+		widget.NewFormItem("流式转码", streamCheck),
+		widget.NewFormItem("直接播放", tryOriginalCheck),
+		widget.NewFormItem("帧率上限", frameRateSelect),
+		widget.NewFormItem("分辨率上限", maxHeightSelect),
+		widget.NewFormItem("视频编码器", encoderSelect),
+		widget.NewFormItem("转码画质", qualitySelect),
+		widget.NewFormItem("额外FFmpeg参数", extraArgsEntry),
+		widget.NewFormItem("FFmpeg路径", ffmpegPathEntry),
+		widget.NewFormItem("ffprobe路径", ffprobePathEntry),
+		widget.NewFormItem("检测到的版本", binaryVersionLabel),
+		widget.NewFormItem("首选语言", languagesEntry),
+		widget.NewFormItem("媒体地址", shortURLCheck),
+		widget.NewFormItem("上传限速（KB/s，0为不限）", rateLimitEntry),
+		widget.NewFormItem("", perConnectionCheck),
+		widget.NewFormItem("空闲连接超时（分钟）", idleTimeoutEntry),
+		widget.NewFormItem("系统休眠", preventSleepCheck),
+		widget.NewFormItem("设备列表", keepMissingCheck),
+		widget.NewFormItem("", backgroundDiscoveryCheck),
+		widget.NewFormItem("", renderersOnlyCheck),
+		widget.NewFormItem("搜索超时（秒）", searchTimeoutEntry),
+		widget.NewFormItem("网络接口", interfaceEntry),
+		widget.NewFormItem("网页遥控器", remoteCheck),
+		widget.NewFormItem("失败重试次数", retryAttemptsEntry),
+		widget.NewFormItem("重试间隔（秒）", retryDelayEntry),
+		widget.NewFormItem("设备响应超时（秒）", deviceTimeoutEntry),
+		widget.NewFormItem("设备准备时间（毫秒）", readyDelayEntry),
+		widget.NewFormItem("直接地址", directSelect),
+		widget.NewFormItem("SMB本地目录", smbRootEntry),
+		widget.NewFormItem("SMB共享地址", smbShareEntry),
+		widget.NewFormItem("字幕烧录", burnCheck),
+		widget.NewFormItem("字幕封装", muxAllSubtitlesCheck),
+		widget.NewFormItem("字体", fontNameEntry),
+		widget.NewFormItem("字号", fontSizeEntry),
+		widget.NewFormItem("颜色", colorEntry),
+		widget.NewFormItem("描边宽度", outlineEntry),
+		widget.NewFormItem("底部边距", marginEntry),
+	}
+
+	form := dialog.NewForm("设置", "保存", "取消", items, func(confirmed bool) {
+		if !confirmed {
+			return
+		}
+
+		fontSize, err := parseIntField("字号", fontSizeEntry.Text, 1)
+		if err != nil {
+			dialog.ShowError(err, app.Window)
+			return
+		}
+		outline, err := parseIntField("描边宽度", outlineEntry.Text, 0)
+		if err != nil {
+			dialog.ShowError(err, app.Window)
+			return
+		}
+		margin, err := parseIntField("底部边距", marginEntry.Text, 0)
+		if err != nil {
+			dialog.ShowError(err, app.Window)
+			return
+		}
+
+		cleanupInterval, err := parseIntField("缓存清理间隔", cleanupIntervalEntry.Text, 0)
+		if err != nil {
+			dialog.ShowError(err, app.Window)
+			return
+		}
+		maxCacheSize, err := parseIntField("缓存上限", maxCacheSizeEntry.Text, 0)
+		if err != nil {
+			dialog.ShowError(err, app.Window)
+			return
+		}
+		retryAttempts, err := parseIntField("失败重试次数", retryAttemptsEntry.Text, 0)
+		if err != nil {
+			dialog.ShowError(err, app.Window)
+			return
+		}
+		retryDelay, err := parseIntField("重试间隔", retryDelayEntry.Text, 0)
+		if err != nil {
+			dialog.ShowError(err, app.Window)
+			return
+		}
+		deviceTimeout, err := parseIntField("设备响应超时", deviceTimeoutEntry.Text, 1)
+		if err != nil {
+			dialog.ShowError(err, app.Window)
+			return
+		}
+		readyDelay, err := parseIntField("设备准备时间", readyDelayEntry.Text, 0)
+		if err != nil {
+			dialog.ShowError(err, app.Window)
+			return
+		}
+		idleTimeout, err := parseIntField("空闲连接超时", idleTimeoutEntry.Text, 1)
+		if err != nil {
+			dialog.ShowError(err, app.Window)
+			return
+		}
+		searchTimeout, err := parseIntField("搜索超时", searchTimeoutEntry.Text, 2)
+		if err != nil {
+			dialog.ShowError(err, app.Window)
+			return
+		}
+		rateLimit, err := parseIntField("上传限速", rateLimitEntry.Text, 0)
+		if err != nil {
+			dialog.ShowError(err, app.Window)
+			return
+		}
+
+		extraArgs := strings.TrimSpace(extraArgsEntry.Text)
+		if _, err := transcoder.ParseExtraArgs(extraArgs); err != nil {
+			dialog.ShowError(err, app.Window)
+			return
+		}
+
+		// 指定的可执行文件路径必须有效，留空时使用系统PATH，未安装也允许保存
+		ffmpegPath := transcoder.NormalizeBinaryPath(ffmpegPathEntry.Text, "ffmpeg")
+		ffprobePath := transcoder.NormalizeBinaryPath(ffprobePathEntry.Text, "ffprobe")
+		if ffmpegPath != "" {
+			if _, err := transcoder.ValidateBinary(ffmpegPath, "ffmpeg"); err != nil {
+				dialog.ShowError(err, app.Window)
+				return
+			}
+		}
+		if ffprobePath != "" {
+			if _, err := transcoder.ValidateBinary(ffprobePath, "ffprobe"); err != nil {
+				dialog.ShowError(err, app.Window)
+				return
+			}
+		}
+
+		previousDirectMode := settings.DirectURLMode
+		if index := directSelect.SelectedIndex(); index >= 0 {
+			settings.DirectURLMode = directURLModes[index]
+		}
+		settings.SMBLocalRoot = strings.TrimSpace(smbRootEntry.Text)
+		settings.SMBShareURL = strings.TrimSpace(smbShareEntry.Text)
+		settings.PersistTranscodeCache = persistCheck.Checked
+		settings.CacheCleanupInterval = cleanupInterval
+		settings.MaxCacheSize = maxCacheSize
+		settings.StreamTranscode = streamCheck.Checked
+		settings.TryOriginalFirst = tryOriginalCheck.Checked
+		settings.ExtraFFmpegArgs = extraArgs
+		settings.FFmpegPath = ffmpegPath
+		settings.FFprobePath = ffprobePath
+		settings.CastRetryAttempts = retryAttempts
+		settings.RemoteEnabled = remoteCheck.Checked
+		settings.PreventSleep = preventSleepCheck.Checked
+		settings.KeepMissingDevices = keepMissingCheck.Checked
+		settings.BackgroundDiscovery = backgroundDiscoveryCheck.Checked
+		settings.RenderersOnly = renderersOnlyCheck.Checked
+		settings.UploadRateLimit = rateLimit
+		settings.PerConnectionLimit = perConnectionCheck.Checked
+		settings.IdleTimeout = idleTimeout
+		settings.SearchTimeout = searchTimeout
+		settings.NetworkInterface = strings.TrimSpace(interfaceEntry.Text)
+		settings.ShortMediaURLs = shortURLCheck.Checked
+		settings.PreferredLanguages = strings.Join(transcoder.ParseLanguageList(languagesEntry.Text), ", ")
+		settings.CastRetryDelay = retryDelay
+		settings.DeviceTimeout = deviceTimeout
+		settings.DeviceReadyDelay = readyDelay
+		if index := frameRateSelect.SelectedIndex(); index >= 0 {
+			settings.MaxFrameRate = frameRateCaps[index]
+		}
+		if index := maxHeightSelect.SelectedIndex(); index >= 0 {
+			settings.MaxVideoHeight = maxVideoHeights[index]
+		}
+		if index := encoderSelect.SelectedIndex(); index >= 0 {
+			settings.VideoEncoder = videoEncoders[index]
+		}
+		if index := qualitySelect.SelectedIndex(); index >= 0 {
+			settings.TranscodeQuality = transcodeQualities[index]
+		}
+		settings.BurnSubtitles = burnCheck.Checked
+		settings.MuxAllSubtitles = muxAllSubtitlesCheck.Checked
+		settings.SubtitleStyle.FontName = strings.TrimSpace(fontNameEntry.Text)
+		settings.SubtitleStyle.FontSize = fontSize
+		settings.SubtitleStyle.PrimaryColor = strings.TrimSpace(colorEntry.Text)
+		settings.SubtitleStyle.OutlineWidth = outline
+		settings.SubtitleStyle.MarginV = margin
+
+		app.ApplySettings(settings)
+		if onApplied != nil {
+			onApplied()
+		}
+
+		// 新启用直接地址模式时提示其适用范围
+		if settings.DirectURLMode != directURLModes[0] && settings.DirectURLMode != previousDirectMode {
+			dialog.ShowInformation("直接地址模式", directURLWarning, app.Window)
+		}
+	}, app.Window)
+	form.Resize(fyne.NewSize(settingsDialogWidth, settingsDialogHeight))
+	form.Show()
+}
+
+// binaryVersionsText 检测FFmpeg和ffprobe的版本，返回用于显示的文本
+func binaryVersionsText(ffmpegPath, ffprobePath string) string {
+	parts := make([]string, 0, 2)
+	for _, binary := range []struct{ path, name string }{{ffmpegPath, "ffmpeg"}, {ffprobePath, "ffprobe"}} {
+		if version, err := transcoder.ValidateBinary(binary.path, binary.name); err == nil {
+			parts = append(parts, binary.name+" "+version)
+		} else {
+			parts = append(parts, binary.name+" 不可用")
+		}
+	}
+	return strings.Join(parts, "，")
+}
+
+// newIntEntry 创建一个显示整数值的输入框
+func newIntEntry(value int) *widget.Entry {
+	entry := widget.NewEntry()
+	entry.SetText(strconv.Itoa(value))
+	return entry
+}
+
+// parseIntField 解析整数输入，小于min时返回错误
+func parseIntField(name, text string, min int) (int, error) {
+	value, err := strconv.Atoi(strings.TrimSpace(text))
+	if err != nil {
+		return 0, fmt.Errorf("%s必须是整数", name)
+	}
+	if value < min {
+		return 0, fmt.Errorf("%s不能小于%d", name, min)
+	}
+	return value, nil
+}
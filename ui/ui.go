@@ -2,6 +2,7 @@ package ui
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"path/filepath"
@@ -9,6 +10,7 @@ import (
 	"time"
 
 	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
 	"fyne.io/fyne/v2/container"
 	"fyne.io/fyne/v2/dialog"
 	"fyne.io/fyne/v2/layout"
@@ -16,6 +18,7 @@ import (
 
 	"GoCastify/app"
 	"GoCastify/discovery"
+	"GoCastify/dlna"
 	"GoCastify/transcoder"
 	"GoCastify/types"
 )
@@ -24,8 +27,18 @@ import (
 const (
 	progressDialogWidth  = 400
 	progressDialogHeight = 200
+	libraryListWidth     = 400
+	libraryListHeight    = 150
+	thumbnailWidth       = 160
+	thumbnailHeight      = 90
 )
 
+// 勾选强制投屏时的提示
+const castAsIsWarning = "强制投屏会跳过格式检查和转码，将原文件直接发送给设备。\n设备不支持该格式时可能无法播放，所选的音轨和字幕也不会生效。"
+
+// parseStartOffset 解析起始时间输入，BuildUI中的app参数遮蔽了app包
+var parseStartOffset = app.ParseStartOffset
+
 // createCustomProgressDialog 创建自定义进度对话框
 func createCustomProgressDialog(title, message string, parent fyne.Window) dialog.Dialog {
 	// 创建标题和消息标签
@@ -87,22 +100,45 @@ func BuildUI(app *app.App) fyne.CanvasObject {
 	// 创建设备列表 - 改进列表项样式以符合苹果设计
 	app.DeviceList = widget.NewList(
 		func() int {
-			return len(app.Devices)
+			return app.DeviceCount()
 		},
 		func() fyne.CanvasObject {
 			// 使用容器来创建更好的列表项布局
 			item := widget.NewLabel("设备名称")
 			item.Wrapping = fyne.TextTruncate
 			item.Alignment = fyne.TextAlignLeading
-			return container.NewMax(item)
+			// 设备图标显示在名称左侧，没有图标时隐藏
+			icon := widget.NewIcon(nil)
+			icon.Hide()
+			return container.NewBorder(nil, nil, icon, nil, item)
 		},
 		func(id widget.ListItemID, obj fyne.CanvasObject) {
-			if id >= 0 && id < len(app.Devices) {
+			if device, ok := app.Device(id); ok {
 				container := obj.(*fyne.Container)
 				label := container.Objects[0].(*widget.Label)
-				label.SetText(getFriendlyDeviceName(app.Devices[id]))
+				icon := container.Objects[1].(*widget.Icon)
+				if resource := app.DeviceIcon(device, app.DeviceList.Refresh); resource != nil {
+					icon.SetResource(resource)
+					icon.Show()
+				} else {
+					icon.Hide()
+				}
+				name := getFriendlyDeviceName(device)
+				if model := deviceModelText(device); model != "" && !strings.Contains(name, model) {
+					// 显示制造商和型号，方便区分名称相同的设备
+					name += " - " + model
+				}
+				if dlna.IsSourceOnly(device) {
+					// 媒体服务器只能提供内容，不能作为投屏目标
+					name += " (媒体服务器，不可投屏)"
+				}
+				if device.Offline {
+					// 最近一次搜索没有响应，设备可能已关机
+					name += " (离线)"
+				}
+				label.SetText(name)
 				// 为选中项添加视觉反馈
-				if id == app.SelectedDeviceIndex {
+				if id == app.SelectedDeviceIndex() {
 					label.TextStyle = fyne.TextStyle{Bold: true}
 				} else {
 					label.TextStyle = fyne.TextStyle{}
@@ -113,10 +149,38 @@ func BuildUI(app *app.App) fyne.CanvasObject {
 
 	// 创建设备列表选中事件 - 添加视觉反馈
 	app.DeviceList.OnSelected = func(id widget.ListItemID) {
-		app.SelectedDeviceIndex = id
+		app.SelectDevice(id)
 		app.DeviceList.Refresh() // 刷新列表以显示选中状态
 	}
 
+	// restoreStatusLabel 恢复FFmpeg状态显示
+	restoreStatusLabel := func() {
+		if app.FFmpegAvailable {
+			ffmpegStatusLabel.SetText("FFmpeg: 已安装 (支持完整功能)")
+		} else {
+			ffmpegStatusLabel.SetText("FFmpeg: 未安装 (部分功能受限)")
+		}
+	}
+
+	// 后台发现设备上线或离开时刷新设备列表，移除设备后列表的选中项与重新确定的选择保持一致
+	app.SetOnDevicesChanged(func() {
+		time.AfterFunc(0, func() {
+			if index := app.SelectedDeviceIndex(); index >= 0 {
+				app.DeviceList.Select(index)
+			} else {
+				app.DeviceList.UnselectAll()
+			}
+			app.DeviceList.Refresh()
+			deviceCountLabel.SetText(fmt.Sprintf("找到 %d 个设备", app.DeviceCount()))
+		})
+	})
+
+	// 创建停止搜索按钮，仅在搜索进行中可用
+	stopSearchButton := widget.NewButton("停止搜索", func() {
+		app.StopSearch()
+	})
+	stopSearchButton.Disable()
+
 	// 创建搜索设备按钮 - 使用苹果风格的操作按钮
 	searchButton := widget.NewButton("搜索设备", func() {
 		// 如果已经有搜索上下文在运行，取消它
@@ -131,29 +195,37 @@ func BuildUI(app *app.App) fyne.CanvasObject {
 		// 显示进度对话框
 		progressMessage := "正在搜索DLNA设备..."
 		progress := createCustomProgressDialog("搜索中...", progressMessage, app.Window)
+		// 对话框关闭（点击"取消"或搜索结束）时立即停止搜索并恢复界面
+		progress.SetOnClosed(func() {
+			cancel()
+			restoreStatusLabel()
+			stopSearchButton.Disable()
+		})
 		progress.Show()
 
 		// 更新状态标签
 		ffmpegStatusLabel.SetText("正在搜索DLNA设备...")
+		stopSearchButton.Enable()
 
-		// 创建设备发现器实例
-		discoverer := discovery.NewSSDPDiscoverer()
+		// 创建设备发现器实例，由组合发现器统一调度各个发现后端
+		discoverer := discovery.NewCompositeDiscoverer(
+			discovery.NewSSDPDiscoverer(app.Settings.DiscoveryOptions()...),
+		)
 
-		// 清空当前设备列表
-		app.Devices = []types.DeviceInfo{}
-		app.DeviceList.Refresh()
+		// 搜索结果合并到当前设备列表，已有的设备和选择保持不变
+		refresh := app.BeginDeviceRefresh()
 
 		// 启动goroutine搜索设备
 		go func() {
 			// 使用回调函数处理发现的设备
 			onDeviceFound := func(device types.DeviceInfo) {
+				// 合并到设备列表，在搜索结束前完成
+				count := refresh.Add(device)
 				// 在主线程中更新UI
 				time.AfterFunc(0, func() {
-					// 添加设备到列表
-					app.Devices = append(app.Devices, device)
 					app.DeviceList.Refresh()
 					// 更新设备数量标签
-					deviceCountLabel.SetText(fmt.Sprintf("找到 %d 个设备", len(app.Devices)))
+					deviceCountLabel.SetText(fmt.Sprintf("找到 %d 个设备", count))
 				})
 			}
 
@@ -162,31 +234,36 @@ func BuildUI(app *app.App) fyne.CanvasObject {
 			if err != nil {
 				log.Printf("搜索设备失败: %v\n", err)
 			}
+			// 搜索正常结束时处理没有响应的设备，被取消时列表保持不变
+			found := refresh.Finish(ctx.Err() == nil)
 
 			// 在主线程中更新设备数量标签
 			time.AfterFunc(0, func() {
-				deviceCountLabel.SetText(fmt.Sprintf("找到 %d 个设备", len(app.Devices)))
+				deviceCountLabel.SetText(fmt.Sprintf("找到 %d 个设备", app.DeviceCount()))
 				app.Window.Canvas().Refresh(deviceCountLabel)
 			})
 			
 			// 使用time.AfterFunc确保UI更新在主线程中执行
 			time.AfterFunc(0, func() {
-				// 隐藏进度对话框
+				// 隐藏进度对话框（同时恢复状态标签）
 				progress.Hide()
 
-				// 恢复FFmpeg状态显示
-				if app.FFmpegAvailable {
-					ffmpegStatusLabel.SetText("FFmpeg: 已安装 (支持完整功能)")
-				} else {
-					ffmpegStatusLabel.SetText("FFmpeg: 未安装 (部分功能受限)")
+				// 如果没有找到设备，显示提示（用户主动停止搜索时不提示）
+				// 区分搜索无法运行和没有设备响应，两种情况都建议手动添加设备
+				if found == 0 && ctx.Err() != context.Canceled {
+					if errors.Is(err, discovery.ErrDiscoveryUnavailable) {
+						dialog.ShowInformation("无法搜索设备", fmt.Sprintf("设备搜索无法运行：%v\n\n请检查网络连接和防火墙设置（SSDP使用UDP 1900端口组播），\n或点击\"手动添加设备\"输入设备描述地址。", err), app.Window)
+					} else {
+						dialog.ShowInformation("未找到设备", "搜索已完成，但没有DLNA设备响应。\n请确保您的设备已开启并连接到同一网络；防火墙阻止入站的UDP响应时也会搜索不到设备。\n也可以点击\"手动添加设备\"输入设备描述地址。", app.Window)
+					}
 				}
 
-				// 如果没有找到设备，显示提示
-				if len(app.Devices) == 0 {
-					dialog.ShowInformation("未找到设备", "未找到任何DLNA设备。\n请确保您的设备已开启并连接到同一网络。", app.Window)
+				// 刷新设备列表和窗口内容，移除设备后列表的选中项与重新确定的选择保持一致
+				if index := app.SelectedDeviceIndex(); index >= 0 {
+					app.DeviceList.Select(index)
+				} else {
+					app.DeviceList.UnselectAll()
 				}
-
-				// 刷新设备列表和窗口内容
 				app.DeviceList.Refresh()
 				app.Window.Canvas().Refresh(app.Window.Content())
 
@@ -209,6 +286,142 @@ audioSelectButton := widget.NewButton("选择音轨", func() {
 		app.SelectAudio(audioLabel)
 	})
 
+	subtitleLabel := widget.NewLabel("字幕: 无")
+	subtitleLabel.Wrapping = fyne.TextWrapWord
+	subtitleSelectButton := widget.NewButton("选择字幕", func() {
+		app.SelectSubtitle(subtitleLabel)
+	})
+
+	// 轨道数量标记：选择文件后在后台探测，显示在对应的选择按钮旁
+	audioCountLabel := widget.NewLabel("")
+	audioCountLabel.Hide()
+	subtitleCountLabel := widget.NewLabel("")
+	subtitleCountLabel.Hide()
+
+	// showTrackCounts 在后台获取文件的轨道数量，没有对应轨道时禁用选择按钮
+	showTrackCounts := func(filePath string) {
+		audioCountLabel.Hide()
+		subtitleCountLabel.Hide()
+		audioSelectButton.Enable()
+		subtitleSelectButton.Enable()
+		if app.Transcoder == nil || !transcoder.CheckFFmpeg() {
+			return
+		}
+		go func() {
+			audioCount, subtitleCount, err := app.TrackCounts(filePath)
+			if err != nil {
+				log.Printf("获取轨道数量失败: %v\n", err)
+				return
+			}
+			// 探测期间用户可能已选择了其他文件
+			if app.MediaFile() != filePath {
+				return
+			}
+			audioCountLabel.SetText(fmt.Sprintf("音轨: %d", audioCount))
+			audioCountLabel.Show()
+			subtitleCountLabel.SetText(fmt.Sprintf("字幕: %d", subtitleCount))
+			subtitleCountLabel.Show()
+			if audioCount == 0 {
+				audioSelectButton.Disable()
+			}
+			if subtitleCount == 0 {
+				subtitleSelectButton.Disable()
+			}
+		}()
+	}
+
+	// 兼容性摘要：说明文件的容器和编码是否需要转码
+	compatLabel := widget.NewLabel("")
+	compatLabel.Wrapping = fyne.TextWrapWord
+	compatLabel.Hide()
+
+	// showCompatibility 在后台分析文件的兼容性，完成后更新摘要
+	showCompatibility := func(filePath string) {
+		compatLabel.Hide()
+		if app.Transcoder == nil || !transcoder.CheckFFmpeg() {
+			return
+		}
+		go func() {
+			report, err := app.Transcoder.CompatibilityReport(filePath)
+			if err != nil {
+				log.Printf("分析文件兼容性失败: %v\n", err)
+				return
+			}
+			// 分析期间用户可能已选择了其他文件
+			if app.MediaFile() != filePath {
+				return
+			}
+			compatLabel.SetText(report.Summary())
+			compatLabel.Show()
+		}()
+	}
+
+	// 视频缩略图：截取视频约10%处的画面，显示在文件名旁边
+	thumbnailImage := canvas.NewImageFromResource(nil)
+	thumbnailImage.FillMode = canvas.ImageFillContain
+	thumbnailImage.SetMinSize(fyne.NewSize(thumbnailWidth, thumbnailHeight))
+	thumbnailImage.Hide()
+
+	// showThumbnail 在后台生成文件的缩略图，完成后显示；纯音频文件不显示
+	showThumbnail := func(filePath string) {
+		thumbnailImage.Hide()
+		if app.Transcoder == nil || !transcoder.CheckFFmpeg() {
+			return
+		}
+		go func() {
+			thumbnail, err := app.Transcoder.GenerateThumbnail(filePath, transcoder.ThumbnailAuto)
+			if err != nil {
+				if !errors.Is(err, transcoder.ErrNoVideo) {
+					log.Printf("生成缩略图失败: %v\n", err)
+				}
+				return
+			}
+			// 生成期间用户可能已选择了其他文件
+			if app.MediaFile() != filePath {
+				return
+			}
+			thumbnailImage.File = thumbnail
+			thumbnailImage.Refresh()
+			thumbnailImage.Show()
+		}()
+	}
+
+	// 强制投屏：跳过格式检查和转码，直接把原文件交给设备，只对当前文件生效
+	castAsIsCheck := widget.NewCheck("强制投屏（不检查格式，直接发送原文件）", func(checked bool) {
+		app.SetCastAsIs(checked)
+		if checked {
+			dialog.ShowInformation("强制投屏", castAsIsWarning, app.Window)
+		}
+	})
+
+	// 起始时间：从指定位置开始转码播放，不依赖设备的跳转功能
+	startOffsetEntry := widget.NewEntry()
+	startOffsetEntry.SetPlaceHolder("从头播放，或输入如 1:23:45、90（秒）")
+
+	// setMediaFile 设置要投屏的文件并检查其格式，文件选择对话框和媒体库列表共用
+	setMediaFile := func(filePath string) {
+		app.SetMediaFile(filePath)
+		mediaFileLabel.SetText(filepath.Base(filePath))
+		audioLabel.SetText("音轨: 默认")
+		subtitleLabel.SetText("字幕: 无")
+		// 强制投屏和起始时间只对选择时的文件生效
+		castAsIsCheck.SetChecked(false)
+		startOffsetEntry.SetText("")
+		showCompatibility(filePath)
+		showTrackCounts(filePath)
+		showThumbnail(filePath)
+
+		supported, needTranscode := transcoder.IsSupportedFormat(filePath)
+		if !supported {
+			dialog.ShowInformation("不支持的格式", "当前文件格式不受支持，请选择其他文件。\n如果确定设备可以播放，可以勾选“强制投屏”。", app.Window)
+			return
+		}
+
+		if needTranscode && !transcoder.CheckFFmpeg() {
+			dialog.ShowInformation("转码功能不可用", "文件需要转码，但未找到FFmpeg。\n请安装FFmpeg以支持非MP4格式的视频。", app.Window)
+		}
+	}
+
 	selectFileButton := widget.NewButton("选择文件", func() {
 		// 使用文件选择对话框并设置合适的大小
 		fileCallback := func(file fyne.URIReadCloser, err error) {
@@ -219,20 +432,7 @@ audioSelectButton := widget.NewButton("选择音轨", func() {
 
 			if file != nil {
 				defer file.Close()
-				app.MediaFile = file.URI().Path()
-				mediaFileLabel.SetText(filepath.Base(app.MediaFile))
-				app.SelectedAudioIndex = -1
-				audioLabel.SetText("音轨: 默认")
-
-				supported, needTranscode := transcoder.IsSupportedFormat(app.MediaFile)
-				if !supported {
-					dialog.ShowInformation("不支持的格式", "当前文件格式不受支持，请选择其他文件。", app.Window)
-					return
-				}
-
-				if needTranscode && !transcoder.CheckFFmpeg() {
-					dialog.ShowInformation("转码功能不可用", "文件需要转码，但未找到FFmpeg。\n请安装FFmpeg以支持非MP4格式的视频。", app.Window)
-				}
+				setMediaFile(file.URI().Path())
 			}
 		}
 
@@ -242,47 +442,184 @@ audioSelectButton := widget.NewButton("选择音轨", func() {
 		obtainer.Show()
 	})
 
-	// 投屏按钮 - 作为主要操作按钮，使用更突出的布局
-	castButton := widget.NewButton("开始投屏", func() {
+	// 查看完整的ffprobe媒体信息，便于排查无法播放的文件
+	mediaInfoButton := widget.NewButton("媒体信息", func() {
+		app.ShowMediaProbe()
+	})
+
+	// 媒体库：打开一个文件夹后列出其中的媒体文件，文件夹内容变化时自动更新
+	libraryFiles := []string{}
+	libraryList := widget.NewList(
+		func() int {
+			return len(libraryFiles)
+		},
+		func() fyne.CanvasObject {
+			label := widget.NewLabel("")
+			label.Truncation = fyne.TextTruncateEllipsis
+			return label
+		},
+		func(id widget.ListItemID, obj fyne.CanvasObject) {
+			if id < len(libraryFiles) {
+				obj.(*widget.Label).SetText(filepath.Base(libraryFiles[id]))
+			}
+		},
+	)
+	libraryList.OnSelected = func(id widget.ListItemID) {
+		if id < len(libraryFiles) {
+			setMediaFile(libraryFiles[id])
+		}
+	}
+	libraryContainer := container.NewGridWrap(fyne.NewSize(libraryListWidth, libraryListHeight), libraryList)
+	libraryContainer.Hide()
+
+	app.SetOnLibraryChanged(func() {
+		libraryFiles = app.LibraryFiles()
+		libraryList.UnselectAll()
+		libraryList.Refresh()
+		libraryContainer.Show()
+	})
+
+	// 投屏队列：从播放列表导入的文件，选择其中一项作为要投屏的文件
+	queueFiles := []string{}
+	queueList := widget.NewList(
+		func() int {
+			return len(queueFiles)
+		},
+		func() fyne.CanvasObject {
+			label := widget.NewLabel("")
+			label.Truncation = fyne.TextTruncateEllipsis
+			return label
+		},
+		func(id widget.ListItemID, obj fyne.CanvasObject) {
+			if id < len(queueFiles) {
+				obj.(*widget.Label).SetText(fmt.Sprintf("%d. %s", id+1, filepath.Base(queueFiles[id])))
+			}
+		},
+	)
+	queueList.OnSelected = func(id widget.ListItemID) {
+		if id < len(queueFiles) {
+			setMediaFile(queueFiles[id])
+		}
+	}
+	queueContainer := container.NewGridWrap(fyne.NewSize(libraryListWidth, libraryListHeight), queueList)
+	queueContainer.Hide()
+
+	app.SetOnQueueChanged(func() {
+		queueFiles = app.Queue()
+		queueList.UnselectAll()
+		queueList.Refresh()
+		if len(queueFiles) > 0 {
+			queueContainer.Show()
+		} else {
+			queueContainer.Hide()
+		}
+	})
+
+	importPlaylistButton := widget.NewButton("导入播放列表", func() {
+		app.ShowImportPlaylist()
+	})
+	exportPlaylistButton := widget.NewButton("导出播放列表", func() {
+		app.ShowExportPlaylist()
+	})
+	queueFolderButton := widget.NewButton("选择文件夹", func() {
+		app.ShowQueueFolder()
+	})
+	clearQueueButton := widget.NewButton("清空队列", func() {
+		app.ClearQueue()
+	})
+
+	openFolderButton := widget.NewButton("打开文件夹", func() {
+		folderDialog := dialog.NewFolderOpen(func(dir fyne.ListableURI, err error) {
+			if err != nil {
+				dialog.ShowError(err, app.Window)
+				return
+			}
+			if dir == nil {
+				return
+			}
+			if err := app.OpenLibrary(dir.Path()); err != nil {
+				dialog.ShowError(err, app.Window)
+			}
+		}, app.Window)
+		folderDialog.Resize(fyne.NewSize(800, 600))
+		folderDialog.Show()
+	})
+
+	// startCast 检查设备和文件后在后台投屏，投屏按钮和重新投屏共用
+	startCast := func() {
 		// 检查是否选择了设备
-		if app.SelectedDeviceIndex < 0 || app.SelectedDeviceIndex >= len(app.Devices) {
+		selectedDevice, ok := app.SelectedDevice()
+		if !ok {
 			dialog.ShowInformation("提示", "请先选择要投屏的设备", app.Window)
 			return
 		}
+		if dlna.IsSourceOnly(selectedDevice) {
+			dialog.ShowInformation("无法投屏", dlna.ErrNotRenderer.Error(), app.Window)
+			return
+		}
 
 		// 检查是否选择了文件
-		if app.MediaFile == "" {
+		mediaFile := app.MediaFile()
+		if mediaFile == "" {
 			dialog.ShowInformation("提示", "请先选择要投屏的文件", app.Window)
 			return
 		}
 
-		// 检查文件格式是否支持
-		supported, needTranscode := transcoder.IsSupportedFormat(app.MediaFile)
-		if !supported {
-			dialog.ShowInformation("不支持的格式", "当前文件格式不受支持，请选择其他文件。", app.Window)
+		// 检查文件格式是否支持，强制投屏时跳过检查
+		supported, needTranscode := transcoder.IsSupportedFormat(mediaFile)
+		if !supported && !app.CastAsIs() {
+			dialog.ShowInformation("不支持的格式", "当前文件格式不受支持，请选择其他文件。\n如果确定设备可以播放，可以勾选“强制投屏”。", app.Window)
+			return
+		}
+
+		// 起始时间需要通过转码实现，强制投屏时不生效
+		startOffset, err := parseStartOffset(startOffsetEntry.Text)
+		if err != nil {
+			dialog.ShowError(err, app.Window)
 			return
 		}
+		app.SetStartOffset(startOffset)
 
-		// 如果需要转码，检查FFmpeg是否可用
-		if needTranscode || (app.SelectedAudioIndex >= 0) {
+		// 如果需要转码、指定了起始时间或仅投屏音频，检查FFmpeg是否可用
+		if app.AudioOnly || (!app.CastAsIs() && (needTranscode || app.SelectedAudioIndex() >= 0 || startOffset > 0)) {
 			if !transcoder.CheckFFmpeg() {
-				dialog.ShowInformation("转码功能不可用", "文件需要转码或选择音轨，但未找到FFmpeg。\n请安装FFmpeg以支持这些功能。", app.Window)
+				dialog.ShowInformation("转码功能不可用", "文件需要转码、选择音轨、指定起始时间或仅投屏音频，但未找到FFmpeg。\n请安装FFmpeg以支持这些功能。", app.Window)
 				return
 			}
 		}
 
 		// 显示加载对话框
 		progressMessage := "正在准备媒体文件并连接设备..."
-		progressDialog := createCustomProgressDialog("投屏中...", progressMessage, app.Window)
+		progressDialog := app.NewProgressDialog("投屏中...", progressMessage)
 		progressDialog.Show()
 
+		// 创建可取消的上下文，与设备交互的超时由StartCastingWithContext控制，
+		// 不设置总超时，避免慢速转码导致投屏被取消；点击对话框的取消按钮时终止预转码和投屏
+		ctx, cancel := context.WithCancel(context.Background())
+		progressDialog.SetOnClosed(cancel)
+
 		// 在后台执行投屏
 		go func() {
-			// 创建带超时的上下文
-			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 			defer cancel()
-			
-			err := app.StartCastingWithContext(ctx, progressDialog)
+
+			// 如果启用了预转码，先完成转码并显示进度
+			if err := app.PreTranscodeWithProgress(ctx, progressDialog); err != nil {
+				if ctx.Err() != nil {
+					log.Printf("已取消投屏\n")
+					return
+				}
+				log.Printf("投屏操作失败: %v\n", err)
+				dialog.ShowError(err, app.Window)
+				progressDialog.Hide()
+				return
+			}
+
+			// 暂时性错误按设置自动重试
+			err := app.CastWithRetry(ctx, progressDialog)
+			if ctx.Err() != nil {
+				log.Printf("已取消投屏\n")
+				return
+			}
 			if err != nil {
 				log.Printf("投屏操作失败: %v\n", err)
 				dialog.ShowError(err, app.Window)
@@ -293,7 +630,79 @@ audioSelectButton := widget.NewButton("选择音轨", func() {
 			// 关闭加载对话框
 			progressDialog.Hide()
 		}()
+	}
+
+	// 投屏按钮 - 作为主要操作按钮，使用更突出的布局
+	castButton := widget.NewButton("开始投屏", startCast)
+
+	// 停止投屏按钮：让设备停止播放，结束当前投屏
+	stopCastButton := widget.NewButton("停止投屏", func() {
+		go func() {
+			if err := app.StopCasting(context.Background()); err != nil {
+				log.Printf("停止投屏失败: %v\n", err)
+				dialog.ShowError(err, app.Window)
+			}
+		}()
+	})
+
+	// 停止媒体服务器但不退出应用，释放端口，再次投屏时自动重新启动
+	stopServerButton := widget.NewButton("停止服务", func() {
+		go func() {
+			if err := app.StopMediaServer(); err != nil {
+				log.Printf("停止媒体服务器失败: %v\n", err)
+				dialog.ShowError(err, app.Window)
+				return
+			}
+			dialog.ShowInformation("停止服务", "媒体服务器已停止，再次投屏时会自动启动。", app.Window)
+		}()
+	})
+
+	// 重新投屏按钮：恢复上次成功投屏的设备、文件和轨道选择后直接投屏
+	relaunchButton := widget.NewButton("重新投屏上次内容", func() {
+		last, ok := app.LastCast()
+		if !ok {
+			dialog.ShowInformation("重新投屏", "还没有成功投屏过的内容。", app.Window)
+			return
+		}
+
+		progressDialog := app.NewProgressDialog("重新投屏", fmt.Sprintf("正在确认设备 %s 是否在线...", last.DeviceName))
+		progressDialog.Show()
+		go func() {
+			err := app.RestoreLastCast(context.Background(), last)
+			progressDialog.Hide()
+			if err != nil {
+				log.Printf("重新投屏失败: %v\n", err)
+				dialog.ShowError(err, app.Window)
+				return
+			}
+
+			// 更新界面上的设备和文件选择，setMediaFile会清除轨道选择，之后恢复上次的选择
+			app.DeviceList.Refresh()
+			deviceCountLabel.SetText(fmt.Sprintf("找到 %d 个设备", app.DeviceCount()))
+			setMediaFile(last.MediaFile)
+			app.SetSelectedAudioIndex(last.AudioIndex)
+			app.SetSelectedSubtitleIndex(last.SubtitleIndex)
+			if last.AudioIndex >= 0 {
+				audioLabel.SetText(fmt.Sprintf("音轨: #%d（上次选择）", last.AudioIndex))
+			}
+			if last.SubtitleIndex >= 0 {
+				subtitleLabel.SetText(fmt.Sprintf("字幕: #%d（上次选择）", last.SubtitleIndex))
+			}
+			startCast()
+		}()
+	})
+
+	// 预转码选项：投屏前完成转码，可以显示转码进度和剩余时间
+	preTranscodeCheck := widget.NewCheck("投屏前预先转码（显示进度）", func(checked bool) {
+		app.PreTranscode = checked
+	})
+	preTranscodeCheck.SetChecked(app.PreTranscode)
+
+	// 仅投屏音频选项：只提取选择的音轨发送给设备，适合音乐会录像、播客等
+	audioOnlyCheck := widget.NewCheck("仅投屏音频（只发送音轨）", func(checked bool) {
+		app.AudioOnly = checked
 	})
+	audioOnlyCheck.SetChecked(app.AudioOnly)
 
 	// 使用提示 - 改进文本样式和排版
 	tipsText := "1. 点击'搜索设备'查找局域网中的DLNA设备\n"
@@ -309,10 +718,59 @@ audioSelectButton := widget.NewButton("选择音轨", func() {
 	tipsLabel.Wrapping = fyne.TextWrapWord
 	tipsLabel.TextStyle = fyne.TextStyle{Monospace: false}
 
+	// 设置按钮
+	settingsButton := widget.NewButton("设置", func() {
+		// FFmpeg路径可能改变，保存后刷新FFmpeg状态
+		showSettingsDialog(app, restoreStatusLabel)
+	})
+
+	// 请求日志按钮：查看设备向媒体服务器发送的请求
+	accessLogButton := widget.NewButton("请求日志", func() {
+		app.ShowAccessLog()
+	})
+
+	// 设备网页按钮：打开选择的设备提供的网页管理界面
+	// 手动添加设备：输入设备描述地址或IP，用于SSDP搜索不可用的网络
+	manualDeviceButton := widget.NewButton("手动添加设备", func() {
+		locationEntry := widget.NewEntry()
+		locationEntry.SetPlaceHolder("例如 192.168.1.20 或 http://192.168.1.20:49152/description.xml")
+		items := []*widget.FormItem{
+			widget.NewFormItem("设备地址或IP", locationEntry),
+		}
+		addDialog := dialog.NewForm("手动添加设备", "添加", "取消", items, func(confirmed bool) {
+			if !confirmed {
+				return
+			}
+			progressDialog := app.NewProgressDialog("手动添加设备", "正在查找设备...")
+			progressDialog.Show()
+			go func() {
+				before := app.DeviceCount()
+				device, err := app.AddDeviceManually(context.Background(), locationEntry.Text)
+				progressDialog.Hide()
+				if err != nil {
+					log.Printf("手动添加设备失败: %v\n", err)
+					dialog.ShowError(err, app.Window)
+					return
+				}
+				app.DeviceList.Refresh()
+				deviceCountLabel.SetText(fmt.Sprintf("找到 %d 个设备", app.DeviceCount()))
+				if app.DeviceCount() == before {
+					dialog.ShowInformation("手动添加设备", fmt.Sprintf("设备 %s 已在列表中", getFriendlyDeviceName(device)), app.Window)
+				}
+			}()
+		}, app.Window)
+		addDialog.Resize(fyne.NewSize(progressDialogWidth*1.5, progressDialogHeight))
+		addDialog.Show()
+	})
+
+	deviceWebButton := widget.NewButton("设备网页", func() {
+		app.OpenDeviceWebUI()
+	})
+
 	// 创建主布局 - 改进整体布局，增加更好的分组和间距（符合苹果HIG）
 	topLayout := container.NewCenter(
 		container.NewPadded(
-			searchButton,
+			container.NewHBox(searchButton, stopSearchButton, manualDeviceButton, settingsButton, accessLogButton, deviceWebButton),
 		),
 	)
 
@@ -349,12 +807,33 @@ audioSelectButton := widget.NewButton("选择音轨", func() {
 
 	// 创建文件选择卡片
 	fileSelectContent := container.NewVBox(
-		container.NewPadded(mediaFileLabel),
+		container.NewPadded(container.NewBorder(nil, nil, thumbnailImage, nil, mediaFileLabel)),
+		container.NewPadded(compatLabel),
 		container.NewPadded(audioLabel),
+		container.NewPadded(subtitleLabel),
+		container.NewPadded(container.NewBorder(nil, nil, widget.NewLabel("起始时间"), nil, startOffsetEntry)),
+		container.NewPadded(preTranscodeCheck),
+		container.NewPadded(audioOnlyCheck),
+		container.NewPadded(castAsIsCheck),
+		libraryContainer,
+		queueContainer,
+		container.NewHBox(
+			layout.NewSpacer(),
+			queueFolderButton,
+			importPlaylistButton,
+			exportPlaylistButton,
+			clearQueueButton,
+			layout.NewSpacer(),
+		),
 		container.NewHBox(
 			layout.NewSpacer(),
 			selectFileButton,
+			openFolderButton,
 			audioSelectButton,
+			audioCountLabel,
+			subtitleSelectButton,
+			subtitleCountLabel,
+			mediaInfoButton,
 			layout.NewSpacer(),
 		),
 	)
@@ -369,13 +848,17 @@ audioSelectButton := widget.NewButton("选择音轨", func() {
 		fileSelectContent,
 	)
 
+	// "正在播放"状态卡片，投屏成功后显示
+	nowPlaying := newNowPlayingCard(app)
+
 	// 底部布局 - 突出主要操作
 	bottomLayout := container.NewVBox(
 		fileCard,
+		nowPlaying.card,
 		layout.NewSpacer(), // 增加间距
 		fyne.NewContainerWithLayout(layout.NewCenterLayout(),
 			container.NewPadded(
-				castButton,
+				container.NewHBox(castButton, stopCastButton, relaunchButton, stopServerButton),
 			),
 		),
 	)
@@ -452,6 +935,20 @@ func getFriendlyDeviceName(device types.DeviceInfo) string {
 	return "未知设备"
 }
 
+// deviceModelText 返回设备的制造商和型号，例如 "Sony BRAVIA"，都未知时返回空字符串
+// 型号中已经包含制造商名称时只显示型号
+func deviceModelText(device types.DeviceInfo) string {
+	manufacturer := strings.TrimSpace(device.Manufacturer)
+	model := strings.TrimSpace(device.ModelName)
+	if manufacturer == "" || strings.Contains(strings.ToLower(model), strings.ToLower(manufacturer)) {
+		return model
+	}
+	if model == "" {
+		return manufacturer
+	}
+	return manufacturer + " " + model
+}
+
 // borderLayout 简单的边框布局
 // 用于实现卡片的边框效果
 type borderLayout struct{}